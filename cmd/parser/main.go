@@ -4,14 +4,14 @@ import (
 	"flag"
 	"fmt"
 	"os"
-	"path/filepath"
-	"strings"
 
+	"sudonters/zootler/internal/astrender"
 	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/logic/loader"
+	"sudonters/zootler/pkg/rules/ast"
 	rulesparser "sudonters/zootler/pkg/rules/parser"
 
 	"github.com/etc-sudonters/substrate/dontio"
-	"muzzammil.xyz/jsonc"
 )
 
 var parseErrorColor dontio.ForegroundColor = 141
@@ -37,169 +37,118 @@ func main() {
 	}
 
 	if rawFilter != "" {
-		filt = parseFilter(rawFilter)
+		var err error
+		if filt, err = parseFilter(rawFilter); err != nil {
+			fmt.Fprintf(os.Stderr, "-f: %s\n", err)
+			os.Exit(2)
+		}
 	}
 
 	filt.errsOnly = errsOnly
-	loadLogic(logicFileDir, filt, pretty)
-
-	if showHelpers {
-		loadHelpers(logicFileDir, filt, showHelpers, pretty)
-	}
-}
-
-func loadHelpers(logicDir string, filt filter, display bool, pretty bool) {
-	contents, err := os.ReadFile(filepath.Join(logicDir, "LogicHelpers.json"))
+	logicFiles, err := loader.Load(logicFileDir)
 	if err != nil {
-		panic(err)
+		fmt.Fprintln(os.Stderr, err)
+		os.Exit(2)
 	}
 
-	var helpers map[string]string
+	passed := parseRegions(logicFiles.Regions, filt, pretty)
 
-	if err := jsonc.Unmarshal(contents, &helpers); err != nil {
-		panic(err)
+	if showHelpers {
+		passed = parseHelpers(logicFiles.Helpers, filt, pretty) && passed
 	}
 
-	for name, helper := range helpers {
-		helper = compressWhiteSpace(helper)
-		l := rulesparser.NewRulesLexer(helper)
-		p := rulesparser.NewRulesParser(l)
-		rule, err := p.Parse()
-		if err != nil {
-			fmt.Fprintf(os.Stdout, "Name:\t%s\n", name)
-			fmt.Fprintf(os.Stdout, "FAILED TO PARSE: %s\n", helper)
-			fmt.Fprintf(os.Stdout, "ERROR: %s\n", err.Error())
-			continue
-		}
-
-		if display && !filt.errsOnly {
-			fancy := newFancy()
-			single := newSingleLine()
-			rulesparser.Visit(manyVisitors(fancy, single), rule)
-			fmt.Fprintf(os.Stdout, "Name:\t%s\n", name)
-			fmt.Fprintf(os.Stdout, "Helper:\t%s\n%s\n", single.b.String(), fancy.b.String())
-		}
-
+	if !passed {
+		os.Exit(1)
 	}
 }
 
-func loadLogic(logicDir string, filt filter, pretty bool) {
-	entries, err := os.ReadDir(logicDir)
-	if err != nil {
-		panic(err)
-	}
-
-	for _, entry := range entries {
-		if !strings.HasSuffix(entry.Name(), "json") || strings.Contains(entry.Name(), "Helpers.json") {
+func parseHelpers(helpers []loader.Rule, filt filter, pretty bool) bool {
+	passed := true
+	for _, helper := range helpers {
+		if !filt.MatchSpecific(helper.Name) {
 			continue
 		}
 
-		path := filepath.Join(logicDir, entry.Name())
-		logicLocations, err := logic.ReadLogicFile(path)
-		if err != nil {
-			panic(err)
+		rule, errs := helper.ParseRecovering()
+		if len(errs) > 0 {
+			passed = false
+			reportFailures(helper, errs)
+			continue
 		}
 
-		for _, logicLocation := range logicLocations {
-			if !filt.MatchRegion(logicLocation.Region) {
-				continue
+		if !filt.errsOnly {
+			fmt.Fprintf(os.Stdout, "Name:\t%s\n", helper.Name)
+			fmt.Fprintf(os.Stdout, "Helper:\t%s\n", singleLine(rule))
+			if pretty {
+				fmt.Fprintf(os.Stdout, "%s\n", fancy(rule))
 			}
-			ParseAllChecks(logicLocation, filt, pretty)
+			fmt.Fprint(os.Stdout, "\n")
 		}
 	}
+	return passed
 }
 
-func ParseAllChecks(loc logic.RawLogicLocation, filt filter, pretty bool) {
-	parseAll("Event", loc.Events, loc.Region, filt, pretty)
-	parseAll("Check", loc.Locations, loc.Region, filt, pretty)
-	parseAll("Exit", loc.Exits, loc.Region, filt, pretty)
+func parseRegions(regions []loader.Region, filt filter, pretty bool) bool {
+	passed := true
+	for _, region := range regions {
+		if !filt.MatchRegion(region.Name) {
+			continue
+		}
+		passed = parseAll("Event", region.Events, region, filt, pretty) && passed
+		passed = parseAll("Check", region.Locations, region, filt, pretty) && passed
+		passed = parseAll("Exit", region.Exits, region, filt, pretty) && passed
+	}
+	return passed
 }
 
-func parseAll[E ~string, R ~string, M map[E]R, N ~string](ctx string, m M, region N, filt filter, prettiness bool) {
-	if !filt.MatchKind(ctx) {
-		return
+func parseAll(kind string, rules []loader.Rule, region loader.Region, filt filter, prettiness bool) bool {
+	if !filt.MatchKind(kind) {
+		return true
 	}
 
-	for check, rule := range m {
-		if !filt.MatchSpecific(string(check)) {
+	passed := true
+	for _, rule := range rules {
+		if !filt.MatchSpecific(rule.Name) {
 			continue
 		}
-		rule = R(compressWhiteSpace(string(rule)))
-		l := rulesparser.NewRulesLexer(string(rule))
-		p := rulesparser.NewRulesParser(l)
-		totalRule, err := p.Parse()
-		if err != nil {
-			fmt.Fprint(os.Stdout, "Failed to parse rule\n")
-			fmt.Fprintf(os.Stdout, "Region:\t%s\nName:\t%s\nKind:\t%s\n", region, check, ctx)
-			fmt.Fprintf(os.Stdout, "Raw:\t%s\n", rule)
-			fmt.Fprintf(os.Stdout, "ERROR: %s\n\n", err.Error())
+
+		totalRule, errs := rule.ParseRecovering()
+		if len(errs) > 0 {
+			passed = false
+			reportFailures(rule, errs)
 			continue
 		}
 
 		if !filt.errsOnly {
-			fancy := newFancy()
-			single := newSingleLine()
-			rulesparser.Visit(manyVisitors(fancy, single), totalRule)
-			fmt.Fprintf(os.Stdout, "Region:\t%s\nName:\t%s\nKind:\t%s\n", region, check, ctx)
-			fmt.Fprintf(os.Stdout, "Raw:\t%s\n", rule)
-			fmt.Fprintf(os.Stdout, "Rule:\t%s\n", single.b.String())
+			fmt.Fprintf(os.Stdout, "Region:\t%s\nName:\t%s\nKind:\t%s\n", region.Name, rule.Name, kind)
+			fmt.Fprintf(os.Stdout, "Raw:\t%s\n", logic.CompressWhiteSpace(rule.Text))
+			fmt.Fprintf(os.Stdout, "Rule:\t%s\n", singleLine(totalRule))
 			if prettiness {
-				fmt.Fprintf(os.Stdout, "%s\n", fancy.b.String())
+				fmt.Fprintf(os.Stdout, "%s\n", fancy(totalRule))
 			}
 			fmt.Fprint(os.Stdout, "\n")
 		}
 	}
+	return passed
 }
 
-const errColor dontio.BackgroundColor = 210
-
-func manyVisitors(v ...rulesparser.Visitor) rulesparser.Visitor {
-	return manyVisit{v}
-}
-
-type manyVisit struct {
-	visitors []rulesparser.Visitor
-}
-
-func (m manyVisit) visit(n rulesparser.Expression) {
-	for _, v := range m.visitors {
-		if v == nil {
-			continue
-		}
-		rulesparser.Visit(v, n)
+// file:line:col: message followed by the offending line for every error in
+// the rule
+func reportFailures(rule loader.Rule, errs []*rulesparser.SyntaxError) {
+	for _, err := range errs {
+		fmt.Fprintf(os.Stdout, "%s (%s)\n", err.Error(), rule.Name)
+		fmt.Fprintf(os.Stdout, "%s\n\n", parseErrorColor.Paint(err.Caret()))
 	}
 }
 
-func (m manyVisit) VisitAttrAccess(n *rulesparser.AttrAccess) {
-	m.visit(n)
+func singleLine(rule ast.Expression) string {
+	sexpr := astrender.NewSexpr(astrender.DefaultColorScheme())
+	ast.Visit(sexpr, rule)
+	return sexpr.String()
 }
-func (m manyVisit) VisitBinOp(n *rulesparser.BinOp) {
-	m.visit(n)
-}
-func (m manyVisit) VisitBoolOp(n *rulesparser.BoolOp) {
-	m.visit(n)
-}
-func (m manyVisit) VisitBoolean(n *rulesparser.Boolean) {
-	m.visit(n)
-}
-func (m manyVisit) VisitCall(n *rulesparser.Call) {
-	m.visit(n)
-}
-func (m manyVisit) VisitIdentifier(n *rulesparser.Identifier) {
-	m.visit(n)
-}
-func (m manyVisit) VisitNumber(n *rulesparser.Number) {
-	m.visit(n)
-}
-func (m manyVisit) VisitString(n *rulesparser.String) {
-	m.visit(n)
-}
-func (m manyVisit) VisitSubscript(n *rulesparser.Subscript) {
-	m.visit(n)
-}
-func (m manyVisit) VisitTuple(n *rulesparser.Tuple) {
-	m.visit(n)
-}
-func (m manyVisit) VisitUnary(n *rulesparser.UnaryOp) {
-	m.visit(n)
+
+func fancy(rule ast.Expression) string {
+	pretty := astrender.NewPretty()
+	ast.Visit(pretty, rule)
+	return pretty.String()
 }
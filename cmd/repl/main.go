@@ -4,36 +4,38 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
-	"strings"
+	"reflect"
 	"sudonters/zootler/internal/entity"
 	"sudonters/zootler/internal/entity/bitpool"
-	"sudonters/zootler/internal/entity/componenttable"
 	"sudonters/zootler/pkg/logic"
 	"sudonters/zootler/pkg/logic/interpreter"
+	"sudonters/zootler/pkg/logic/loader"
 	"sudonters/zootler/pkg/rules/ast"
 	"sudonters/zootler/pkg/rules/parser"
 	"sudonters/zootler/pkg/world"
 	"sudonters/zootler/pkg/world/components"
+	"sudonters/zootler/pkg/world/settings"
 
 	"github.com/etc-sudonters/substrate/mirrors"
 	"github.com/etc-sudonters/substrate/skelly/hashset"
-	"muzzammil.xyz/jsonc"
 )
 
 func main() {
-	tbl := componenttable.New(30000)
-	pool := bitpool.FromTable(tbl, 400)
-	b := world.NewBuilder(pool, tbl)
+	// every region's rules are loaded into one world
+	b, err := world.LimitedBuilder(bitpool.Settings{MaxComponentId: 400, MaxEntityId: 30000})
+	if err != nil {
+		panic(err)
+	}
 
 	env := interpreter.NewEnv()
 	rewriter := interpreter.NewInliner(env)
 	rewriter.Settings = make(map[string]any, 0)
 	rewriter.SkippedTrials = make(map[string]bool, 0)
-	rewriter.Tricks = defaultTricks()
+	rewriter.Tricks = settings.DefaultTricks()
 	rewriter.Builder = b
 	loadHelpers("inputs/logic", env, rewriter)
 
-	for name, value := range defaultSettings() {
+	for name, value := range settings.Defaults() {
 		env.Set(name, interpreter.Box(value))
 	}
 
@@ -49,19 +51,20 @@ func main() {
 	}
 
 	var itemName components.Name
-	typedStrings := mirrors.NewTypedStrings()
 
 	for _, t := range tokens {
 		t.Get(&itemName)
 		literal := logic.EscapeName(string(itemName))
-		component := typedStrings.InstanceOf(literal)
-		t.Add(component)
+		typ, err := b.Registry.TypedString(literal)
+		if err != nil {
+			panic(err)
+		}
+		t.Add(reflect.New(typ).Elem().Interface())
 		env.Set(literal, interpreter.Token{
-			Component: typedStrings.Typed(literal),
+			Component: typ,
 			Literal:   literal,
 		})
 	}
-	tbl.RowOf(mirrors.TypeOf[components.Collected]())
 
 	rules, err := b.Pool.Query(entity.FilterBuilder{}.
 		With(mirrors.TypeOf[logic.RawRule]()).
@@ -76,21 +79,18 @@ func main() {
 
 	var rule logic.RawRule
 	var region world.FromName
+	var name components.Name
 
 	env.SetBuiltIn("at_day", 0, interpreter.AtDay)
 	env.SetBuiltIn("at_night", 0, interpreter.AtNigt)
 	env.SetBuiltIn("at_dampe_time", 0, interpreter.AtDampe)
 
-	env.SetBuiltIn("has", 2, interpreter.Zoot_HasQuantityOf{
-		Entities: b.Pool,
-		Strs:     b.TypedStrs,
-	})
+	has := interpreter.NewHasQuantityOf(b.Pool, b.World)
+	defer has.Close()
+	env.SetBuiltIn("has", 2, has)
 
 	env.SetBuiltIn("has_medallions", 1, interpreter.Zoot_HasMedallions{
-		Has: interpreter.Zoot_HasQuantityOf{
-			Entities: b.Pool,
-			Strs:     b.TypedStrs,
-		},
+		Has: has,
 	})
 
 	env.SetBuiltIn("region_has_shortcuts", 1, interpreter.Zoot_RegionHasShortcuts{
@@ -99,10 +99,7 @@ func main() {
 	})
 
 	env.SetBuiltIn("has_bottle", 0, interpreter.Zoot_HasBottle{
-		Has: interpreter.Zoot_HasQuantityOf{
-			Entities: b.Pool,
-			Strs:     b.TypedStrs,
-		},
+		Has: has,
 	})
 
 	// argument to rule
@@ -124,51 +121,49 @@ func main() {
 	for _, bearer := range rules {
 		bearer.Get(&rule)
 		bearer.Get(&region)
+		bearer.Get(&name)
 		rewriter.RegionName = string(region)
 		p, err := parser.Parse(string(rule))
 		if err != nil {
 			panic(err)
 		}
-		p = rewriter.Rewrite(p, env)
-		if r, rewrote := rewriter.Make0ArityFnCall(p, env); rewrote {
-			p = rewriter.Rewrite(r, env)
+		p, err = rewriter.RewriteRule(p, env)
+		if err != nil {
+			fmt.Fprintf(os.Stdout, "ERROR: %s\n", err.Error())
+			continue
+		}
+		val, err := I.ForRule(string(name)).Evaluate(p, env)
+		if err != nil {
+			fmt.Fprintf(os.Stdout, "ERROR: %s\n", err.Error())
+			continue
 		}
-		val := I.Evaluate(p, env)
 		results = append(results, val)
 	}
 
 }
 
 func loadHelpers(logicDir string, env interpreter.Environment, rewriter *interpreter.Inliner) {
-	contents, err := os.ReadFile(filepath.Join(logicDir, "LogicHelpers.json"))
+	path := filepath.Join(logicDir, loader.HelpersFile)
+	helpers, err := loader.ReadHelpers(path)
 	if err != nil {
 		panic(err)
 	}
 
-	var helpers map[string]string
-
-	if err := jsonc.Unmarshal(contents, &helpers); err != nil {
-		panic(err)
-	}
-
 	passed := true
 
-	for raw, helper := range helpers {
-		helper = compressWhiteSpace(helper)
-		decl, err := parser.Parse(raw)
+	for _, helper := range helpers {
+		decl, err := helper.ParseDecl()
 		if err != nil {
 			passed = false
-			fmt.Fprintf(os.Stdout, "Name:\t%s\n", raw)
-			fmt.Fprintf(os.Stdout, "FAILED TO PARSE: %s\n", helper)
-			fmt.Fprintf(os.Stdout, "ERROR: %s\n", err.Error())
+			reportParseError(helper.Name, err)
 			continue
 		}
-		rule, err := parser.Parse(helper)
-		if err != nil {
+		rule, errs := helper.ParseRecovering()
+		if len(errs) > 0 {
 			passed = false
-			fmt.Fprintf(os.Stdout, "Name:\t%s\n", raw)
-			fmt.Fprintf(os.Stdout, "FAILED TO PARSE: %s\n", helper)
-			fmt.Fprintf(os.Stdout, "ERROR: %s\n", err.Error())
+			for _, err := range errs {
+				reportParseError(helper.Name, err)
+			}
 			continue
 		}
 
@@ -180,6 +175,14 @@ func loadHelpers(logicDir string, env interpreter.Environment, rewriter *interpr
 	}
 }
 
+func reportParseError(name string, err error) {
+	fmt.Fprintf(os.Stdout, "Name:\t%s\n", name)
+	fmt.Fprintf(os.Stdout, "ERROR: %s\n", err.Error())
+	if syntax, ok := err.(*parser.SyntaxError); ok {
+		fmt.Fprintf(os.Stdout, "%s\n", syntax.Caret())
+	}
+}
+
 func toZootCallable(rawDecl string, body ast.Expression) interpreter.Fn {
 	decl, err := parser.Parse(rawDecl)
 	if err != nil {
@@ -210,136 +213,3 @@ func toZootCallable(rawDecl string, body ast.Expression) interpreter.Fn {
 		panic(fmt.Errorf("expected Ident or Call for decl, got %T", decl))
 	}
 }
-
-func compressWhiteSpace[S ~string](s S) S {
-	return S(strings.Join(strings.Fields(string(s)), " "))
-}
-
-func defaultSettings() map[string]any {
-	return map[string]any{
-		"show_seed_info":                          true,
-		"user_message":                            "",
-		"world_count":                             1,
-		"create_spoiler":                          true,
-		"randomize_settings":                      false,
-		"logic_rules":                             "glitchless",
-		"reachable_locations":                     "all",
-		"triforce_hunt":                           false,
-		"lacs_condition":                          "vanilla",
-		"bridge":                                  "medallions",
-		"bridge_medallions":                       6,
-		"trials_random":                           false,
-		"trials":                                  0,
-		"shuffle_ganon_bosskey":                   "remove",
-		"shuffle_bosskeys":                        "dungeon",
-		"shuffle_smallkeys":                       "dungeon",
-		"shuffle_hideoutkeys":                     "vanilla",
-		"shuffle_tcgkeys":                         "vanilla",
-		"key_rings_choice":                        "off",
-		"shuffle_silver_rupees":                   "vanilla",
-		"shuffle_mapcompass":                      "startwith",
-		"enhance_map_compass":                     false,
-		"open_forest":                             "closed_deku",
-		"open_kakariko":                           "open",
-		"open_door_of_time":                       true,
-		"zora_fountain":                           "open",
-		"gerudo_fortress":                         "fast",
-		"dungeon_shortcuts_choice":                "off",
-		"starting_age":                            "adult",
-		"mq_dungeons_mode":                        "vanilla",
-		"empty_dungeons_mode":                     "none",
-		"shuffle_interior_entrances":              "off",
-		"shuffle_grotto_entrances":                false,
-		"shuffle_dungeon_entrances":               "off",
-		"shuffle_bosses":                          "off",
-		"shuffle_overworld_entrances":             false,
-		"shuffle_gerudo_valley_river_exit":        false,
-		"owl_drops":                               true,
-		"warp_songs":                              false,
-		"free_bombchu_drops":                      false,
-		"one_item_per_dungeon":                    false,
-		"shuffle_song_items":                      "song",
-		"shopsanity":                              "off",
-		"tokensanity":                             "off",
-		"shuffle_scrubs":                          "off",
-		"shuffle_freestanding_items":              "off",
-		"shuffle_pots":                            "off",
-		"shuffle_crates":                          "off",
-		"shuffle_cows":                            false,
-		"shuffle_beehives":                        false,
-		"shuffle_kokiri_sword":                    true,
-		"shuffle_ocarinas":                        false,
-		"shuffle_gerudo_card":                     false,
-		"shuffle_beans":                           false,
-		"shuffle_expensive_merchants":             false,
-		"shuffle_frog_song_rupees":                false,
-		"shuffle_individual_ocarina_notes":        true,
-		"shuffle_loach_reward":                    "off",
-		"logic_no_night_tokens_without_suns_song": false,
-		"start_with_consumables":                  true,
-		"start_with_rupees":                       false,
-		"starting_hearts":                         3,
-		"no_escape_sequence":                      true,
-		"no_guard_stealth":                        true,
-		"no_epona_race":                           true,
-		"skip_some_minigame_phases":               true,
-		"complete_mask_quest":                     false,
-		"useful_cutscenes":                        false,
-		"fast_chests":                             true,
-		"free_scarecrow":                          false,
-		"fast_bunny_hood":                         true,
-		"auto_equip_masks":                        false,
-		"plant_beans":                             false,
-		"chicken_count_random":                    false,
-		"chicken_count":                           7,
-		"big_poe_count_random":                    false,
-		"big_poe_count":                           1,
-		"easier_fire_arrow_entry":                 false,
-		"ruto_already_f1_jabu":                    false,
-		"ocarina_songs":                           "off",
-		"correct_chest_appearances":               "both",
-		"minor_items_as_major_chest":              false,
-		"invisible_chests":                        false,
-		"correct_potcrate_appearances":            "textures_content",
-		"key_appearance_match_dungeon":            false,
-		"clearer_hints":                           true,
-		"hints":                                   "always",
-		"hint_dist":                               "tournament",
-		"text_shuffle":                            "none",
-		"damage_multiplier":                       "normal",
-		"deadly_bonks":                            "none",
-		"no_collectible_hearts":                   false,
-		"starting_tod":                            "default",
-		"blue_fire_arrows":                        false,
-		"fix_broken_drops":                        false,
-		"item_pool_value":                         "balanced",
-		"junk_ice_traps":                          "off",
-		"ice_trap_appearance":                     "junk_only",
-		"adult_trade_shuffle":                     false,
-		"bridge_tokens":                           100,
-		"ganon_bosskey_tokens":                    999,
-		"lacs_tokens":                             999,
-	}
-}
-
-func defaultTricks() map[string]bool {
-	return map[string]bool{
-		"visible_collisions":          true,
-		"grottos_without_agony":       true,
-		"fewer_tunic_requirements":    true,
-		"rusted_switches":             true,
-		"man_on_roof":                 true,
-		"windmill_poh":                true,
-		"crater_bean_poh_with_hovers": true,
-		"dc_jump":                     true,
-		"lens_botw":                   true,
-		"child_deadhand":              true,
-		"forest_vines":                true,
-		"lens_shadow":                 true,
-		"lens_shadow_platform":        true,
-		"lens_bongo":                  true,
-		"lens_spirit":                 true,
-		"lens_gtg":                    true,
-		"lens_castle":                 true,
-	}
-}
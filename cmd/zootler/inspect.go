@@ -0,0 +1,272 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/world"
+	"sudonters/zootler/pkg/world/archive"
+	"sudonters/zootler/pkg/world/components"
+	"sudonters/zootler/pkg/world/filter"
+
+	"github.com/etc-sudonters/substrate/dontio"
+	"github.com/etc-sudonters/substrate/reiterate"
+)
+
+type inspectOptions struct {
+	load      string
+	logicDir  string
+	filter    string
+	component string
+	json      bool
+	world     uint
+	models    []string
+	seed      seedOptions
+}
+
+func (opts *inspectOptions) init(args []string) error {
+	flags := flag.NewFlagSet("inspect", flag.ContinueOnError)
+	flags.StringVar(&opts.load, "load", "", "World archive to inspect")
+	flags.StringVar(&opts.logicDir, "logic", "", "Build the world to inspect from these logic files")
+	flags.StringVar(&opts.filter, "f", "", "Only inspect matching entities: region=...;name=...")
+	flags.StringVar(&opts.component, "component", "", "Only inspect entities with this component attached")
+	flags.BoolVar(&opts.json, "json", false, "Write components as JSON")
+	flags.UintVar(&opts.world, "world", 0, "Player world entity names are resolved in")
+	opts.seed.register(flags)
+	if err := flags.Parse(args); err != nil {
+		return err
+	}
+	opts.models = flags.Args()
+	return nil
+}
+
+func (opts inspectOptions) validate() error {
+	if opts.load == "" && opts.logicDir == "" {
+		return missingRequired("-load or -logic")
+	}
+
+	if opts.load != "" && opts.logicDir != "" {
+		return fmt.Errorf("-load and -logic cannot be used together")
+	}
+
+	if opts.world > 255 {
+		return fmt.Errorf("-world must be less than 256")
+	}
+
+	return nil
+}
+
+type inspectedEntity struct {
+	Model      entity.Model         `json:"model"`
+	Name       components.Name      `json:"name,omitempty"`
+	Components []inspectedComponent `json:"components"`
+	Relations  []inspectedRelation  `json:"relations,omitempty"`
+}
+
+type inspectedComponent struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type inspectedRelation struct {
+	Name   string       `json:"name"`
+	Target entity.Model `json:"target"`
+}
+
+// prints every component attached to the requested entities, or every
+// entity if none are requested
+func inspect(ctx context.Context, args []string) error {
+	var opts inspectOptions
+	if err := (&opts).init(args); err != nil {
+		return err
+	}
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	spec, err := filter.ParseSpec(opts.filter)
+	if err != nil {
+		return err
+	}
+	// the parser's kind is Event, Check or Exit rather than a component
+	if spec.Kind != "" {
+		return fmt.Errorf("inspect's filter doesn't take kind, use -component to match attached components")
+	}
+
+	b, err := world.DefaultBuilder()
+	if err != nil {
+		return err
+	}
+	w, err := worldToInspect(opts, b)
+	if err != nil {
+		return err
+	}
+
+	models, err := modelsToInspect(w, b.Names(components.WorldId(opts.world)), opts.models)
+	if err != nil {
+		return err
+	}
+
+	reg, err := archive.Default(b.Registry)
+	if err != nil {
+		return err
+	}
+	inspected := make([]inspectedEntity, 0, len(models))
+	for _, m := range models {
+		e, err := inspectEntity(w, reg, m)
+		if err != nil {
+			return fmt.Errorf("inspecting %s: %w", m, err)
+		}
+
+		if !matchesSpec(spec, opts.component, e) {
+			continue
+		}
+		inspected = append(inspected, e)
+	}
+
+	stdio, _ := dontio.StdFromContext(ctx)
+	if opts.json {
+		enc := json.NewEncoder(stdio.Out)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		return enc.Encode(inspected)
+	}
+
+	for _, e := range inspected {
+		fmt.Fprintf(stdio.Out, "%s %s\n", e.Model, e.Name)
+		for _, c := range e.Components {
+			if c.Value == nil {
+				fmt.Fprintf(stdio.Out, "\t%s\n", c.Name)
+				continue
+			}
+			fmt.Fprintf(stdio.Out, "\t%s = %s\n", c.Name, c.Value)
+		}
+		for _, r := range e.Relations {
+			fmt.Fprintf(stdio.Out, "\t%s -> %s\n", r.Name, r.Target)
+		}
+	}
+
+	return nil
+}
+
+// the archive if one was given, otherwise the world is built from the logic
+// files the same way zootler builds it
+func worldToInspect(opts inspectOptions, b *world.Builder) (world.World, error) {
+	if opts.load != "" {
+		w, err := loadWorld(opts.load, b)
+		if err != nil {
+			return w, fmt.Errorf("loading world: %w", err)
+		}
+		return w, nil
+	}
+
+	if err := loadLogic(opts.logicDir, opts.seed, b); err != nil {
+		return world.World{}, fmt.Errorf("loading logic: %w", err)
+	}
+	stampTokens(b)
+	return b.Build(), nil
+}
+
+// requested entities are either a name in the chosen world or a model id
+func modelsToInspect(w world.World, names map[components.Name]entity.View, requested []string) ([]entity.Model, error) {
+	if len(requested) == 0 {
+		views, err := w.Entities.Query(entity.BuildFilter().Build())
+		if err != nil && !errors.Is(err, entity.ErrNoEntities) {
+			return nil, err
+		}
+
+		models := make([]entity.Model, len(views))
+		for i := range views {
+			models[i] = views[i].Model()
+		}
+		sort.Slice(models, func(i, j int) bool { return models[i].Index() < models[j].Index() })
+		return models, nil
+	}
+
+	models := make([]entity.Model, 0, len(requested))
+	for _, req := range requested {
+		if view, ok := names[components.Name(req)]; ok {
+			models = append(models, view.Model())
+			continue
+		}
+
+		id, err := strconv.ParseUint(req, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("no entity named %q", req)
+		}
+
+		view, err := w.Entities.Fetch(entity.Model(id))
+		if err != nil {
+			return nil, err
+		}
+		models = append(models, view.Model())
+	}
+	return models, nil
+}
+
+func inspectEntity(w world.World, reg *archive.Registry, m entity.Model) (inspectedEntity, error) {
+	inspected := inspectedEntity{Model: m, Components: []inspectedComponent{}}
+
+	for _, row := range reiterate.ToSlice(w.Components.Rows()) {
+		c := row.Get(m)
+		if c == nil {
+			continue
+		}
+
+		name, value, err := reg.Encode(c)
+		if err != nil {
+			return inspected, err
+		}
+
+		if n, ok := c.(components.Name); ok {
+			inspected.Name = n
+		}
+		inspected.Components = append(inspected.Components, inspectedComponent{name, value})
+	}
+
+	sort.Slice(inspected.Components, func(i, j int) bool {
+		return inspected.Components[i].Name < inspected.Components[j].Name
+	})
+
+	for _, info := range w.Components.Registry().Components() {
+		for _, target := range w.Entities.Targets(info.Type, m) {
+			inspected.Relations = append(inspected.Relations, inspectedRelation{info.Name, target})
+		}
+	}
+
+	return inspected, nil
+}
+
+// component matches the name of any attached component without regard to
+// case, region matches the region an edge leaves from or the entity's own
+// name otherwise
+func matchesSpec(spec filter.Spec, component string, e inspectedEntity) bool {
+	if !spec.MatchName(string(e.Name)) {
+		return false
+	}
+
+	region := string(e.Name)
+	attached := component == ""
+	for _, c := range e.Components {
+		if strings.EqualFold(component, c.Name) {
+			attached = true
+		}
+
+		if c.Name == "world.FromName" {
+			var from string
+			if err := json.Unmarshal(c.Value, &from); err == nil {
+				region = from
+			}
+		}
+	}
+
+	return attached && spec.MatchRegion(region)
+}
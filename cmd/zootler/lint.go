@@ -0,0 +1,75 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"flag"
+	"fmt"
+
+	"sudonters/zootler/pkg/logic/lint"
+	"sudonters/zootler/pkg/logic/loader"
+
+	"github.com/etc-sudonters/substrate/dontio"
+	"github.com/etc-sudonters/substrate/stageleft"
+)
+
+type lintOptions struct {
+	logicDir string
+	json     bool
+}
+
+func (opts *lintOptions) init(args []string) error {
+	flags := flag.NewFlagSet("lint", flag.ContinueOnError)
+	flags.StringVar(&opts.logicDir, "l", "", "Directory where logic files are located")
+	flags.BoolVar(&opts.json, "json", false, "Write findings as JSON")
+	return flags.Parse(args)
+}
+
+func (opts lintOptions) validate() error {
+	if opts.logicDir == "" {
+		return missingRequired("-l")
+	}
+	return nil
+}
+
+// reports every problem found in the logic files one per line as
+// file:line:col: check: message, any findings exit with 1
+func lintLogic(ctx context.Context, args []string) error {
+	var opts lintOptions
+	if err := (&opts).init(args); err != nil {
+		return err
+	}
+
+	if err := opts.validate(); err != nil {
+		return err
+	}
+
+	logic, err := loader.Load(opts.logicDir)
+	if err != nil {
+		return fmt.Errorf("loading logic: %w", err)
+	}
+
+	findings := lint.Lint(logic, lint.DefaultNames())
+
+	stdio, _ := dontio.StdFromContext(ctx)
+	if opts.json {
+		if findings == nil {
+			findings = []lint.Finding{}
+		}
+		enc := json.NewEncoder(stdio.Out)
+		enc.SetIndent("", "  ")
+		enc.SetEscapeHTML(false)
+		if err := enc.Encode(findings); err != nil {
+			return err
+		}
+	} else {
+		for _, finding := range findings {
+			fmt.Fprintln(stdio.Out, finding)
+		}
+	}
+
+	if len(findings) > 0 {
+		return stageleft.AttachExitCode(errAlreadyReported, stageleft.ExitCode(1))
+	}
+	return nil
+}
@@ -2,15 +2,22 @@ package main
 
 import (
 	"context"
+	"errors"
 	"flag"
 	"fmt"
 	"os"
+	"path/filepath"
 	"runtime/debug"
 
 	"sudonters/zootler/cmd/zootler/tui"
 	"sudonters/zootler/internal/entity"
 	"sudonters/zootler/pkg/filler"
+	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/logic/compiler"
+	"sudonters/zootler/pkg/logic/interpreter"
+	"sudonters/zootler/pkg/logic/loader"
 	"sudonters/zootler/pkg/world"
+	"sudonters/zootler/pkg/world/archive"
 	"sudonters/zootler/pkg/world/components"
 	"sudonters/zootler/pkg/world/filter"
 
@@ -23,12 +30,20 @@ type cliOptions struct {
 	logicDir   string `short:"-l" description:"Path to logic files" required:"t"`
 	dataDir    string `short:"-d" description:"Path to data files" required:"t"`
 	visualizer bool   `short:"-v" description:"Open visualizer" required:"f"`
+	load       string `short:"-load" description:"Load a previously saved world" required:"f"`
+	save       string `short:"-save" description:"Save the built world" required:"f"`
+	stats      bool   `short:"-stats" description:"Report entity and component usage" required:"f"`
+	seed       seedOptions
 }
 
 func (opts *cliOptions) init() {
 	flag.StringVar(&opts.logicDir, "l", "", "Directory where logic files are located")
 	flag.StringVar(&opts.dataDir, "d", "", "Directory where data files are stored")
 	flag.BoolVar(&opts.visualizer, "v", false, "Open visualizer")
+	flag.StringVar(&opts.load, "load", "", "Load a world archive instead of building one")
+	flag.StringVar(&opts.save, "save", "", "Write the built world to an archive")
+	flag.BoolVar(&opts.stats, "stats", false, "Report entity and component usage")
+	opts.seed.register(flag.CommandLine)
 	flag.Parse()
 }
 
@@ -70,6 +85,18 @@ func main() {
 	ctx := context.Background()
 	ctx = dontio.AddStdToContext(ctx, &stdio)
 
+	if len(os.Args) > 1 {
+		if subcommand, ok := subcommands[os.Args[1]]; ok {
+			if err := subcommand(ctx, os.Args[2:]); err != nil {
+				if !errors.Is(err, errAlreadyReported) {
+					fmt.Fprintf(stdio.Err, "%s\n", err.Error())
+				}
+				exit = stageleft.ExitCodeFromErr(err, stageleft.ExitCode(2))
+			}
+			return
+		}
+	}
+
 	(&opts).init()
 
 	if cliErr := opts.validate(); cliErr != nil {
@@ -78,11 +105,53 @@ func main() {
 		return
 	}
 
-	b := world.DefaultBuilder()
+	b, err := world.DefaultBuilder()
+	if err != nil {
+		exit = stageleft.ExitCodeFromErr(err, stageleft.ExitCode(2))
+		fmt.Fprintf(stdio.Err, "Error creating world: %s\n", err.Error())
+		return
+	}
+	var w world.World
 
-	stampTokens(b)
+	if opts.load != "" {
+		var err error
+		w, err = loadWorld(opts.load, b)
+		if err != nil {
+			exit = stageleft.ExitCodeFromErr(err, stageleft.ExitCode(2))
+			fmt.Fprintf(stdio.Err, "Error loading world: %s\n", err.Error())
+			return
+		}
+	} else {
+		if err := loadLogic(opts.logicDir, opts.seed, b); err != nil {
+			exit = stageleft.ExitCodeFromErr(err, stageleft.ExitCode(2))
+			fmt.Fprintf(stdio.Err, "Error loading logic: %s\n", err.Error())
+			return
+		}
+		stampTokens(b)
+		w = b.Build()
+	}
+
+	if opts.save != "" {
+		if err := saveWorld(opts.save, w, b); err != nil {
+			exit = stageleft.ExitCodeFromErr(err, stageleft.ExitCode(2))
+			fmt.Fprintf(stdio.Err, "Error saving world: %s\n", err.Error())
+			return
+		}
+	}
+
+	env, err := compileRules(ctx, opts.logicDir, b)
+	if err != nil {
+		exit = stageleft.ExitCodeFromErr(err, stageleft.ExitCode(2))
+		fmt.Fprintf(stdio.Err, "Error compiling rules: %s\n", err.Error())
+		return
+	}
+	defer compiler.Release(env)
 
-	w := b.Build()
+	if err := showStats(ctx, w, opts.stats); err != nil {
+		exit = stageleft.ExitCodeFromErr(err, stageleft.ExitCode(2))
+		fmt.Fprintf(stdio.Err, "Error reporting stats: %s\n", err.Error())
+		return
+	}
 
 	if opts.visualizer {
 		v := tui.Tui(w)
@@ -111,6 +180,15 @@ func main() {
 	}
 }
 
+var subcommands = map[string]func(context.Context, []string) error{
+	"inspect": inspect,
+	"lint":    lintLogic,
+}
+
+// the subcommand already told the user what went wrong, only its exit code is
+// left to set
+var errAlreadyReported = errors.New("already reported")
+
 type missingRequired string // option name
 
 func (arg missingRequired) Error() string {
@@ -118,27 +196,25 @@ func (arg missingRequired) Error() string {
 }
 
 func showTokenPlacements(ctx context.Context, w world.World, fb entity.FilterBuilder) error {
-	placed, err := w.Entities.Query(entity.BuildFilter(filter.Inhabits).Combine(fb).Build())
+	tokens, err := w.Entities.Query(fb.Build())
 	if err != nil {
 		return fmt.Errorf("while querying placements: %w", err)
 	}
 	stdio, _ := dontio.StdFromContext(ctx)
 
-	for _, tok := range placed {
-		var itemName components.Name
-		var placementName components.Name
-		var placement components.Inhabits
-
-		err = tok.Get(&itemName)
-		if err != nil {
-			return err
+	for _, tok := range tokens {
+		placements := entity.Targets[components.Inhabits](w.Entities, tok.Model())
+		if len(placements) == 0 {
+			continue
 		}
-		err = tok.Get(&placement)
+
+		itemName, err := entity.GetComponent[components.Name](tok)
 		if err != nil {
 			return err
 		}
-		w.Entities.Get(entity.Model(placement), []interface{}{&placementName})
-		if placementName == "" {
+		placement := placements[0]
+		placementName, err := entity.GetFrom[components.Name](w.Entities, placement)
+		if err != nil || placementName == "" {
 			return fmt.Errorf("%v did not have an attached name", placement)
 		}
 
@@ -148,9 +224,83 @@ func showTokenPlacements(ctx context.Context, w world.World, fb entity.FilterBui
 	return nil
 }
 
+func loadWorld(path string, b *world.Builder) (world.World, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return world.World{}, err
+	}
+	defer f.Close()
+
+	reg, err := archive.Default(b.Registry)
+	if err != nil {
+		return world.World{}, err
+	}
+	return archive.Load(f, reg, b)
+}
+
+func saveWorld(path string, w world.World, b *world.Builder) error {
+	reg, err := archive.Default(b.Registry)
+	if err != nil {
+		return err
+	}
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+	if err := archive.Save(f, reg, w); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// dungeons use the quest the seed's settings choose for them
+func loadLogic(dir string, opts seedOptions, b *world.Builder) error {
+	seed, rng, err := opts.settings()
+	if err != nil {
+		return err
+	}
+
+	l, err := loader.Load(dir)
+	if err != nil {
+		return err
+	}
+
+	mq, err := world.ChooseMasterQuest(seed.MasterQuest, l.MasterQuestDungeons(), rng)
+	if err != nil {
+		return err
+	}
+
+	return b.LoadLogic(l, mq)
+}
+
+// rules are compiled after the world is saved, archives carry the rules as
+// written. Rules that can't be compiled are never fulfilled, the rest count
+// with the returned environment's prepared queries
+func compileRules(ctx context.Context, dir string, b *world.Builder) (interpreter.Environment, error) {
+	helpers, err := loader.ReadHelpers(filepath.Join(dir, loader.HelpersFile))
+	if err != nil {
+		return interpreter.Environment{}, err
+	}
+
+	env, rw, err := compiler.NewEnvironment(b, helpers)
+	if err != nil {
+		return interpreter.Environment{}, err
+	}
+
+	if err := compiler.CompileWorld(b, env, rw); err != nil {
+		stdio, _ := dontio.StdFromContext(ctx)
+		fmt.Fprintf(stdio.Err, "Warning: some rules could not be compiled:\n%s\n", err)
+	}
+	return env, nil
+}
+
 func stampTokens(b *world.Builder) {
 	tokens, err := b.Pool.Query(entity.FilterBuilder{}.With(mirrors.TypeOf[components.Token]()).Build())
 	if err != nil {
+		if errors.Is(err, entity.ErrNoEntities) {
+			return
+		}
 		panic(err)
 	}
 
@@ -158,7 +308,11 @@ func stampTokens(b *world.Builder) {
 
 	for _, token := range tokens {
 		token.Get(&name)
-		stamp := b.TypedStrs.Typed(string(name))
+		// rules refer to tokens by their escaped names
+		stamp, err := b.Registry.TypedInstance(logic.EscapeName(string(name)))
+		if err != nil {
+			panic(err)
+		}
 		token.Add(stamp)
 	}
 }
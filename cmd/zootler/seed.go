@@ -0,0 +1,52 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"strings"
+	"time"
+
+	"sudonters/zootler/pkg/world/settings"
+)
+
+// the settings a world is built with, shared by every command that builds
+// one so they all build the same world
+type seedOptions struct {
+	seed       int64
+	mqMode     string
+	mqSpecific string
+	mqCount    uint
+}
+
+func (opts *seedOptions) register(flags *flag.FlagSet) {
+	flags.Int64Var(&opts.seed, "seed", 0, "Seed for random choices, 0 picks one from the clock")
+	flags.StringVar(&opts.mqMode, "mq", "vanilla", "Master quest dungeons: vanilla, mq, specific, count or random")
+	flags.StringVar(&opts.mqSpecific, "mq-specific", "", "Comma separated dungeons that use master quest with -mq specific")
+	flags.UintVar(&opts.mqCount, "mq-count", 0, "How many dungeons use master quest with -mq count")
+}
+
+func (opts seedOptions) settings() (settings.SeedSettings, *rand.Rand, error) {
+	var seed settings.SeedSettings
+
+	mode, err := settings.MasterQuestModeNamed(opts.mqMode)
+	if err != nil {
+		return seed, nil, fmt.Errorf("-mq: %w", err)
+	}
+	if opts.mqCount > 255 {
+		return seed, nil, fmt.Errorf("-mq-count must be less than 256")
+	}
+
+	seed.MasterQuest = settings.MasterQuestDungeons{Mode: mode, Count: uint8(opts.mqCount)}
+	if opts.mqSpecific != "" {
+		for _, dungeon := range strings.Split(opts.mqSpecific, ",") {
+			seed.MasterQuest.Specific = append(seed.MasterQuest.Specific, strings.TrimSpace(dungeon))
+		}
+	}
+
+	source := opts.seed
+	if source == 0 {
+		source = time.Now().UnixNano()
+	}
+	return seed, rand.New(rand.NewSource(source)), nil
+}
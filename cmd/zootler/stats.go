@@ -0,0 +1,58 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"text/tabwriter"
+
+	"sudonters/zootler/internal/entity/bitpool"
+	"sudonters/zootler/pkg/world"
+
+	"github.com/etc-sudonters/substrate/dontio"
+)
+
+type statsReporter interface {
+	Stats() bitpool.Stats
+}
+
+// reports how much of the pool and table's configured capacity the world
+// uses, warnings are written even if the report isn't requested
+func showStats(ctx context.Context, w world.World, report bool) error {
+	stdio, _ := dontio.StdFromContext(ctx)
+	reporter, ok := w.Entities.Pool.(statsReporter)
+	if !ok {
+		if report {
+			return fmt.Errorf("%T does not report stats", w.Entities.Pool)
+		}
+		return nil
+	}
+
+	stats := reporter.Stats()
+	for _, warning := range stats.Warnings() {
+		fmt.Fprintf(stdio.Err, "warning: %s\n", warning)
+	}
+
+	if !report {
+		return nil
+	}
+
+	fmt.Fprintf(stdio.Out, "entities:\t%d live, %d of %d slots, %d free\n",
+		stats.Live, stats.Slots, stats.MaxEntityId, stats.FreeSlots)
+	fmt.Fprintf(stdio.Out, "components:\t%d of %d ids, %d buckets per entity\n",
+		stats.Components, stats.MaxComponent, stats.ComponentBuckets)
+	fmt.Fprintf(stdio.Out, "table:\t\t%d stored in %d slots (%.1f%% dense), %d buckets per row\n",
+		stats.Table.Population, stats.Table.Capacity, 100*stats.Table.Density(), stats.Table.EntityBuckets)
+	fmt.Fprintf(stdio.Out, "bytes:\t\t%d\n\n", stats.Bytes)
+
+	tw := tabwriter.NewWriter(stdio.Out, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(tw, "ID\tCOMPONENT\tSTORAGE\tPOPULATION\tCAPACITY\tDENSITY\tBYTES")
+	for _, row := range stats.Table.Rows {
+		name := row.Name
+		if name == "" {
+			name = row.Type.String()
+		}
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%d\t%.1f%%\t%d\n",
+			row.Id, name, row.Storage, row.Population, row.Capacity, 100*row.Density(), row.Bytes)
+	}
+	return tw.Flush()
+}
@@ -27,6 +27,14 @@ func (p PrettyPrint) String() string {
 	return p.b.String()
 }
 
+func (w *PrettyPrint) VisitAttribute(a *ast.Attribute) error {
+	w.writeObject(a)
+	w.writeProperty("Target", a.Target)
+	w.writeIdentifierProperty("Attr", a.Attr)
+	w.writeObjectEnd()
+	return nil
+}
+
 func (w *PrettyPrint) VisitBinOp(b *ast.BinOp) error {
 	w.writeObject(b)
 	w.writeKeywordProperty("Op", string(b.Op))
@@ -80,6 +88,12 @@ func (w *PrettyPrint) VisitIdentifier(i *ast.Identifier) error {
 	return nil
 }
 
+func (w *PrettyPrint) VisitInvalid(i *ast.Invalid) error {
+	w.writeObject(i)
+	w.writeObjectEnd()
+	return nil
+}
+
 func (w *PrettyPrint) VisitSubscript(s *ast.Subscript) error {
 	w.writeObject(s)
 	w.writeProperty("Target", s.Target)
@@ -159,7 +173,7 @@ func (a *PrettyPrint) writeStrProperty(name, value string) {
 }
 
 func (a *PrettyPrint) writeNumber(f float64) {
-	fmt.Fprintf(a.b, a.scheme.Number.Paint("%.0f"), f)
+	fmt.Fprintf(a.b, a.scheme.Number.Paint("%g"), f)
 }
 
 func (a *PrettyPrint) writeNumProperty(name string, value float64) {
@@ -42,6 +42,16 @@ func (s *sexprFormatter) SetIdentifier(cb IdentifierCallback) {
 	s.identifier = cb
 }
 
+func (s *sexprFormatter) VisitAttribute(a *ast.Attribute) error {
+	s.writeOpenParen()
+	s.b.WriteString(". ")
+	ast.Visit(s, a.Target)
+	s.b.WriteRune(' ')
+	s.b.WriteString(s.scheme.Identifier.Paint(a.Attr))
+	s.writeCloseParen()
+	return nil
+}
+
 func (s *sexprFormatter) VisitBinOp(b *ast.BinOp) error {
 	s.writeOpenParen()
 	s.b.WriteString(s.scheme.Keyword.Paint(string(b.Op)))
@@ -68,7 +78,7 @@ func (s *sexprFormatter) VisitLiteral(l *ast.Literal) error {
 	case ast.LiteralBool:
 		fmt.Fprintf(s.b, s.scheme.Boolean.Paint("%t"), l.Value)
 	case ast.LiteralNum:
-		fmt.Fprintf(s.b, s.scheme.Number.Paint("%.0f"), l.Value)
+		fmt.Fprintf(s.b, s.scheme.Number.Paint("%g"), l.Value)
 	case ast.LiteralStr:
 		s.b.WriteString(s.scheme.String.Paint(l.Value.(string)))
 	default:
@@ -103,6 +113,10 @@ func (s *sexprFormatter) VisitIdentifier(i *ast.Identifier) error {
 	s.b.WriteString(s.scheme.Identifier.Paint(i.Value))
 	return nil
 }
+func (s *sexprFormatter) VisitInvalid(i *ast.Invalid) error {
+	s.b.WriteString(s.scheme.Keyword.Paint("<invalid>"))
+	return nil
+}
 func (s *sexprFormatter) VisitSubscript(r *ast.Subscript) error {
 	s.writeOpenParen()
 	s.b.WriteString("[] ")
@@ -0,0 +1,70 @@
+package archpool
+
+import (
+	"reflect"
+
+	"sudonters/zootler/internal/entity"
+)
+
+// applies mutations without acquiring the pool's lock, only handed out
+// while Batch holds it
+type batch struct {
+	p *archpool
+}
+
+func (p *archpool) Batch(fn func(entity.Batch) error) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return fn(batch{p})
+}
+
+func (b batch) Create() (entity.Model, error) {
+	return b.p.create(), nil
+}
+
+func (b batch) Destroy(m entity.Model) error {
+	return b.p.destroy(m)
+}
+
+func (b batch) Add(m entity.Model, c entity.Component) error {
+	return b.p.add(m, c)
+}
+
+func (b batch) Remove(m entity.Model, c entity.Component) error {
+	return b.p.remove(m, c)
+}
+
+func (b batch) Relate(source, target entity.Model, r entity.Component) error {
+	return b.p.relate(source, target, r)
+}
+
+func (b batch) Component(m entity.Model, typ reflect.Type) (entity.Component, error) {
+	return b.p.component(m, typ)
+}
+
+// component slices are unpacked so views and batches accept the same values
+func (p *archpool) add(m entity.Model, c entity.Component) error {
+	if many, ok := c.([]entity.Component); ok {
+		for _, c := range many {
+			if err := p.addCompToEnt(m, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return p.addCompToEnt(m, c)
+}
+
+func (p *archpool) remove(m entity.Model, c entity.Component) error {
+	if many, ok := c.([]entity.Component); ok {
+		for _, c := range many {
+			if err := p.removeCompFromEnt(m, c); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	return p.removeCompFromEnt(m, c)
+}
@@ -0,0 +1,365 @@
+package archpool
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sync"
+
+	"sudonters/zootler/internal/entity"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+	"github.com/etc-sudonters/substrate/skelly/bitset"
+)
+
+var ErrTooManyComponents = errors.New("component id exceeds configured maximum")
+
+// groups entities by their exact set of components, each distinct set gets
+// its own table of dense columns so queries only visit tables whose signature
+// matches rather than every entity in the population
+//
+// exported methods and views acquire mu, unexported methods assume the
+// caller already holds it
+type archpool struct {
+	mu                   sync.RWMutex
+	componentBucketCount int
+	maxComponentId       int
+	types                mirrors.TypeMap
+	typesById            []reflect.Type
+	tables               []*table
+	bySignature          map[string]*table
+	entities             []record
+	free                 []int
+	entity.Observers
+	entity.Relations
+}
+
+var _ entity.Pool = (*archpool)(nil)
+var _ entity.View = archview{}
+var _ entity.DirectView = archview{}
+
+type Settings struct {
+	MaxComponentId int
+}
+
+// where an entity's components currently live, destroyed entities have no
+// table and hold the model of the slot's next occupant
+type record struct {
+	id  entity.Model
+	t   *table
+	row int
+}
+
+func New(s Settings) *archpool {
+	var p archpool
+	p.maxComponentId = s.MaxComponentId
+	p.componentBucketCount = bitset.Buckets(s.MaxComponentId)
+	p.types = make(mirrors.TypeMap, 32)
+	p.types[nil] = mirrors.TypeId(entity.INVALID_COMPONENT)
+	p.typesById = []reflect.Type{nil}
+	p.bySignature = make(map[string]*table, 32)
+	p.entities = make([]record, 1, 128)
+	p.tableFor(bitset.New(p.componentBucketCount))
+	return &p
+}
+
+// destroyed slots are reused before the population grows
+func (p *archpool) Create() (entity.View, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return archview{id: p.create(), p: p}, nil
+}
+
+func (p *archpool) create() entity.Model {
+	var id entity.Model
+	if n := len(p.free); n > 0 {
+		id = p.entities[p.free[n-1]].id
+		p.free = p.free[:n-1]
+	} else {
+		id = entity.NewModel(len(p.entities), 0)
+		p.entities = append(p.entities, record{})
+	}
+
+	empty := p.tables[0]
+	row := empty.insert(id)
+	p.entities[id.Index()] = record{id, empty, row}
+	p.Notify(entity.Change{Kind: entity.EntityCreated, Entity: id})
+	return id
+}
+
+func (p *archpool) Destroy(m entity.Model) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.destroy(m)
+}
+
+func (p *archpool) destroy(m entity.Model) error {
+	if !p.alive(m) {
+		return entity.ErrEntityNotExist
+	}
+
+	rec := p.entities[m.Index()]
+	var removed []entity.Change
+	if p.Observed() {
+		for _, col := range rec.t.columns {
+			removed = append(removed, entity.Change{
+				Kind: entity.ComponentRemoved, Entity: m, Type: col.typ, Component: rec.t.get(col.id, rec.row),
+			})
+		}
+	}
+
+	if moved, ok := rec.t.evict(rec.row); ok {
+		p.entities[moved.Index()].row = rec.row
+	}
+	p.Forget(m)
+
+	p.entities[m.Index()] = record{id: m.Next()}
+	if m.Next().Generation() != 0 {
+		p.free = append(p.free, m.Index())
+	}
+
+	for _, change := range removed {
+		p.Notify(change)
+	}
+	p.Notify(entity.Change{Kind: entity.EntityDestroyed, Entity: m})
+	return nil
+}
+
+// return a subset of the population that matches the provided filter
+func (p *archpool) Query(f entity.Filter) ([]entity.View, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	q, err := p.compile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	var entities []entity.View
+	for _, t := range p.tables {
+		if !q.admits(t) {
+			continue
+		}
+
+		for row := range t.members {
+			if q.passes(t, row) {
+				entities = append(entities, q.view(p, t, row))
+			}
+		}
+	}
+
+	if len(entities) == 0 {
+		return nil, entity.ErrNoEntities
+	}
+
+	return entities, nil
+}
+
+func (p *archpool) Get(m entity.Model, cs []interface{}) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	getter := componentGetter{p}
+	for i := range cs {
+		_ = entity.AssignComponentTo(m, cs[i], getter)
+	}
+}
+
+func (p *archpool) Fetch(m entity.Model) (entity.View, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.alive(m) {
+		return nil, entity.ErrEntityNotExist
+	}
+	return archview{id: m, p: p}, nil
+}
+
+func (p *archpool) Relate(source, target entity.Model, r entity.Component) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.relate(source, target, r)
+}
+
+func (p *archpool) relate(source, target entity.Model, r entity.Component) error {
+	if !p.alive(source) || !p.alive(target) {
+		return entity.ErrEntityNotExist
+	}
+	return p.Link(source, target, r)
+}
+
+func (p *archpool) Unrelate(kind reflect.Type, source, target entity.Model) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.alive(source) || !p.alive(target) {
+		return entity.ErrEntityNotExist
+	}
+	p.Unlink(kind, source, target)
+	return nil
+}
+
+func (p *archpool) Relation(kind reflect.Type, source, target entity.Model) (entity.Component, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Relations.Relation(kind, source, target)
+}
+
+func (p *archpool) Targets(kind reflect.Type, source entity.Model) []entity.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Relations.Targets(kind, source)
+}
+
+func (p *archpool) Sources(kind reflect.Type, target entity.Model) []entity.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Relations.Sources(kind, target)
+}
+
+func (p *archpool) Pairs(kind reflect.Type) []entity.Pair {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Relations.Pairs(kind)
+}
+
+func (p *archpool) alive(m entity.Model) bool {
+	idx := m.Index()
+	if m == entity.INVALID_ENTITY || idx >= len(p.entities) {
+		return false
+	}
+
+	rec := p.entities[idx]
+	return rec.t != nil && rec.id == m
+}
+
+func (p *archpool) idOf(typ reflect.Type) (entity.ComponentId, error) {
+	id, err := p.types.IdOf(typ)
+	if err != nil {
+		name := typ.Name()
+		if name == "" {
+			if n, ok := mirrors.TryGetLiteral(typ); ok {
+				name = n
+			}
+		}
+		return 0, fmt.Errorf("during component %s: %w", name, entity.ErrUnknownComponent{T: typ})
+	}
+	return entity.ComponentId(id), nil
+}
+
+func (p *archpool) register(typ reflect.Type) (entity.ComponentId, error) {
+	id := entity.ComponentId(p.types.Add(typ))
+	if int(id) > p.maxComponentId {
+		delete(p.types, typ)
+		return entity.INVALID_COMPONENT, fmt.Errorf("%w: %s", ErrTooManyComponents, typ.Name())
+	}
+	if int(id) == len(p.typesById) {
+		p.typesById = append(p.typesById, typ)
+	}
+	return id, nil
+}
+
+func (p *archpool) component(m entity.Model, typ reflect.Type) (entity.Component, error) {
+	if !p.alive(m) {
+		return nil, entity.ErrEntityNotExist
+	}
+
+	id, err := p.types.IdOf(typ)
+	if err != nil {
+		return nil, entity.ErrNotAssigned
+	}
+
+	rec := p.entities[m.Index()]
+	c := rec.t.get(entity.ComponentId(id), rec.row)
+	if c == nil {
+		return nil, entity.ErrNotAssigned
+	}
+	return c, nil
+}
+
+func (p *archpool) addCompToEnt(m entity.Model, c entity.Component) error {
+	if !p.alive(m) {
+		return entity.ErrEntityNotExist
+	}
+
+	typ := entity.PierceComponentType(c)
+	id, err := p.register(typ)
+	if err != nil {
+		return err
+	}
+
+	rec := p.entities[m.Index()]
+	if !rec.t.signature.Test(int(id)) {
+		sig := bitset.Copy(rec.t.signature)
+		sig.Set(int(id))
+		p.move(m, p.tableFor(sig))
+		rec = p.entities[m.Index()]
+	}
+
+	rec.t.set(id, rec.row, c)
+	p.Notify(entity.Change{Kind: entity.ComponentAdded, Entity: m, Type: typ, Component: c})
+	return nil
+}
+
+func (p *archpool) removeCompFromEnt(m entity.Model, c entity.Component) error {
+	if !p.alive(m) {
+		return entity.ErrEntityNotExist
+	}
+
+	typ := entity.PierceComponentType(c)
+	id, err := p.types.IdOf(typ)
+	if err != nil {
+		return nil
+	}
+
+	rec := p.entities[m.Index()]
+	if !rec.t.signature.Test(int(id)) {
+		return nil
+	}
+
+	prior := rec.t.get(entity.ComponentId(id), rec.row)
+	sig := bitset.Copy(rec.t.signature)
+	sig.Clear(int(id))
+	p.move(m, p.tableFor(sig))
+	p.Notify(entity.Change{Kind: entity.ComponentRemoved, Entity: m, Type: typ, Component: prior})
+	return nil
+}
+
+// relocates the entity into the destination table, carrying over every
+// component the destination has a column for. A column the entity had no
+// component for is left zeroed for the caller to set
+func (p *archpool) move(m entity.Model, dest *table) {
+	rec := p.entities[m.Index()]
+	row := dest.insert(m)
+	for _, col := range dest.columns {
+		if c := rec.t.get(col.id, rec.row); c != nil {
+			dest.set(col.id, row, c)
+		}
+	}
+
+	if moved, ok := rec.t.evict(rec.row); ok {
+		p.entities[moved.Index()].row = rec.row
+	}
+
+	p.entities[m.Index()] = record{m, dest, row}
+}
+
+func (p *archpool) tableFor(sig bitset.Bitset64) *table {
+	key := signatureKey(sig, p.maxComponentId)
+	if t, ok := p.bySignature[key]; ok {
+		return t
+	}
+
+	t := newTable(sig, p.typesById)
+	p.bySignature[key] = t
+	p.tables = append(p.tables, t)
+	return t
+}
+
+type componentGetter struct {
+	p *archpool
+}
+
+func (c componentGetter) GetComponent(m entity.Model, typ reflect.Type) (entity.Component, error) {
+	return c.p.component(m, typ)
+}
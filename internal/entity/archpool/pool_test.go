@@ -0,0 +1,53 @@
+package archpool
+
+import (
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/internal/entity/entitytest"
+)
+
+func newTestPool() entity.Pool {
+	return New(Settings{MaxComponentId: 64})
+}
+
+func TestConformance(t *testing.T) {
+	entitytest.Conformance(t, newTestPool)
+}
+
+func TestTablesStayDense(t *testing.T) {
+	p := New(Settings{MaxComponentId: 64})
+	views := make([]entity.View, 10)
+	for i := range views {
+		views[i], _ = p.Create()
+		views[i].Add(entitytest.Tagged{V: i})
+	}
+
+	// move every other entity into a different table
+	for i := 0; i < len(views); i += 2 {
+		views[i].Add(entitytest.Marker{})
+	}
+
+	for _, tbl := range p.tables {
+		for _, col := range tbl.columns {
+			if col.values.Len() != len(tbl.members) {
+				t.Fatal("column length diverged from table membership")
+			}
+		}
+	}
+
+	for i, v := range views {
+		tagged, err := entity.GetComponent[entitytest.Tagged](v)
+		if err != nil || tagged.V != i {
+			t.Fatalf("entity %d lost its component after moving tables: %v %v", v.Model(), tagged, err)
+		}
+	}
+}
+
+func BenchmarkSyntheticSweep(b *testing.B) {
+	entitytest.BenchmarkSyntheticSweep(b, newTestPool, 2000)
+}
+
+func BenchmarkPreparedSweep(b *testing.B) {
+	entitytest.BenchmarkPreparedSweep(b, newTestPool, 2000)
+}
@@ -0,0 +1,120 @@
+package archpool
+
+import (
+	"sudonters/zootler/internal/entity"
+
+	"github.com/etc-sudonters/substrate/reiterate"
+)
+
+var _ entity.PreparedQuery = (*prepared)(nil)
+
+// archpool has no upper bound on entities so membership is tracked in a
+// slice that grows alongside the population. Observing happens under the
+// pool's write lock so answers are read under its read lock
+type prepared struct {
+	p         *archpool
+	source    entity.Filter
+	q         query
+	members   []bool
+	count     int
+	unobserve func()
+}
+
+func (p *archpool) Prepare(f entity.Filter) (entity.PreparedQuery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	compiled, err := p.compile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &prepared{p: p, source: f, q: compiled}
+	q.rebuild()
+	q.unobserve = p.Observe(q.observe)
+	return q, nil
+}
+
+func (q *prepared) Count() int {
+	q.p.mu.RLock()
+	defer q.p.mu.RUnlock()
+	return q.count
+}
+
+func (q *prepared) Contains(m entity.Model) bool {
+	q.p.mu.RLock()
+	defer q.p.mu.RUnlock()
+
+	idx := m.Index()
+	return q.p.alive(m) && idx < len(q.members) && q.members[idx]
+}
+
+func (q *prepared) Iter() reiterate.Iterator[entity.View] {
+	q.p.mu.RLock()
+	defer q.p.mu.RUnlock()
+
+	views := make([]entity.View, 0, q.count)
+	for idx, member := range q.members {
+		if member {
+			views = append(views, archview{id: q.p.entities[idx].id, p: q.p})
+		}
+	}
+	return reiterate.SliceIter(views)
+}
+
+func (q *prepared) Close() {
+	if q.unobserve != nil {
+		q.unobserve()
+		q.unobserve = nil
+	}
+}
+
+func (q *prepared) observe(c entity.Change) {
+	switch c.Kind {
+	case entity.PoolRestored:
+		q.rebuild()
+		return
+	case entity.ComponentAdded:
+		if reiterate.Contains(c.Type, q.q.pending) {
+			// compiling can't fail here, everything it could fail on was
+			// already resolved the first time
+			q.q, _ = q.p.compile(q.source)
+			q.rebuild()
+			return
+		}
+	}
+
+	q.revisit(c.Entity)
+}
+
+func (q *prepared) rebuild() {
+	q.members = make([]bool, len(q.p.entities))
+	q.count = 0
+	for _, rec := range q.p.entities {
+		if rec.t != nil {
+			q.revisit(rec.id)
+		}
+	}
+}
+
+func (q *prepared) revisit(m entity.Model) {
+	idx := m.Index()
+	for idx >= len(q.members) {
+		q.members = append(q.members, false)
+	}
+
+	is := false
+	if q.p.alive(m) {
+		rec := q.p.entities[idx]
+		is = q.q.admits(rec.t) && q.q.passes(rec.t, rec.row)
+	}
+
+	switch {
+	case is && !q.members[idx]:
+		q.members[idx] = true
+		q.count++
+	case q.members[idx] && !is:
+		q.members[idx] = false
+		q.count--
+	}
+}
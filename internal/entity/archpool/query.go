@@ -0,0 +1,117 @@
+package archpool
+
+import (
+	"reflect"
+
+	"sudonters/zootler/internal/entity"
+
+	"github.com/etc-sudonters/substrate/skelly/bitset"
+)
+
+// a filter resolved against this pool's component ids
+type query struct {
+	include bitset.Bitset64
+	exclude bitset.Bitset64
+	any     []bitset.Bitset64
+	where   []predicate
+	// group members that weren't registered when the query was compiled
+	pending []reflect.Type
+	// optional components that are registered, unregistered ones are never
+	// present
+	optional []entity.ComponentId
+}
+
+type predicate struct {
+	id   entity.ComponentId
+	test func(entity.Component) bool
+}
+
+func (p *archpool) compile(f entity.Filter) (query, error) {
+	var q query
+	q.include = bitset.New(p.componentBucketCount)
+	q.exclude = bitset.New(p.componentBucketCount)
+
+	for _, typ := range f.With() {
+		id, err := p.idOf(typ)
+		if err != nil {
+			return q, err
+		}
+		q.include.Set(int(id))
+	}
+
+	for _, typ := range f.Without() {
+		id, err := p.idOf(typ)
+		if err != nil {
+			return q, err
+		}
+		q.exclude.Set(int(id))
+	}
+
+	for _, group := range f.AnyOf() {
+		set := bitset.New(p.componentBucketCount)
+		for _, typ := range group {
+			if id, err := p.types.IdOf(typ); err == nil {
+				set.Set(int(id))
+			} else {
+				q.pending = append(q.pending, typ)
+			}
+		}
+		q.any = append(q.any, set)
+	}
+
+	for _, typ := range f.Optional() {
+		if id, err := p.types.IdOf(typ); err == nil {
+			q.optional = append(q.optional, entity.ComponentId(id))
+		}
+	}
+
+	for _, pred := range f.Where() {
+		id, err := p.idOf(pred.Type)
+		if err != nil {
+			return q, err
+		}
+		q.where = append(q.where, predicate{id, pred.Test})
+	}
+
+	return q, nil
+}
+
+// every member of an admitted table satisfies the query's component terms
+func (q query) admits(t *table) bool {
+	if !t.matches(q.include, q.exclude) {
+		return false
+	}
+
+	for _, group := range q.any {
+		if bitset.IsEmpty(t.signature.Intersect(group)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (q query) passes(t *table, row int) bool {
+	for _, pred := range q.where {
+		if !pred.test(t.get(pred.id, row)) {
+			return false
+		}
+	}
+	return true
+}
+
+// the row's optional components are loaded from the table's columns
+func (q query) view(p *archpool, t *table, row int) archview {
+	v := archview{id: t.members[row], p: p}
+	if len(q.optional) == 0 {
+		return v
+	}
+
+	v.loaded = new(entity.Loaded)
+	for _, id := range q.optional {
+		if c := t.get(id, row); c != nil {
+			v.loaded.Load(c)
+		}
+	}
+	return v
+}
@@ -0,0 +1,85 @@
+package archpool
+
+import (
+	"sudonters/zootler/internal/entity"
+)
+
+type snapshot struct {
+	p         *archpool
+	tables    []*table
+	entities  []record
+	relations entity.Relations
+}
+
+// tables are dense so they're copied outright rather than shared
+func (p *archpool) Snapshot() entity.Snapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	tables, entities := cloneTables(p.tables, p.entities)
+	return snapshot{p, tables, entities, p.Relations.Clone()}
+}
+
+// slots that are dead after restoring are advanced past any generation
+// handed out since the snapshot so those handles stay stale
+func (p *archpool) Restore(snap entity.Snapshot) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := snap.(snapshot)
+	if !ok || s.p != p {
+		return entity.ErrForeignSnapshot
+	}
+
+	tables, entities := cloneTables(s.tables, s.entities)
+	p.tables = tables
+	p.bySignature = make(map[string]*table, len(tables))
+	for _, t := range tables {
+		p.bySignature[signatureKey(t.signature, p.maxComponentId)] = t
+	}
+
+	p.free = p.free[:0]
+	for idx := len(p.entities) - 1; idx > 0; idx-- {
+		current := p.entities[idx]
+		if idx < len(entities) && entities[idx].t != nil {
+			p.entities[idx] = entities[idx]
+			continue
+		}
+
+		next := current.id
+		if current.t != nil {
+			next = next.Next()
+		}
+		if idx < len(entities) && entities[idx].id.Generation() > next.Generation() {
+			next = entities[idx].id
+		}
+
+		p.entities[idx] = record{id: next}
+		if next.Generation() != 0 {
+			p.free = append(p.free, idx)
+		}
+	}
+
+	p.Relations = s.relations.Clone()
+	p.Notify(entity.Change{Kind: entity.PoolRestored})
+	return nil
+}
+
+func cloneTables(tables []*table, entities []record) ([]*table, []record) {
+	clones := make(map[*table]*table, len(tables))
+	tablesCopy := make([]*table, len(tables))
+	for i, t := range tables {
+		tablesCopy[i] = t.clone()
+		clones[t] = tablesCopy[i]
+	}
+
+	entitiesCopy := make([]record, len(entities))
+	for i, rec := range entities {
+		if rec.t != nil {
+			rec.t = clones[rec.t]
+		}
+		entitiesCopy[i] = rec
+	}
+
+	return tablesCopy, entitiesCopy
+}
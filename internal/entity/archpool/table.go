@@ -0,0 +1,123 @@
+package archpool
+
+import (
+	"reflect"
+	"strconv"
+	"strings"
+
+	"sudonters/zootler/internal/entity"
+
+	"github.com/etc-sudonters/substrate/skelly/bitset"
+)
+
+// every member of a table has exactly the components described by its
+// signature, each component is stored densely in its own column
+type table struct {
+	signature bitset.Bitset64
+	columns   []column
+	members   []entity.Model
+}
+
+// values is a []T of the component's own type so rows are stored unboxed
+// next to each other, components are only boxed as they're read
+type column struct {
+	id     entity.ComponentId
+	typ    reflect.Type
+	values reflect.Value
+}
+
+func newColumn(id entity.ComponentId, typ reflect.Type, rows int) column {
+	return column{id, typ, reflect.MakeSlice(reflect.SliceOf(typ), rows, rows)}
+}
+
+// typesById must know every component in the signature
+func newTable(sig bitset.Bitset64, typesById []reflect.Type) *table {
+	t := new(table)
+	t.signature = sig
+	for i, typ := range typesById {
+		if sig.Test(i) {
+			t.columns = append(t.columns, newColumn(entity.ComponentId(i), typ, 0))
+		}
+	}
+	return t
+}
+
+func (t *table) matches(include, exclude bitset.Bitset64) bool {
+	if !t.signature.Intersect(include).Eq(include) {
+		return false
+	}
+
+	return bitset.IsEmpty(t.signature.Intersect(exclude))
+}
+
+func (t *table) column(id entity.ComponentId) *column {
+	for i := range t.columns {
+		if t.columns[i].id == id {
+			return &t.columns[i]
+		}
+	}
+	return nil
+}
+
+func (t *table) get(id entity.ComponentId, row int) entity.Component {
+	if col := t.column(id); col != nil {
+		return col.values.Index(row).Interface()
+	}
+	return nil
+}
+
+func (t *table) set(id entity.ComponentId, row int, c entity.Component) {
+	t.column(id).values.Index(row).Set(reflect.ValueOf(c))
+}
+
+// appends an empty row for the entity and returns its index
+func (t *table) insert(m entity.Model) int {
+	t.members = append(t.members, m)
+	for i := range t.columns {
+		col := &t.columns[i]
+		col.values = reflect.Append(col.values, reflect.Zero(col.typ))
+	}
+	return len(t.members) - 1
+}
+
+// swap removes the row, if another entity was moved into the vacated row
+// it is returned so its record can be updated
+func (t *table) evict(row int) (entity.Model, bool) {
+	last := len(t.members) - 1
+	moved := t.members[last]
+
+	t.members[row] = moved
+	t.members = t.members[:last]
+	for i := range t.columns {
+		col := &t.columns[i]
+		col.values.Index(row).Set(col.values.Index(last))
+		col.values.Index(last).Set(reflect.Zero(col.typ))
+		col.values = col.values.Slice(0, last)
+	}
+
+	return moved, row != last
+}
+
+func signatureKey(sig bitset.Bitset64, maxComponentId int) string {
+	var key strings.Builder
+	for i := 0; i <= maxComponentId; i++ {
+		if sig.Test(i) {
+			key.WriteString(strconv.Itoa(i))
+			key.WriteRune(',')
+		}
+	}
+	return key.String()
+}
+
+func (t *table) clone() *table {
+	c := new(table)
+	c.signature = bitset.Copy(t.signature)
+	c.members = make([]entity.Model, len(t.members))
+	copy(c.members, t.members)
+	c.columns = make([]column, len(t.columns))
+	for i, col := range t.columns {
+		c.columns[i] = newColumn(col.id, col.typ, col.values.Len())
+		reflect.Copy(c.columns[i].values, col.values)
+	}
+	return c
+}
@@ -0,0 +1,60 @@
+package archpool
+
+import (
+	"fmt"
+	"reflect"
+
+	"sudonters/zootler/internal/entity"
+)
+
+type archview struct {
+	id     entity.Model
+	p      *archpool
+	loaded *entity.Loaded
+}
+
+func (a archview) String() string {
+	return fmt.Sprintf("archview{ %d }", a.id)
+}
+
+func (a archview) Model() entity.Model {
+	return a.id
+}
+
+func (a archview) Get(w interface{}) error {
+	if a.loaded != nil && entity.AssignComponentTo(a.id, w, a.loaded) == nil {
+		return nil
+	}
+	a.p.mu.RLock()
+	defer a.p.mu.RUnlock()
+	return entity.AssignComponentTo(a.id, w, componentGetter{a.p})
+}
+
+func (a archview) Component(typ reflect.Type) (entity.Component, error) {
+	if c, err := a.loaded.GetComponent(a.id, typ); err == nil {
+		return c, nil
+	}
+	a.p.mu.RLock()
+	defer a.p.mu.RUnlock()
+	return a.p.component(a.id, typ)
+}
+
+func (a archview) Add(c entity.Component) error {
+	a.p.mu.Lock()
+	defer a.p.mu.Unlock()
+	if err := a.p.add(a.id, c); err != nil {
+		return err
+	}
+	a.loaded.Update(c)
+	return nil
+}
+
+func (a archview) Remove(c entity.Component) error {
+	a.p.mu.Lock()
+	defer a.p.mu.Unlock()
+	if err := a.p.remove(a.id, c); err != nil {
+		return err
+	}
+	a.loaded.Forget(entity.PierceComponentType(c))
+	return nil
+}
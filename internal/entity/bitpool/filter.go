@@ -1,14 +1,29 @@
 package bitpool
 
 import (
+	"fmt"
+	"reflect"
 	"sudonters/zootler/internal/entity"
 
+	"github.com/etc-sudonters/substrate/mirrors"
 	"github.com/etc-sudonters/substrate/skelly/bitset"
 )
 
 type filter struct {
-	i bitset.Bitset64
-	e bitset.Bitset64
+	i     bitset.Bitset64
+	e     bitset.Bitset64
+	any   []bitset.Bitset64
+	where []entity.Predicate
+	// group members that weren't registered when the filter was compiled
+	pending []reflect.Type
+	// optional components that are registered, unregistered ones are never
+	// present
+	optional []optional
+}
+
+type optional struct {
+	id  entity.ComponentId
+	typ reflect.Type
 }
 
 func (f *filter) init(k int) {
@@ -24,6 +39,7 @@ func (f filter) exclude(t entity.ComponentId) {
 	f.e.Set(int(t))
 }
 
+// everything except predicates is decided by the entity's component set
 func (f filter) test(b bitview) bool {
 	if !bitset.IsEmpty(f.i) && !b.comps.Intersect(f.i).Eq(f.i) {
 		return false
@@ -33,5 +49,101 @@ func (f filter) test(b bitview) bool {
 		return false
 	}
 
+	for _, group := range f.any {
+		if bitset.IsEmpty(b.comps.Intersect(group)) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (p *bitpool) compile(f entity.Filter) (filter, error) {
+	var compiled filter
+	(&compiled).init(p.componentBucketCount)
+
+	for _, typ := range f.With() {
+		id, err := p.idOf(typ)
+		if err != nil {
+			return compiled, err
+		}
+		compiled.include(id)
+	}
+
+	for _, typ := range f.Without() {
+		id, err := p.idOf(typ)
+		if err != nil {
+			return compiled, err
+		}
+		compiled.exclude(id)
+	}
+
+	for _, group := range f.AnyOf() {
+		set := bitset.New(p.componentBucketCount)
+		for _, typ := range group {
+			if id, err := p.table.IdOf(typ); err == nil {
+				set.Set(int(id))
+			} else {
+				compiled.pending = append(compiled.pending, typ)
+			}
+		}
+		compiled.any = append(compiled.any, set)
+	}
+
+	for _, typ := range f.Optional() {
+		if id, err := p.table.IdOf(typ); err == nil {
+			compiled.optional = append(compiled.optional, optional{id, typ})
+		}
+	}
+
+	compiled.where = f.Where()
+	return compiled, nil
+}
+
+func (p *bitpool) matches(f filter, b bitview) bool {
+	if !f.test(b) {
+		return false
+	}
+
+	for _, pred := range f.where {
+		c, err := p.table.Get(b.id, pred.Type)
+		if err != nil || !pred.Test(c) {
+			return false
+		}
+	}
+
 	return true
 }
+
+func (p *bitpool) idOf(typ reflect.Type) (entity.ComponentId, error) {
+	id, err := p.table.IdOf(typ)
+	if err != nil {
+		name := typ.Name()
+		if name == "" {
+			if n, ok := mirrors.TryGetLiteral(typ); ok {
+				name = n
+			}
+		}
+		return 0, fmt.Errorf("during component %s: %w", name, err)
+	}
+
+	return id, nil
+}
+
+// the view with the filter's optional components it has loaded into it
+func (p *bitpool) load(f filter, b bitview) bitview {
+	if len(f.optional) == 0 {
+		return b
+	}
+
+	b.loaded = new(entity.Loaded)
+	for _, opt := range f.optional {
+		if !b.comps.Test(int(opt.id)) {
+			continue
+		}
+		if c, err := p.table.Get(b.id, opt.typ); err == nil && c != nil {
+			b.loaded.Load(c)
+		}
+	}
+	return b
+}
@@ -1,23 +1,32 @@
 package bitpool
 
 import (
-	"fmt"
 	"reflect"
 	"sudonters/zootler/internal/entity"
 	"sudonters/zootler/internal/entity/componenttable"
+	"sync"
 
-	"github.com/etc-sudonters/substrate/mirrors"
 	"github.com/etc-sudonters/substrate/skelly/bitset"
 )
 
+// exported methods and views acquire mu, unexported methods assume the
+// caller already holds it
 type bitpool struct {
+	mu                   sync.RWMutex
 	componentBucketCount int
+	maxEntityId          int
 	entities             []bitview
+	free                 []int
 	table                *componenttable.Table
+	// slots whose component set isn't shared with a snapshot
+	owned bitset.Bitset64
+	entity.Observers
+	entity.Relations
 }
 
 var _ entity.Pool = (*bitpool)(nil)
 var _ entity.View = bitview{}
+var _ entity.DirectView = bitview{}
 
 type Settings struct {
 	MaxComponentId int
@@ -25,72 +34,167 @@ type Settings struct {
 }
 
 func New(s Settings) *bitpool {
-	var b bitpool
-	b.componentBucketCount = bitset.Buckets(s.MaxComponentId)
-	b.entities = make([]bitview, 1, 128)
-	b.table = componenttable.New(s.MaxEntityId)
-	return &b
+	return FromTable(componenttable.New(s.MaxEntityId), s.MaxComponentId)
 }
 
 func FromTable(tbl *componenttable.Table, maxComponentId int) *bitpool {
 	var b bitpool
 	b.componentBucketCount = bitset.Buckets(maxComponentId)
+	b.maxEntityId = tbl.EntityCapacity() - 1
 	b.table = tbl
-	b.entities = make([]bitview, 128)
+	b.entities = make([]bitview, 1, 128)
+	b.owned = bitset.New(bitset.Buckets(tbl.EntityCapacity()))
 	return &b
 }
 
+// destroyed slots are reused before the population grows
 func (p *bitpool) Create() (entity.View, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	view, err := p.create()
+	if err != nil {
+		return nil, err
+	}
+	return view, nil
+}
+
+func (p *bitpool) create() (bitview, error) {
 	var view bitview
-	view.id = entity.Model(len(p.entities))
+
+	if n := len(p.free); n > 0 {
+		idx := p.free[n-1]
+		p.free = p.free[:n-1]
+		view.id = p.entities[idx].id
+	} else {
+		if len(p.entities) > p.maxEntityId {
+			return view, entity.ErrNoMoreIds
+		}
+		view.id = entity.NewModel(len(p.entities), 0)
+		p.entities = append(p.entities, bitview{})
+	}
+
 	view.comps = bitset.New(p.componentBucketCount)
 	view.p = p
-	p.entities = append(p.entities, view)
+	p.entities[view.id.Index()] = view
+	p.owned.Set(view.id.Index())
+	p.Notify(entity.Change{Kind: entity.EntityCreated, Entity: view.id})
 	return view, nil
 }
 
-// return a subset of the population that matches the provided selectors
-func (p *bitpool) Query(f entity.Filter) ([]entity.View, error) {
-	var filter filter
-	(&filter).init(p.componentBucketCount)
-
-	getTypeId := func(typ reflect.Type) (entity.ComponentId, error) {
-		id, err := p.table.IdOf(typ)
-		if err != nil {
-			name := typ.Name()
-			if name == "" {
-				if n, ok := mirrors.TryGetLiteral(typ); ok {
-					name = n
-				}
+func (p *bitpool) Destroy(m entity.Model) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.destroy(m)
+}
+
+func (p *bitpool) destroy(m entity.Model) error {
+	if !p.alive(m) {
+		return entity.ErrEntityNotExist
+	}
+
+	var removed []entity.Change
+	if p.Observed() {
+		for rows := p.table.Rows(); rows.MoveNext(); {
+			row := rows.Current()
+			if c := row.Get(m); c != nil {
+				removed = append(removed, entity.Change{
+					Kind: entity.ComponentRemoved, Entity: m, Type: row.Type(), Component: c,
+				})
 			}
-			return 0, fmt.Errorf("during component %s: %w", name, err)
 		}
+	}
 
-		return id, nil
+	idx := m.Index()
+	p.table.UnsetAll(m)
+	p.Forget(m)
+	// dead slots keep the model of their next occupant but no pool reference
+	p.entities[idx] = bitview{id: m.Next()}
+	if m.Next().Generation() != 0 {
+		p.free = append(p.free, idx)
 	}
 
-	for _, typ := range f.With() {
-		id, err := getTypeId(typ)
-		if err != nil {
-			return nil, err
-		}
-		filter.include(id)
+	for _, change := range removed {
+		p.Notify(change)
 	}
+	p.Notify(entity.Change{Kind: entity.EntityDestroyed, Entity: m})
+	return nil
+}
 
-	for _, typ := range f.Without() {
-		id, err := getTypeId(typ)
-		if err != nil {
-			return nil, err
-		}
-		filter.exclude(id)
+func (p *bitpool) Relate(source, target entity.Model, r entity.Component) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	return p.relate(source, target, r)
+}
+
+func (p *bitpool) relate(source, target entity.Model, r entity.Component) error {
+	if !p.alive(source) || !p.alive(target) {
+		return entity.ErrEntityNotExist
+	}
+	return p.Link(source, target, r)
+}
+
+func (p *bitpool) Unrelate(kind reflect.Type, source, target entity.Model) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if !p.alive(source) || !p.alive(target) {
+		return entity.ErrEntityNotExist
+	}
+	p.Unlink(kind, source, target)
+	return nil
+}
+
+func (p *bitpool) Relation(kind reflect.Type, source, target entity.Model) (entity.Component, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Relations.Relation(kind, source, target)
+}
+
+func (p *bitpool) Targets(kind reflect.Type, source entity.Model) []entity.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Relations.Targets(kind, source)
+}
+
+func (p *bitpool) Sources(kind reflect.Type, target entity.Model) []entity.Model {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Relations.Sources(kind, target)
+}
+
+func (p *bitpool) Pairs(kind reflect.Type) []entity.Pair {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+	return p.Relations.Pairs(kind)
+}
+
+func (p *bitpool) alive(m entity.Model) bool {
+	idx := m.Index()
+	if m == entity.INVALID_ENTITY || idx >= len(p.entities) {
+		return false
+	}
+
+	slot := p.entities[idx]
+	return slot.p != nil && slot.id == m
+}
+
+// return a subset of the population that matches the provided selectors
+func (p *bitpool) Query(f entity.Filter) ([]entity.View, error) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	filter, err := p.compile(f)
+	if err != nil {
+		return nil, err
 	}
 
 	var entities []entity.View
 
 	for _, e := range p.entities {
 		e := e
-		if filter.test(e) {
-			entities = append(entities, e)
+		if e.p != nil && p.matches(filter, e) {
+			entities = append(entities, p.load(filter, e))
 		}
 	}
 
@@ -102,28 +206,87 @@ func (p *bitpool) Query(f entity.Filter) ([]entity.View, error) {
 }
 
 func (p *bitpool) Get(m entity.Model, cs []interface{}) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.alive(m) {
+		return
+	}
 	for i := range cs {
-		_ = entity.AssignComponentTo(m, cs[i], p.table.Getter())
+		_ = entity.AssignComponentTo(m, cs[i], componentGetter{p})
 	}
 }
 
 func (p *bitpool) Fetch(m entity.Model) (entity.View, error) {
-	if int(m) >= len(p.entities) {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	if !p.alive(m) {
 		return nil, entity.ErrEntityNotExist
 	}
 
-	return p.entities[int(m)], nil
+	return p.entities[m.Index()], nil
 }
 
-func (p *bitpool) addCompToEnt(b bitview, c entity.Component) error {
-	id := p.table.Set(b.id, c)
-	b.comps.Set(int(id))
+func (p *bitpool) component(m entity.Model, typ reflect.Type) (entity.Component, error) {
+	if !p.alive(m) {
+		return nil, entity.ErrEntityNotExist
+	}
+
+	return p.table.Get(m, typ)
+}
+
+func (p *bitpool) addCompToEnt(m entity.Model, c entity.Component) error {
+	if !p.alive(m) {
+		return entity.ErrEntityNotExist
+	}
+
+	id, err := p.table.Set(m, c)
+	if err != nil {
+		return err
+	}
+	p.writable(m).Set(int(id))
+	p.Notify(entity.Change{
+		Kind: entity.ComponentAdded, Entity: m, Type: entity.PierceComponentType(c), Component: c,
+	})
 	return nil
 }
 
-func (p *bitpool) removeCompFromEnt(b bitview, c entity.Component) error {
-	if id := p.table.Unset(b.id, entity.PierceComponentType(c)); id != entity.INVALID_COMPONENT {
-		b.comps.Clear(int(id))
+func (p *bitpool) removeCompFromEnt(m entity.Model, c entity.Component) error {
+	if !p.alive(m) {
+		return entity.ErrEntityNotExist
+	}
+
+	typ := entity.PierceComponentType(c)
+	prior, err := p.table.Get(m, typ)
+	if err != nil {
+		return nil
 	}
+
+	if id := p.table.Unset(m, typ); id != entity.INVALID_COMPONENT {
+		p.writable(m).Clear(int(id))
+	}
+	p.Notify(entity.Change{
+		Kind: entity.ComponentRemoved, Entity: m, Type: typ, Component: prior,
+	})
 	return nil
 }
+
+// views hand out their component set by value, so mutations are made
+// against the pool's copy which is cloned first if a snapshot shares it
+func (p *bitpool) writable(m entity.Model) bitset.Bitset64 {
+	idx := m.Index()
+	if !p.owned.Test(idx) {
+		p.entities[idx].comps = bitset.Copy(p.entities[idx].comps)
+		p.owned.Set(idx)
+	}
+	return p.entities[idx].comps
+}
+
+type componentGetter struct {
+	p *bitpool
+}
+
+func (c componentGetter) GetComponent(m entity.Model, typ reflect.Type) (entity.Component, error) {
+	return c.p.component(m, typ)
+}
@@ -9,11 +9,27 @@ import (
 	"testing"
 
 	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/internal/entity/entitytest"
 
+	"github.com/etc-sudonters/substrate/mirrors"
 	"github.com/etc-sudonters/substrate/skelly/hashset"
 	"github.com/etc-sudonters/substrate/stageleft"
 )
 
+var testSettings = Settings{MaxComponentId: 64, MaxEntityId: 10001}
+
+func newTestPool() entity.Pool {
+	return New(testSettings)
+}
+
+func TestConformance(t *testing.T) {
+	entitytest.Conformance(t, newTestPool)
+}
+
+func BenchmarkSyntheticSweep(b *testing.B) {
+	entitytest.BenchmarkSyntheticSweep(b, newTestPool, 2000)
+}
+
 func dump(t *testing.T, v interface{}) {
 	t.Logf("%+v", v)
 }
@@ -53,7 +69,7 @@ func TestCanStoreAndRetrievePointerToComp(t *testing.T) {
 		V int
 	}
 
-	p := New(100)
+	p := New(testSettings)
 	v, _ := p.Create()
 	v.Add(&TestCanStoreAndRetrievePointerToComp0{initialValue})
 
@@ -83,7 +99,7 @@ func TestCanRemoveCustomComponent(t *testing.T) {
 			t.Fatal(p)
 		}
 	}()
-	p := New(1000)
+	p := New(testSettings)
 	v, _ := p.Create()
 	ent := v.(bitview)
 	ent.Add(myTestComponent{})
@@ -110,7 +126,7 @@ func TestCanQueryForEntitiesByComponentExistence(t *testing.T) {
 	}()
 	entitiesToMake := 10000
 	tagRatio := 7
-	p := New(10000)
+	p := New(testSettings)
 
 	totalEnts := hashset.New[entity.Model]()
 	taggedEnts := hashset.New[entity.Model]()
@@ -130,7 +146,7 @@ func TestCanQueryForEntitiesByComponentExistence(t *testing.T) {
 		t.Fatalf("mismatched entity count\nexpected:\t%d\nactual:\t%d", len(totalEnts), entitiesToMake)
 	}
 
-	filter := []entity.Selector{entity.With[myTestComponent]{}}
+	filter := entity.BuildFilter().With(mirrors.TypeOf[myTestComponent]()).Build()
 	queryedFor, err := p.Query(filter)
 	if err != nil {
 		didNotExpectError(t, err)
@@ -164,7 +180,7 @@ func TestCanUseMultipleComponents(t *testing.T) {
 	componentsToMake := 35
 	goodTagRatio := 7
 	badTagRation := 5
-	p := New(1000)
+	p := New(testSettings)
 
 	totalEnts := hashset.New[entity.Model]()
 	goodTaggedEnts := hashset.New[entity.Model]()
@@ -187,15 +203,10 @@ func TestCanUseMultipleComponents(t *testing.T) {
 	}
 
 	comboTagSet := hashset.Intersection(goodTaggedEnts, badTaggedEnts)
-	comboQueries, err := p.Query([]entity.Selector{
-		entity.DebugSelector{
-			F:        func(string, ...any) {}, //t.Logf,
-			Selector: entity.With[myTestComponent]{},
-		},
-		entity.DebugSelector{
-			F:        t.Logf,
-			Selector: entity.With[anotherComponent]{},
-		}},
+	comboQueries, err := p.Query(entity.BuildFilter().
+		With(mirrors.TypeOf[myTestComponent]()).
+		With(mirrors.TypeOf[anotherComponent]()).
+		Build(),
 	)
 
 	if err != nil {
@@ -223,7 +234,7 @@ func TestCanExcludeEntitiesBasedOnComponent(t *testing.T) {
 	componentsToMake := 1001
 	firstTagRatio := 7
 	secondTagRatio := 5
-	p := New(int(componentsToMake))
+	p := New(testSettings)
 
 	totalEnts := hashset.New[entity.Model]()
 	firstTagEnts := hashset.New[entity.Model]()
@@ -250,17 +261,11 @@ func TestCanExcludeEntitiesBasedOnComponent(t *testing.T) {
 		hashset.Union(firstTagEnts, secondTagEnt),
 	)
 
-	debuggify := func(q entity.Selector) entity.DebugSelector {
-		return entity.DebugSelector{
-			Selector: q,
-			F:        t.Logf,
-		}
-	}
-
-	queriedAllUntagged, err := p.Query([]entity.Selector{
-		debuggify(entity.Without[myTestComponent]{}),
-		debuggify(entity.Without[anotherComponent]{}),
-	})
+	queriedAllUntagged, err := p.Query(entity.BuildFilter().
+		Without(mirrors.TypeOf[myTestComponent]()).
+		Without(mirrors.TypeOf[anotherComponent]()).
+		Build(),
+	)
 
 	if err != nil {
 		didNotExpectError(t, err)
@@ -293,7 +298,7 @@ func TestCanFilterWithoutLoading(t *testing.T) {
 	}()
 	entitiesToMake := 10000
 	tagRatio := 7
-	p := New(10)
+	p := New(testSettings)
 
 	totalEnts := hashset.New[entity.Model]()
 	taggedEnts := hashset.New[entity.Model]()
@@ -312,7 +317,7 @@ func TestCanFilterWithoutLoading(t *testing.T) {
 		}
 	}
 
-	queriedEnts, err := p.Query([]entity.Selector{entity.Without[myTestComponent]{}})
+	queriedEnts, err := p.Query(entity.BuildFilter().Without(mirrors.TypeOf[myTestComponent]()).Build())
 
 	if err != nil {
 		didNotExpectError(t, err)
@@ -346,7 +351,7 @@ func TestCanRetrieveArbitraryEntityWithComps(t *testing.T) {
 			t.Fatal(p, stageleft.ShowPanicTrace())
 		}
 	}()
-	p := New(1000)
+	p := New(testSettings)
 	v, _ := p.Create()
 	ent := v.(bitview)
 	ent.Add(myTestComponent{99})
@@ -375,3 +380,46 @@ type myTestComponent struct {
 type anotherComponent struct {
 	K float64
 }
+
+func BenchmarkPreparedSweep(b *testing.B) {
+	entitytest.BenchmarkPreparedSweep(b, newTestPool, 2000)
+}
+
+func TestStatsWarnAboutMaxima(t *testing.T) {
+	// maxima are rounded up to a whole bucket
+	p := New(Settings{MaxComponentId: 2, MaxEntityId: 60})
+	for i := 0; i < 60; i++ {
+		view, err := p.Create()
+		if err != nil {
+			didNotExpectError(t, err)
+		}
+		if err := view.Add(myTestComponent{i}); err != nil {
+			didNotExpectError(t, err)
+		}
+	}
+	if err := p.Destroy(entity.NewModel(2, 0)); err != nil {
+		didNotExpectError(t, err)
+	}
+
+	stats := p.Stats()
+	if stats.Live != 59 || stats.Slots != 60 || stats.FreeSlots != 1 || stats.MaxEntityId != 63 {
+		t.Fatalf("unexpected population %+v", stats)
+	}
+
+	if warnings := stats.Warnings(); len(warnings) != 2 || !strings.Contains(warnings[0], "raise the maximum entity id") {
+		t.Fatalf("expected a crowded entity warning but got %v", warnings)
+	}
+
+	roomy := New(Settings{MaxComponentId: 640, MaxEntityId: 6400})
+	view, err := roomy.Create()
+	if err != nil {
+		didNotExpectError(t, err)
+	}
+	if err := view.Add(myTestComponent{}); err != nil {
+		didNotExpectError(t, err)
+	}
+
+	if warnings := roomy.Stats().Warnings(); len(warnings) != 2 {
+		t.Fatalf("expected both maxima to be called wasteful but got %v", warnings)
+	}
+}
@@ -0,0 +1,112 @@
+package bitpool
+
+import (
+	"sudonters/zootler/internal/entity"
+
+	"github.com/etc-sudonters/substrate/reiterate"
+	"github.com/etc-sudonters/substrate/skelly/bitset"
+)
+
+var _ entity.PreparedQuery = (*prepared)(nil)
+
+// membership is tracked in a bitset indexed by entity slot and revisited
+// for a single entity whenever that entity changes. Observing happens under
+// the pool's write lock so answers are read under its read lock
+type prepared struct {
+	p         *bitpool
+	source    entity.Filter
+	f         filter
+	members   bitset.Bitset64
+	count     int
+	unobserve func()
+}
+
+func (p *bitpool) Prepare(f entity.Filter) (entity.PreparedQuery, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	compiled, err := p.compile(f)
+	if err != nil {
+		return nil, err
+	}
+
+	q := &prepared{p: p, source: f, f: compiled}
+	q.rebuild()
+	q.unobserve = p.Observe(q.observe)
+	return q, nil
+}
+
+func (q *prepared) Count() int {
+	q.p.mu.RLock()
+	defer q.p.mu.RUnlock()
+	return q.count
+}
+
+func (q *prepared) Contains(m entity.Model) bool {
+	q.p.mu.RLock()
+	defer q.p.mu.RUnlock()
+	return q.p.alive(m) && q.members.Test(m.Index())
+}
+
+func (q *prepared) Iter() reiterate.Iterator[entity.View] {
+	q.p.mu.RLock()
+	defer q.p.mu.RUnlock()
+
+	views := make([]entity.View, 0, q.count)
+	for idx, e := range q.p.entities {
+		if q.members.Test(idx) {
+			views = append(views, e)
+		}
+	}
+	return reiterate.SliceIter(views)
+}
+
+func (q *prepared) Close() {
+	if q.unobserve != nil {
+		q.unobserve()
+		q.unobserve = nil
+	}
+}
+
+func (q *prepared) observe(c entity.Change) {
+	switch c.Kind {
+	case entity.PoolRestored:
+		q.rebuild()
+		return
+	case entity.ComponentAdded:
+		if reiterate.Contains(c.Type, q.f.pending) {
+			// compiling can't fail here, everything it could fail on was
+			// already resolved the first time
+			q.f, _ = q.p.compile(q.source)
+			q.rebuild()
+			return
+		}
+	}
+
+	q.revisit(c.Entity)
+}
+
+func (q *prepared) rebuild() {
+	q.members = bitset.New(bitset.Buckets(q.p.table.EntityCapacity()))
+	q.count = 0
+	for _, e := range q.p.entities {
+		if e.p != nil {
+			q.revisit(e.id)
+		}
+	}
+}
+
+func (q *prepared) revisit(m entity.Model) {
+	idx := m.Index()
+	was := q.members.Test(idx)
+	is := q.p.alive(m) && q.p.matches(q.f, q.p.entities[idx])
+
+	switch {
+	case is && !was:
+		q.members.Set(idx)
+		q.count++
+	case was && !is:
+		q.members.Clear(idx)
+		q.count--
+	}
+}
@@ -0,0 +1,73 @@
+package bitpool
+
+import (
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/internal/entity/componenttable"
+
+	"github.com/etc-sudonters/substrate/skelly/bitset"
+)
+
+type snapshot struct {
+	p         *bitpool
+	entities  []bitview
+	table     componenttable.Snapshot
+	relations entity.Relations
+}
+
+// component sets are shared with the snapshot until the next write to them
+func (p *bitpool) Snapshot() entity.Snapshot {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	var s snapshot
+	s.p = p
+	s.entities = make([]bitview, len(p.entities))
+	copy(s.entities, p.entities)
+	s.table = p.table.Snapshot()
+	s.relations = p.Relations.Clone()
+	p.owned = bitset.New(bitset.Buckets(p.table.EntityCapacity()))
+	return s
+}
+
+// slots that are dead after restoring are advanced past any generation
+// handed out since the snapshot so those handles stay stale
+func (p *bitpool) Restore(snap entity.Snapshot) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	s, ok := snap.(snapshot)
+	if !ok || s.p != p {
+		return entity.ErrForeignSnapshot
+	}
+
+	if err := p.table.Restore(s.table); err != nil {
+		return err
+	}
+
+	p.free = p.free[:0]
+	for idx := len(p.entities) - 1; idx > 0; idx-- {
+		current := p.entities[idx]
+		if idx < len(s.entities) && s.entities[idx].p != nil {
+			p.entities[idx] = s.entities[idx]
+			continue
+		}
+
+		next := current.id
+		if current.p != nil {
+			next = next.Next()
+		}
+		if idx < len(s.entities) && s.entities[idx].id.Generation() > next.Generation() {
+			next = s.entities[idx].id
+		}
+
+		p.entities[idx] = bitview{id: next}
+		if next.Generation() != 0 {
+			p.free = append(p.free, idx)
+		}
+	}
+
+	p.owned = bitset.New(bitset.Buckets(p.table.EntityCapacity()))
+	p.Relations = s.relations.Clone()
+	p.Notify(entity.Change{Kind: entity.PoolRestored})
+	return nil
+}
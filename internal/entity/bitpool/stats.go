@@ -0,0 +1,85 @@
+package bitpool
+
+import (
+	"fmt"
+	"sudonters/zootler/internal/entity/componenttable"
+)
+
+// maxima used above crowded are close to running out and maxima used below
+// sparse are mostly allocating nothing
+const (
+	crowded = 0.9
+	sparse  = 0.1
+)
+
+type Stats struct {
+	Table componenttable.Stats
+	// entities currently alive
+	Live int
+	// slots handed out so far including dead slots waiting to be reused
+	Slots        int
+	MaxEntityId  int
+	FreeSlots    int
+	Components   int
+	MaxComponent int
+	// every view carries its own component set of this many buckets
+	ComponentBuckets int
+	Bytes            int
+}
+
+func (p *bitpool) Stats() Stats {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	stats := Stats{
+		Table:            p.table.Stats(),
+		Slots:            len(p.entities) - 1,
+		MaxEntityId:      p.maxEntityId,
+		FreeSlots:        len(p.free),
+		MaxComponent:     p.componentBucketCount*64 - 1,
+		ComponentBuckets: p.componentBucketCount,
+	}
+
+	stats.Components = len(stats.Table.Rows)
+	for idx := 1; idx < len(p.entities); idx++ {
+		// dead slots drop their pool reference
+		if p.entities[idx].p != nil {
+			stats.Live++
+		}
+	}
+	stats.Bytes = stats.Table.Bytes + stats.Slots*p.componentBucketCount*8
+	return stats
+}
+
+// describes configured maxima that are too small for what the pool holds or
+// much larger than it needs
+func (s Stats) Warnings() []string {
+	var warnings []string
+
+	if used := ratio(s.Slots, s.MaxEntityId); used >= crowded {
+		warnings = append(warnings, fmt.Sprintf(
+			"%d of %d entity slots are in use, raise the maximum entity id", s.Slots, s.MaxEntityId))
+	} else if s.Slots > 0 && used < sparse {
+		warnings = append(warnings, fmt.Sprintf(
+			"only %d of %d entity slots are in use, every row's membership set is sized for all of them",
+			s.Slots, s.MaxEntityId))
+	}
+
+	if used := ratio(s.Components, s.MaxComponent); used >= crowded {
+		warnings = append(warnings, fmt.Sprintf(
+			"%d of %d component ids are in use, raise the maximum component id", s.Components, s.MaxComponent))
+	} else if s.Components > 0 && used < sparse {
+		warnings = append(warnings, fmt.Sprintf(
+			"only %d of %d component ids are in use, every entity's component set is sized for all of them",
+			s.Components, s.MaxComponent))
+	}
+
+	return warnings
+}
+
+func ratio(used, capacity int) float64 {
+	if capacity <= 0 {
+		return 1
+	}
+	return float64(used) / float64(capacity)
+}
@@ -2,15 +2,17 @@ package bitpool
 
 import (
 	"fmt"
+	"reflect"
 	"sudonters/zootler/internal/entity"
 
 	"github.com/etc-sudonters/substrate/skelly/bitset"
 )
 
 type bitview struct {
-	id    entity.Model
-	comps bitset.Bitset64
-	p     *bitpool
+	id     entity.Model
+	comps  bitset.Bitset64
+	p      *bitpool
+	loaded *entity.Loaded
 }
 
 func (b bitview) String() string {
@@ -22,39 +24,39 @@ func (b bitview) Model() entity.Model {
 }
 
 func (b bitview) Get(w interface{}) error {
-	return entity.AssignComponentTo(b.id, w, b.p.table.Getter())
-}
-
-func (b bitview) addMany(cs []entity.Component) error {
-	for _, c := range cs {
-		if err := b.Add(c); err != nil {
-			return err
-		}
+	if b.loaded != nil && entity.AssignComponentTo(b.id, w, b.loaded) == nil {
+		return nil
 	}
-	return nil
+	b.p.mu.RLock()
+	defer b.p.mu.RUnlock()
+	return entity.AssignComponentTo(b.id, w, componentGetter{b.p})
 }
 
-func (b bitview) Add(c entity.Component) error {
-	if many, ok := c.([]entity.Component); ok {
-		return b.addMany(many)
+func (b bitview) Component(typ reflect.Type) (entity.Component, error) {
+	if c, err := b.loaded.GetComponent(b.id, typ); err == nil {
+		return c, nil
 	}
-
-	return b.p.addCompToEnt(b, c)
+	b.p.mu.RLock()
+	defer b.p.mu.RUnlock()
+	return b.p.component(b.id, typ)
 }
 
-func (b bitview) removeMany(cs []entity.Component) error {
-	for _, c := range cs {
-		if err := b.Remove(c); err != nil {
-			return err
-		}
+func (b bitview) Add(c entity.Component) error {
+	b.p.mu.Lock()
+	defer b.p.mu.Unlock()
+	if err := b.p.add(b.id, c); err != nil {
+		return err
 	}
+	b.loaded.Update(c)
 	return nil
 }
 
 func (b bitview) Remove(c entity.Component) error {
-	if many, ok := c.([]entity.Component); ok {
-		return b.removeMany(many)
+	b.p.mu.Lock()
+	defer b.p.mu.Unlock()
+	if err := b.p.remove(b.id, c); err != nil {
+		return err
 	}
-
-	return b.p.removeCompFromEnt(b, c)
+	b.loaded.Forget(entity.PierceComponentType(c))
+	return nil
 }
@@ -31,7 +31,11 @@ func (r RowData) Components() reiterate.Iterator[RowEntry] {
 }
 
 func (r RowData) Capacity() int {
-	return len(r.r.components)
+	return r.r.Capacity()
+}
+
+func (r RowData) Storage() Storage {
+	return r.r.Storage()
 }
 
 func (r RowData) Len() int {
@@ -0,0 +1,225 @@
+package componenttable
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+	"sudonters/zootler/internal/entity"
+	"sync"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+)
+
+var ErrUnregisteredComponent = errors.New("component is not registered")
+var ErrDuplicateComponent = errors.New("component is already registered")
+
+// typed strings are named by their literal
+const TypedStringPrefix = "literal:"
+
+type ComponentKind uint8
+
+const (
+	_ ComponentKind = iota
+	// carries no data, presence is the whole point
+	TagComponent
+	// carries a value
+	DataComponent
+)
+
+func (k ComponentKind) String() string {
+	switch k {
+	case TagComponent:
+		return "tag"
+	case DataComponent:
+		return "data"
+	default:
+		return "unknown"
+	}
+}
+
+type ComponentInfo struct {
+	Id      entity.ComponentId
+	Name    string
+	Type    reflect.Type
+	Kind    ComponentKind
+	Storage Storage
+}
+
+// assigns component ids in registration order so the same registrations
+// always produce the same ids, tables built from a registry only accept the
+// components registered with it. Registries are safe for concurrent use
+type ComponentRegistry struct {
+	mu     sync.RWMutex
+	infos  []ComponentInfo
+	byType map[reflect.Type]entity.ComponentId
+	byName map[string]entity.ComponentId
+	strs   mirrors.TypedStrings
+}
+
+func NewRegistry() *ComponentRegistry {
+	r := new(ComponentRegistry)
+	r.infos = make([]ComponentInfo, 1, 128)
+	r.byType = make(map[reflect.Type]entity.ComponentId, 128)
+	r.byName = make(map[string]entity.ComponentId, 128)
+	r.strs = mirrors.NewTypedStrings()
+	return r
+}
+
+func Register[T entity.Component](r *ComponentRegistry, name string) (entity.ComponentId, error) {
+	return r.RegisterType(name, mirrors.TypeOf[T]())
+}
+
+// like Register but panics, intended for static registration lists
+func MustRegister[T entity.Component](r *ComponentRegistry, name string) entity.ComponentId {
+	id, err := Register[T](r, name)
+	if err != nil {
+		panic(err)
+	}
+	return id
+}
+
+// registering the same name and type again is a no-op, reusing either with a
+// different partner is an error
+func (r *ComponentRegistry) RegisterType(name string, typ reflect.Type) (entity.ComponentId, error) {
+	kind := DataComponent
+	if typ.Size() == 0 {
+		kind = TagComponent
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.register(name, typ, kind)
+}
+
+func (r *ComponentRegistry) register(name string, typ reflect.Type, kind ComponentKind) (entity.ComponentId, error) {
+	byName, nameTaken := r.byName[name]
+	byType, typeTaken := r.byType[typ]
+
+	if nameTaken && typeTaken && byName == byType {
+		return byName, nil
+	}
+
+	if nameTaken {
+		return entity.INVALID_COMPONENT, fmt.Errorf("%w: %q is %s", ErrDuplicateComponent, name, r.infos[byName].Type)
+	}
+
+	if typeTaken {
+		return entity.INVALID_COMPONENT, fmt.Errorf("%w: %s is %q", ErrDuplicateComponent, typ, r.infos[byType].Name)
+	}
+
+	id := entity.ComponentId(len(r.infos))
+	r.infos = append(r.infos, ComponentInfo{id, name, typ, kind, AutoStorage})
+	r.byName[name] = id
+	r.byType[typ] = id
+	return id, nil
+}
+
+// chooses how rows for the named component lay out their values, only
+// tables that haven't created the row yet are affected
+func (r *ComponentRegistry) UseStorage(name string, storage Storage) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	id, ok := r.byName[name]
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnregisteredComponent, name)
+	}
+	r.infos[id].Storage = storage
+	return nil
+}
+
+// the type for the literal, registering it if needed. Typed strings are
+// tags even though they're backed by a field
+func (r *ComponentRegistry) TypedString(literal string) (reflect.Type, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	typ := r.strs.Typed(literal)
+	if _, err := r.register(TypedStringPrefix+literal, typ, TagComponent); err != nil {
+		return nil, err
+	}
+	return typ, nil
+}
+
+// registers the literals in sorted order so their ids don't depend on the
+// order they're discovered in
+func (r *ComponentRegistry) TypedStrings(literals ...string) error {
+	sorted := make([]string, len(literals))
+	copy(sorted, literals)
+	sort.Strings(sorted)
+
+	for _, literal := range sorted {
+		if _, err := r.TypedString(literal); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// a component value for the literal, registering it if needed
+func (r *ComponentRegistry) TypedInstance(literal string) (entity.Component, error) {
+	typ, err := r.TypedString(literal)
+	if err != nil {
+		return nil, err
+	}
+	return reflect.New(typ).Elem().Interface(), nil
+}
+
+func (r *ComponentRegistry) IdOf(typ reflect.Type) (entity.ComponentId, error) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.byType[typ]
+	if !ok {
+		return entity.INVALID_COMPONENT, fmt.Errorf("%w: %s", ErrUnregisteredComponent, typ)
+	}
+	return id, nil
+}
+
+func (r *ComponentRegistry) Info(typ reflect.Type) (ComponentInfo, error) {
+	id, err := r.IdOf(typ)
+	if err != nil {
+		return ComponentInfo{}, err
+	}
+
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.infos[id], nil
+}
+
+func (r *ComponentRegistry) Lookup(name string) (ComponentInfo, bool) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	id, ok := r.byName[name]
+	if !ok {
+		return ComponentInfo{}, false
+	}
+	return r.infos[id], true
+}
+
+// every registered component in id order
+func (r *ComponentRegistry) Components() []ComponentInfo {
+	return r.since(1)
+}
+
+func (r *ComponentRegistry) Len() int {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return len(r.infos) - 1
+}
+
+// every component with an id of at least id
+func (r *ComponentRegistry) since(id int) []ComponentInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	if id >= len(r.infos) {
+		return nil
+	}
+
+	infos := make([]ComponentInfo, len(r.infos)-id)
+	copy(infos, r.infos[id:])
+	return infos
+}
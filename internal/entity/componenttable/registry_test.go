@@ -0,0 +1,203 @@
+package componenttable
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+	"github.com/etc-sudonters/substrate/stageleft"
+)
+
+type tag struct{}
+type data struct{ V int }
+type other struct{ V string }
+
+func TestRegistryIdsAreDeterministic(t *testing.T) {
+	build := func(literals ...string) *ComponentRegistry {
+		r := NewRegistry()
+		MustRegister[tag](r, "tag")
+		MustRegister[data](r, "data")
+		if err := r.TypedStrings(literals...); err != nil {
+			t.Fatal(err)
+		}
+		return r
+	}
+
+	first := build("Kokiri_Sword", "Bow", "Hookshot")
+	second := build("Hookshot", "Kokiri_Sword", "Bow")
+
+	if first.Len() != second.Len() {
+		t.Fatalf("expected %d components but got %d", first.Len(), second.Len())
+	}
+
+	for i, info := range first.Components() {
+		other := second.Components()[i]
+		if info.Id != other.Id || info.Name != other.Name {
+			t.Fatalf("expected %d:%q but got %d:%q", info.Id, info.Name, other.Id, other.Name)
+		}
+	}
+
+	if id := MustRegister[tag](first, "tag"); id != 1 {
+		t.Fatalf("expected re-registering to return 1 but got %d", id)
+	}
+}
+
+func TestRegistryRejectsDuplicates(t *testing.T) {
+	r := NewRegistry()
+	MustRegister[data](r, "data")
+
+	if _, err := Register[other](r, "data"); !errors.Is(err, ErrDuplicateComponent) {
+		t.Fatalf("expected %s for reused name but got %v", ErrDuplicateComponent, err)
+	}
+
+	if _, err := Register[data](r, "renamed"); !errors.Is(err, ErrDuplicateComponent) {
+		t.Fatalf("expected %s for reused type but got %v", ErrDuplicateComponent, err)
+	}
+}
+
+func TestRegistryMetadata(t *testing.T) {
+	r := NewRegistry()
+	MustRegister[tag](r, "tag")
+	MustRegister[data](r, "data")
+	typ, err := r.TypedString("Bow")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := map[string]ComponentKind{
+		"tag":                     TagComponent,
+		"data":                    DataComponent,
+		TypedStringPrefix + "Bow": TagComponent,
+	}
+
+	for name, kind := range expected {
+		info, ok := r.Lookup(name)
+		if !ok {
+			t.Fatalf("expected %q to be registered", name)
+		}
+		if info.Kind != kind {
+			t.Fatalf("expected %q to be a %s but was a %s", name, kind, info.Kind)
+		}
+	}
+
+	info, err := r.Info(typ)
+	if err != nil || info.Name != TypedStringPrefix+"Bow" {
+		t.Fatalf("expected typed string to be named by its literal: %+v %v", info, err)
+	}
+
+	if _, err := r.Info(mirrors.TypeOf[other]()); !errors.Is(err, ErrUnregisteredComponent) {
+		t.Fatalf("expected %s but got %v", ErrUnregisteredComponent, err)
+	}
+}
+
+func TestTableFromRegistry(t *testing.T) {
+	r := NewRegistry()
+	tagId := MustRegister[tag](r, "tag")
+	dataId := MustRegister[data](r, "data")
+	tbl := MustFromRegistry(16, r)
+	e := entity.Model(1)
+
+	if tbl.Len() != r.Len()+1 {
+		t.Fatalf("expected rows to be pre-sized to %d but got %d", r.Len()+1, tbl.Len())
+	}
+
+	if id, err := tbl.Set(e, data{1}); err != nil || id != dataId {
+		t.Fatalf("expected data to be stored at %d: %d %v", dataId, id, err)
+	}
+
+	if id, err := tbl.IdOf(mirrors.TypeOf[tag]()); err != nil || id != tagId {
+		t.Fatalf("expected tag to be stored at %d: %d %v", tagId, id, err)
+	}
+
+	if _, err := tbl.Set(e, other{}); !errors.Is(err, ErrUnregisteredComponent) {
+		t.Fatalf("expected %s but got %v", ErrUnregisteredComponent, err)
+	}
+
+	// registered after the table was made
+	MustRegister[other](r, "other")
+	if _, err := tbl.Set(e, other{"late"}); err != nil {
+		t.Fatalf("expected late registrations to be accepted: %v", err)
+	}
+}
+
+func TestTableStats(t *testing.T) {
+	r := NewRegistry()
+	MustRegister[tag](r, "tag")
+	MustRegister[data](r, "data")
+	if err := r.UseStorage("tag", DenseStorage); err != nil {
+		t.Fatal(err)
+	}
+	tbl := MustFromRegistry(128, r)
+
+	for i := 1; i <= 4; i++ {
+		if _, err := tbl.Set(entity.Model(i), data{i}); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := tbl.Set(entity.Model(10), tag{}); err != nil {
+		t.Fatal(err)
+	}
+	tbl.Unset(entity.Model(10), mirrors.TypeOf[tag]())
+	if _, err := tbl.Set(entity.Model(6), tag{}); err != nil {
+		t.Fatal(err)
+	}
+
+	stats := tbl.Stats()
+	if len(stats.Rows) != 2 {
+		t.Fatalf("expected 2 rows but got %d", len(stats.Rows))
+	}
+
+	tagRow, dataRow := stats.Rows[0], stats.Rows[1]
+	if tagRow.Name != "tag" || tagRow.Storage != DenseStorage || tagRow.Population != 1 || tagRow.Capacity != 11 {
+		t.Fatalf("unexpected tag row %+v", tagRow)
+	}
+
+	if dataRow.Name != "data" || dataRow.Storage != SparseStorage || dataRow.Population != 4 || dataRow.Density() != 1 {
+		t.Fatalf("unexpected data row %+v", dataRow)
+	}
+
+	if stats.HighestSlot != 6 {
+		t.Fatalf("expected the highest slot to be 6 but got %d", stats.HighestSlot)
+	}
+
+	if stats.Population != 5 || stats.EntityCapacity != tbl.EntityCapacity() {
+		t.Fatalf("unexpected table stats %+v", stats)
+	}
+}
+
+func TestSetRejectsStrings(t *testing.T) {
+	tbl := New(16)
+	if _, err := tbl.Set(entity.Model(1), "Kokiri Sword"); !errors.Is(err, ErrStringComponent) {
+		t.Fatalf("expected %s but got %v", ErrStringComponent, err)
+	}
+
+	if tbl.Len() != 1 {
+		t.Fatalf("expected no rows to be added but have %d", tbl.Len())
+	}
+}
+
+func TestCorruptedTableReturnsError(t *testing.T) {
+	r := NewRegistry()
+	MustRegister[tag](r, "tag")
+	tbl := MustFromRegistry(16, r)
+
+	// hand out an id without a row to go with it
+	tbl.typemap.Add(mirrors.TypeOf[other]())
+	MustRegister[data](r, "data")
+
+	_, err := tbl.Set(entity.Model(1), data{1})
+	if !errors.Is(err, ErrCorruptedTable) {
+		t.Fatalf("expected %s but got %v", ErrCorruptedTable, err)
+	}
+
+	if code := stageleft.ExitCodeFromErr(err, stageleft.ExitCode(2)); code != 117 {
+		t.Fatalf("expected exit code 117 but got %d", code)
+	}
+
+	if !strings.Contains(err.Error(), "data") {
+		t.Fatalf("expected error to name the component: %s", err)
+	}
+}
@@ -9,25 +9,28 @@ import (
 )
 
 type Row struct {
-	id         entity.ComponentId
-	typ        reflect.Type
-	components []entity.Component
-	members    bitset.Bitset64
+	id      entity.ComponentId
+	typ     reflect.Type
+	storage rowStorage
+	// the storage the row was asked for, auto rows may change layout
+	policy  Storage
+	members bitset.Bitset64
+	// storage only knows slots, the generation of members whose slot has
+	// been recycled is kept here so entries hand back live models
+	generations map[int]uint32
+	version     uint64
 }
 
 func (r *Row) Components() reiterate.Iterator[RowEntry] {
-	i := reiterate.SubsliceIter(r.components, 1)
-	e := reiterate.EnumerateFrom(i, 1)
-	f := reiterate.FilterIter(e, func(i reiterate.Index[entity.Component]) bool {
-		return i.Elem != nil
-	})
-	m := reiterate.MapIter(f, func(i reiterate.Index[entity.Component]) RowEntry {
-		return RowEntry{
-			Entity:    entity.Model(i.Index),
-			Component: i.Elem,
-		}
+	entries := r.storage.components()
+	if len(r.generations) == 0 {
+		return entries
+	}
+	return reiterate.MapIter(entries, func(entry RowEntry) RowEntry {
+		idx := entry.Entity.Index()
+		entry.Entity = entity.NewModel(idx, r.generations[idx])
+		return entry
 	})
-	return m
 }
 
 func (r *Row) Len() int {
@@ -35,44 +38,78 @@ func (r *Row) Len() int {
 }
 
 func (r *Row) Capacity() int {
-	return len(r.components)
+	return r.storage.capacity()
+}
+
+// the layout the row currently uses, never AutoStorage
+func (r *Row) Storage() Storage {
+	return r.storage.layout()
 }
 
-func (r *Row) Init(id entity.ComponentId, entityBuckets int) {
+func (r *Row) Init(id entity.ComponentId, entityBuckets int, storage Storage) {
 	r.id = id
-	r.components = make([]entity.Component, 0)
+	r.policy = storage
+	r.storage = newStorage(storage)
 	r.members = bitset.New(entityBuckets)
 }
 
+// rows are indexed by the model's slot, telling live models from stale ones
+// is the pool's concern
 func (row *Row) Set(e entity.Model, c entity.Component) {
-	row.EnsureSize(int(e))
-	row.components[e] = c
-	row.members.Set(int(e))
+	idx := e.Index()
+	row.storage.set(idx, c)
+	row.members.Set(idx)
+	row.remember(e)
+
+	if row.policy == AutoStorage && row.storage.layout() == SparseStorage {
+		population := row.members.Len()
+		if population >= densifyPopulation && population*densifyRatio >= row.storage.span() {
+			row.storage = densify(row.storage)
+		}
+	}
 }
 
 func (row *Row) Unset(e entity.Model) {
-	if len(row.components) < int(e) {
+	idx := e.Index()
+	row.storage.unset(idx)
+	row.members.Clear(idx)
+	delete(row.generations, idx)
+}
+
+func (row *Row) remember(e entity.Model) {
+	gen := e.Generation()
+	if gen == 0 {
+		delete(row.generations, e.Index())
 		return
 	}
-
-	row.components[e] = nil
-	row.members.Clear(int(e))
+	if row.generations == nil {
+		row.generations = make(map[int]uint32)
+	}
+	row.generations[e.Index()] = gen
 }
 
 func (row Row) Get(e entity.Model) entity.Component {
-	if !row.members.Test(int(e)) {
+	idx := e.Index()
+	if !row.members.Test(idx) {
 		return nil
 	}
 
-	return row.components[e]
+	return row.storage.get(idx)
 }
 
-func (row *Row) EnsureSize(n int) {
-	if len(row.components) > n {
-		return
-	}
+func (row Row) Has(e entity.Model) bool {
+	return row.members.Test(e.Index())
+}
 
-	expaded := make([]entity.Component, n+1, n*2)
-	copy(expaded, row.components)
-	row.components = expaded
+func (row Row) clone() *Row {
+	clone := row
+	clone.storage = row.storage.clone()
+	clone.members = bitset.Copy(row.members)
+	if row.generations != nil {
+		clone.generations = make(map[int]uint32, len(row.generations))
+		for idx, gen := range row.generations {
+			clone.generations[idx] = gen
+		}
+	}
+	return &clone
 }
@@ -0,0 +1,197 @@
+package componenttable
+
+import (
+	"fmt"
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+
+	"github.com/etc-sudonters/substrate/reiterate"
+	"github.com/etc-sudonters/substrate/skelly/bitset"
+)
+
+func newRow(storage Storage) *Row {
+	r := new(Row)
+	r.Init(1, bitset.Buckets(10000), storage)
+	return r
+}
+
+func TestRowStorageAgrees(t *testing.T) {
+	for _, storage := range []Storage{DenseStorage, SparseStorage} {
+		t.Run(storage.String(), func(t *testing.T) {
+			r := newRow(storage)
+			for _, slot := range []int{700, 3, 64, 65, 9000} {
+				r.Set(entity.Model(slot), data{slot})
+			}
+			r.Unset(entity.Model(64))
+			r.Unset(entity.Model(12))
+			r.Set(entity.Model(3), data{-3})
+
+			if r.Len() != 4 {
+				t.Fatalf("expected 4 members but got %d", r.Len())
+			}
+
+			if c := r.Get(entity.Model(3)); c != (data{-3}) {
+				t.Fatalf("expected overwritten value but got %v", c)
+			}
+
+			if c := r.Get(entity.Model(64)); c != nil {
+				t.Fatalf("expected unset slot to be empty but got %v", c)
+			}
+
+			entries := reiterate.ToSlice(r.Components())
+			expected := []int{3, 65, 700, 9000}
+			if len(entries) != len(expected) {
+				t.Fatalf("expected %d entries but got %+v", len(expected), entries)
+			}
+			for i, entry := range entries {
+				if entry.Entity.Index() != expected[i] {
+					t.Fatalf("expected entries ordered by slot but got %+v", entries)
+				}
+			}
+
+			clone := r.clone()
+			clone.Unset(entity.Model(9000))
+			if r.Get(entity.Model(9000)) == nil {
+				t.Fatal("expected clone to be independent of its row")
+			}
+		})
+	}
+}
+
+func TestAutoStorageDensifies(t *testing.T) {
+	r := newRow(AutoStorage)
+	r.Set(entity.Model(5000), data{})
+	if r.Storage() != SparseStorage {
+		t.Fatalf("expected auto rows to start sparse but was %s", r.Storage())
+	}
+
+	for slot := 1; slot <= densifyPopulation; slot++ {
+		r.Set(entity.Model(slot), data{slot})
+	}
+	if r.Storage() != SparseStorage {
+		t.Fatalf("expected a scattered row to stay sparse but was %s", r.Storage())
+	}
+
+	r.Unset(entity.Model(5000))
+	for slot := densifyPopulation + 1; r.Storage() == SparseStorage; slot++ {
+		if slot > 5000 {
+			t.Fatal("expected row to densify")
+		}
+		r.Set(entity.Model(slot), data{slot})
+	}
+
+	for slot := 1; slot <= densifyPopulation; slot++ {
+		if c := r.Get(entity.Model(slot)); c != (data{slot}) {
+			t.Fatalf("expected %d to survive densifying but got %v", slot, c)
+		}
+	}
+
+	if r.Get(entity.Model(5000)) != nil {
+		t.Fatal("expected unset slot to stay empty after densifying")
+	}
+}
+
+// entries hand back the model that set the slot, not just the slot
+func TestRowEntriesKeepGenerations(t *testing.T) {
+	for _, storage := range []Storage{DenseStorage, SparseStorage} {
+		t.Run(storage.String(), func(t *testing.T) {
+			r := newRow(storage)
+			recycled := entity.NewModel(3, 2)
+			r.Set(entity.Model(1), data{1})
+			r.Set(recycled, data{3})
+
+			entries := reiterate.ToSlice(r.Components())
+			if len(entries) != 2 || entries[0].Entity != entity.Model(1) || entries[1].Entity != recycled {
+				t.Fatalf("expected entries for %s and %s but got %+v", entity.Model(1), recycled, entries)
+			}
+
+			r.Unset(recycled)
+			r.Set(recycled.Next(), data{3})
+			clone := r.clone()
+			r.Set(recycled.Next().Next(), data{3})
+
+			entries = reiterate.ToSlice(clone.Components())
+			if entries[1].Entity != recycled.Next() {
+				t.Fatalf("expected the clone to keep %s but got %+v", recycled.Next(), entries)
+			}
+		})
+	}
+}
+
+// a handful of entities scattered across the table, like most location
+// categories
+var sparseSlots = func() []int {
+	slots := make([]int, 16)
+	for i := range slots {
+		slots[i] = 1 + i*613
+	}
+	return slots
+}()
+
+var denseSlots = func() []int {
+	slots := make([]int, 9000)
+	for i := range slots {
+		slots[i] = i + 1
+	}
+	return slots
+}()
+
+func BenchmarkRowSet(b *testing.B) {
+	for _, population := range []struct {
+		name  string
+		slots []int
+	}{{"scattered", sparseSlots}, {"populated", denseSlots}} {
+		for _, storage := range []Storage{DenseStorage, SparseStorage, AutoStorage} {
+			b.Run(fmt.Sprintf("%s/%s", population.name, storage), func(b *testing.B) {
+				b.ReportAllocs()
+				var r *Row
+				for i := 0; i < b.N; i++ {
+					r = newRow(storage)
+					for _, slot := range population.slots {
+						r.Set(entity.Model(slot), data{slot})
+					}
+				}
+				b.ReportMetric(float64(r.storage.bytes()), "bytes/row")
+			})
+		}
+	}
+}
+
+func BenchmarkRowGet(b *testing.B) {
+	for _, storage := range []Storage{DenseStorage, SparseStorage} {
+		b.Run(storage.String(), func(b *testing.B) {
+			r := newRow(storage)
+			for _, slot := range sparseSlots {
+				r.Set(entity.Model(slot), data{slot})
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for slot := 1; slot < 10000; slot += 7 {
+					_ = r.Get(entity.Model(slot))
+				}
+			}
+		})
+	}
+}
+
+func BenchmarkRowComponents(b *testing.B) {
+	for _, storage := range []Storage{DenseStorage, SparseStorage} {
+		b.Run(storage.String(), func(b *testing.B) {
+			r := newRow(storage)
+			for _, slot := range sparseSlots {
+				r.Set(entity.Model(slot), data{slot})
+			}
+
+			b.ReportAllocs()
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				for it := r.Components(); it.MoveNext(); {
+					_ = it.Current()
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package componenttable
+
+import (
+	"sudonters/zootler/internal/entity"
+)
+
+// the rows of a table as they were at some point in time, rows are shared
+// with the table until the table next writes to them
+type Snapshot struct {
+	table *Table
+	rows  []*Row
+}
+
+func (t *Table) Snapshot() Snapshot {
+	rows := make([]*Row, len(t.rows))
+	copy(rows, t.rows)
+	t.version++
+	return Snapshot{t, rows}
+}
+
+// rows registered after the snapshot was taken are emptied rather than
+// dropped so component ids remain stable
+func (t *Table) Restore(s Snapshot) error {
+	if s.table != t {
+		return entity.ErrForeignSnapshot
+	}
+
+	t.version++
+	for i, r := range t.rows {
+		if r == nil {
+			continue
+		}
+
+		if i < len(s.rows) {
+			t.rows[i] = s.rows[i]
+			continue
+		}
+
+		empty := new(Row)
+		empty.Init(r.id, t.entityBuckets, r.policy)
+		empty.typ = r.typ
+		empty.version = t.version
+		t.rows[i] = empty
+	}
+
+	return nil
+}
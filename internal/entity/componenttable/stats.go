@@ -0,0 +1,108 @@
+package componenttable
+
+import (
+	"reflect"
+	"sudonters/zootler/internal/entity"
+	"unsafe"
+)
+
+var (
+	componentSize   = int(unsafe.Sizeof(entity.Component(nil)))
+	sliceHeaderSize = int(unsafe.Sizeof([]int32(nil)))
+	intSize         = int(unsafe.Sizeof(int(0)))
+)
+
+const bucketSize = 8
+
+type RowStats struct {
+	Id   entity.ComponentId
+	Type reflect.Type
+	// empty unless the table was built from a registry
+	Name string
+	// entities that have the component
+	Population int
+	// slots the row has allocated, dense rows grow to the highest slot ever
+	// stored and sparse rows to their population
+	Capacity int
+	Buckets  int
+	Bytes    int
+	Storage  Storage
+}
+
+// how much of the row's allocation is in use
+func (r RowStats) Density() float64 {
+	if r.Capacity == 0 {
+		return 0
+	}
+	return float64(r.Population) / float64(r.Capacity)
+}
+
+type Stats struct {
+	Rows []RowStats
+	// the number of entity slots the table was configured for
+	EntityCapacity int
+	EntityBuckets  int
+	// the highest slot any row holds a component for, 0 if the table is empty
+	HighestSlot int
+	Population  int
+	Capacity    int
+	Bytes       int
+}
+
+// the population against the capacity across every row, sparse tables spend
+// most of their memory on empty slots
+func (s Stats) Density() float64 {
+	if s.Capacity == 0 {
+		return 0
+	}
+	return float64(s.Population) / float64(s.Capacity)
+}
+
+// rows are reported in id order, bytes are an estimate of what each row
+// holds onto and don't account for the components' own allocations
+func (t *Table) Stats() Stats {
+	stats := Stats{
+		EntityCapacity: t.EntityCapacity(),
+		EntityBuckets:  t.entityBuckets,
+	}
+
+	for rows := t.Rows(); rows.MoveNext(); {
+		row := rows.Current()
+		rs := RowStats{
+			Id:         row.Id(),
+			Type:       row.Type(),
+			Population: row.Len(),
+			Capacity:   row.Capacity(),
+			Buckets:    t.entityBuckets,
+			Storage:    row.Storage(),
+		}
+		rs.Bytes = row.r.storage.bytes() + rs.Buckets*bucketSize
+
+		if t.registry != nil {
+			if info, err := t.registry.Info(rs.Type); err == nil {
+				rs.Name = info.Name
+			}
+		}
+
+		if row.r.storage.span()-1 > stats.HighestSlot {
+			stats.HighestSlot = highestSlot(row.r, stats.HighestSlot)
+		}
+
+		stats.Rows = append(stats.Rows, rs)
+		stats.Population += rs.Population
+		stats.Capacity += rs.Capacity
+		stats.Bytes += rs.Bytes
+	}
+
+	return stats
+}
+
+// unsetting doesn't shrink rows so the highest slot has to be found
+func highestSlot(r *Row, floor int) int {
+	for i := r.storage.span() - 1; i > floor; i-- {
+		if r.members.Test(i) {
+			return i
+		}
+	}
+	return floor
+}
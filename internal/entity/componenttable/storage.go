@@ -0,0 +1,265 @@
+package componenttable
+
+import (
+	"sort"
+	"sudonters/zootler/internal/entity"
+
+	"github.com/etc-sudonters/substrate/reiterate"
+)
+
+// how a row lays out its components
+type Storage uint8
+
+const (
+	// starts sparse and becomes dense once enough entities have the component
+	AutoStorage Storage = iota
+	// indexed directly by slot, grows to the highest slot stored
+	DenseStorage
+	// packed with a paged index, grows with the number of entities stored
+	SparseStorage
+)
+
+func (s Storage) String() string {
+	switch s {
+	case AutoStorage:
+		return "auto"
+	case DenseStorage:
+		return "dense"
+	case SparseStorage:
+		return "sparse"
+	default:
+		return "unknown"
+	}
+}
+
+// auto rows become dense once they're at least half populated, before then
+// a dense row spends more on empty slots than a sparse row spends on its
+// index. Small rows stay sparse regardless
+const (
+	densifyPopulation = 64
+	densifyRatio      = 2
+)
+
+// rows track membership themselves, storage only holds components for slots
+// that are members
+type rowStorage interface {
+	layout() Storage
+	get(idx int) entity.Component
+	set(idx int, c entity.Component)
+	unset(idx int)
+	// every stored component ordered by slot, entries only carry the slot
+	components() reiterate.Iterator[RowEntry]
+	// the number of component slots allocated
+	capacity() int
+	// one past the highest slot that may be stored
+	span() int
+	bytes() int
+	clone() rowStorage
+}
+
+func newStorage(s Storage) rowStorage {
+	if s == DenseStorage {
+		return &denseStorage{make([]entity.Component, 0)}
+	}
+	return new(sparseStorage)
+}
+
+type denseStorage struct {
+	comps []entity.Component
+}
+
+func (d *denseStorage) layout() Storage { return DenseStorage }
+
+func (d *denseStorage) get(idx int) entity.Component {
+	if idx >= len(d.comps) {
+		return nil
+	}
+	return d.comps[idx]
+}
+
+func (d *denseStorage) set(idx int, c entity.Component) {
+	d.ensureSize(idx)
+	d.comps[idx] = c
+}
+
+func (d *denseStorage) unset(idx int) {
+	if len(d.comps) <= idx {
+		return
+	}
+	d.comps[idx] = nil
+}
+
+func (d *denseStorage) components() reiterate.Iterator[RowEntry] {
+	return &denseIter{comps: d.comps}
+}
+
+func (d *denseStorage) capacity() int { return len(d.comps) }
+func (d *denseStorage) span() int     { return len(d.comps) }
+func (d *denseStorage) bytes() int    { return cap(d.comps) * componentSize }
+
+func (d *denseStorage) clone() rowStorage {
+	clone := &denseStorage{make([]entity.Component, len(d.comps), cap(d.comps))}
+	copy(clone.comps, d.comps)
+	return clone
+}
+
+func (d *denseStorage) ensureSize(n int) {
+	if len(d.comps) > n {
+		return
+	}
+
+	if cap(d.comps) > n {
+		d.comps = d.comps[:n+1]
+		return
+	}
+
+	expaded := make([]entity.Component, n+1, n*2)
+	copy(expaded, d.comps)
+	d.comps = expaded
+}
+
+// skips empty slots, slot 0 is never occupied
+type denseIter struct {
+	comps []entity.Component
+	idx   int
+}
+
+func (d *denseIter) MoveNext() bool {
+	for d.idx++; d.idx < len(d.comps); d.idx++ {
+		if d.comps[d.idx] != nil {
+			return true
+		}
+	}
+	return false
+}
+
+func (d *denseIter) Current() RowEntry {
+	return RowEntry{Entity: entity.Model(d.idx), Component: d.comps[d.idx]}
+}
+
+const pageSize = 64
+
+// a sparse set, pages map slots to positions in the packed slices and are
+// only allocated once a slot in them is stored. Unsetting swaps the last
+// component into the vacated position
+type sparseStorage struct {
+	// positions are offset by one so a zeroed page is empty
+	pages [][]int32
+	slots []int
+	comps []entity.Component
+	// unsetting doesn't lower it
+	high int
+}
+
+func (s *sparseStorage) layout() Storage { return SparseStorage }
+
+func (s *sparseStorage) position(idx int) int {
+	page := idx / pageSize
+	if page >= len(s.pages) || s.pages[page] == nil {
+		return -1
+	}
+	return int(s.pages[page][idx%pageSize]) - 1
+}
+
+func (s *sparseStorage) get(idx int) entity.Component {
+	pos := s.position(idx)
+	if pos < 0 {
+		return nil
+	}
+	return s.comps[pos]
+}
+
+func (s *sparseStorage) set(idx int, c entity.Component) {
+	if pos := s.position(idx); pos >= 0 {
+		s.comps[pos] = c
+		return
+	}
+
+	page := idx / pageSize
+	if page >= len(s.pages) {
+		pages := make([][]int32, page+1)
+		copy(pages, s.pages)
+		s.pages = pages
+	}
+	if s.pages[page] == nil {
+		s.pages[page] = make([]int32, pageSize)
+	}
+
+	s.slots = append(s.slots, idx)
+	s.comps = append(s.comps, c)
+	s.pages[page][idx%pageSize] = int32(len(s.slots))
+	if idx >= s.high {
+		s.high = idx + 1
+	}
+}
+
+func (s *sparseStorage) unset(idx int) {
+	pos := s.position(idx)
+	if pos < 0 {
+		return
+	}
+
+	last := len(s.slots) - 1
+	moved := s.slots[last]
+	s.slots[pos] = moved
+	s.comps[pos] = s.comps[last]
+	s.pages[moved/pageSize][moved%pageSize] = int32(pos + 1)
+
+	s.comps[last] = nil
+	s.slots = s.slots[:last]
+	s.comps = s.comps[:last]
+	s.pages[idx/pageSize][idx%pageSize] = 0
+}
+
+// the packed slices are unordered so iterating sorts a copy of them
+func (s *sparseStorage) components() reiterate.Iterator[RowEntry] {
+	entries := make([]RowEntry, len(s.slots))
+	for i, slot := range s.slots {
+		entries[i] = RowEntry{Entity: entity.Model(slot), Component: s.comps[i]}
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Entity < entries[j].Entity })
+	return reiterate.SliceIter(entries)
+}
+
+func (s *sparseStorage) capacity() int { return len(s.comps) }
+
+func (s *sparseStorage) span() int { return s.high }
+
+func (s *sparseStorage) bytes() int {
+	size := cap(s.pages)*sliceHeaderSize + cap(s.slots)*intSize + cap(s.comps)*componentSize
+	for _, page := range s.pages {
+		size += cap(page) * 4
+	}
+	return size
+}
+
+func (s *sparseStorage) clone() rowStorage {
+	clone := &sparseStorage{
+		pages: make([][]int32, len(s.pages)),
+		slots: make([]int, len(s.slots), cap(s.slots)),
+		comps: make([]entity.Component, len(s.comps), cap(s.comps)),
+		high:  s.high,
+	}
+	for i, page := range s.pages {
+		if page != nil {
+			clone.pages[i] = make([]int32, pageSize)
+			copy(clone.pages[i], page)
+		}
+	}
+	copy(clone.slots, s.slots)
+	copy(clone.comps, s.comps)
+	return clone
+}
+
+// copies sparse storage into dense storage
+func densify(s rowStorage) rowStorage {
+	dense := &denseStorage{make([]entity.Component, 0)}
+	if span := s.span(); span > 0 {
+		dense.ensureSize(span - 1)
+	}
+	for entries := s.components(); entries.MoveNext(); {
+		entry := entries.Current()
+		dense.comps[entry.Entity.Index()] = entry.Component
+	}
+	return dense
+}
@@ -13,6 +13,7 @@ import (
 
 var strType reflect.Type = mirrors.TypeOf[string]()
 var ErrCorruptedTable = errors.New("table became corrupted")
+var ErrStringComponent = errors.New("bare strings cannot be components")
 
 type componentGetter struct {
 	*Table
@@ -33,37 +34,94 @@ func New(maxEntities int) *Table {
 	return t
 }
 
+// every component registered with reg gets a row up front and components
+// that aren't registered are rejected
+func FromRegistry(maxEntities int, reg *ComponentRegistry) (*Table, error) {
+	t := New(maxEntities)
+	t.registry = reg
+	if err := t.sync(); err != nil {
+		return nil, err
+	}
+	return t, nil
+}
+
+// like FromRegistry but panics, a new table's rows can only disagree with
+// its type map if the table itself is broken
+func MustFromRegistry(maxEntities int, reg *ComponentRegistry) *Table {
+	t, err := FromRegistry(maxEntities, reg)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
 type Table struct {
 	entityBuckets int
 	rows          []*Row
 	typemap       mirrors.TypeMap
 	getter        entity.ComponentGetter
+	registry      *ComponentRegistry
+	// rows stamped with an older version are shared with a snapshot and
+	// must be cloned before they're written to
+	version uint64
 }
 
-func (t *Table) Set(e entity.Model, c entity.Component) entity.ComponentId {
+func (t *Table) Set(e entity.Model, c entity.Component) (entity.ComponentId, error) {
 	typ := entity.PierceComponentType(c)
 	if typ == strType {
-		panic(fmt.Errorf("string component added to %d: %q", e, c))
+		return entity.INVALID_COMPONENT, fmt.Errorf("%w: %q added to %s", ErrStringComponent, c, e)
+	}
+
+	r, err := t.RowOf(typ)
+	if err != nil {
+		return entity.INVALID_COMPONENT, fmt.Errorf("adding %s to %s: %w", t.nameOf(typ), e, err)
 	}
-	row := t.RowOf(typ)
+
+	row := t.writable(r)
 	row.Set(e, c)
-	return row.id
+	return row.id, nil
 }
 
 func (t *Table) Unset(e entity.Model, typ reflect.Type) entity.ComponentId {
 	if r := t.rowFor(typ); r != nil {
-		r.Unset(e)
+		if r.Has(e) {
+			t.writable(r).Unset(e)
+		}
 		return r.id
 	}
 	return entity.INVALID_COMPONENT
 }
 
+// removes the entity from every row, returns the ids of the rows it was removed from
+func (t *Table) UnsetAll(e entity.Model) []entity.ComponentId {
+	var removed []entity.ComponentId
+	for _, r := range t.rows {
+		if r == nil || !r.Has(e) {
+			continue
+		}
+		t.writable(r).Unset(e)
+		removed = append(removed, r.id)
+	}
+	return removed
+}
+
+// the number of entity slots each row can track
+func (t Table) EntityCapacity() int {
+	return t.entityBuckets * 64
+}
+
+// components registered since the table last synced share their registry
+// id even though they don't have a row yet
 func (t *Table) IdOf(typ reflect.Type) (entity.ComponentId, error) {
-	id, err := t.typemap.IdOf(typ)
-	if err != nil {
-		return 0, entity.ErrUnknownComponent{T: typ}
+	if r := t.rowFor(typ); r != nil {
+		return r.id, nil
 	}
-	return entity.ComponentId(id), nil
+	if t.registry != nil {
+		if id, err := t.registry.IdOf(typ); err == nil {
+			return id, nil
+		}
+	}
+	return 0, entity.ErrUnknownComponent{T: typ}
 }
 
 func (t *Table) Get(e entity.Model, typ reflect.Type) (entity.Component, error) {
@@ -88,7 +146,14 @@ func (t Table) Len() int {
 	return len(t.rows)
 }
 
-func (t Table) rowFor(typ reflect.Type) *Row {
+// the registry controls the table's rows when present
+func (t *Table) Registry() *ComponentRegistry {
+	return t.registry
+}
+
+// only reads the table so it's safe to call alongside other readers, rows
+// for newly registered components are added by writes
+func (t *Table) rowFor(typ reflect.Type) *Row {
 	id, err := t.typemap.IdOf(typ)
 	if err != nil {
 		return nil
@@ -101,20 +166,75 @@ func (t Table) rowFor(typ reflect.Type) *Row {
 	return nil
 }
 
-func (t *Table) RowOf(typ reflect.Type) *Row {
+// tables built from a registry refuse to create rows for unregistered
+// components, register the component instead. Rows for components added to
+// tables without a registry choose their storage automatically
+func (t *Table) RowOf(typ reflect.Type) (*Row, error) {
 	if r := t.rowFor(typ); r != nil {
-		return r
+		return r, nil
+	}
+
+	if t.registry != nil {
+		if err := t.sync(); err != nil {
+			return nil, err
+		}
+		if r := t.rowFor(typ); r != nil {
+			return r, nil
+		}
+		return nil, fmt.Errorf("%w: %s", ErrUnregisteredComponent, typ)
 	}
 
+	return t.addRow(typ, AutoStorage)
+}
+
+// the table is corrupted if the type map hands out an id that isn't the next
+// row, the error carries exit code 117 for the CLIs
+func (t *Table) addRow(typ reflect.Type, storage Storage) (*Row, error) {
 	id := t.typemap.Add(typ)
 
 	if len(t.rows) != int(id) {
-		panic(stageleft.AttachExitCode(ErrCorruptedTable, stageleft.ExitCode(117)))
+		err := fmt.Errorf("%w: %s was assigned id %d but the next row is %d", ErrCorruptedTable, t.nameOf(typ), id, len(t.rows))
+		return nil, stageleft.AttachExitCode(err, stageleft.ExitCode(117))
 	}
 
 	r := new(Row)
-	r.Init(entity.ComponentId(id), t.entityBuckets)
+	r.Init(entity.ComponentId(id), t.entityBuckets, storage)
 	t.rows = append(t.rows, r)
 	r.typ = typ
-	return r
+	r.version = t.version
+	return r, nil
+}
+
+// the registered name if there is one
+func (t *Table) nameOf(typ reflect.Type) string {
+	if t.registry != nil {
+		if info, err := t.registry.Info(typ); err == nil {
+			return info.Name
+		}
+	}
+	return typ.String()
+}
+
+// returns a row that is safe to mutate, cloning it if a snapshot still
+// shares it
+func (t *Table) writable(r *Row) *Row {
+	if r.version == t.version {
+		return r
+	}
+
+	clone := r.clone()
+	clone.version = t.version
+	t.rows[int(r.id)] = clone
+	return clone
+}
+
+// adds rows for components registered since the last sync in id order so
+// row ids and registry ids agree
+func (t *Table) sync() error {
+	for _, info := range t.registry.since(len(t.rows)) {
+		if _, err := t.addRow(info.Type, info.Storage); err != nil {
+			return err
+		}
+	}
+	return nil
 }
@@ -5,10 +5,38 @@ import (
 )
 
 // a member of a pool's population
+// the low bits identify the member's slot in the pool and the high bits are
+// the slot's generation which is bumped every time the slot is recycled, this
+// lets pools tell a stale handle apart from the slot's current occupant
 type Model uint64
 
 const INVALID_ENTITY Model = 0
 
+const generationShift = 32
+const indexMask Model = 1<<generationShift - 1
+
+func NewModel(index int, generation uint32) Model {
+	return Model(generation)<<generationShift | (Model(index) & indexMask)
+}
+
+// the slot this model occupies
+func (m Model) Index() int {
+	return int(m & indexMask)
+}
+
+// how many times this model's slot has been recycled
+func (m Model) Generation() uint32 {
+	return uint32(m >> generationShift)
+}
+
+// the model that will occupy this slot after it is recycled
+func (m Model) Next() Model {
+	return NewModel(m.Index(), m.Generation()+1)
+}
+
 func (m Model) String() string {
+	if gen := m.Generation(); gen != 0 {
+		return fmt.Sprintf("Model{%d@%d}", m.Index(), gen)
+	}
 	return fmt.Sprintf("Model{%d}", m)
 }
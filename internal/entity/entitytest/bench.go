@@ -0,0 +1,135 @@
+package entitytest
+
+import (
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+)
+
+// component shapes loosely modeled after the world: a handful of tags shared by
+// many entities and a few that are rare
+type (
+	sweepLocation  struct{}
+	sweepToken     struct{}
+	sweepCollected struct{}
+	sweepEdge      struct{ From, To entity.Model }
+	sweepRare      struct{}
+)
+
+// populates a pool roughly the shape of a loaded world
+func populate(b *testing.B, p entity.Pool, locations int) {
+	b.Helper()
+	for i := 0; i < locations; i++ {
+		loc, err := p.Create()
+		if err != nil {
+			b.Fatal(err)
+		}
+		loc.Add(sweepLocation{})
+
+		tok, err := p.Create()
+		if err != nil {
+			b.Fatal(err)
+		}
+		tok.Add(sweepToken{})
+		if i%50 == 0 {
+			tok.Add(sweepRare{})
+		}
+
+		edge, err := p.Create()
+		if err != nil {
+			b.Fatal(err)
+		}
+		edge.Add(sweepEdge{loc.Model(), tok.Model()})
+	}
+}
+
+// exercises the query patterns the filler leans on without a world: every
+// sweep asks how many of a token have been collected, then collects more
+// tokens and repeats. The filler's own loop is benchmarked against both pools
+// by pkg/filler's BenchmarkReachabilitySweep, it can't live here since
+// pkg/world imports the pools
+func BenchmarkSyntheticSweep(b *testing.B, mk PoolFactory, locations int) {
+	uncollected := entity.BuildFilter().
+		With(mirrors.TypeOf[sweepToken]()).
+		Without(mirrors.TypeOf[sweepCollected]()).
+		Build()
+	collected := entity.BuildFilter().
+		With(mirrors.TypeOf[sweepToken]()).
+		With(mirrors.TypeOf[sweepCollected]())
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		p := mk()
+		populate(b, p, locations)
+		if v, err := p.Create(); err == nil {
+			v.Add(sweepCollected{})
+		}
+		b.StartTimer()
+
+		for {
+			remaining, err := p.Query(uncollected)
+			if err != nil {
+				break
+			}
+
+			if _, err := entity.Query1[sweepEdge](p, entity.BuildFilter()); err != nil {
+				b.Fatal(err)
+			}
+
+			// nothing rare collected yet is fine
+			_, _ = p.Query(collected.Clone().With(mirrors.TypeOf[sweepRare]()).Build())
+
+			for _, r := range remaining[:len(remaining)/4+1] {
+				r.Add(sweepCollected{})
+			}
+		}
+	}
+}
+
+// the same sweep with the collected count answered by a prepared query
+// rather than a fresh query on every pass
+func BenchmarkPreparedSweep(b *testing.B, mk PoolFactory, locations int) {
+	uncollected := entity.BuildFilter().
+		With(mirrors.TypeOf[sweepToken]()).
+		Without(mirrors.TypeOf[sweepCollected]()).
+		Build()
+	collectedRare := entity.BuildFilter().
+		With(mirrors.TypeOf[sweepToken]()).
+		With(mirrors.TypeOf[sweepCollected]()).
+		With(mirrors.TypeOf[sweepRare]()).
+		Build()
+
+	for i := 0; i < b.N; i++ {
+		b.StopTimer()
+		p := mk()
+		populate(b, p, locations)
+		if v, err := p.Create(); err == nil {
+			v.Add(sweepCollected{})
+		}
+		rare, err := p.Prepare(collectedRare)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.StartTimer()
+
+		for {
+			remaining, err := p.Query(uncollected)
+			if err != nil {
+				break
+			}
+
+			if _, err := entity.Query1[sweepEdge](p, entity.BuildFilter()); err != nil {
+				b.Fatal(err)
+			}
+
+			_ = rare.Count()
+
+			for _, r := range remaining[:len(remaining)/4+1] {
+				r.Add(sweepCollected{})
+			}
+		}
+		rare.Close()
+	}
+}
@@ -0,0 +1,1021 @@
+// shared behavior every entity.Pool implementation must exhibit, pool
+// packages run these from their own tests so each backend is held to the
+// same contract
+package entitytest
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+	"github.com/etc-sudonters/substrate/reiterate"
+	"github.com/etc-sudonters/substrate/skelly/hashset"
+)
+
+type PoolFactory func() entity.Pool
+
+type Tagged struct {
+	V int
+}
+
+type Weighted struct {
+	K float64
+}
+
+type Marker struct{}
+
+func Conformance(t *testing.T, mk PoolFactory) {
+	t.Run("StoreAndRetrieveValue", func(t *testing.T) { storeAndRetrieveValue(t, mk()) })
+	t.Run("StoreAndRetrievePointer", func(t *testing.T) { storeAndRetrievePointer(t, mk()) })
+	t.Run("OverwriteComponent", func(t *testing.T) { overwriteComponent(t, mk()) })
+	t.Run("RemoveComponent", func(t *testing.T) { removeComponent(t, mk()) })
+	t.Run("QueryWith", func(t *testing.T) { queryWith(t, mk()) })
+	t.Run("QueryWithout", func(t *testing.T) { queryWithout(t, mk()) })
+	t.Run("QueryNoMatches", func(t *testing.T) { queryNoMatches(t, mk()) })
+	t.Run("QueryUnknownComponent", func(t *testing.T) { queryUnknownComponent(t, mk()) })
+	t.Run("QueryAnyOf", func(t *testing.T) { queryAnyOf(t, mk()) })
+	t.Run("QueryOptional", func(t *testing.T) { queryOptional(t, mk()) })
+	t.Run("QueryPredicate", func(t *testing.T) { queryPredicate(t, mk()) })
+	t.Run("FetchUnknownEntity", func(t *testing.T) { fetchUnknownEntity(t, mk()) })
+	t.Run("GetManyFromPool", func(t *testing.T) { getManyFromPool(t, mk()) })
+	t.Run("TypedAccessors", func(t *testing.T) { typedAccessors(t, mk()) })
+	t.Run("TypedQueries", func(t *testing.T) { typedQueries(t, mk()) })
+	t.Run("DestroyEntity", func(t *testing.T) { destroyEntity(t, mk()) })
+	t.Run("DestroyRecyclesIds", func(t *testing.T) { destroyRecyclesIds(t, mk()) })
+	t.Run("StaleHandles", func(t *testing.T) { staleHandles(t, mk()) })
+	t.Run("RestoreComponents", func(t *testing.T) { restoreComponents(t, mk()) })
+	t.Run("RestoreLifecycle", func(t *testing.T) { restoreLifecycle(t, mk()) })
+	t.Run("RestoreRepeatedly", func(t *testing.T) { restoreRepeatedly(t, mk()) })
+	t.Run("RestoreForeignSnapshot", func(t *testing.T) { restoreForeignSnapshot(t, mk(), mk()) })
+	t.Run("ObserveTypedChanges", func(t *testing.T) { observeTypedChanges(t, mk()) })
+	t.Run("ObserveDestroy", func(t *testing.T) { observeDestroy(t, mk()) })
+	t.Run("JournalSince", func(t *testing.T) { journalSince(t, mk()) })
+	t.Run("PreparedQueryTracksChanges", func(t *testing.T) { preparedQueryTracksChanges(t, mk()) })
+	t.Run("PreparedQueryLateComponents", func(t *testing.T) { preparedQueryLateComponents(t, mk()) })
+	t.Run("RelateModels", func(t *testing.T) { relateModels(t, mk()) })
+	t.Run("RelationsCleanedOnDestroy", func(t *testing.T) { relationsCleanedOnDestroy(t, mk()) })
+	t.Run("RestoreRelations", func(t *testing.T) { restoreRelations(t, mk()) })
+	t.Run("ConcurrentAccess", func(t *testing.T) { concurrentAccess(t, mk()) })
+	t.Run("BatchMutations", func(t *testing.T) { batchMutations(t, mk()) })
+}
+
+func create(t *testing.T, p entity.Pool) entity.View {
+	t.Helper()
+	v, err := p.Create()
+	if err != nil {
+		t.Fatalf("could not create entity: %s", err)
+	}
+	return v
+}
+
+func add(t *testing.T, v entity.View, c entity.Component) {
+	t.Helper()
+	if err := v.Add(c); err != nil {
+		t.Fatalf("could not add %T to %d: %s", c, v.Model(), err)
+	}
+}
+
+func query(t *testing.T, p entity.Pool, fb entity.FilterBuilder) hashset.Hash[entity.Model] {
+	t.Helper()
+	views, err := p.Query(fb.Build())
+	if err != nil && !errors.Is(err, entity.ErrNoEntities) {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	return hashset.MapFromSlice(views, entity.View.Model)
+}
+
+func setsEqual(t *testing.T, expected, actual hashset.Hash[entity.Model]) {
+	t.Helper()
+	if !hashset.Equal(expected, actual) {
+		t.Logf("expected:\t%+v", expected)
+		t.Logf("actual:\t\t%+v", actual)
+		t.Fatal("selected a different set of entities than expected")
+	}
+}
+
+func storeAndRetrieveValue(t *testing.T, p entity.Pool) {
+	v := create(t, p)
+	add(t, v, Tagged{99})
+
+	var c Tagged
+	if err := v.Get(&c); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	if c.V != 99 {
+		t.Fatalf("expected to retrieve 99 but got %d", c.V)
+	}
+}
+
+func storeAndRetrievePointer(t *testing.T, p entity.Pool) {
+	v := create(t, p)
+	add(t, v, &Tagged{10})
+
+	var c *Tagged
+	if err := v.Get(&c); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	c.V = 9999
+
+	var d *Tagged
+	if err := v.Get(&d); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	if d.V != 9999 {
+		t.Fatalf("expected pointer components to share storage, got %d", d.V)
+	}
+}
+
+func overwriteComponent(t *testing.T, p entity.Pool) {
+	v := create(t, p)
+	add(t, v, Tagged{1})
+	add(t, v, Weighted{2})
+	add(t, v, Tagged{3})
+
+	tagged, err := entity.GetComponent[Tagged](v)
+	if err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	if tagged.V != 3 {
+		t.Fatalf("expected second add to overwrite first, got %d", tagged.V)
+	}
+
+	weighted, err := entity.GetComponent[Weighted](v)
+	if err != nil || weighted.K != 2 {
+		t.Fatalf("expected untouched component to survive: %v %s", weighted, err)
+	}
+}
+
+func removeComponent(t *testing.T, p entity.Pool) {
+	v := create(t, p)
+	add(t, v, Tagged{1})
+	add(t, v, Marker{})
+
+	if err := v.Remove(Tagged{}); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	var c Tagged
+	if err := v.Get(&c); !errors.Is(err, entity.ErrNotAssigned) {
+		t.Fatalf("expected %s but got %v", entity.ErrNotAssigned, err)
+	}
+
+	if !entity.Has[Marker](v) {
+		t.Fatal("removing one component should not remove others")
+	}
+
+	if err := v.Remove(Weighted{}); err != nil {
+		t.Fatalf("removing an unattached component should not error: %s", err)
+	}
+}
+
+func queryWith(t *testing.T, p entity.Pool) {
+	tagged := hashset.New[entity.Model]()
+	both := hashset.New[entity.Model]()
+
+	for i := 0; i < 100; i++ {
+		v := create(t, p)
+		if i%7 == 0 {
+			add(t, v, Tagged{i})
+			tagged.Add(v.Model())
+		}
+		if i%5 == 0 {
+			add(t, v, Weighted{float64(i)})
+			if i%7 == 0 {
+				both.Add(v.Model())
+			}
+		}
+	}
+
+	setsEqual(t, tagged, query(t, p, entity.BuildFilter().With(mirrors.TypeOf[Tagged]())))
+	setsEqual(t, both, query(t, p, entity.BuildFilter().
+		With(mirrors.TypeOf[Tagged]()).
+		With(mirrors.TypeOf[Weighted]())))
+}
+
+func queryWithout(t *testing.T, p entity.Pool) {
+	untagged := hashset.New[entity.Model]()
+
+	for i := 0; i < 100; i++ {
+		v := create(t, p)
+		add(t, v, Marker{})
+		switch {
+		case i%7 == 0:
+			add(t, v, Tagged{i})
+		case i%5 == 0:
+			add(t, v, Weighted{float64(i)})
+		default:
+			untagged.Add(v.Model())
+		}
+	}
+
+	setsEqual(t, untagged, query(t, p, entity.BuildFilter().
+		With(mirrors.TypeOf[Marker]()).
+		Without(mirrors.TypeOf[Tagged]()).
+		Without(mirrors.TypeOf[Weighted]())))
+}
+
+func queryNoMatches(t *testing.T, p entity.Pool) {
+	add(t, create(t, p), Tagged{})
+	create(t, p).Add(Weighted{})
+
+	_, err := p.Query(entity.BuildFilter().
+		With(mirrors.TypeOf[Tagged]()).
+		With(mirrors.TypeOf[Weighted]()).
+		Build())
+
+	if !errors.Is(err, entity.ErrNoEntities) {
+		t.Fatalf("expected %s but got %v", entity.ErrNoEntities, err)
+	}
+}
+
+func queryUnknownComponent(t *testing.T, p entity.Pool) {
+	add(t, create(t, p), Tagged{})
+
+	_, err := p.Query(entity.BuildFilter().With(mirrors.TypeOf[Marker]()).Build())
+	var unknown entity.ErrUnknownComponent
+	if !errors.As(err, &unknown) {
+		t.Fatalf("expected ErrUnknownComponent but got %v", err)
+	}
+}
+
+func queryAnyOf(t *testing.T, p entity.Pool) {
+	tagged := create(t, p)
+	add(t, tagged, Tagged{})
+	weighted := create(t, p)
+	add(t, weighted, Weighted{})
+	both := create(t, p)
+	add(t, both, Tagged{})
+	add(t, both, Weighted{})
+	add(t, both, Marker{})
+	neither := create(t, p)
+	add(t, neither, Marker{})
+
+	expected := hashset.New[entity.Model]()
+	expected.Add(tagged.Model())
+	expected.Add(weighted.Model())
+	setsEqual(t, expected, query(t, p, entity.BuildFilter().
+		AnyOf(mirrors.TypeOf[Tagged](), mirrors.TypeOf[Weighted]()).
+		Without(mirrors.TypeOf[Marker]())))
+
+	expected = hashset.New[entity.Model]()
+	expected.Add(both.Model())
+	setsEqual(t, expected, query(t, p, entity.BuildFilter().
+		AnyOf(mirrors.TypeOf[Tagged](), mirrors.TypeOf[Weighted]()).
+		AnyOf(mirrors.TypeOf[Marker]())))
+
+	type unregistered struct{}
+	setsEqual(t, hashset.New[entity.Model](), query(t, p, entity.BuildFilter().AnyOf(mirrors.TypeOf[unregistered]())))
+}
+
+// optional components never narrow the population, the ones present are
+// loaded into the views as they were when the query ran
+func queryOptional(t *testing.T, p entity.Pool) {
+	type unregistered struct{}
+	with := create(t, p)
+	add(t, with, Tagged{})
+	add(t, with, Weighted{K: 1})
+	without := create(t, p)
+	add(t, without, Tagged{})
+
+	fb := entity.BuildFilter().
+		With(mirrors.TypeOf[Tagged]()).
+		Optional(mirrors.TypeOf[Weighted]()).
+		Optional(mirrors.TypeOf[unregistered]())
+
+	expected := hashset.New[entity.Model]()
+	expected.Add(with.Model())
+	expected.Add(without.Model())
+	setsEqual(t, expected, query(t, p, fb))
+
+	views, err := p.Query(fb.Build())
+	if err != nil {
+		t.Fatal(err)
+	}
+	add(t, with, Weighted{K: 2})
+
+	for _, v := range views {
+		var weighted Weighted
+		err := v.Get(&weighted)
+		switch v.Model() {
+		case with.Model():
+			if err != nil || weighted.K != 1 {
+				t.Fatalf("expected the loaded weight to be 1 but got %v %v", weighted, err)
+			}
+		case without.Model():
+			if err == nil {
+				t.Fatalf("expected no weight but got %v", weighted)
+			}
+		}
+	}
+}
+
+func queryPredicate(t *testing.T, p entity.Pool) {
+	expected := hashset.New[entity.Model]()
+	for i := 0; i < 10; i++ {
+		v := create(t, p)
+		add(t, v, Tagged{i})
+		if i < 3 {
+			expected.Add(v.Model())
+		}
+	}
+	add(t, create(t, p), Weighted{})
+
+	cheap := entity.Matching(func(t Tagged) bool { return t.V < 3 })
+	setsEqual(t, expected, query(t, p, entity.BuildFilter(cheap)))
+
+	type unregistered struct{}
+	_, err := p.Query(entity.BuildFilter(entity.Matching(func(unregistered) bool { return true })).Build())
+	var unknown entity.ErrUnknownComponent
+	if !errors.As(err, &unknown) {
+		t.Fatalf("expected predicate on unknown component to fail with ErrUnknownComponent but got %v", err)
+	}
+}
+
+func fetchUnknownEntity(t *testing.T, p entity.Pool) {
+	v := create(t, p)
+
+	fetched, err := p.Fetch(v.Model())
+	if err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	if fetched.Model() != v.Model() {
+		t.Fatalf("fetched %d but wanted %d", fetched.Model(), v.Model())
+	}
+
+	if _, err := p.Fetch(v.Model() + 100); !errors.Is(err, entity.ErrEntityNotExist) {
+		t.Fatalf("expected %s but got %v", entity.ErrEntityNotExist, err)
+	}
+}
+
+func getManyFromPool(t *testing.T, p entity.Pool) {
+	v := create(t, p)
+	add(t, v, Tagged{99})
+
+	var c1 Tagged
+	var c2 *Weighted
+	p.Get(v.Model(), []interface{}{&c1, &c2})
+
+	if c1.V != 99 {
+		t.Fatalf("did not retrieve expected instance of %[1]T: %[1]v", c1)
+	}
+
+	if c2 != nil {
+		t.Fatalf("did not expect to retrieve %T", c2)
+	}
+}
+
+func typedAccessors(t *testing.T, p entity.Pool) {
+	v := create(t, p)
+	if err := entity.Add(v, Tagged{4}); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	tagged, err := entity.GetComponent[Tagged](v)
+	if err != nil || tagged.V != 4 {
+		t.Fatalf("expected Tagged{4} but got %v %v", tagged, err)
+	}
+
+	ptr, err := entity.GetComponent[*Tagged](v)
+	if err != nil || ptr.V != 4 {
+		t.Fatalf("expected pointer to Tagged{4} but got %v %v", ptr, err)
+	}
+
+	if _, err := entity.GetComponent[Weighted](v); !entity.IsMissingComponent(err) {
+		t.Fatalf("expected missing component but got %v", err)
+	}
+
+	fetched, err := entity.GetFrom[Tagged](p, v.Model())
+	if err != nil || fetched != tagged {
+		t.Fatalf("expected GetFrom to match GetComponent: %v %v", fetched, err)
+	}
+
+	if err := entity.Remove[Tagged](v); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	if entity.Has[Tagged](v) {
+		t.Fatal("expected Tagged to be removed")
+	}
+}
+
+func typedQueries(t *testing.T, p entity.Pool) {
+	expected := make(map[entity.Model]entity.Tuple2[Tagged, Weighted])
+
+	for i := 0; i < 20; i++ {
+		v := create(t, p)
+		add(t, v, Tagged{i})
+		if i%2 == 0 {
+			add(t, v, Weighted{float64(i)})
+			expected[v.Model()] = entity.Tuple2[Tagged, Weighted]{
+				Model: v.Model(), A: Tagged{i}, B: Weighted{float64(i)},
+			}
+		}
+	}
+
+	ones, err := entity.Query1[Tagged](p, entity.BuildFilter())
+	if err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	if len(ones) != 20 {
+		t.Fatalf("expected 20 results but got %d", len(ones))
+	}
+
+	twos, err := entity.Query2[Tagged, Weighted](p, entity.BuildFilter())
+	if err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	actual := make(map[entity.Model]entity.Tuple2[Tagged, Weighted], len(twos))
+	for _, tup := range twos {
+		actual[tup.Model] = tup
+	}
+
+	if !reflect.DeepEqual(expected, actual) {
+		t.Logf("expected:\t%+v", expected)
+		t.Logf("actual:\t\t%+v", actual)
+		t.Fatal("typed query returned unexpected tuples")
+	}
+}
+
+func destroyEntity(t *testing.T, p entity.Pool) {
+	doomed := create(t, p)
+	add(t, doomed, Tagged{1})
+	survivor := create(t, p)
+	add(t, survivor, Tagged{2})
+
+	if err := p.Destroy(doomed.Model()); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	if _, err := p.Fetch(doomed.Model()); !errors.Is(err, entity.ErrEntityNotExist) {
+		t.Fatalf("expected %s but got %v", entity.ErrEntityNotExist, err)
+	}
+
+	if err := p.Destroy(doomed.Model()); !errors.Is(err, entity.ErrEntityNotExist) {
+		t.Fatalf("expected destroying twice to fail with %s but got %v", entity.ErrEntityNotExist, err)
+	}
+
+	expected := hashset.New[entity.Model]()
+	expected.Add(survivor.Model())
+	setsEqual(t, expected, query(t, p, entity.BuildFilter().With(mirrors.TypeOf[Tagged]())))
+
+	if tagged, err := entity.GetComponent[Tagged](survivor); err != nil || tagged.V != 2 {
+		t.Fatalf("destroying a neighbor disturbed survivor: %v %v", tagged, err)
+	}
+}
+
+func destroyRecyclesIds(t *testing.T, p entity.Pool) {
+	first := create(t, p)
+	add(t, first, Tagged{1})
+	if err := p.Destroy(first.Model()); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	second := create(t, p)
+	if second.Model().Index() != first.Model().Index() {
+		t.Fatalf("expected slot %d to be reused but got %d", first.Model().Index(), second.Model().Index())
+	}
+
+	if second.Model() == first.Model() {
+		t.Fatalf("recycled model %s must not equal the destroyed model", second.Model())
+	}
+
+	if entity.Has[Tagged](second) {
+		t.Fatal("recycled entity inherited components from destroyed entity")
+	}
+}
+
+func staleHandles(t *testing.T, p entity.Pool) {
+	stale := create(t, p)
+	if err := p.Destroy(stale.Model()); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	fresh := create(t, p)
+	add(t, fresh, Tagged{5})
+
+	if err := stale.Add(Weighted{}); !errors.Is(err, entity.ErrEntityNotExist) {
+		t.Fatalf("expected adding to a stale handle to fail with %s but got %v", entity.ErrEntityNotExist, err)
+	}
+
+	var tagged Tagged
+	if err := stale.Get(&tagged); !errors.Is(err, entity.ErrEntityNotExist) {
+		t.Fatalf("expected reading from a stale handle to fail with %s but got %v", entity.ErrEntityNotExist, err)
+	}
+
+	if entity.Has[Weighted](fresh) {
+		t.Fatal("stale handle wrote to the slot's new occupant")
+	}
+}
+
+func restore(t *testing.T, p entity.Pool, s entity.Snapshot) {
+	t.Helper()
+	if err := p.Restore(s); err != nil {
+		t.Fatalf("could not restore snapshot: %s", err)
+	}
+}
+
+func restoreComponents(t *testing.T, p entity.Pool) {
+	changed := create(t, p)
+	add(t, changed, Tagged{1})
+	removed := create(t, p)
+	add(t, removed, Weighted{2})
+	untouched := create(t, p)
+	add(t, untouched, Tagged{3})
+
+	snap := p.Snapshot()
+	add(t, changed, Tagged{10})
+	add(t, changed, Marker{})
+	if err := removed.Remove(Weighted{}); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	restore(t, p, snap)
+
+	if tagged, err := entity.GetComponent[Tagged](changed); err != nil || tagged.V != 1 {
+		t.Fatalf("expected overwritten component to be restored: %v %v", tagged, err)
+	}
+	if entity.Has[Marker](changed) {
+		t.Fatal("component added after snapshot survived restore")
+	}
+	if weighted, err := entity.GetComponent[Weighted](removed); err != nil || weighted.K != 2 {
+		t.Fatalf("expected removed component to be restored: %v %v", weighted, err)
+	}
+	if tagged, err := entity.GetComponent[Tagged](untouched); err != nil || tagged.V != 3 {
+		t.Fatalf("restore disturbed untouched entity: %v %v", tagged, err)
+	}
+
+	expected := hashset.New[entity.Model]()
+	expected.Add(changed.Model())
+	expected.Add(untouched.Model())
+	setsEqual(t, expected, query(t, p, entity.BuildFilter().With(mirrors.TypeOf[Tagged]())))
+	setsEqual(t, hashset.New[entity.Model](), query(t, p, entity.BuildFilter().With(mirrors.TypeOf[Marker]())))
+}
+
+func restoreLifecycle(t *testing.T, p entity.Pool) {
+	doomed := create(t, p)
+	add(t, doomed, Tagged{1})
+
+	snap := p.Snapshot()
+	if err := p.Destroy(doomed.Model()); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	born := create(t, p)
+	add(t, born, Tagged{2})
+	restore(t, p, snap)
+
+	if tagged, err := entity.GetComponent[Tagged](doomed); err != nil || tagged.V != 1 {
+		t.Fatalf("expected destroyed entity to be revived: %v %v", tagged, err)
+	}
+
+	if _, err := p.Fetch(born.Model()); !errors.Is(err, entity.ErrEntityNotExist) {
+		t.Fatalf("expected entity created after snapshot to be stale but got %v", err)
+	}
+
+	reborn := create(t, p)
+	if reborn.Model() == born.Model() {
+		t.Fatalf("model %s was handed out again after restore", born.Model())
+	}
+	if entity.Has[Tagged](reborn) {
+		t.Fatal("entity created after restore inherited components")
+	}
+	if err := born.Add(Marker{}); !errors.Is(err, entity.ErrEntityNotExist) {
+		t.Fatalf("expected stale handle to be rejected but got %v", err)
+	}
+}
+
+func restoreRepeatedly(t *testing.T, p entity.Pool) {
+	v := create(t, p)
+	add(t, v, Tagged{1})
+	snap := p.Snapshot()
+
+	for i := 2; i < 5; i++ {
+		add(t, v, Tagged{i})
+		add(t, create(t, p), Marker{})
+		restore(t, p, snap)
+
+		if tagged, err := entity.GetComponent[Tagged](v); err != nil || tagged.V != 1 {
+			t.Fatalf("attempt %d: expected snapshot to be reusable: %v %v", i, tagged, err)
+		}
+		setsEqual(t, hashset.New[entity.Model](), query(t, p, entity.BuildFilter().With(mirrors.TypeOf[Marker]())))
+	}
+}
+
+func restoreForeignSnapshot(t *testing.T, p, other entity.Pool) {
+	snap := other.Snapshot()
+	if err := p.Restore(snap); !errors.Is(err, entity.ErrForeignSnapshot) {
+		t.Fatalf("expected %s but got %v", entity.ErrForeignSnapshot, err)
+	}
+}
+
+func observeTypedChanges(t *testing.T, p entity.Pool) {
+	added := map[entity.Model]int{}
+	removed := map[entity.Model]int{}
+	stopAdd := entity.OnAdd(p, func(m entity.Model, tagged Tagged) { added[m] = tagged.V })
+	defer entity.OnRemove(p, func(m entity.Model, tagged Tagged) { removed[m] = tagged.V })()
+
+	v := create(t, p)
+	add(t, v, Tagged{1})
+	add(t, v, Weighted{1})
+	add(t, v, Tagged{2})
+
+	if added[v.Model()] != 2 || len(added) != 1 {
+		t.Fatalf("expected to observe the latest Tagged on %s but saw %v", v.Model(), added)
+	}
+
+	if err := v.Remove(Tagged{}); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	if err := v.Remove(Tagged{}); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	if removed[v.Model()] != 2 || len(removed) != 1 {
+		t.Fatalf("expected to observe removal of Tagged with its prior value but saw %v", removed)
+	}
+
+	stopAdd()
+	add(t, v, Tagged{3})
+	if added[v.Model()] != 2 {
+		t.Fatal("observer was notified after it was unregistered")
+	}
+}
+
+func observeDestroy(t *testing.T, p entity.Pool) {
+	v := create(t, p)
+	add(t, v, Tagged{1})
+	add(t, v, Marker{})
+
+	var kinds []entity.ChangeKind
+	var types []reflect.Type
+	defer p.Observe(func(c entity.Change) {
+		kinds = append(kinds, c.Kind)
+		types = append(types, c.Type)
+	})()
+
+	if err := p.Destroy(v.Model()); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	if len(kinds) != 3 || kinds[2] != entity.EntityDestroyed {
+		t.Fatalf("expected two removals followed by destruction but saw %v", kinds)
+	}
+
+	seen := hashset.New[reflect.Type]()
+	for i := 0; i < 2; i++ {
+		if kinds[i] != entity.ComponentRemoved {
+			t.Fatalf("expected removal but saw %s", kinds[i])
+		}
+		seen.Add(types[i])
+	}
+	if !seen.Exists(mirrors.TypeOf[Tagged]()) || !seen.Exists(mirrors.TypeOf[Marker]()) {
+		t.Fatalf("expected removal of every component but saw %v", types)
+	}
+}
+
+func journalSince(t *testing.T, p entity.Pool) {
+	journal := entity.NewJournal(p)
+	defer journal.Close()
+
+	v := create(t, p)
+	add(t, v, Tagged{1})
+	cursor := journal.Cursor()
+
+	add(t, v, Marker{})
+	snap := p.Snapshot()
+	restore(t, p, snap)
+
+	changes := reiterate.ToSlice(journal.Since(cursor))
+	if len(changes) != 2 {
+		t.Fatalf("expected 2 changes since cursor but got %+v", changes)
+	}
+
+	if changes[0].Kind != entity.ComponentAdded || changes[0].Entity != v.Model() || changes[0].Type != mirrors.TypeOf[Marker]() {
+		t.Fatalf("unexpected change recorded: %+v", changes[0])
+	}
+
+	if changes[1].Kind != entity.PoolRestored {
+		t.Fatalf("expected restoring to be recorded but got %+v", changes[1])
+	}
+
+	journal.Close()
+	add(t, v, Weighted{})
+	if journal.Cursor() != cursor+2 {
+		t.Fatal("journal recorded changes after being closed")
+	}
+}
+
+func prepare(t *testing.T, p entity.Pool, fb entity.FilterBuilder) entity.PreparedQuery {
+	t.Helper()
+	q, err := p.Prepare(fb.Build())
+	if err != nil {
+		t.Fatalf("could not prepare query: %s", err)
+	}
+	return q
+}
+
+func preparedMatches(t *testing.T, q entity.PreparedQuery, expected ...entity.View) {
+	t.Helper()
+	want := hashset.MapFromSlice(expected, entity.View.Model)
+	got := hashset.MapFromSlice(reiterate.ToSlice(q.Iter()), entity.View.Model)
+	setsEqual(t, want, got)
+
+	if q.Count() != len(expected) {
+		t.Fatalf("expected count of %d but got %d", len(expected), q.Count())
+	}
+
+	for _, v := range expected {
+		if !q.Contains(v.Model()) {
+			t.Fatalf("expected prepared query to contain %s", v.Model())
+		}
+	}
+}
+
+func preparedQueryTracksChanges(t *testing.T, p entity.Pool) {
+	early := create(t, p)
+	add(t, early, Tagged{1})
+	other := create(t, p)
+	add(t, other, Weighted{})
+	add(t, other, Marker{})
+
+	q := prepare(t, p, entity.BuildFilter(entity.Matching(func(t Tagged) bool { return t.V < 10 })).
+		Without(mirrors.TypeOf[Marker]()))
+	defer q.Close()
+	preparedMatches(t, q, early)
+
+	late := create(t, p)
+	add(t, late, Tagged{2})
+	preparedMatches(t, q, early, late)
+
+	snap := p.Snapshot()
+	add(t, early, Marker{})
+	add(t, late, Tagged{20})
+	preparedMatches(t, q)
+
+	restore(t, p, snap)
+	preparedMatches(t, q, early, late)
+
+	if err := p.Destroy(late.Model()); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	preparedMatches(t, q, early)
+	if q.Contains(late.Model()) {
+		t.Fatal("prepared query contains destroyed entity")
+	}
+
+	reborn := create(t, p)
+	preparedMatches(t, q, early)
+	add(t, reborn, Tagged{3})
+	preparedMatches(t, q, early, reborn)
+}
+
+func preparedQueryLateComponents(t *testing.T, p entity.Pool) {
+	tagged := create(t, p)
+	add(t, tagged, Tagged{})
+
+	q := prepare(t, p, entity.BuildFilter().AnyOf(mirrors.TypeOf[Tagged](), mirrors.TypeOf[Marker]()))
+	defer q.Close()
+	preparedMatches(t, q, tagged)
+
+	marked := create(t, p)
+	add(t, marked, Marker{})
+	preparedMatches(t, q, tagged, marked)
+}
+
+type Contains struct{}
+
+type Weight struct {
+	W int
+}
+
+func relate(t *testing.T, p entity.Pool, source, target entity.View, r entity.Component) {
+	t.Helper()
+	if err := p.Relate(source.Model(), target.Model(), r); err != nil {
+		t.Fatalf("could not relate %s to %s: %s", source.Model(), target.Model(), err)
+	}
+}
+
+func modelsEqual(t *testing.T, expected []entity.View, actual []entity.Model) {
+	t.Helper()
+	if len(expected) != len(actual) {
+		t.Fatalf("expected %d models but got %v", len(expected), actual)
+	}
+	for i := range expected {
+		if expected[i].Model() != actual[i] {
+			t.Fatalf("expected %s at %d but got %s", expected[i].Model(), i, actual[i])
+		}
+	}
+}
+
+func relateModels(t *testing.T, p entity.Pool) {
+	bag := create(t, p)
+	first := create(t, p)
+	second := create(t, p)
+
+	relate(t, p, bag, second, Contains{})
+	relate(t, p, bag, first, Contains{})
+	relate(t, p, first, second, Weight{3})
+	relate(t, p, first, second, Weight{5})
+
+	modelsEqual(t, []entity.View{first, second}, entity.Targets[Contains](p, bag.Model()))
+	modelsEqual(t, []entity.View{bag}, entity.Sources[Contains](p, first.Model()))
+	modelsEqual(t, nil, entity.Targets[Contains](p, first.Model()))
+
+	if w, err := entity.GetRelation[Weight](p, first.Model(), second.Model()); err != nil || w.W != 5 {
+		t.Fatalf("expected relating again to replace the value: %v %v", w, err)
+	}
+	if _, err := entity.GetRelation[Weight](p, second.Model(), first.Model()); !errors.Is(err, entity.ErrNotRelated) {
+		t.Fatalf("expected relations to be directed but got %v", err)
+	}
+
+	if err := entity.Unrelate[Contains](p, bag.Model(), second.Model()); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	modelsEqual(t, []entity.View{first}, entity.Targets[Contains](p, bag.Model()))
+
+	pairs := p.Pairs(mirrors.TypeOf[Contains]())
+	if len(pairs) != 1 || pairs[0].Source != bag.Model() || pairs[0].Target != first.Model() {
+		t.Fatalf("unexpected pairs: %v", pairs)
+	}
+}
+
+func relationsCleanedOnDestroy(t *testing.T, p entity.Pool) {
+	bag := create(t, p)
+	doomed := create(t, p)
+	kept := create(t, p)
+	relate(t, p, bag, doomed, Contains{})
+	relate(t, p, bag, kept, Contains{})
+	relate(t, p, doomed, kept, Contains{})
+
+	if err := p.Destroy(doomed.Model()); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	modelsEqual(t, []entity.View{kept}, entity.Targets[Contains](p, bag.Model()))
+	modelsEqual(t, []entity.View{bag}, entity.Sources[Contains](p, kept.Model()))
+
+	reborn := create(t, p)
+	if reborn.Model().Index() == doomed.Model().Index() {
+		modelsEqual(t, nil, entity.Sources[Contains](p, reborn.Model()))
+	}
+
+	if err := entity.Relate[Contains](p, bag.Model(), doomed.Model()); !errors.Is(err, entity.ErrEntityNotExist) {
+		t.Fatalf("expected relating a stale model to fail but got %v", err)
+	}
+}
+
+func restoreRelations(t *testing.T, p entity.Pool) {
+	bag := create(t, p)
+	kept := create(t, p)
+	relate(t, p, bag, kept, Contains{})
+
+	snap := p.Snapshot()
+	added := create(t, p)
+	relate(t, p, bag, added, Contains{})
+	if err := entity.Unrelate[Contains](p, bag.Model(), kept.Model()); err != nil {
+		t.Fatalf("did not expect error: %s", err)
+	}
+	restore(t, p, snap)
+
+	modelsEqual(t, []entity.View{kept}, entity.Targets[Contains](p, bag.Model()))
+
+	// the snapshot must not be disturbed by relations made after restoring
+	relate(t, p, kept, bag, Contains{})
+	restore(t, p, snap)
+	modelsEqual(t, nil, entity.Targets[Contains](p, kept.Model()))
+}
+
+// run with -race, readers and writers share the pool without coordinating
+func concurrentAccess(t *testing.T, p entity.Pool) {
+	const writers = 4
+	const perWriter = 50
+
+	shared := create(t, p)
+	add(t, shared, Weighted{1})
+	add(t, shared, Tagged{-1})
+
+	q := prepare(t, p, entity.BuildFilter().With(mirrors.TypeOf[Tagged]()))
+	defer q.Close()
+
+	var wg sync.WaitGroup
+	errs := make(chan error, writers*perWriter*4)
+	done := make(chan struct{})
+
+	for w := 0; w < writers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := 0; i < perWriter; i++ {
+				v, err := p.Create()
+				if err != nil {
+					errs <- err
+					return
+				}
+				if err := v.Add(Tagged{w*perWriter + i}); err != nil {
+					errs <- err
+				}
+				if err := p.Relate(shared.Model(), v.Model(), Contains{}); err != nil {
+					errs <- err
+				}
+			}
+		}(w)
+	}
+
+	var readers sync.WaitGroup
+	for r := 0; r < 4; r++ {
+		readers.Add(1)
+		go func() {
+			defer readers.Done()
+			for {
+				select {
+				case <-done:
+					return
+				default:
+				}
+
+				views, err := p.Query(entity.BuildFilter().With(mirrors.TypeOf[Tagged]()).Build())
+				if err != nil && !errors.Is(err, entity.ErrNoEntities) {
+					errs <- err
+					return
+				}
+				for _, v := range views {
+					var tagged Tagged
+					if err := v.Get(&tagged); err != nil && !errors.Is(err, entity.ErrEntityNotExist) {
+						errs <- err
+						return
+					}
+				}
+
+				var weighted Weighted
+				p.Get(shared.Model(), []interface{}{&weighted})
+				q.Count()
+				entity.Targets[Contains](p, shared.Model())
+			}
+		}()
+	}
+
+	wg.Wait()
+	close(done)
+	readers.Wait()
+	close(errs)
+
+	for err := range errs {
+		t.Fatalf("did not expect error: %s", err)
+	}
+
+	if q.Count() != writers*perWriter+1 {
+		t.Fatalf("expected %d tagged entities but prepared query saw %d", writers*perWriter+1, q.Count())
+	}
+	if related := entity.Targets[Contains](p, shared.Model()); len(related) != writers*perWriter {
+		t.Fatalf("expected %d related entities but got %d", writers*perWriter, len(related))
+	}
+}
+
+func batchMutations(t *testing.T, p entity.Pool) {
+	existing := create(t, p)
+	var created entity.Model
+	expected := errors.New("stop here")
+
+	err := p.Batch(func(b entity.Batch) error {
+		var err error
+		if created, err = b.Create(); err != nil {
+			return err
+		}
+		if err := b.Add(created, Tagged{1}); err != nil {
+			return err
+		}
+		if err := b.Add(existing.Model(), []entity.Component{Tagged{2}, Marker{}}); err != nil {
+			return err
+		}
+		if err := b.Relate(existing.Model(), created, Contains{}); err != nil {
+			return err
+		}
+		if err := b.Remove(existing.Model(), Marker{}); err != nil {
+			return err
+		}
+
+		c, err := b.Component(existing.Model(), mirrors.TypeOf[Tagged]())
+		if err != nil || c.(Tagged).V != 2 {
+			t.Errorf("expected batch to read its own writes: %v %v", c, err)
+		}
+		return expected
+	})
+
+	if !errors.Is(err, expected) {
+		t.Fatalf("expected batch to return %s but got %v", expected, err)
+	}
+
+	if tagged, err := entity.GetFrom[Tagged](p, created); err != nil || tagged.V != 1 {
+		t.Fatalf("expected mutations made before the error to be kept: %v %v", tagged, err)
+	}
+	if entity.Has[Marker](existing) {
+		t.Fatal("expected component removed in batch to be gone")
+	}
+	if related := entity.Targets[Contains](p, existing.Model()); len(related) != 1 || related[0] != created {
+		t.Fatalf("expected relation made in batch to be kept: %v", related)
+	}
+}
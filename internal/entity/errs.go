@@ -14,6 +14,8 @@ var ErrNotLoaded = errors.New("not loaded")
 var ErrNotAssigned = errors.New("not assigned")
 var ErrNonNilPtrOnly = errors.New("non-nil pointers only")
 var ErrNilComponentPtr = errors.New("nil pointer to component")
+var ErrForeignSnapshot = errors.New("snapshot belongs to another pool")
+var ErrCannotInvert = errors.New("cannot invert filter")
 
 type ErrUnknownComponent struct {
 	T reflect.Type
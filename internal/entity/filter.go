@@ -1,8 +1,10 @@
 package entity
 
 import (
+	"fmt"
 	"reflect"
 
+	"github.com/etc-sudonters/substrate/mirrors"
 	"github.com/etc-sudonters/substrate/skelly/hashset"
 )
 
@@ -14,8 +16,11 @@ func BuildFilter(opts ...FilterOption) FilterBuilder {
 
 // used to narrow the entity population
 type Filter struct {
-	include []reflect.Type
-	exclude []reflect.Type
+	include  []reflect.Type
+	exclude  []reflect.Type
+	anyOf    [][]reflect.Type
+	optional []reflect.Type
+	where    []Predicate
 }
 
 func (f Filter) With() []reflect.Type {
@@ -26,10 +31,48 @@ func (f Filter) Without() []reflect.Type {
 	return f.exclude
 }
 
+// each group requires at least one of its components to be present,
+// components that have never been registered can't match
+func (f Filter) AnyOf() [][]reflect.Type {
+	return f.anyOf
+}
+
+// components loaded into the matched views when present, these never narrow
+// the population and may be unknown to the pool
+func (f Filter) Optional() []reflect.Type {
+	return f.optional
+}
+
+// tests run against component values after the population has been narrowed
+// by component presence
+func (f Filter) Where() []Predicate {
+	return f.where
+}
+
+// narrows the population by the value of a component, the component must be
+// present for the predicate to pass
+type Predicate struct {
+	Type reflect.Type
+	Test func(Component) bool
+}
+
+// matches entities whose T passes the provided test
+func Matching[T Component](test func(T) bool) FilterOption {
+	return func(b FilterBuilder) FilterBuilder {
+		return b.Where(mirrors.TypeOf[T](), func(c Component) bool {
+			t, ok := c.(T)
+			return ok && test(t)
+		})
+	}
+}
+
 // constructs a Filter
 type FilterBuilder struct {
-	include hashset.Hash[reflect.Type]
-	exclude hashset.Hash[reflect.Type]
+	include  hashset.Hash[reflect.Type]
+	exclude  hashset.Hash[reflect.Type]
+	anyOf    []hashset.Hash[reflect.Type]
+	optional hashset.Hash[reflect.Type]
+	where    []Predicate
 }
 
 func (f FilterBuilder) Configure(opts ...FilterOption) FilterBuilder {
@@ -55,30 +98,83 @@ func (f FilterBuilder) Without(t reflect.Type) FilterBuilder {
 	return f
 }
 
+// requires at least one of the provided components, each call adds a
+// separate group
+func (f FilterBuilder) AnyOf(ts ...reflect.Type) FilterBuilder {
+	f.anyOf = append(f.anyOf[:len(f.anyOf):len(f.anyOf)], hashset.FromSlice(ts))
+	return f
+}
+
+// loads the component into matched views that have it, views without it
+// still match
+func (f FilterBuilder) Optional(t reflect.Type) FilterBuilder {
+	if f.optional == nil {
+		f.optional = make(hashset.Hash[reflect.Type])
+	}
+	f.optional.Add(t)
+	return f
+}
+
+// requires the component and that its value passes the test
+func (f FilterBuilder) Where(t reflect.Type, test func(Component) bool) FilterBuilder {
+	f = f.With(t)
+	f.where = append(f.where[:len(f.where):len(f.where)], Predicate{t, test})
+	return f
+}
+
 func (f FilterBuilder) Build() Filter {
-	return Filter{
-		include: hashset.AsSlice(f.include),
-		exclude: hashset.AsSlice(f.exclude),
+	filter := Filter{
+		include:  hashset.AsSlice(f.include),
+		exclude:  hashset.AsSlice(f.exclude),
+		optional: hashset.AsSlice(f.optional),
+		where:    append([]Predicate(nil), f.where...),
 	}
+
+	for _, group := range f.anyOf {
+		filter.anyOf = append(filter.anyOf, hashset.AsSlice(group))
+	}
+
+	return filter
 }
 
 func (f FilterBuilder) Clone() FilterBuilder {
 	return FilterBuilder{
-		include: hashset.FromMap(f.include),
-		exclude: hashset.FromMap(f.exclude),
+		include:  hashset.FromMap(f.include),
+		exclude:  hashset.FromMap(f.exclude),
+		anyOf:    cloneGroups(f.anyOf),
+		optional: hashset.FromMap(f.optional),
+		where:    append([]Predicate(nil), f.where...),
 	}
 }
 
 func (f FilterBuilder) Combine(o FilterBuilder) FilterBuilder {
 	return FilterBuilder{
-		include: hashset.Union(f.include, o.include),
-		exclude: hashset.Union(f.exclude, o.exclude),
+		include:  hashset.Union(f.include, o.include),
+		exclude:  hashset.Union(f.exclude, o.exclude),
+		anyOf:    append(cloneGroups(f.anyOf), cloneGroups(o.anyOf)...),
+		optional: hashset.Union(f.optional, o.optional),
+		where:    append(append([]Predicate(nil), f.where...), o.where...),
 	}
 }
 
-func (f FilterBuilder) Invert() FilterBuilder {
+// swaps With and Without terms, groups and predicates have no inverse that
+// can be expressed as a Filter so builders with them are refused
+func (f FilterBuilder) Invert() (FilterBuilder, error) {
+	if len(f.anyOf) > 0 || len(f.where) > 0 {
+		return f, fmt.Errorf("%w: AnyOf groups and predicates have no inverse", ErrCannotInvert)
+	}
+
 	return FilterBuilder{
-		include: hashset.FromMap(f.exclude),
-		exclude: hashset.FromMap(f.include),
+		include:  hashset.FromMap(f.exclude),
+		exclude:  hashset.FromMap(f.include),
+		optional: hashset.FromMap(f.optional),
+	}, nil
+}
+
+func cloneGroups(groups []hashset.Hash[reflect.Type]) []hashset.Hash[reflect.Type] {
+	clones := make([]hashset.Hash[reflect.Type], len(groups))
+	for i := range groups {
+		clones[i] = hashset.FromMap(groups[i])
 	}
+	return clones
 }
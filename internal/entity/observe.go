@@ -0,0 +1,195 @@
+package entity
+
+import (
+	"reflect"
+	"sync"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+	"github.com/etc-sudonters/substrate/reiterate"
+)
+
+type ChangeKind uint8
+
+const (
+	_ ChangeKind = iota
+	ComponentAdded
+	ComponentRemoved
+	EntityCreated
+	EntityDestroyed
+	// the pool was rewound to a snapshot, observers that cache anything
+	// about the population should start over
+	PoolRestored
+)
+
+func (k ChangeKind) String() string {
+	switch k {
+	case ComponentAdded:
+		return "ComponentAdded"
+	case ComponentRemoved:
+		return "ComponentRemoved"
+	case EntityCreated:
+		return "EntityCreated"
+	case EntityDestroyed:
+		return "EntityDestroyed"
+	case PoolRestored:
+		return "PoolRestored"
+	default:
+		return "UnknownChange"
+	}
+}
+
+// describes a single mutation of the pool. Type and Component are only
+// populated for component changes, for removals Component holds the value
+// that was detached. Destroying an entity reports the removal of each of
+// its components before the entity itself
+type Change struct {
+	Kind      ChangeKind
+	Entity    Model
+	Type      reflect.Type
+	Component Component
+}
+
+type Observer func(Change)
+
+// responsible for notifying interested parties of changes to the population
+type Observable interface {
+	// observers are called synchronously after the change has been made and
+	// in the order they were registered, the returned func unregisters it
+	Observe(Observer) (unobserve func())
+}
+
+// a dispatcher pools can embed to satisfy Observable, observers may be
+// added and removed concurrently with notifications
+type Observers struct {
+	mu        sync.Mutex
+	next      int
+	observers []registeredObserver
+}
+
+type registeredObserver struct {
+	id int
+	fn Observer
+}
+
+func (o *Observers) Observe(fn Observer) func() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	o.next++
+	id := o.next
+	o.observers = append(o.observers, registeredObserver{id, fn})
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+
+		for i := range o.observers {
+			if o.observers[i].id == id {
+				// notifications in flight hold the old slice
+				observers := make([]registeredObserver, 0, len(o.observers)-1)
+				observers = append(observers, o.observers[:i]...)
+				o.observers = append(observers, o.observers[i+1:]...)
+				return
+			}
+		}
+	}
+}
+
+// reports if anyone is listening, pools check this before doing any extra
+// work to describe a change
+func (o *Observers) Observed() bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	return len(o.observers) > 0
+}
+
+func (o *Observers) Notify(c Change) {
+	o.mu.Lock()
+	observers := o.observers
+	o.mu.Unlock()
+
+	for _, obs := range observers {
+		obs.fn(c)
+	}
+}
+
+// calls fn whenever T is attached to an entity, including when an existing
+// T is overwritten
+func OnAdd[T Component](o Observable, fn func(Model, T)) func() {
+	typ := mirrors.TypeOf[T]()
+	return o.Observe(func(c Change) {
+		if c.Kind != ComponentAdded || c.Type != typ {
+			return
+		}
+		if t, ok := c.Component.(T); ok {
+			fn(c.Entity, t)
+		}
+	})
+}
+
+// calls fn whenever T is detached from an entity, either directly or because
+// the entity was destroyed
+func OnRemove[T Component](o Observable, fn func(Model, T)) func() {
+	typ := mirrors.TypeOf[T]()
+	return o.Observe(func(c Change) {
+		if c.Kind != ComponentRemoved || c.Type != typ {
+			return
+		}
+		if t, ok := c.Component.(T); ok {
+			fn(c.Entity, t)
+		}
+	})
+}
+
+// a position in a journal
+type Cursor int
+
+// records every change made to a pool so consumers can catch up at their own
+// pace rather than reacting to each change as it happens. Journals may be
+// read while the pool is being changed
+type Journal struct {
+	mu        sync.Mutex
+	changes   []Change
+	unobserve func()
+}
+
+func NewJournal(o Observable) *Journal {
+	j := new(Journal)
+	j.unobserve = o.Observe(func(c Change) {
+		j.mu.Lock()
+		defer j.mu.Unlock()
+		j.changes = append(j.changes, c)
+	})
+	return j
+}
+
+// the position after the most recent change
+func (j *Journal) Cursor() Cursor {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+	return Cursor(len(j.changes))
+}
+
+// every change recorded after the cursor, in the order they happened
+func (j *Journal) Since(c Cursor) reiterate.Iterator[Change] {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	if int(c) > len(j.changes) {
+		c = Cursor(len(j.changes))
+	}
+	since := make([]Change, len(j.changes)-int(c))
+	copy(since, j.changes[c:])
+	return reiterate.SliceIter(since)
+}
+
+// stops recording, changes already recorded remain available
+func (j *Journal) Close() {
+	j.mu.Lock()
+	unobserve := j.unobserve
+	j.unobserve = nil
+	j.mu.Unlock()
+
+	if unobserve != nil {
+		unobserve()
+	}
+}
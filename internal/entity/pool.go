@@ -1,14 +1,69 @@
 package entity
 
+import (
+	"reflect"
+
+	"github.com/etc-sudonters/substrate/reiterate"
+)
+
 // responsible for the total administration of a population of models
+//
+// pools are safe for concurrent use. Reads -- queries, fetches, prepared
+// query answers and reading components through views -- may run in parallel
+// with one another. Mutations, including snapshotting, are serialized and
+// exclude readers for their duration. Observers are called while the
+// mutation's lock is still held so they must not call back into the pool,
+// use a Journal to react to changes outside of the pool's lock
 type Pool interface {
 	Queryable
 	Manager
+	Checkpointer
+	Observable
+	Relatable
+	Batcher
+}
+
+// responsible for applying many mutations under a single acquisition of the
+// pool's write lock
+type Batcher interface {
+	// fn has exclusive access to the pool until it returns. Views acquire
+	// the pool's lock themselves and must not be used inside fn, everything
+	// fn needs is available from the batch. Mutations made before fn returns
+	// an error are kept
+	Batch(fn func(Batch) error) error
+}
+
+// the mutations available inside Batcher.Batch
+type Batch interface {
+	Create() (Model, error)
+	Destroy(Model) error
+	Add(Model, Component) error
+	Remove(Model, Component) error
+	Relate(source, target Model, r Component) error
+	Component(Model, reflect.Type) (Component, error)
 }
 
-// responsible for creation of models
+// an opaque capture of a pool's state, only the pool that produced it
+// understands its contents
+type Snapshot interface{}
+
+// responsible for capturing and rewinding the state of a population
+type Checkpointer interface {
+	// capture the current state of every model and component, snapshots are
+	// cheap to take and remain valid after being restored from
+	Snapshot() Snapshot
+	// rewind the pool to the captured state, models created since the
+	// snapshot become stale and destroyed models are revived
+	Restore(Snapshot) error
+}
+
+// responsible for creation and destruction of models
 type Manager interface {
 	Create() (View, error)
+	// removes every component from the model and releases its id for reuse,
+	// any outstanding handles to the model become stale and operations on
+	// them return ErrEntityNotExist
+	Destroy(Model) error
 }
 
 // responsible for looking either individual models or creating a subset of the
@@ -21,4 +76,20 @@ type Queryable interface {
 	Get(m Model, components []interface{})
 	// return the specific model from the pool
 	Fetch(m Model) (View, error)
+	// compile the filter into a query whose matches are kept current as the
+	// population changes
+	Prepare(f Filter) (PreparedQuery, error)
+}
+
+// a standing query, answers are maintained as components are added and
+// removed rather than computed when asked
+type PreparedQuery interface {
+	// the number of models that currently match
+	Count() int
+	// reports if the model currently matches
+	Contains(Model) bool
+	// the current matches in ascending model order
+	Iter() reiterate.Iterator[View]
+	// stop maintaining the query, its answers are undefined afterwards
+	Close()
 }
@@ -0,0 +1,242 @@
+package entity
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"sort"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+)
+
+var ErrNotRelated = errors.New("models are not related")
+
+// responsible for pairing models with one another. A relation's kind is the
+// type of the component describing it and a source may be related to many
+// targets, and a target to many sources, under the same kind. Relations only
+// hold while both models are alive
+type Relatable interface {
+	// pairs source with target under r's type, relating an existing pair
+	// again replaces the relation's value
+	Relate(source, target Model, r Component) error
+	// it is not an error to unrelate models that are not related
+	Unrelate(kind reflect.Type, source, target Model) error
+	// the value the pair was related with
+	Relation(kind reflect.Type, source, target Model) (Component, error)
+	// every model source is related to ordered by slot
+	Targets(kind reflect.Type, source Model) []Model
+	// every model related to target ordered by slot
+	Sources(kind reflect.Type, target Model) []Model
+	// every pair related under kind ordered by source then target
+	Pairs(kind reflect.Type) []Pair
+}
+
+type Pair struct {
+	Source Model
+	Target Model
+	Value  Component
+}
+
+// relates source to target with R's zero value, intended for relations that
+// carry no data
+func Relate[R Component](p Relatable, source, target Model) error {
+	var r R
+	return p.Relate(source, target, r)
+}
+
+func RelateWith[R Component](p Relatable, source, target Model, r R) error {
+	return p.Relate(source, target, r)
+}
+
+func Unrelate[R Component](p Relatable, source, target Model) error {
+	return p.Unrelate(mirrors.TypeOf[R](), source, target)
+}
+
+func GetRelation[R Component](p Relatable, source, target Model) (R, error) {
+	var r R
+	c, err := p.Relation(mirrors.TypeOf[R](), source, target)
+	if err != nil {
+		return r, err
+	}
+	return c.(R), nil
+}
+
+func Targets[R Component](p Relatable, source Model) []Model {
+	return p.Targets(mirrors.TypeOf[R](), source)
+}
+
+func Sources[R Component](p Relatable, target Model) []Model {
+	return p.Sources(mirrors.TypeOf[R](), target)
+}
+
+// storage pools can embed to satisfy the read half of Relatable. Pools are
+// responsible for checking both models are alive before calling Link and
+// for calling Forget when a model is destroyed
+type Relations struct {
+	kinds map[reflect.Type]*relation
+}
+
+type relation struct {
+	forward  map[Model]map[Model]Component
+	backward map[Model]map[Model]struct{}
+}
+
+func (r *Relations) Link(source, target Model, c Component) error {
+	if c == nil {
+		return fmt.Errorf("%w: nil relation between %s and %s", ErrNilComponentPtr, source, target)
+	}
+
+	kind := PierceComponentType(c)
+	if r.kinds == nil {
+		r.kinds = make(map[reflect.Type]*relation)
+	}
+
+	rel, ok := r.kinds[kind]
+	if !ok {
+		rel = &relation{
+			forward:  make(map[Model]map[Model]Component),
+			backward: make(map[Model]map[Model]struct{}),
+		}
+		r.kinds[kind] = rel
+	}
+
+	if rel.forward[source] == nil {
+		rel.forward[source] = make(map[Model]Component)
+	}
+	if rel.backward[target] == nil {
+		rel.backward[target] = make(map[Model]struct{})
+	}
+
+	rel.forward[source][target] = c
+	rel.backward[target][source] = struct{}{}
+	return nil
+}
+
+func (r *Relations) Unlink(kind reflect.Type, source, target Model) {
+	rel, ok := r.kinds[kind]
+	if !ok {
+		return
+	}
+	rel.unlink(source, target)
+}
+
+// drops every relation the model takes part in
+func (r *Relations) Forget(m Model) {
+	for _, rel := range r.kinds {
+		for target := range rel.forward[m] {
+			rel.unlink(m, target)
+		}
+		for source := range rel.backward[m] {
+			rel.unlink(source, m)
+		}
+	}
+}
+
+func (r *Relations) Relation(kind reflect.Type, source, target Model) (Component, error) {
+	if rel, ok := r.kinds[kind]; ok {
+		if c, ok := rel.forward[source][target]; ok {
+			return c, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: %s between %s and %s", ErrNotRelated, kind, source, target)
+}
+
+func (r *Relations) Targets(kind reflect.Type, source Model) []Model {
+	rel, ok := r.kinds[kind]
+	if !ok {
+		return nil
+	}
+
+	targets := make([]Model, 0, len(rel.forward[source]))
+	for target := range rel.forward[source] {
+		targets = append(targets, target)
+	}
+	sortModels(targets)
+	return targets
+}
+
+func (r *Relations) Sources(kind reflect.Type, target Model) []Model {
+	rel, ok := r.kinds[kind]
+	if !ok {
+		return nil
+	}
+
+	sources := make([]Model, 0, len(rel.backward[target]))
+	for source := range rel.backward[target] {
+		sources = append(sources, source)
+	}
+	sortModels(sources)
+	return sources
+}
+
+func (r *Relations) Pairs(kind reflect.Type) []Pair {
+	rel, ok := r.kinds[kind]
+	if !ok {
+		return nil
+	}
+
+	var pairs []Pair
+	for source, targets := range rel.forward {
+		for target, c := range targets {
+			pairs = append(pairs, Pair{source, target, c})
+		}
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i].Source != pairs[j].Source {
+			return pairs[i].Source.Index() < pairs[j].Source.Index()
+		}
+		return pairs[i].Target.Index() < pairs[j].Target.Index()
+	})
+	return pairs
+}
+
+// a deep copy suitable for snapshotting
+func (r *Relations) Clone() Relations {
+	var clone Relations
+	if r.kinds == nil {
+		return clone
+	}
+
+	clone.kinds = make(map[reflect.Type]*relation, len(r.kinds))
+	for kind, rel := range r.kinds {
+		copied := &relation{
+			forward:  make(map[Model]map[Model]Component, len(rel.forward)),
+			backward: make(map[Model]map[Model]struct{}, len(rel.backward)),
+		}
+		for source, targets := range rel.forward {
+			copied.forward[source] = make(map[Model]Component, len(targets))
+			for target, c := range targets {
+				copied.forward[source][target] = c
+			}
+		}
+		for target, sources := range rel.backward {
+			copied.backward[target] = make(map[Model]struct{}, len(sources))
+			for source := range sources {
+				copied.backward[target][source] = struct{}{}
+			}
+		}
+		clone.kinds[kind] = copied
+	}
+	return clone
+}
+
+func (rel *relation) unlink(source, target Model) {
+	if targets, ok := rel.forward[source]; ok {
+		delete(targets, target)
+		if len(targets) == 0 {
+			delete(rel.forward, source)
+		}
+	}
+
+	if sources, ok := rel.backward[target]; ok {
+		delete(sources, source)
+		if len(sources) == 0 {
+			delete(rel.backward, target)
+		}
+	}
+}
+
+func sortModels(models []Model) {
+	sort.Slice(models, func(i, j int) bool { return models[i].Index() < models[j].Index() })
+}
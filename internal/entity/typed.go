@@ -0,0 +1,130 @@
+package entity
+
+import (
+	"errors"
+	"reflect"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+)
+
+// implemented by views that can hand out components directly from their
+// backing storage, this lets the typed accessors skip AssignComponentTo's
+// reflection when the stored component is already the requested type
+type DirectView interface {
+	View
+	Component(reflect.Type) (Component, error)
+}
+
+// retrieves the component T from the view. T must be a concrete component type
+// rather than an interface. If T is a pointer type and the component was
+// stored as a value, a pointer to a copy is returned; this mirrors View.Get
+func GetComponent[T Component](v View) (T, error) {
+	var t T
+
+	if direct, ok := v.(DirectView); ok {
+		c, err := direct.Component(mirrors.TypeOf[T]())
+		if err == nil {
+			if typed, ok := c.(T); ok {
+				return typed, nil
+			}
+		} else if !isTryDerefErr(err) {
+			return t, err
+		}
+	}
+
+	if err := v.Get(&t); err != nil {
+		return t, err
+	}
+
+	return t, nil
+}
+
+// reports if T is attached to the view
+func Has[T Component](v View) bool {
+	_, err := GetComponent[T](v)
+	return err == nil
+}
+
+// attaches the component to the view
+func Add[T Component](v View, c T) error {
+	return v.Add(c)
+}
+
+// detaches T from the view, it is not an error to remove a component that is
+// not attached
+func Remove[T Component](v View) error {
+	var t T
+	return v.Remove(t)
+}
+
+// retrieves the component T for the specific model from the pool
+func GetFrom[T Component](q Queryable, m Model) (T, error) {
+	var t T
+	v, err := q.Fetch(m)
+	if err != nil {
+		return t, err
+	}
+	return GetComponent[T](v)
+}
+
+type Tuple1[A Component] struct {
+	Model Model
+	A     A
+}
+
+type Tuple2[A Component, B Component] struct {
+	Model Model
+	A     A
+	B     B
+}
+
+// returns every entity that matches the filter and has A attached along with
+// the loaded A component
+func Query1[A Component](q Queryable, fb FilterBuilder) ([]Tuple1[A], error) {
+	views, err := q.Query(fb.Clone().With(mirrors.TypeOf[A]()).Build())
+	if err != nil {
+		return nil, err
+	}
+
+	tuples := make([]Tuple1[A], len(views))
+	for i, v := range views {
+		tuples[i].Model = v.Model()
+		if tuples[i].A, err = GetComponent[A](v); err != nil {
+			return nil, err
+		}
+	}
+
+	return tuples, nil
+}
+
+// returns every entity that matches the filter and has both A and B attached
+// along with the loaded components
+func Query2[A Component, B Component](q Queryable, fb FilterBuilder) ([]Tuple2[A, B], error) {
+	views, err := q.Query(
+		fb.Clone().
+			With(mirrors.TypeOf[A]()).
+			With(mirrors.TypeOf[B]()).
+			Build(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	tuples := make([]Tuple2[A, B], len(views))
+	for i, v := range views {
+		tuples[i].Model = v.Model()
+		if tuples[i].A, err = GetComponent[A](v); err != nil {
+			return nil, err
+		}
+		if tuples[i].B, err = GetComponent[B](v); err != nil {
+			return nil, err
+		}
+	}
+
+	return tuples, nil
+}
+
+// true if the error means the component just isn't present
+func IsMissingComponent(err error) bool {
+	return err != nil && (errors.Is(err, ErrNotAssigned) || errors.Is(err, ErrNotLoaded))
+}
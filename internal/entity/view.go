@@ -1,5 +1,7 @@
 package entity
 
+import "reflect"
+
 // a mutable reference to a pool population member
 // a view may be created with some components loaded from the pool
 type View interface {
@@ -26,7 +28,7 @@ type View interface {
 			}
 
 
-		Ideally we could define this as `Get[T any](*T) error` instead
+		Prefer GetComponent[T] which avoids the double pointer dance
 	*/
 	Get(interface{}) error
 	// attaches a component to this model, this component is retrievable via Get
@@ -37,3 +39,42 @@ type View interface {
 	// Component interface
 	Remove(Component) error
 }
+
+// the optional components a query loaded into a view, views answer from these
+// before going back to their pool so they hold the values from when the query
+// ran unless they're changed through the view itself
+type Loaded struct {
+	components map[reflect.Type]Component
+}
+
+func (l *Loaded) Load(c Component) {
+	if l.components == nil {
+		l.components = make(map[reflect.Type]Component)
+	}
+	l.components[PierceComponentType(c)] = c
+}
+
+// replaces the loaded component if its type was loaded
+func (l *Loaded) Update(c Component) {
+	if l == nil {
+		return
+	}
+	if _, ok := l.components[PierceComponentType(c)]; ok {
+		l.components[PierceComponentType(c)] = c
+	}
+}
+
+func (l *Loaded) Forget(typ reflect.Type) {
+	if l != nil {
+		delete(l.components, typ)
+	}
+}
+
+func (l *Loaded) GetComponent(_ Model, typ reflect.Type) (Component, error) {
+	if l != nil {
+		if c, ok := l.components[typ]; ok {
+			return c, nil
+		}
+	}
+	return nil, ErrNotLoaded
+}
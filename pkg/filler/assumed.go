@@ -28,6 +28,8 @@ type Filler interface {
 	Fill(context.Context, world.World, Goal) error
 }
 
+// locations and items aren't restricted to a single player world, narrow
+// either side with filter.InWorld to keep a world's items at home
 type AssumedFill struct {
 	Locations entity.FilterBuilder
 	Items     entity.FilterBuilder
@@ -77,8 +79,12 @@ func (a *AssumedFill) Fill(ctx context.Context, w world.World, g Goal) error {
 			return err
 		}
 
-		loc.Add(components.Inhabited(item.Model()))
-		item.Add(components.Inhabits(loc.Model()))
+		// the goal may mark entities while sweeping the world so roll back
+		// everything rather than just the placement
+		snap := w.Entities.Snapshot()
+		if err := entity.Relate[components.Inhabits](w.Entities, item.Model(), loc.Model()); err != nil {
+			return err
+		}
 
 		solved, err = g.Reachable(ctx, w)
 		if err != nil {
@@ -86,8 +92,9 @@ func (a *AssumedFill) Fill(ctx context.Context, w world.World, g Goal) error {
 		}
 
 		if !solved {
-			loc.Remove(components.Inhabited(item.Model()))
-			item.Remove(components.Inhabits(loc.Model()))
+			if err = w.Entities.Restore(snap); err != nil {
+				return err
+			}
 			L.Push(loc)
 			I.Push(item)
 		}
@@ -2,6 +2,7 @@ package filler
 
 import (
 	"context"
+	"errors"
 	"sync"
 
 	"sudonters/zootler/internal/entity"
@@ -13,11 +14,15 @@ import (
 	"github.com/etc-sudonters/substrate/skelly/hashset"
 )
 
+// shared by every spawn's walk so additions are serialized
 type setVisitor struct {
-	s hashset.Hash[graph.Node]
+	mu *sync.Mutex
+	s  hashset.Hash[graph.Node]
 }
 
 func (s setVisitor) Visit(_ context.Context, g graph.Node) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
 	s.s.Add(g)
 	return nil
 }
@@ -25,11 +30,11 @@ func (s setVisitor) Visit(_ context.Context, g graph.Node) error {
 func FindReachableWorld(ctx context.Context, w *world.World) (hashset.Hash[graph.Node], error) {
 	reachable := hashset.New[graph.Node]()
 
-	bfs := graph.BreadthFirst[graph.Destination]{
+	bfs := breadthFirst[graph.Destination]{
 		Selector: &RulesAwareSelector[graph.Destination]{
 			w, graph.Successors, nil,
 		},
-		Visitor: setVisitor{reachable},
+		Visitor: setVisitor{new(sync.Mutex), reachable},
 	}
 
 	spawns, err := w.Entities.Query(entity.FilterBuilder{}.With(mirrors.TypeOf[components.Spawn]()).Build())
@@ -38,15 +43,16 @@ func FindReachableWorld(ctx context.Context, w *world.World) (hashset.Hash[graph
 	}
 
 	var wg sync.WaitGroup
-	for _, e := range spawns {
-		e := e
+	errs := make([]error, len(spawns))
+	for i, e := range spawns {
+		i, e := i, e
 		wg.Add(1)
 		go func() {
 			defer wg.Done()
-			bfs.Walk(ctx, w.Graph, graph.Node(e.Model()))
+			errs[i] = bfs.Walk(ctx, w.Graph, graph.Node(e.Model()))
 		}()
 	}
 
 	wg.Wait()
-	return reachable, nil
+	return reachable, errors.Join(errs...)
 }
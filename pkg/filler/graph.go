@@ -30,20 +30,24 @@ func (s RulesAwareSelector[T]) Select(g graph.Directed, n graph.Node) ([]T, erro
 
 	accessibleNeighbors := make([]T, 0, len(candidates))
 
-	var rule logic.Rule
+	var compiled logic.CompiledRule
 
 	for _, c := range candidates {
-		edge, _ := s.W.Edge(world.Edge{
+		edge, err := s.W.Edge(world.Edge{
 			Origination: entity.Model(n),
 			Destination: entity.Model(c),
 		})
-		if err := edge.Get(&rule); err != nil {
+		if err != nil {
+			return nil, err
+		}
+		if err := edge.Get(&compiled); err != nil {
 			if errors.Is(err, entity.ErrNotLoaded) {
 				panic(fmt.Errorf("edge %d exists without a rule: %w", edge.Model(), err))
 			}
 			return nil, err
 		}
 
+		rule := compiled.R
 		if rule == nil {
 			accessibleNeighbors = append(accessibleNeighbors, c)
 			continue
@@ -0,0 +1,280 @@
+package filler_test
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/internal/entity/archpool"
+	"sudonters/zootler/internal/entity/bitpool"
+	"sudonters/zootler/internal/entity/componenttable"
+	"sudonters/zootler/pkg/filler"
+	"sudonters/zootler/pkg/logic/compiler"
+	"sudonters/zootler/pkg/logic/loader"
+	"sudonters/zootler/pkg/world"
+	"sudonters/zootler/pkg/world/components"
+
+	"github.com/etc-sudonters/substrate/skelly/graph"
+	"github.com/etc-sudonters/substrate/skelly/hashset"
+)
+
+var limits = bitpool.Settings{MaxComponentId: 600, MaxEntityId: 4000}
+
+type builderFactory func(testing.TB) *world.Builder
+
+func bitpoolBuilder(tb testing.TB) *world.Builder {
+	b, err := world.LimitedBuilder(limits)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return b
+}
+
+func archpoolBuilder(tb testing.TB) *world.Builder {
+	reg, err := world.DefaultComponents()
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tbl, err := componenttable.FromRegistry(limits.MaxEntityId, reg)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	return world.NewBuilder(archpool.New(archpool.Settings{MaxComponentId: limits.MaxComponentId}), tbl)
+}
+
+// a chain of regions each holding a chest, every tenth exit needs the key
+// hidden a few chests back so each sweep opens the next stretch of the chain
+func writeChain(tb testing.TB, regions int) string {
+	var chain strings.Builder
+	chain.WriteString(`[{"region_name": "Root", "exits": {"Region 0": "True"}}`)
+	for i := 0; i < regions; i++ {
+		rule := "True"
+		if i%10 == 9 {
+			rule = fmt.Sprintf("Key_%d", i/10)
+		}
+		fmt.Fprintf(&chain, `,{"region_name": "Region %d", "locations": {"Chest %d": "True"}, "exits": {"Region %d": %q}}`, i, i, i+1, rule)
+	}
+	fmt.Fprintf(&chain, `,{"region_name": "Region %d"}]`, regions)
+
+	dir := tb.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Overworld.json"), []byte(chain.String()), 0o644); err != nil {
+		tb.Fatal(err)
+	}
+	return dir
+}
+
+// loads and compiles the chain then hides each key in its stretch of chests
+func chainWorld(tb testing.TB, mk builderFactory, regions int) (*world.Builder, world.World) {
+	tb.Helper()
+	l, err := loader.Load(writeChain(tb, regions))
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	b := mk(tb)
+	if err := b.LoadLogic(l, nil); err != nil {
+		tb.Fatal(err)
+	}
+	env, rw, err := compiler.NewEnvironment(b, l.Helpers)
+	if err != nil {
+		tb.Fatal(err)
+	}
+	tb.Cleanup(func() { compiler.Release(env) })
+	if err := compiler.CompileWorld(b, env, rw); err != nil {
+		tb.Fatal(err)
+	}
+
+	for i := 9; i < regions; i += 10 {
+		key := b.NameCache[components.Name(fmt.Sprintf("Key_%d", i/10))]
+		chest := b.NameCache[components.Name(fmt.Sprintf("Chest %d", i-5))]
+		if err := entity.Relate[components.Inhabits](b.Pool, key.Model(), chest.Model()); err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return b, b.Build()
+}
+
+// the reachability loop the filler runs: find what's reachable, collect the
+// tokens sitting in reachable locations and repeat until nothing new is found
+func sweep(tb testing.TB, w *world.World) (reachable int, sweeps int) {
+	collected := make(map[entity.Model]bool)
+	for {
+		sweeps++
+		nodes, err := filler.FindReachableWorld(context.Background(), w)
+		if err != nil {
+			tb.Fatal(err)
+		}
+
+		if !collect(tb, w, nodes, collected) {
+			return len(nodes), sweeps
+		}
+	}
+}
+
+// collects the tokens in the reachable nodes that haven't been yet
+func collect(tb testing.TB, w *world.World, nodes hashset.Hash[graph.Node], collected map[entity.Model]bool) bool {
+	found := false
+	for node := range nodes {
+		for _, token := range entity.Sources[components.Inhabits](w.Entities, entity.Model(node)) {
+			if collected[token] {
+				continue
+			}
+			view, err := w.Entities.Fetch(token)
+			if err != nil {
+				tb.Fatal(err)
+			}
+			if err := view.Add(components.Collected{}); err != nil {
+				tb.Fatal(err)
+			}
+			collected[token] = true
+			found = true
+		}
+	}
+	return found
+}
+
+func TestSweepOpensTheChain(t *testing.T) {
+	for name, mk := range map[string]builderFactory{"bitpool": bitpoolBuilder, "archpool": archpoolBuilder} {
+		t.Run(name, func(t *testing.T) {
+			b, w := chainWorld(t, mk, 50)
+			_, sweeps := sweep(t, &w)
+			reachable, err := filler.FindReachableWorld(context.Background(), &w)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if !reachable.Exists(graph.Node(b.NameCache["Region 50"].Model())) {
+				t.Fatal("expected the keys to open the whole chain")
+			}
+			if sweeps != 6 {
+				t.Fatalf("expected a sweep for each key and one to find nothing new but took %d", sweeps)
+			}
+		})
+	}
+}
+
+func BenchmarkReachabilitySweep(b *testing.B) {
+	for name, mk := range map[string]builderFactory{"bitpool": bitpoolBuilder, "archpool": archpoolBuilder} {
+		b.Run(name, func(b *testing.B) {
+			_, w := chainWorld(b, mk, 300)
+			start := w.Entities.Snapshot()
+			b.ResetTimer()
+
+			for i := 0; i < b.N; i++ {
+				sweep(b, &w)
+
+				b.StopTimer()
+				if err := w.Entities.Restore(start); err != nil {
+					b.Fatal(err)
+				}
+				b.StartTimer()
+			}
+		})
+	}
+}
+
+func TestUntrackedGraphChangesAreErrors(t *testing.T) {
+	b := bitpoolBuilder(t)
+	root, err := b.Entity("Root")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := root.Add(components.Spawn{}); err != nil {
+		t.Fatal(err)
+	}
+	forest, err := b.Entity("Kokiri Forest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Node(root)
+	b.Node(forest)
+	// skips the builder so the pool never learns of the connection
+	if err := b.Graph.AddEdge(graph.Origination(root.Model()), graph.Destination(forest.Model())); err != nil {
+		t.Fatal(err)
+	}
+
+	w := b.Build()
+	if _, err := filler.FindReachableWorld(context.Background(), &w); !errors.Is(err, world.ErrEntityNotConnected) {
+		t.Fatalf("expected %s but got %v", world.ErrEntityNotConnected, err)
+	}
+}
+
+// each player's key is hidden in the other player's world, opening either
+// temple has to wait for its own player's key
+func TestWorldsCountTheirOwnInventory(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Overworld.json"), []byte(`[
+		{"region_name": "Root", "exits": {"Kokiri Forest": "True"}},
+		{"region_name": "Kokiri Forest", "locations": {"Forest Chest": "True"}, "exits": {"Temple": "Key"}},
+		{"region_name": "Temple", "locations": {"Temple Chest": "True"}}
+	]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := loader.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := bitpoolBuilder(t)
+	players := []*world.Builder{b, b.ForWorld(1)}
+	for _, player := range players {
+		if err := player.LoadLogic(l, nil); err != nil {
+			t.Fatal(err)
+		}
+		env, rw, err := compiler.NewEnvironment(player, l.Helpers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { compiler.Release(env) })
+		if err := compiler.CompileWorld(player, env, rw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	place := func(token, location *world.Builder, tokenName, locationName components.Name) {
+		t.Helper()
+		if err := entity.Relate[components.Inhabits](b.Pool, token.NameCache[tokenName].Model(), location.NameCache[locationName].Model()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	place(players[0], players[1], "Key", "Temple Chest")
+	place(players[1], players[0], "Key", "Forest Chest")
+
+	w := b.Build()
+	collected := make(map[entity.Model]bool)
+	reachable := func() hashset.Hash[graph.Node] {
+		t.Helper()
+		nodes, err := filler.FindReachableWorld(context.Background(), &w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		collect(t, &w, nodes, collected)
+		return nodes
+	}
+	temple := func(nodes hashset.Hash[graph.Node], player *world.Builder) bool {
+		return nodes.Exists(graph.Node(player.NameCache["Temple"].Model()))
+	}
+
+	nodes := reachable()
+	if temple(nodes, players[0]) || temple(nodes, players[1]) {
+		t.Fatal("expected both temples to start closed")
+	}
+
+	nodes = reachable()
+	if temple(nodes, players[0]) {
+		t.Fatal("expected player 1's key to leave player 0's temple closed")
+	}
+	if !temple(nodes, players[1]) {
+		t.Fatal("expected player 1's key from player 0's forest to open player 1's temple")
+	}
+
+	nodes = reachable()
+	if !temple(nodes, players[0]) {
+		t.Fatal("expected player 0's key from player 1's temple to open player 0's temple")
+	}
+}
@@ -0,0 +1,54 @@
+package filler
+
+import (
+	"context"
+	"errors"
+
+	"github.com/etc-sudonters/substrate/skelly/graph"
+	"github.com/etc-sudonters/substrate/skelly/hashset"
+	"github.com/etc-sudonters/substrate/skelly/queue"
+)
+
+// graph.BreadthFirst sizes what it has seen by the graph's node count but
+// nodes are entity models, which are much sparser than that
+type breadthFirst[T graph.Direction] struct {
+	graph.Visitor
+	graph.Selector[T]
+}
+
+var _ graph.Walker[graph.Destination] = breadthFirst[graph.Destination]{}
+
+func (b breadthFirst[T]) Walk(ctx context.Context, g graph.Directed, r graph.Node) error {
+	q := &queue.Q[graph.Node]{r}
+	seen := hashset.New[graph.Node]()
+	seen.Add(r)
+
+	for len(*q) > 0 {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+
+		node, _ := q.Pop()
+		if err := b.Visitor.Visit(ctx, node); err != nil {
+			if errors.Is(err, graph.ErrVisitTerminated) {
+				return nil
+			}
+			return err
+		}
+
+		neighbors, err := b.Selector.Select(g, node)
+		if err != nil {
+			return err
+		}
+
+		for _, neighbor := range neighbors {
+			neighbor := graph.Node(neighbor)
+			if !seen.Exists(neighbor) {
+				seen.Add(neighbor)
+				q.Push(neighbor)
+			}
+		}
+	}
+
+	return nil
+}
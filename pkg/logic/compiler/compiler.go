@@ -0,0 +1,483 @@
+// compiles rewritten rules into trees of logic.Rule so they can be fulfilled
+// without walking the AST
+package compiler
+
+import (
+	"errors"
+	"fmt"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/logic/interpreter"
+	"sudonters/zootler/pkg/rules/ast"
+	"sudonters/zootler/pkg/world/components"
+)
+
+var ErrCannotCompile = errors.New("cannot compile rule")
+
+// helpers calling helpers with arguments are expanded at every call
+const maxExpansion = 64
+
+// Rules should be rewritten with interpreter.Inliner first, identifiers are
+// resolved from the environment they're compiled in. Bare tokens mean the
+// token has been collected, bool ops with a constant side are folded away so
+// a branch that can't be taken isn't compiled.
+type Compiler struct {
+	Globals interpreter.Environment
+	World   components.WorldId
+	// helpers without params are inlined with this if it's set, otherwise
+	// their bodies are compiled as they're written
+	Inliner *interpreter.Inliner
+	// HasRules count with this if it's set, New shares the inventory of the
+	// environment's has when it counts for the same world
+	Inventory *logic.Inventory
+	// helpers without args are compiled once and shared between rules
+	helpers map[string]*helperRule
+}
+
+func New(globals interpreter.Environment, world components.WorldId) *Compiler {
+	c := &Compiler{
+		Globals: globals,
+		World:   world,
+		helpers: make(map[string]*helperRule),
+	}
+	if has := hasOf(globals); has != nil && has.Inventory.World == world {
+		c.Inventory = has.Inventory
+	}
+	return c
+}
+
+// the environment's has if it counts collected tokens
+func hasOf(env interpreter.Environment) *interpreter.Zoot_HasQuantityOf {
+	v, ok := env.Get("has")
+	if !ok {
+		return nil
+	}
+	b, ok := v.(interpreter.BuiltIn)
+	if !ok {
+		return nil
+	}
+	has, _ := b.F.(*interpreter.Zoot_HasQuantityOf)
+	return has
+}
+
+func (c *Compiler) Compile(rule ast.Expression, env interpreter.Environment) (logic.Rule, error) {
+	return c.compile(rule, &scope{env: env})
+}
+
+// helper params are bound to the caller's args, which are compiled where they
+// were written
+type scope struct {
+	env    interpreter.Environment
+	args   map[string]ast.Expression
+	caller *scope
+	depth  int
+}
+
+func (s *scope) arg(name string) (ast.Expression, *scope, bool) {
+	arg, ok := s.args[name]
+	return arg, s.caller, ok
+}
+
+// refers to a helper by name so helpers may refer to themselves
+type helperRule struct {
+	Name string
+	R    logic.Rule
+}
+
+func (h *helperRule) Fulfill(q entity.Queryable) (bool, error) {
+	if h.R == nil {
+		return false, fmt.Errorf("helper %q used while it was compiled", h.Name)
+	}
+	return h.R.Fulfill(q)
+}
+
+func cannotCompile(format string, v ...any) error {
+	return fmt.Errorf("%w: %s", ErrCannotCompile, fmt.Sprintf(format, v...))
+}
+
+func (c *Compiler) compile(node ast.Expression, s *scope) (logic.Rule, error) {
+	switch node := node.(type) {
+	case *ast.Literal:
+		return c.literal(node, s)
+	case *ast.Identifier:
+		if arg, caller, ok := s.arg(node.Value); ok {
+			return c.compile(arg, caller)
+		}
+		v, ok := s.env.Get(node.Value)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", interpreter.UnknownIdentifierErr, node.Value)
+		}
+		return c.value(v, node.Value, s)
+	case *ast.BoolOp:
+		return c.boolOp(node, s)
+	case *ast.BinOp:
+		v, err := c.known(node, s)
+		if err != nil {
+			return nil, err
+		}
+		return c.value(v, "", s)
+	case *ast.UnaryOp:
+		if node.Op != ast.UnaryNot {
+			return nil, cannotCompile("unknown unary op %q", node.Op)
+		}
+		r, err := c.compile(node.Target, s)
+		if err != nil {
+			return nil, err
+		}
+		if b, ok := constant(r); ok {
+			return constRule(!b), nil
+		}
+		return logic.NotRule{R: r}, nil
+	case *ast.Call:
+		return c.call(node, s)
+	default:
+		return nil, cannotCompile("%s", node.Type())
+	}
+}
+
+// quoted names refer to tokens the same way the inliner reads them, the
+// token is under its quoted name if a helper has the same name
+func (c *Compiler) literal(node *ast.Literal, s *scope) (logic.Rule, error) {
+	if str, ok := node.Value.(string); ok {
+		for _, name := range []string{fmt.Sprintf("%q", str), str} {
+			if v, ok := s.env.Get(name); ok && v.Type() == interpreter.TOK_TYPE {
+				return c.value(v, "", s)
+			}
+		}
+	}
+	return c.value(interpreter.Box(node.Value), "", s)
+}
+
+// name is the identifier the value was found under, if any
+func (c *Compiler) value(v interpreter.Value, name string, s *scope) (logic.Rule, error) {
+	switch v := v.(type) {
+	case interpreter.Boolean:
+		return setting(name, v.Value), nil
+	case interpreter.Number:
+		return setting(name, v.Value != 0), nil
+	case interpreter.String:
+		return setting(name, v.Value != ""), nil
+	case interpreter.Token:
+		return logic.HasRule{Component: v.Component, Qty: 1, World: c.World, Inventory: c.Inventory}, nil
+	case interpreter.Fn, interpreter.PartiallyEvaluatedFn:
+		return c.helper(v.(interpreter.Callable), s)
+	case interpreter.BuiltIn:
+		if v.Arity() != 0 {
+			return nil, cannotCompile("%s takes %d arguments", v.Name, v.Arity())
+		}
+		return c.builtIn(v, nil)
+	default:
+		return nil, cannotCompile("%T", v)
+	}
+}
+
+func setting(name string, value bool) logic.Rule {
+	if name == "" {
+		return constRule(value)
+	}
+	return logic.SettingRule{Name: name, Value: value}
+}
+
+func constRule(b bool) logic.Rule {
+	if b {
+		return logic.TrueRule
+	}
+	return logic.FalseRule
+}
+
+func constant(r logic.Rule) (bool, bool) {
+	switch r := r.(type) {
+	case logic.SettingRule:
+		return r.Value, true
+	}
+
+	switch r {
+	case logic.TrueRule:
+		return true, true
+	case logic.FalseRule:
+		return false, true
+	default:
+		return false, false
+	}
+}
+
+func (c *Compiler) boolOp(op *ast.BoolOp, s *scope) (logic.Rule, error) {
+	lhs, err := c.compile(op.Left, s)
+	if err != nil {
+		return nil, err
+	}
+
+	// the right side decides the op unless the left side already has
+	if l, ok := constant(lhs); ok {
+		if l == (op.Op == ast.BoolOpOr) {
+			return lhs, nil
+		}
+		return c.compile(op.Right, s)
+	}
+
+	rhs, err := c.compile(op.Right, s)
+	if err != nil {
+		return nil, err
+	}
+
+	if r, ok := constant(rhs); ok {
+		if r == (op.Op == ast.BoolOpOr) {
+			return rhs, nil
+		}
+		return lhs, nil
+	}
+
+	if op.Op == ast.BoolOpOr {
+		return logic.OrRule{LHS: lhs, RHS: rhs}, nil
+	}
+	return logic.AndRule{LHS: lhs, RHS: rhs}, nil
+}
+
+// helpers without params are compiled in the environment they're called in
+// by the interpreter, helpers with params are expanded where they're called
+func (c *Compiler) helper(fn interpreter.Callable, s *scope) (logic.Rule, error) {
+	if fn.Arity() != 0 {
+		return nil, cannotCompile("%s takes %d arguments", fn, fn.Arity())
+	}
+
+	name := fn.String()
+	if h, ok := c.helpers[name]; ok {
+		return h, nil
+	}
+
+	h := &helperRule{Name: name}
+	c.helpers[name] = h
+
+	var env interpreter.Environment
+	var body ast.Expression
+	var err error
+	switch fn := fn.(type) {
+	case interpreter.Fn:
+		env, body = c.Globals, fn.Body
+		if c.Inliner != nil {
+			body, err = c.inline(&ast.Call{Callee: fn.Name}, env)
+		}
+	case interpreter.PartiallyEvaluatedFn:
+		env, body = fn.Env, fn.Body
+	}
+
+	var r logic.Rule
+	if err == nil {
+		r, err = c.compile(body, &scope{env: env, depth: s.depth})
+	}
+	if err != nil {
+		delete(c.helpers, name)
+		return nil, fmt.Errorf("compiling %s: %w", name, err)
+	}
+
+	// constant helpers are inlined into the rules that use them
+	if _, ok := constant(r); ok {
+		c.helpers[name] = &helperRule{Name: name, R: r}
+		return r, nil
+	}
+
+	h.R = r
+	return h, nil
+}
+
+func (c *Compiler) call(call *ast.Call, s *scope) (logic.Rule, error) {
+	callee, callScope := call.Callee, s
+	for {
+		ident, ok := callee.(*ast.Identifier)
+		if !ok {
+			return nil, cannotCompile("calling %s", callee.Type())
+		}
+		arg, caller, isArg := callScope.arg(ident.Value)
+		if !isArg {
+			break
+		}
+		callee, callScope = arg, caller
+	}
+
+	name := callee.(*ast.Identifier).Value
+	v, ok := callScope.env.Get(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", interpreter.UnknownIdentifierErr, name)
+	}
+
+	fn, ok := v.(interpreter.Callable)
+	if !ok {
+		return nil, cannotCompile("%v is not callable", v)
+	}
+	if fn.Arity() != len(call.Args) {
+		return nil, cannotCompile("%s takes %d arguments but was given %d", name, fn.Arity(), len(call.Args))
+	}
+
+	switch fn := fn.(type) {
+	case interpreter.BuiltIn:
+		args := make([]interpreter.Value, len(call.Args))
+		for i, arg := range call.Args {
+			var err error
+			if args[i], err = c.known(arg, s); err != nil {
+				return nil, fmt.Errorf("calling %s: %w", name, err)
+			}
+		}
+		if fn.Name == "has" {
+			return c.has(args)
+		}
+		return c.builtIn(fn, args)
+	case interpreter.Fn:
+		if len(call.Args) == 0 {
+			return c.helper(fn, s)
+		}
+		if s.depth >= maxExpansion {
+			return nil, cannotCompile("%s is expanded too deeply", name)
+		}
+		args := make(map[string]ast.Expression, len(fn.Params))
+		for i, param := range fn.Params {
+			args[param] = call.Args[i]
+		}
+		return c.compile(fn.Body, &scope{env: c.Globals, args: args, caller: s, depth: s.depth + 1})
+	default:
+		return c.helper(fn, s)
+	}
+}
+
+func (c *Compiler) inline(node ast.Expression, env interpreter.Environment) (ast.Expression, error) {
+	expr, err := c.Inliner.Rewrite(node, env)
+	if err != nil {
+		return nil, fmt.Errorf("%w: inlining: %w", ErrCannotCompile, err)
+	}
+	return expr, nil
+}
+
+// builtin args and both sides of comparisons must be known while compiling
+func (c *Compiler) known(node ast.Expression, s *scope) (interpreter.Value, error) {
+	switch node := node.(type) {
+	case *ast.Literal:
+		return interpreter.Box(node.Value), nil
+	case *ast.Identifier:
+		if arg, caller, ok := s.arg(node.Value); ok {
+			return c.known(arg, caller)
+		}
+		v, ok := s.env.Get(node.Value)
+		if !ok {
+			return nil, fmt.Errorf("%w: %q", interpreter.UnknownIdentifierErr, node.Value)
+		}
+		return v, nil
+	case *ast.BinOp:
+		return c.binOp(node, s)
+	default:
+		return nil, cannotCompile("%s must be known while compiling", node.Type())
+	}
+}
+
+func (c *Compiler) binOp(op *ast.BinOp, s *scope) (interpreter.Value, error) {
+	if op.Op == ast.BinOpContains || op.Op == ast.BinOpNotContains {
+		return c.contains(op, s)
+	}
+
+	l, err := c.known(op.Left, s)
+	if err != nil {
+		return nil, err
+	}
+	r, err := c.known(op.Right, s)
+	if err != nil {
+		return nil, err
+	}
+
+	switch op.Op {
+	case ast.BinOpEq:
+		return interpreter.Boolean{Value: l.Eq(r)}, nil
+	case ast.BinOpNotEq:
+		return interpreter.Boolean{Value: !l.Eq(r)}, nil
+	}
+
+	ln, lok := l.(interpreter.Number)
+	rn, rok := r.(interpreter.Number)
+	if !lok || !rok {
+		return nil, cannotCompile("%s only between numbers not %v and %v", op.Op, l, r)
+	}
+
+	switch op.Op {
+	case ast.BinOpLt:
+		return interpreter.Boolean{Value: ln.Value < rn.Value}, nil
+	case ast.BinOpLtEq:
+		return interpreter.Boolean{Value: ln.Value <= rn.Value}, nil
+	case ast.BinOpGt:
+		return interpreter.Boolean{Value: ln.Value > rn.Value}, nil
+	case ast.BinOpGtEq:
+		return interpreter.Boolean{Value: ln.Value >= rn.Value}, nil
+	case ast.BinOpAdd:
+		return interpreter.Number{Value: ln.Value + rn.Value}, nil
+	case ast.BinOpSub:
+		return interpreter.Number{Value: ln.Value - rn.Value}, nil
+	case ast.BinOpMul:
+		return interpreter.Number{Value: ln.Value * rn.Value}, nil
+	default:
+		return nil, cannotCompile("unknown op %q", op.Op)
+	}
+}
+
+// only the inliner knows what settings collections hold
+func (c *Compiler) contains(op *ast.BinOp, s *scope) (interpreter.Value, error) {
+	if c.Inliner == nil {
+		return nil, cannotCompile("%q needs an inliner", op.Op)
+	}
+
+	item, err := c.known(op.Left, s)
+	if err != nil {
+		return nil, err
+	}
+	str, ok := item.(interpreter.String)
+	collection, isIdent := op.Right.(*ast.Identifier)
+	if !ok || !isIdent {
+		return nil, cannotCompile("%q only checks names in settings", op.Op)
+	}
+
+	expr, err := c.inline(&ast.BinOp{
+		Left:  interpreter.Literalify(str),
+		Op:    op.Op,
+		Right: collection,
+	}, s.env)
+	if err != nil {
+		return nil, err
+	}
+
+	literal, ok := expr.(*ast.Literal)
+	if !ok {
+		return nil, cannotCompile("%s %s %s wasn't inlined", str, op.Op, collection.Value)
+	}
+	return interpreter.ReifyLiteral(literal), nil
+}
+
+func (c *Compiler) has(args []interpreter.Value) (logic.Rule, error) {
+	token, ok := args[0].(interpreter.Token)
+	if !ok {
+		return nil, cannotCompile("has expects a token not %v", args[0])
+	}
+	qty, ok := args[1].(interpreter.Number)
+	if !ok {
+		return nil, cannotCompile("has expects a quantity not %v", args[1])
+	}
+	return logic.HasRule{Component: token.Component, Qty: int(qty.Value), World: c.World, Inventory: c.Inventory}, nil
+}
+
+// builtins that only count a token are compiled like has, the rest query
+// the entities the rule is fulfilled against
+func (c *Compiler) builtIn(b interpreter.BuiltIn, args []interpreter.Value) (logic.Rule, error) {
+	switch b.F.(type) {
+	case interpreter.Zoot_HasMedallions:
+		return c.has([]interpreter.Value{interpreter.Medallions, args[0]})
+	case interpreter.Zoot_HasBottle:
+		return c.has([]interpreter.Value{interpreter.Bottles, interpreter.Box(1)})
+	}
+
+	return logic.CallRule{
+		Name: b.Name,
+		Fn: func(q entity.Queryable) (bool, error) {
+			t := interpreter.New(c.Globals).Querying(q)
+			v, err := b.Call(t, args)
+			if err != nil {
+				return false, fmt.Errorf("calling %s: %w", b.Name, err)
+			}
+			return t.IsTruthy(v)
+		},
+	}, nil
+}
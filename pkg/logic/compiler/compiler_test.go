@@ -0,0 +1,314 @@
+package compiler
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/internal/entity/bitpool"
+	"sudonters/zootler/pkg/filler"
+	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/logic/interpreter"
+	"sudonters/zootler/pkg/logic/loader"
+	"sudonters/zootler/pkg/rules/parser"
+	"sudonters/zootler/pkg/world"
+	"sudonters/zootler/pkg/world/components"
+
+	"github.com/etc-sudonters/substrate/skelly/graph"
+)
+
+type bow struct{}
+type hookshot struct{}
+
+func testEnv() interpreter.Environment {
+	env := interpreter.NewEnv()
+	env.Set("Bow", interpreter.Token{Component: reflect.TypeOf(bow{}), Literal: "Bow"})
+	env.Set("Hookshot", interpreter.Token{Component: reflect.TypeOf(hookshot{}), Literal: "Hookshot"})
+	env.SetBuiltIn("has", 2, interpreter.BuiltInFn(func(interpreter.Interpreter, []interpreter.Value) (interpreter.Value, error) {
+		panic("has should be compiled to a HasRule")
+	}))
+	env.SetBuiltIn("has_bottle", 0, interpreter.Zoot_HasBottle{})
+	env.SetBuiltIn("has_medallions", 1, interpreter.Zoot_HasMedallions{})
+	env.SetString("age", "child")
+	env.SetBool("open_forest", true)
+
+	for decl, body := range map[string]string{
+		"is_child":       "age == 'child'",
+		"is_adult":       "age == 'adult'",
+		"can_use(item)":  "is_child and item",
+		"has_bows(qty)":  "has(Bow, qty)",
+		"both_bows(qty)": "has_bows(qty) and has_bows(qty + 1)",
+	} {
+		d, err := parser.Parse(decl)
+		if err != nil {
+			panic(err)
+		}
+		b, err := parser.Parse(body)
+		if err != nil {
+			panic(err)
+		}
+		interpreter.FunctionDecl(d, b, env)
+	}
+	return env
+}
+
+func compile(env interpreter.Environment, rule string) (logic.Rule, error) {
+	expr, err := parser.Parse(rule)
+	if err != nil {
+		return nil, err
+	}
+	return New(env, 0).Compile(expr, env)
+}
+
+func TestRulesAreCompiledToTrees(t *testing.T) {
+	env := testEnv()
+	bows := func(qty int) logic.Rule {
+		return logic.HasRule{Component: reflect.TypeOf(bow{}), Qty: qty}
+	}
+	hookshots := logic.HasRule{Component: reflect.TypeOf(hookshot{}), Qty: 1}
+
+	for rule, expected := range map[string]logic.Rule{
+		"Bow":                   bows(1),
+		"has(Bow, 2)":           bows(2),
+		"not Bow":               logic.NotRule{R: bows(1)},
+		"Bow or Hookshot":       logic.OrRule{LHS: bows(1), RHS: hookshots},
+		"Bow and not Hookshot":  logic.AndRule{LHS: bows(1), RHS: logic.NotRule{R: hookshots}},
+		"open_forest":           logic.SettingRule{Name: "open_forest", Value: true},
+		"True or Hookshot":      logic.TrueRule,
+		"is_adult and Hookshot": logic.FalseRule,
+		"is_child and Bow":      bows(1),
+		"not is_adult":          logic.TrueRule,
+		"can_use(Hookshot)":     hookshots,
+		"both_bows(2)":          logic.AndRule{LHS: bows(2), RHS: bows(3)},
+		"has_bottle":            logic.HasRule{Component: interpreter.Bottles.Component, Qty: 1},
+		"has_medallions(3)":     logic.HasRule{Component: interpreter.Medallions.Component, Qty: 3},
+	} {
+		compiled, err := compile(env, rule)
+		if err != nil {
+			t.Fatalf("%s: %s", rule, err)
+		}
+		if compiled != expected {
+			t.Errorf("expected %q to compile to %#v but got %#v", rule, expected, compiled)
+		}
+	}
+}
+
+func TestUncompilableRules(t *testing.T) {
+	env := testEnv()
+
+	if _, err := compile(env, "Bow and Slingshot"); !errors.Is(err, interpreter.UnknownIdentifierErr) {
+		t.Errorf("expected %s but got %v", interpreter.UnknownIdentifierErr, err)
+	}
+
+	for _, rule := range []string{"Bow < 1", "is_child(Bow)", "age()", "has(Bow, Bow + 1)"} {
+		if _, err := compile(env, rule); !errors.Is(err, ErrCannotCompile) {
+			t.Errorf("expected %q to fail with %s but got %v", rule, ErrCannotCompile, err)
+		}
+	}
+
+	// the dead branch is never compiled
+	if _, err := compile(env, "is_adult and Slingshot"); err != nil {
+		t.Errorf("expected the dead branch to be skipped: %s", err)
+	}
+}
+
+func TestCompiledWorldIsWalkable(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		loader.HelpersFile: `{"is_child": "age == 'child'", "can_enter_deku": "is_child and Kokiri_Sword"}`,
+		"Overworld.json": `[
+			{"region_name": "Root", "exits": {"Kokiri Forest": "True"}},
+			{"region_name": "Kokiri Forest",
+				"locations": {"KF Midos Top Left Chest": "True"},
+				"exits": {"Deku Tree Lobby": "can_enter_deku"}},
+			{"region_name": "Deku Tree Lobby",
+				"locations": {"Deku Tree Map Chest": "True"}}
+		]`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := loader.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := world.LimitedBuilder(bitpool.Settings{MaxComponentId: 200, MaxEntityId: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.LoadLogic(l, nil); err != nil {
+		t.Fatal(err)
+	}
+	env, rw, err := NewEnvironment(b, l.Helpers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := CompileWorld(b, env, rw); err != nil {
+		t.Fatal(err)
+	}
+
+	w := b.Build()
+	reachable := func(name components.Name) bool {
+		t.Helper()
+		nodes, err := filler.FindReachableWorld(context.Background(), &w)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return nodes.Exists(graph.Node(b.NameCache[name].Model()))
+	}
+
+	if !reachable("KF Midos Top Left Chest") {
+		t.Fatal("expected the chest to be reachable from the root")
+	}
+	if reachable("Deku Tree Map Chest") {
+		t.Fatal("expected the deku tree to need the sword")
+	}
+
+	if err := b.NameCache["Kokiri_Sword"].Add(components.Collected{}); err != nil {
+		t.Fatal(err)
+	}
+	if !reachable("Deku Tree Map Chest") {
+		t.Fatal("expected the deku tree to be reachable with the sword")
+	}
+
+	sword, err := compile(env, "Kokiri_Sword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if has, ok := sword.(logic.HasRule); !ok || has.Inventory == nil {
+		t.Fatalf("expected tokens to be counted with the environment's inventory but got %#v", sword)
+	}
+
+	Release(env)
+	if !reachable("Deku Tree Map Chest") {
+		t.Fatal("expected released rules to keep counting")
+	}
+}
+
+func TestCompileWorldStaysInItsWorld(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Overworld.json"), []byte(`[
+		{"region_name": "Root", "exits": {"Kokiri Forest": "True"}},
+		{"region_name": "Kokiri Forest", "locations": {"KF Kokiri Sword Chest": "True", "Deku Tree Map Chest": "Kokiri_Sword"}},
+		{"region_name": "Deku Tree Lobby", "locations": {"Deku Tree Map Chest": "False"}}
+	]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := loader.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := world.LimitedBuilder(bitpool.Settings{MaxComponentId: 200, MaxEntityId: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	worlds := []*world.Builder{b, b.ForWorld(1)}
+	for _, wb := range worlds {
+		if err := wb.LoadLogic(l, nil); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	compile := func(wb *world.Builder) {
+		t.Helper()
+		env, rw, err := NewEnvironment(wb, l.Helpers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { Release(env) })
+		if err := CompileWorld(wb, env, rw); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fulfilled := func(wb *world.Builder, name components.Name) bool {
+		t.Helper()
+		rule, err := entity.GetComponent[logic.CompiledRule](wb.NameCache[name])
+		if err != nil {
+			t.Fatalf("expected %q in world %d to be compiled: %s", name, wb.World, err)
+		}
+		ok, err := rule.R.Fulfill(wb.Pool)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return ok
+	}
+
+	compile(worlds[0])
+	for name := range worlds[1].Names(1) {
+		if _, err := entity.GetComponent[logic.CompiledRule](worlds[1].NameCache[name]); err == nil {
+			t.Fatalf("expected world 1's %q to be left for its own compile", name)
+		}
+	}
+
+	compile(worlds[1])
+	if err := worlds[1].NameCache["Kokiri_Sword"].Add(components.Collected{}); err != nil {
+		t.Fatal(err)
+	}
+
+	if !fulfilled(worlds[0], "KF Kokiri Sword Chest") {
+		t.Fatal("expected locations to carry their region's rule")
+	}
+	if fulfilled(worlds[0], "Deku Tree Map Chest") {
+		t.Fatal("expected world 0's chest to count world 0's sword")
+	}
+	if !fulfilled(worlds[1], "Deku Tree Map Chest") {
+		t.Fatal("expected a location reached from two regions to be open if either rule is")
+	}
+}
+
+func TestRewritingErrorsAreReturned(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Overworld.json"), []byte(`[
+		{"region_name": "Root", "exits": {"Kokiri Forest": "True"}},
+		{"region_name": "Kokiri Forest", "exits": {"Deku Tree Lobby": "(Kokiri_Sword, 'two')", "Lost Woods": "at()"}},
+		{"region_name": "Deku Tree Lobby"},
+		{"region_name": "Lost Woods"}
+	]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := loader.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := world.LimitedBuilder(bitpool.Settings{MaxComponentId: 200, MaxEntityId: 100})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := b.LoadLogic(l, nil); err != nil {
+		t.Fatal(err)
+	}
+	env, rw, err := NewEnvironment(b, l.Helpers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer Release(env)
+
+	err = CompileWorld(b, env, rw)
+	if !errors.Is(err, interpreter.BadTupleErr) {
+		t.Fatalf("expected %s but got %v", interpreter.BadTupleErr, err)
+	}
+	if !strings.Contains(err.Error(), "at(region, rule)") {
+		t.Fatalf("expected the bad macro to be reported but got %v", err)
+	}
+
+	w := b.Build()
+	nodes, err := filler.FindReachableWorld(context.Background(), &w)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []components.Name{"Deku Tree Lobby", "Lost Woods"} {
+		if nodes.Exists(graph.Node(b.NameCache[name].Model())) {
+			t.Errorf("expected %q to stay closed behind a rule that couldn't be rewritten", name)
+		}
+	}
+}
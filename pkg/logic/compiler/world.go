@@ -0,0 +1,227 @@
+package compiler
+
+import (
+	"errors"
+	"fmt"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/logic/interpreter"
+	"sudonters/zootler/pkg/logic/loader"
+	"sudonters/zootler/pkg/rules/parser"
+	"sudonters/zootler/pkg/world"
+	"sudonters/zootler/pkg/world/components"
+	"sudonters/zootler/pkg/world/filter"
+	"sudonters/zootler/pkg/world/settings"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+	"github.com/etc-sudonters/substrate/skelly/hashset"
+)
+
+// the environment rules are rewritten and compiled in: the helpers, default
+// settings overridden by the builder's, tricks, and the builtins that are
+// implemented. Tokens are added to the builder's pool as rules refer to them
+func NewEnvironment(b *world.Builder, helpers []loader.Rule) (interpreter.Environment, *interpreter.Inliner, error) {
+	env := interpreter.NewEnv()
+	rw := interpreter.NewInliner(env)
+	rw.Settings = make(map[string]any)
+	rw.SkippedTrials = make(map[string]bool)
+	rw.DungeonShortcuts = make(map[string]bool)
+	rw.Tricks = settings.DefaultTricks()
+	rw.Builder = b
+
+	for _, helper := range helpers {
+		decl, err := helper.ParseDecl()
+		if err != nil {
+			return env, nil, fmt.Errorf("helper %q: %w", helper.Name, err)
+		}
+		body, err := helper.Parse()
+		if err != nil {
+			return env, nil, fmt.Errorf("helper %q: %w", helper.Name, err)
+		}
+		interpreter.FunctionDecl(decl, body, env)
+	}
+
+	for name, value := range settings.Defaults() {
+		env.Set(name, interpreter.Box(value))
+	}
+	for name, value := range b.Settings {
+		env.Set(name, interpreter.Box(value))
+	}
+
+	has := interpreter.NewHasQuantityOf(b.Pool, b.World)
+	env.SetBuiltIn("at_day", 0, interpreter.AtDay)
+	env.SetBuiltIn("at_night", 0, interpreter.AtNigt)
+	env.SetBuiltIn("at_dampe_time", 0, interpreter.AtDampe)
+	env.SetBuiltIn("has", 2, has)
+	env.SetBuiltIn("has_medallions", 1, interpreter.Zoot_HasMedallions{Has: has})
+	env.SetBuiltIn("region_has_shortcuts", 1, interpreter.Zoot_RegionHasShortcuts{
+		RegionalShortcuts: hashset.New[string](),
+	})
+	env.SetBuiltIn("has_bottle", 0, interpreter.Zoot_HasBottle{Has: has})
+
+	// TODO: these depend on where and when the rule is evaluated
+	env.SetBool("spot", false)
+	env.SetNumber("tod", 0)
+	env.SetString("age", "child")
+	env.SetBool("skip_child_zelda", true)
+	for _, projectile := range []string{"child", "adult", "both", "either"} {
+		env.SetString(projectile, projectile)
+	}
+
+	return env, rw, nil
+}
+
+// every edge in the builder's world has its rule rewritten, compiled and
+// attached as a logic.CompiledRule, locations are given the rules of the
+// edges leading to them. Rules that can't be compiled are never fulfilled
+// and are returned together
+func CompileWorld(b *world.Builder, env interpreter.Environment, rw *interpreter.Inliner) error {
+	c := New(env, b.World)
+	c.Inliner = rw
+	var errs []error
+
+	raw, err := b.Pool.Query(entity.BuildFilter(filter.InWorld(b.World)).
+		With(mirrors.TypeOf[world.Edge]()).
+		With(mirrors.TypeOf[logic.RawRule]()).
+		With(mirrors.TypeOf[world.FromName]()).
+		Build())
+	if err != nil && !nothingMatched(err) {
+		return err
+	}
+
+	var text logic.RawRule
+	var region world.FromName
+	for _, edge := range raw {
+		if err := edge.Get(&text); err != nil {
+			return err
+		}
+		if err := edge.Get(&region); err != nil {
+			return err
+		}
+
+		rule, err := compileRaw(c, rw, env, string(region), string(text))
+		errs = append(errs, attach(edge, rule, err))
+	}
+
+	// here and at create event edges while rewriting
+	parsed, err := b.Pool.Query(entity.BuildFilter(filter.InWorld(b.World)).
+		With(mirrors.TypeOf[world.Edge]()).
+		With(mirrors.TypeOf[logic.ParsedRule]()).
+		Without(mirrors.TypeOf[logic.CompiledRule]()).
+		Build())
+	if err != nil && !nothingMatched(err) {
+		return err
+	}
+
+	var expr logic.ParsedRule
+	for _, edge := range parsed {
+		if err := edge.Get(&expr); err != nil {
+			return err
+		}
+		rule, err := c.Compile(expr.R, env)
+		errs = append(errs, attach(edge, rule, err))
+	}
+
+	if err := compileLocations(b); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errors.Join(errs...)
+}
+
+// a location reached from more than one region can be checked from any of them
+func compileLocations(b *world.Builder) error {
+	locations, err := b.Pool.Query(entity.BuildFilter(filter.InWorld(b.World), filter.Location).Build())
+	if err != nil {
+		if nothingMatched(err) {
+			return nil
+		}
+		return err
+	}
+
+	rules := make(map[entity.Model]logic.Rule, len(locations))
+	for _, location := range locations {
+		rules[location.Model()] = nil
+	}
+
+	edges, err := b.Pool.Query(entity.BuildFilter(filter.InWorld(b.World)).
+		With(mirrors.TypeOf[world.Edge]()).
+		With(mirrors.TypeOf[logic.CompiledRule]()).
+		Build())
+	if err != nil && !nothingMatched(err) {
+		return err
+	}
+
+	var e world.Edge
+	var compiled logic.CompiledRule
+	for _, edge := range edges {
+		if err := edge.Get(&e); err != nil {
+			return err
+		}
+		rule, isLocation := rules[e.Destination]
+		if !isLocation {
+			continue
+		}
+		if err := edge.Get(&compiled); err != nil {
+			return err
+		}
+		if rule != nil {
+			compiled.R = logic.OrRule{LHS: rule, RHS: compiled.R}
+		}
+		rules[e.Destination] = compiled.R
+	}
+
+	for _, location := range locations {
+		rule := rules[location.Model()]
+		if rule == nil {
+			rule = logic.FalseRule
+		}
+		if err := location.Add(logic.CompiledRule{R: rule}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// pools that register components as they're first added don't know
+// components no entity has had yet, nothing has them either
+func nothingMatched(err error) bool {
+	var unknown entity.ErrUnknownComponent
+	return errors.Is(err, entity.ErrNoEntities) || errors.As(err, &unknown)
+}
+
+// releases the prepared queries the environment's has and the rules compiled
+// in it count with, counting again prepares them anew
+func Release(env interpreter.Environment) {
+	if has := hasOf(env); has != nil {
+		has.Close()
+	}
+}
+
+func compileRaw(c *Compiler, rw *interpreter.Inliner, env interpreter.Environment, region, text string) (logic.Rule, error) {
+	expr, err := parser.Parse(text)
+	if err != nil {
+		return nil, err
+	}
+
+	rw.RegionName = region
+	expr, err = rw.RewriteRule(expr, env)
+	if err != nil {
+		return nil, fmt.Errorf("rewriting: %w", err)
+	}
+	return c.Compile(expr, env)
+}
+
+func attach(edge entity.View, rule logic.Rule, err error) error {
+	if err != nil {
+		rule = logic.FalseRule
+		name, _ := entity.GetComponent[components.Name](edge)
+		err = fmt.Errorf("%s: %w", name, err)
+	}
+
+	if addErr := edge.Add(logic.CompiledRule{R: rule}); addErr != nil {
+		return errors.Join(err, addErr)
+	}
+	return err
+}
@@ -1,82 +1,126 @@
 package interpreter
 
 import (
+	"fmt"
 	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/logic"
 	"sudonters/zootler/pkg/world/components"
 
 	"github.com/etc-sudonters/substrate/mirrors"
 	"github.com/etc-sudonters/substrate/skelly/hashset"
 )
 
+// every builtin OOTR's rules may call, not all of them are implemented yet
+var BuiltInNames = []string{
+	"at", "here",
+	"at_day", "at_night", "at_dampe_time",
+	"can_live_dmg", "count_of", "guarantee_hint", "had_night_start",
+	"has", "has_all_item_goals", "has_all_notes_for_song", "has_all_of",
+	"has_any_of", "has_bottle", "has_dungeon_rewards", "has_full_item_goal",
+	"has_hearts", "has_item_goal", "has_medallions", "has_ocarina_buttons",
+	"has_stones", "heart_count", "item_count", "item_name_count",
+	"region_has_shortcuts",
+}
+
 var (
 	AtDay   BuiltInFn = atTod
 	AtNigt  BuiltInFn = atTod
 	AtDampe BuiltInFn = atTod
 )
 
-func atTod(_ Interpreter, _ []Value) Value {
-	return Box(true)
+func atTod(_ Interpreter, _ []Value) (Value, error) {
+	return Box(true), nil
+}
+
+// builtins are handed whatever the rule passed them
+func arg[T Value](builtin string, args []Value, i int) (T, error) {
+	v, ok := args[i].(T)
+	if !ok {
+		return v, fmt.Errorf("%w: %s expects %T as argument %d not %s", TypeErr, builtin, v, i+1, args[i])
+	}
+	return v, nil
 }
 
+// the tokens has_medallions and has_bottle count
+var (
+	Medallions = Token{Component: mirrors.TypeOf[components.Medallion](), Literal: "Medallion"}
+	Bottles    = Token{Component: mirrors.TypeOf[components.Bottle](), Literal: "Bottle"}
+)
+
 // State.py
 // ("item name", qty) tuples and "raw_item_name" w/ implicit qty = 1, having more is fine
+// only items belonging to World are counted, in multiworld seeds each player
+// gets their own has
 type Zoot_HasQuantityOf struct {
-	Entities entity.Queryable
+	Inventory *logic.Inventory
 }
 
-func (z Zoot_HasQuantityOf) Call(t Interpreter, args []Value) Value {
-	token := args[0].(Token)
-	qty := int(args[1].(Number).Value)
+func NewHasQuantityOf(entities entity.Queryable, world components.WorldId) *Zoot_HasQuantityOf {
+	return &Zoot_HasQuantityOf{Inventory: logic.NewInventory(entities, world)}
+}
 
-	filter := entity.FilterBuilder{}.
-		With(mirrors.TypeOf[components.Collected]()).
-		With(token.Component).
-		Build()
+// releases the prepared queries has counts with
+func (z *Zoot_HasQuantityOf) Close() {
+	z.Inventory.Close()
+}
 
-	ents, err := z.Entities.Query(filter)
+func (z *Zoot_HasQuantityOf) Call(t Interpreter, args []Value) (Value, error) {
+	token, err := arg[Token]("has", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	qty, err := arg[Number]("has", args, 1)
+	if err != nil {
+		return nil, err
+	}
 
+	entities := z.Inventory.Entities
+	if t.entities != nil {
+		entities = t.entities
+	}
+	has, err := z.rule(token, int(qty.Value)).Fulfill(entities)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
+	return Box(has), nil
+}
+
+// if at least qty of the token have been collected
+func (z *Zoot_HasQuantityOf) Has(token Token, qty int) (bool, error) {
+	return z.rule(token, qty).Fulfill(z.Inventory.Entities)
+}
 
-	return Box(qty <= len(ents))
+// counts with the inventory unless it's asked about other entities
+func (z *Zoot_HasQuantityOf) rule(token Token, qty int) logic.HasRule {
+	return logic.HasRule{Component: token.Component, Qty: qty, World: z.Inventory.World, Inventory: z.Inventory}
 }
 
 type Zoot_HasMedallions struct {
-	Has Zoot_HasQuantityOf
+	Has *Zoot_HasQuantityOf
 }
 
-func (z Zoot_HasMedallions) Call(t Interpreter, args []Value) Value {
-	return z.Has.Call(t, []Value{
-		Token{
-			Component: mirrors.TypeOf[components.Medallion](),
-			Literal:   "",
-		},
-		args[0],
-	})
+func (z Zoot_HasMedallions) Call(t Interpreter, args []Value) (Value, error) {
+	return z.Has.Call(t, []Value{Medallions, args[0]})
 }
 
 type Zoot_RegionHasShortcuts struct {
 	RegionalShortcuts hashset.Hash[string]
 }
 
-func (z Zoot_RegionHasShortcuts) Call(t Interpreter, args []Value) Value {
-	region := args[0].(String)
-	return Box(z.RegionalShortcuts.Exists(region.Value))
+func (z Zoot_RegionHasShortcuts) Call(t Interpreter, args []Value) (Value, error) {
+	region, err := arg[String]("region_has_shortcuts", args, 0)
+	if err != nil {
+		return nil, err
+	}
+	return Box(z.RegionalShortcuts.Exists(region.Value)), nil
 }
 
 type Zoot_HasBottle struct {
-	Has Zoot_HasQuantityOf
+	Has *Zoot_HasQuantityOf
 }
 
-func (z Zoot_HasBottle) Call(t Interpreter, args []Value) Value {
-	return z.Has.Call(
-		t, []Value{
-			Token{
-				Component: mirrors.TypeOf[components.Bottle](),
-			},
-			Box(1),
-		})
+func (z Zoot_HasBottle) Call(t Interpreter, args []Value) (Value, error) {
+	return z.Has.Call(t, []Value{Bottles, Box(1)})
 }
 
 type Zoot_HasAnyOf struct{}
@@ -95,4 +139,3 @@ type Zoot_HasAllItemGoals struct{}
 type Zoot_HadNightStart struct{}
 type Zoot_CanLiveDmg struct{}
 type Zoot_GuaranteeHint struct{}
-type Zoot_HasNotesForSong struct{}
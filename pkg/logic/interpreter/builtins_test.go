@@ -0,0 +1,36 @@
+package interpreter
+
+import (
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/world"
+	"sudonters/zootler/pkg/world/components"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+)
+
+func TestHasCountsOnlyThePlayersItems(t *testing.T) {
+	b := world.MustDefaultBuilder()
+	for _, id := range []components.WorldId{0, 1, 1} {
+		bottle, err := b.ForWorld(id).Pool.Create("Bottle")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := bottle.Add([]entity.Component{id, components.Bottle{}, components.Collected{}}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	bottle := Token{Component: mirrors.TypeOf[components.Bottle]()}
+	for id, count := range map[components.WorldId]int{0: 1, 1: 2, 2: 0} {
+		has := NewHasQuantityOf(b.Pool, id)
+
+		if enough, err := has.Call(Interpreter{}, []Value{bottle, Box(count)}); err != nil || !enough.(Boolean).Value {
+			t.Fatalf("expected world %d to have %d bottles", id, count)
+		}
+		if tooMany, err := has.Call(Interpreter{}, []Value{bottle, Box(count + 1)}); err != nil || tooMany.(Boolean).Value {
+			t.Fatalf("expected world %d to have fewer than %d bottles", id, count+1)
+		}
+	}
+}
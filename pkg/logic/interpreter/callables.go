@@ -13,16 +13,16 @@ var _ Callable = (*BuiltIn)(nil)
 type Callable interface {
 	Value
 	Arity() int
-	Call(t Interpreter, args []Value) Value
+	Call(t Interpreter, args []Value) (Value, error)
 }
 
 type BuiltInCallable interface {
-	Call(t Interpreter, args []Value) Value
+	Call(t Interpreter, args []Value) (Value, error)
 }
 
-type BuiltInFn func(Interpreter, []Value) Value
+type BuiltInFn func(Interpreter, []Value) (Value, error)
 
-func (b BuiltInFn) Call(t Interpreter, args []Value) Value {
+func (b BuiltInFn) Call(t Interpreter, args []Value) (Value, error) {
 	return b(t, args)
 }
 
@@ -56,7 +56,7 @@ func (f Fn) Arity() int {
 	return len(f.Params)
 }
 
-func (f Fn) Call(t Interpreter, args []Value) Value {
+func (f Fn) Call(t Interpreter, args []Value) (Value, error) {
 	env := t.globals.Enclosed()
 	for i := range args {
 		env.Set(f.Params[i], args[i])
@@ -83,7 +83,7 @@ func (f PartiallyEvaluatedFn) Arity() int {
 	return 0
 }
 
-func (f PartiallyEvaluatedFn) Call(t Interpreter, _ []Value) Value {
+func (f PartiallyEvaluatedFn) Call(t Interpreter, _ []Value) (Value, error) {
 	return t.Evaluate(f.Body, f.Env)
 }
 
@@ -97,7 +97,7 @@ func (b BuiltIn) Arity() int {
 	return b.N
 }
 
-func (b BuiltIn) Call(t Interpreter, args []Value) Value {
+func (b BuiltIn) Call(t Interpreter, args []Value) (Value, error) {
 	return b.F.Call(t, args)
 }
 
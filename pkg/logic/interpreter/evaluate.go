@@ -8,9 +8,11 @@ import (
 )
 
 type Evaluation[T any] interface {
+	EvalAttribute(attr *ast.Attribute, env Environment) T
 	EvalBinOp(op *ast.BinOp, env Environment) T
 	EvalBoolOp(op *ast.BoolOp, env Environment) T
 	EvalCall(call *ast.Call, env Environment) T
+	EvalInvalid(invalid *ast.Invalid, env Environment) T
 	EvalIdentifier(ident *ast.Identifier, env Environment) T
 	EvalLiteral(str *ast.Literal, env Environment) T
 	EvalSubscript(subscript *ast.Subscript, env Environment) T
@@ -18,8 +20,12 @@ type Evaluation[T any] interface {
 	EvalUnary(unary *ast.UnaryOp, env Environment) T
 }
 
+// the parser only makes the nodes dispatched here, any other node is a bug
+// and panics since T has no way to carry an error
 func Evaluate[T any](v Evaluation[T], node ast.Expression, env Environment) T {
 	switch node := node.(type) {
+	case *ast.Attribute:
+		return v.EvalAttribute(node, env)
 	case *ast.BinOp:
 		return v.EvalBinOp(node, env)
 	case *ast.BoolOp:
@@ -28,6 +34,8 @@ func Evaluate[T any](v Evaluation[T], node ast.Expression, env Environment) T {
 		return v.EvalCall(node, env)
 	case *ast.Identifier:
 		return v.EvalIdentifier(node, env)
+	case *ast.Invalid:
+		return v.EvalInvalid(node, env)
 	case *ast.Literal:
 		return v.EvalLiteral(node, env)
 	case *ast.Subscript:
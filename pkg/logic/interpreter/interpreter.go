@@ -3,6 +3,8 @@ package interpreter
 import (
 	"errors"
 	"fmt"
+	"sudonters/zootler/internal/astrender"
+	"sudonters/zootler/internal/entity"
 	"sudonters/zootler/pkg/rules/ast"
 )
 
@@ -13,110 +15,302 @@ func parseError(reason string, v ...any) error {
 
 var parseErr = errors.New("parse error")
 
-var _ Evaluation[Value] = Interpreter{}
+var (
+	UnknownIdentifierErr = errors.New("unknown identifier")
+	NotCallableErr       = errors.New("not callable")
+	ArityErr             = errors.New("wrong number of arguments")
+	TypeErr              = errors.New("wrong type")
+)
+
+// a rule that couldn't be evaluated, Node is where evaluation stopped and Env
+// is the frame it was evaluated in. Rule is only known if the interpreter was
+// told with ForRule
+type EvalError struct {
+	Rule string
+	Node ast.Expression
+	Env  Environment
+	Err  error
+}
+
+func (e *EvalError) Error() string {
+	s := astrender.NewSexpr(astrender.DontTheme())
+	ast.Visit(s, e.Node)
+	if e.Rule == "" {
+		return fmt.Sprintf("evaluating %s: %s", s.String(), e.Err)
+	}
+	return fmt.Sprintf("%s: evaluating %s: %s", e.Rule, s.String(), e.Err)
+}
 
-var UnknownIdentifierErr = errors.New("unknown identifier")
+func (e *EvalError) Unwrap() error {
+	return e.Err
+}
 
 func New(globals Environment) Interpreter {
-	return Interpreter{globals}
+	return Interpreter{globals: globals}
 }
 
 type Interpreter struct {
-	globals Environment
+	globals  Environment
+	rule     string
+	entities entity.Queryable
+}
+
+// errors from this interpreter name the rule they came from
+func (t Interpreter) ForRule(name string) Interpreter {
+	t.rule = name
+	return t
+}
+
+// builtins that query entities query these instead of the entities they
+// were created with
+func (t Interpreter) Querying(entities entity.Queryable) Interpreter {
+	t.entities = entities
+	return t
 }
 
-func (t Interpreter) Evaluate(ex ast.Expression, env Environment) Value {
-	return Evaluate(t, ex, env)
+// errors from deeper in the rule already know where they happened
+func (t Interpreter) fail(node ast.Expression, env Environment, err error) error {
+	var evalErr *EvalError
+	if errors.As(err, &evalErr) {
+		return err
+	}
+	return &EvalError{Rule: t.rule, Node: node, Env: env, Err: err}
 }
 
-func (t Interpreter) EvalLiteral(expr *ast.Literal, env Environment) Value {
-	return Box(expr.Value)
+func (t Interpreter) Evaluate(ex ast.Expression, env Environment) (Value, error) {
+	switch node := ex.(type) {
+	case *ast.Attribute:
+		return t.EvalAttribute(node, env)
+	case *ast.BinOp:
+		return t.EvalBinOp(node, env)
+	case *ast.BoolOp:
+		return t.EvalBoolOp(node, env)
+	case *ast.Call:
+		return t.EvalCall(node, env)
+	case *ast.Identifier:
+		return t.EvalIdentifier(node, env)
+	case *ast.Invalid:
+		return t.EvalInvalid(node, env)
+	case *ast.Literal:
+		return t.EvalLiteral(node, env)
+	case *ast.Subscript:
+		return t.EvalSubscript(node, env)
+	case *ast.Tuple:
+		return t.EvalTuple(node, env)
+	case *ast.UnaryOp:
+		return t.EvalUnary(node, env)
+	default:
+		return nil, t.fail(ex, env, fmt.Errorf("unknown node type %T", ex))
+	}
 }
 
-func (t Interpreter) EvalBinOp(op *ast.BinOp, env Environment) Value {
-	left := t.Evaluate(op.Left, env)
-	right := t.Evaluate(op.Right, env)
+func (t Interpreter) EvalInvalid(invalid *ast.Invalid, env Environment) (Value, error) {
+	return nil, t.fail(invalid, env, fmt.Errorf("cannot evaluate rule that failed to parse at %s", invalid.Pos.Start))
+}
+
+func (t Interpreter) EvalLiteral(expr *ast.Literal, env Environment) (Value, error) {
+	return Box(expr.Value), nil
+}
+
+func (t Interpreter) EvalBinOp(op *ast.BinOp, env Environment) (Value, error) {
+	if op.Op == ast.BinOpContains || op.Op == ast.BinOpNotContains {
+		return t.contains(op, env)
+	}
+
+	left, err := t.Evaluate(op.Left, env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := t.Evaluate(op.Right, env)
+	if err != nil {
+		return nil, err
+	}
 
 	switch op.Op {
 	case ast.BinOpEq:
-		return Boolean{Value: left.Eq(right)}
+		return Boolean{Value: left.Eq(right)}, nil
 	case ast.BinOpNotEq:
-		return Boolean{Value: !left.Eq(right)}
-	case ast.BinOpLt:
+		return Boolean{Value: !left.Eq(right)}, nil
+	case ast.BinOpLt, ast.BinOpLtEq, ast.BinOpGt, ast.BinOpGtEq, ast.BinOpAdd, ast.BinOpSub, ast.BinOpMul:
 		if left.Type() == right.Type() && left.Type() == NUM_TYPE {
-			l := left.(Number)
-			r := right.(Number)
-			return Boolean{Value: l.Value < r.Value}
+			v, err := numericBinOp(op.Op, left.(Number).Value, right.(Number).Value)
+			if err != nil {
+				return nil, t.fail(op, env, err)
+			}
+			return v, nil
 		}
-		panic(fmt.Errorf("only numbers can be compared not %T and %T", left, right))
+		return nil, t.fail(op, env, fmt.Errorf("%w: %s only between numbers not %T and %T", TypeErr, op.Op, left, right))
+	default:
+		return nil, t.fail(op, env, parseError("unknown op %q", op.Op))
 	}
-	panic("unreachable")
 }
 
-func (t Interpreter) EvalBoolOp(op *ast.BoolOp, env Environment) Value {
-	left := t.Evaluate(op.Left, env)
+func numericBinOp(op ast.BinOpKind, l, r float64) (Value, error) {
+	switch op {
+	case ast.BinOpLt:
+		return Boolean{Value: l < r}, nil
+	case ast.BinOpLtEq:
+		return Boolean{Value: l <= r}, nil
+	case ast.BinOpGt:
+		return Boolean{Value: l > r}, nil
+	case ast.BinOpGtEq:
+		return Boolean{Value: l >= r}, nil
+	case ast.BinOpAdd:
+		return Number{Value: l + r}, nil
+	case ast.BinOpSub:
+		return Number{Value: l - r}, nil
+	case ast.BinOpMul:
+		return Number{Value: l * r}, nil
+	default:
+		return nil, parseError("%q is not a numeric op", op)
+	}
+}
 
-	if op.Op == ast.BoolOpOr {
-		if t.IsTruthy(left) {
-			return left
-		}
-	} else {
-		if !t.IsTruthy(left) {
-			return left
-		}
+// membership is the same as subscripting, just like the inliner lowers it
+func (t Interpreter) contains(op *ast.BinOp, env Environment) (Value, error) {
+	v, err := t.EvalSubscript(&ast.Subscript{Target: op.Right, Index: op.Left, Pos: op.Pos}, env)
+	if err != nil {
+		return nil, err
+	}
+
+	truthy, err := t.IsTruthy(v)
+	if err != nil {
+		return nil, t.fail(op, env, err)
+	}
+	return Boolean{Value: truthy == (op.Op == ast.BinOpContains)}, nil
+}
+
+func (t Interpreter) EvalBoolOp(op *ast.BoolOp, env Environment) (Value, error) {
+	left, err := t.Evaluate(op.Left, env)
+	if err != nil {
+		return nil, err
+	}
+
+	truthy, err := t.IsTruthy(left)
+	if err != nil {
+		return nil, t.fail(op.Left, env, err)
+	}
+
+	if truthy == (op.Op == ast.BoolOpOr) {
+		return left, nil
 	}
 
 	return t.Evaluate(op.Right, env)
 }
 
-func (t Interpreter) EvalCall(call *ast.Call, env Environment) Value {
-	callee := t.Evaluate(call.Callee, env)
+func (t Interpreter) EvalCall(call *ast.Call, env Environment) (Value, error) {
+	callee, err := t.Evaluate(call.Callee, env)
+	if err != nil {
+		return nil, err
+	}
+
 	fn, ok := callee.(Callable)
 	if !ok {
-		panic(fmt.Errorf("%v is not callable", callee))
+		return nil, t.fail(call, env, fmt.Errorf("%w: %v", NotCallableErr, callee))
 	}
 
 	if fn.Arity() != len(call.Args) {
-		panic(fmt.Errorf(
-			"%q: Expected %d arguments but got %d: %s",
+		return nil, t.fail(call, env, fmt.Errorf(
+			"%w: %q expected %d but got %d",
+			ArityErr,
 			fn.(Value),
 			fn.Arity(),
 			len(call.Args),
-			call.Args,
 		))
 	}
 
 	args := make([]Value, len(call.Args))
 	for i := range args {
-		args[i] = t.Evaluate(call.Args[i], env)
+		if args[i], err = t.Evaluate(call.Args[i], env); err != nil {
+			return nil, err
+		}
 	}
 
-	return fn.Call(t, args)
+	v, err := fn.Call(t, args)
+	if err != nil {
+		return nil, t.fail(call, env, err)
+	}
+	return v, nil
 }
 
-func (t Interpreter) EvalIdentifier(ident *ast.Identifier, env Environment) Value {
+func (t Interpreter) EvalIdentifier(ident *ast.Identifier, env Environment) (Value, error) {
 	v, ok := env.Get(ident.Value)
 	if !ok {
-		panic(fmt.Errorf("%w: %q", UnknownIdentifierErr, ident.Value))
+		return nil, t.fail(ident, env, fmt.Errorf("%w: %q", UnknownIdentifierErr, ident.Value))
 	}
 
-	return v
+	return v, nil
 }
 
-func (t Interpreter) EvalSubscript(subscript *ast.Subscript, env Environment) Value {
-	panic("not implemented") // TODO: Implement
+func (t Interpreter) EvalAttribute(attr *ast.Attribute, env Environment) (Value, error) {
+	return nil, t.fail(attr, env, fmt.Errorf("attributes are not supported: .%s", attr.Attr))
 }
 
-func (t Interpreter) EvalTuple(tup *ast.Tuple, env Environment) Value {
-	panic("not implemented") // TODO: Implement
+// settings are read from dicts, names that aren't in the dict are false
+func (t Interpreter) EvalSubscript(subscript *ast.Subscript, env Environment) (Value, error) {
+	target, err := t.Evaluate(subscript.Target, env)
+	if err != nil {
+		return nil, err
+	}
+
+	dict, ok := target.(Dict)
+	if !ok {
+		return nil, t.fail(subscript, env, fmt.Errorf("%w: %s is not subscriptable", TypeErr, target))
+	}
+
+	key, err := t.key(subscript.Index, env)
+	if err != nil {
+		return nil, t.fail(subscript, env, err)
+	}
+	return dict.Get(key), nil
 }
 
-func (t Interpreter) EvalUnary(unary *ast.UnaryOp, env Environment) Value {
+// bare names like skipped_trials[Forest] are keys unless they're bound to a
+// string, anything else must evaluate to a string
+func (t Interpreter) key(index ast.Expression, env Environment) (string, error) {
+	if ident, ok := index.(*ast.Identifier); ok {
+		if v, bound := env.Get(ident.Value); bound && v.Type() == STR_TYPE {
+			return v.(String).Value, nil
+		}
+		return ident.Value, nil
+	}
+
+	v, err := t.Evaluate(index, env)
+	if err != nil {
+		return "", err
+	}
+	if v.Type() != STR_TYPE {
+		return "", fmt.Errorf("%w: keys are names or strings not %s", TypeErr, v)
+	}
+	return v.(String).Value, nil
+}
+
+// (token, qty) is shorthand for has(token, qty)
+func (t Interpreter) EvalTuple(tup *ast.Tuple, env Environment) (Value, error) {
+	if len(tup.Elems) != 2 {
+		return nil, t.fail(tup, env, BadTupleErr)
+	}
+
+	return t.EvalCall(&ast.Call{
+		Callee: &ast.Identifier{Value: "has", Pos: tup.Pos},
+		Args:   tup.Elems,
+		Pos:    tup.Pos,
+	}, env)
+}
+
+func (t Interpreter) EvalUnary(unary *ast.UnaryOp, env Environment) (Value, error) {
 	switch unary.Op {
 	case ast.UnaryNot:
-		v := t.Evaluate(unary.Target, env)
-		return Box(!t.IsTruthy(v))
+		v, err := t.Evaluate(unary.Target, env)
+		if err != nil {
+			return nil, err
+		}
+		truthy, err := t.IsTruthy(v)
+		if err != nil {
+			return nil, t.fail(unary, env, err)
+		}
+		return Box(!truthy), nil
 	default:
-		panic(parseError("unknown unary op %q", unary.Op))
+		return nil, t.fail(unary, env, parseError("unknown unary op %q", unary.Op))
 	}
 }
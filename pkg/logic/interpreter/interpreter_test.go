@@ -0,0 +1,173 @@
+package interpreter
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+
+	"sudonters/zootler/internal/entity/bitpool"
+	"sudonters/zootler/pkg/rules/ast"
+	"sudonters/zootler/pkg/rules/parser"
+	"sudonters/zootler/pkg/world"
+	"sudonters/zootler/pkg/world/components"
+)
+
+type bow struct{}
+
+func testEnv() Environment {
+	env := NewEnv()
+	env.Set("Bow", Token{Component: reflect.TypeOf(bow{}), Literal: "Bow"})
+	env.SetBuiltIn("has", 2, BuiltInFn(func(_ Interpreter, args []Value) (Value, error) {
+		token, err := arg[Token]("has", args, 0)
+		if err != nil {
+			return nil, err
+		}
+		qty, err := arg[Number]("has", args, 1)
+		if err != nil {
+			return nil, err
+		}
+		return Box(token.Literal == "Bow" && qty.Value <= 2), nil
+	}))
+	env.SetString("age", "child")
+	env.Set("skipped_trials", Dict{Name: "skipped_trials", Values: map[string]Value{"Forest": Box(true)}})
+	env.Set("dungeon_shortcuts", Dict{Name: "dungeon_shortcuts", Values: map[string]Value{"Shadow Temple": Box(true)}})
+
+	for decl, body := range map[string]string{
+		"is_child":     "age == 'child'",
+		"can_use(obj)": "is_child and obj",
+		"broken(obj)":  "obj and Slingshot",
+	} {
+		d, err := parser.Parse(decl)
+		if err != nil {
+			panic(err)
+		}
+		b, err := parser.Parse(body)
+		if err != nil {
+			panic(err)
+		}
+		FunctionDecl(d, b, env)
+	}
+	return env
+}
+
+func evaluate(env Environment, rule string) (bool, error) {
+	expr, err := parser.Parse(rule)
+	if err != nil {
+		return false, err
+	}
+	t := New(env).ForRule(rule)
+	v, err := t.Evaluate(expr, env)
+	if err != nil {
+		return false, err
+	}
+	return t.IsTruthy(v)
+}
+
+func TestUnInlinedRules(t *testing.T) {
+	env := testEnv()
+
+	for rule, expected := range map[string]bool{
+		"skipped_trials[Forest]":                  true,
+		"skipped_trials[Water]":                   false,
+		"skipped_trials['Forest']":                true,
+		"'Shadow Temple' in dungeon_shortcuts":    true,
+		"'Water Temple' in dungeon_shortcuts":     false,
+		"'Water Temple' not in dungeon_shortcuts": true,
+		"(Bow, 2)":                  true,
+		"(Bow, 3)":                  false,
+		"can_use(Bow) and is_child": true,
+		"True or Slingshot":         true,
+	} {
+		ok, err := evaluate(env, rule)
+		if err != nil {
+			t.Fatalf("%s: %s", rule, err)
+		}
+		if ok != expected {
+			t.Errorf("expected %q to be %t", rule, expected)
+		}
+	}
+}
+
+func TestErrorsAreReturned(t *testing.T) {
+	env := testEnv()
+
+	for rule, expected := range map[string]error{
+		"Slingshot":            UnknownIdentifierErr,
+		"age()":                NotCallableErr,
+		"is_child(Bow)":        ArityErr,
+		"not can_use":          ArityErr,
+		"Bow < 1":              TypeErr,
+		"has('Bow', 1)":        TypeErr,
+		"age[Forest]":          TypeErr,
+		"(Bow, 1, 2)":          BadTupleErr,
+		"is_child and (1, 2)":  TypeErr,
+		"can_use(broken(Bow))": UnknownIdentifierErr,
+	} {
+		_, err := evaluate(env, rule)
+		if !errors.Is(err, expected) {
+			t.Errorf("expected %q to fail with %s but got %v", rule, expected, err)
+			continue
+		}
+
+		var evalErr *EvalError
+		if !errors.As(err, &evalErr) || evalErr.Rule != rule || evalErr.Node == nil {
+			t.Errorf("expected %q to fail with an EvalError but got %#v", rule, err)
+		}
+	}
+}
+
+// the error points at the innermost node and the frame it was evaluated in
+func TestEvalErrorsKeepTheirFrame(t *testing.T) {
+	env := testEnv()
+
+	_, err := evaluate(env, "is_child and broken(Bow)")
+	var evalErr *EvalError
+	if !errors.As(err, &evalErr) {
+		t.Fatalf("expected an EvalError but got %v", err)
+	}
+
+	ident, ok := evalErr.Node.(*ast.Identifier)
+	if !ok || ident.Value != "Slingshot" {
+		t.Fatalf("expected the error to point at Slingshot but got %#v", evalErr.Node)
+	}
+	if obj, ok := evalErr.Env.Get("obj"); !ok || !obj.Eq(Token{Literal: "Bow"}) {
+		t.Fatalf("expected the error to carry the helper's frame but found %v", obj)
+	}
+}
+
+// rules fulfilled against other entities, like a sphere being filled, ask
+// about those instead of the world has was created with
+func TestHasCountsTheEntitiesItsAskedAbout(t *testing.T) {
+	pools := make([]*world.Builder, 2)
+	for i := range pools {
+		b, err := world.LimitedBuilder(bitpool.Settings{MaxComponentId: 200, MaxEntityId: 100})
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, err := b.Entity("Bottle")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := token.Add(components.Bottle{}); err != nil {
+			t.Fatal(err)
+		}
+		pools[i] = b
+	}
+	if err := pools[1].NameCache["Bottle"].Add(components.Collected{}); err != nil {
+		t.Fatal(err)
+	}
+
+	has := NewHasQuantityOf(pools[0].Pool, 0)
+	defer has.Close()
+	args := []Value{Bottles, Box(1)}
+
+	for entities, expected := range map[*world.Builder]bool{pools[0]: false, pools[1]: true} {
+		v, err := has.Call(New(NewEnv()).Querying(entities.Pool), args)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !v.Eq(Box(expected)) {
+			t.Errorf("expected has to be %t but was %v", expected, v)
+		}
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"crypto/sha256"
 	"errors"
 	"fmt"
+	"reflect"
 	"regexp"
 	"strings"
 	"sudonters/zootler/internal/astrender"
@@ -13,7 +14,7 @@ import (
 	"sudonters/zootler/pkg/world/components"
 )
 
-var _entityName = regexp.MustCompile("^[A-Z][A-Za-z_]+$")
+var _entityName = regexp.MustCompile("^[A-Z][A-Za-z0-9_]+$")
 
 type eq int
 
@@ -25,8 +26,6 @@ const (
 	notSure
 )
 
-var _ Evaluation[ast.Expression] = Inliner{}
-
 func NewInliner(globals Environment) *Inliner {
 	return &Inliner{Globals: globals}
 }
@@ -36,6 +35,8 @@ func NewInliner(globals Environment) *Inliner {
 // this includes recursing into function calls and either replacing it with a constant
 // or storing the partially executed function into the environment and replaces the general
 // call to the optimized call
+//
+// rewriting a rule that can't be rewritten returns the reason why
 type Inliner struct {
 	Globals          Environment
 	Settings         map[string]any
@@ -46,8 +47,43 @@ type Inliner struct {
 	RegionName       string
 }
 
-func (rw Inliner) Rewrite(expr ast.Expression, env Environment) ast.Expression {
-	return Evaluate(rw, expr, env)
+func (rw Inliner) Rewrite(expr ast.Expression, env Environment) (ast.Expression, error) {
+	switch node := expr.(type) {
+	case *ast.Attribute:
+		return rw.EvalAttribute(node, env)
+	case *ast.BinOp:
+		return rw.EvalBinOp(node, env)
+	case *ast.BoolOp:
+		return rw.EvalBoolOp(node, env)
+	case *ast.Call:
+		return rw.EvalCall(node, env)
+	case *ast.Identifier:
+		return rw.EvalIdentifier(node, env)
+	case *ast.Invalid:
+		return rw.EvalInvalid(node, env)
+	case *ast.Literal:
+		return rw.EvalLiteral(node, env)
+	case *ast.Subscript:
+		return rw.EvalSubscript(node, env)
+	case *ast.Tuple:
+		return rw.EvalTuple(node, env)
+	case *ast.UnaryOp:
+		return rw.EvalUnary(node, env)
+	default:
+		return nil, parseError("unknown node type %T", expr)
+	}
+}
+
+// rewrites a rule or operand and calls it if it's just a helper's name
+func (rw Inliner) RewriteRule(expr ast.Expression, env Environment) (ast.Expression, error) {
+	expr, err := rw.Rewrite(expr, env)
+	if err != nil {
+		return nil, err
+	}
+	if call, ok := rw.Make0ArityFnCall(expr, env); ok {
+		return rw.Rewrite(call, env)
+	}
+	return expr, nil
 }
 
 func (rw Inliner) areEq(left, right ast.Expression, env Environment) eq {
@@ -92,6 +128,8 @@ func (rw Inliner) resolveToValue(expr ast.Expression, env Environment) Value {
 	return nil
 }
 
+// only for values the inliner has already resolved to literals, anything
+// else is a bug in the inliner
 func IsTruthy(v Value) bool {
 	switch v := v.(type) {
 	case Boolean:
@@ -113,41 +151,59 @@ func (rw Inliner) fromEnv(i ast.Expression, env Environment) (Value, bool) {
 	return nil, false
 }
 
-func (rw Inliner) EvalLiteral(literal *ast.Literal, env Environment) ast.Expression {
+func (rw Inliner) EvalInvalid(invalid *ast.Invalid, env Environment) (ast.Expression, error) {
+	return invalid, nil
+}
+
+func (rw Inliner) EvalLiteral(literal *ast.Literal, env Environment) (ast.Expression, error) {
 	if literal.Kind == ast.LiteralStr {
 		ident := &ast.Identifier{Value: literal.Value.(string)}
-		_, ok := env.Get(ident.Value)
+		v, ok := env.Get(ident.Value)
+		if ok && v.Type() == CALL_TYPE {
+			// helpers like Bugs quote their own name to refer to the item
+			// they're named after, the token can't share the helper's name
+			ident.Value = fmt.Sprintf("%q", ident.Value)
+			v, ok = env.Get(ident.Value)
+		}
 		if ok {
-			return ident
+			return ident, nil
 		}
 
-		typ := rw.Builder.TypedStrs.Typed(ident.Value)
-		rw.Builder.Components.RowOf(typ)
-		env.Set(ident.Value, Token{Component: typ, Literal: ident.Value})
-		return ident
+		typ, err := rw.Builder.Registry.TypedString(logic.EscapeName(literal.Value.(string)))
+		if err != nil {
+			return nil, err
+		}
+		env.Set(ident.Value, Token{Component: typ, Literal: literal.Value.(string)})
+		return ident, nil
 	}
-	return literal
+	return literal, nil
 }
 
-func (rw Inliner) EvalBinOp(op *ast.BinOp, env Environment) ast.Expression {
-	left := rw.Rewrite(op.Left, env)
-	right := rw.Rewrite(op.Right, env)
+func (rw Inliner) EvalBinOp(op *ast.BinOp, env Environment) (ast.Expression, error) {
+	left, err := rw.Rewrite(op.Left, env)
+	if err != nil {
+		return nil, err
+	}
+	right, err := rw.Rewrite(op.Right, env)
+	if err != nil {
+		return nil, err
+	}
 
 	switch op.Op {
 	case ast.BinOpEq:
 		if eq := rw.areEq(left, right, env); eq != notSure {
-			return Literalify(eq == definitelyEq)
+			return Literalify(eq == definitelyEq), nil
 		}
 		break
 	case ast.BinOpNotEq:
 		if eq := rw.areEq(left, right, env); eq != notSure {
-			return Literalify(eq != definitelyEq)
+			return Literalify(eq != definitelyEq), nil
 		}
 		break
 	case ast.BinOpLt:
 		r, ok := right.(*ast.Literal)
 		if !ok || r.Kind != ast.LiteralNum {
-			panic(parseError("cmp(<) only between numbers"))
+			return nil, parseError("cmp(<) only between numbers")
 		}
 
 		var l float64
@@ -157,11 +213,11 @@ func (rw Inliner) EvalBinOp(op *ast.BinOp, env Environment) ast.Expression {
 		case ast.ExprIdentifier:
 			lv, ok := rw.fromEnv(left, env)
 			if !ok {
-				panic(parseError("expected %q to be available at compile time", left.(*ast.Identifier).Value))
+				return nil, parseError("expected %q to be available at compile time", left.(*ast.Identifier).Value)
 			}
 
 			if lv.Type() != NUM_TYPE {
-				panic(parseError("cmp(<) only between numbers"))
+				return nil, parseError("cmp(<) only between numbers")
 			}
 
 			l = lv.(Number).Value
@@ -169,26 +225,62 @@ func (rw Inliner) EvalBinOp(op *ast.BinOp, env Environment) ast.Expression {
 		case ast.ExprLiteral:
 			lv := left.(*ast.Literal)
 			if lv.Kind != ast.LiteralNum {
-				panic(parseError("cmp(<) only between numbers"))
+				return nil, parseError("cmp(<) only between numbers")
 			}
 
 			l = lv.Value.(float64)
 		}
 
-		return Literalify(l < r.Value.(float64))
+		return Literalify(l < r.Value.(float64)), nil
 	case ast.BinOpContains:
 		if op.Left.Type() == ast.ExprLiteral {
 			// subscript assumes identifiers only
 			op.Left = &ast.Identifier{Value: op.Left.(*ast.Literal).Value.(string)}
 		}
 		return rw.Rewrite(&ast.Subscript{Target: op.Right, Index: op.Left}, env)
+	case ast.BinOpNotContains:
+		contains := &ast.BinOp{Left: op.Left, Op: ast.BinOpContains, Right: op.Right}
+		return rw.Rewrite(&ast.UnaryOp{Op: ast.UnaryNot, Target: contains}, env)
+	case ast.BinOpLtEq, ast.BinOpGt, ast.BinOpGtEq, ast.BinOpAdd, ast.BinOpSub, ast.BinOpMul:
+		l, lok := rw.numberOf(left, env)
+		r, rok := rw.numberOf(right, env)
+		if lok && rok {
+			v, err := numericBinOp(op.Op, l, r)
+			if err != nil {
+				return nil, err
+			}
+			return Literalify(v), nil
+		}
 	}
 
 	return &ast.BinOp{
 		Left:  left,
 		Op:    op.Op,
 		Right: right,
+	}, nil
+}
+
+// numbers known at compile time
+func (rw Inliner) numberOf(expr ast.Expression, env Environment) (float64, bool) {
+	switch expr := expr.(type) {
+	case *ast.Literal:
+		if expr.Kind == ast.LiteralNum {
+			return expr.Value.(float64), true
+		}
+	case *ast.Identifier:
+		if v, ok := rw.fromEnv(expr, env); ok && v.Type() == NUM_TYPE {
+			return v.(Number).Value, true
+		}
+	}
+	return 0, false
+}
+
+func (rw Inliner) EvalAttribute(attr *ast.Attribute, env Environment) (ast.Expression, error) {
+	target, err := rw.Rewrite(attr.Target, env)
+	if err != nil {
+		return nil, err
 	}
+	return &ast.Attribute{Target: target, Attr: attr.Attr}, nil
 }
 
 func (rw Inliner) isFuncPointer(expr ast.Expression, env Environment) (*ast.Identifier, bool) {
@@ -205,11 +297,13 @@ func (rw Inliner) isFuncPointer(expr ast.Expression, env Environment) (*ast.Iden
 	return expr.(*ast.Identifier), true
 }
 
+// only helpers without params are called, any other helper is left for
+// evaluation to reject
 func (rw Inliner) Make0ArityFnCall(expr ast.Expression, env Environment) (ast.Expression, bool) {
 	if ident, ok := rw.isFuncPointer(expr, env); ok {
 		fn, _ := rw.fromEnv(ident, env)
 		if fn.(Callable).Arity() != 0 {
-			panic(errors.New("must be 0 arity func"))
+			return expr, false
 		}
 
 		return &ast.Call{
@@ -221,10 +315,10 @@ func (rw Inliner) Make0ArityFnCall(expr ast.Expression, env Environment) (ast.Ex
 	return expr, false
 }
 
-func (rw Inliner) EvalBoolOp(op *ast.BoolOp, env Environment) ast.Expression {
-	left := rw.Rewrite(op.Left, env)
-	if call, ok := rw.Make0ArityFnCall(left, env); ok {
-		left = rw.Rewrite(call, env)
+func (rw Inliner) EvalBoolOp(op *ast.BoolOp, env Environment) (ast.Expression, error) {
+	left, err := rw.RewriteRule(op.Left, env)
+	if err != nil {
+		return nil, err
 	}
 
 	if literal, ok := left.(*ast.Literal); ok && literal.Kind == ast.LiteralBool {
@@ -233,34 +327,34 @@ func (rw Inliner) EvalBoolOp(op *ast.BoolOp, env Environment) ast.Expression {
 		switch op.Op {
 		case ast.BoolOpOr:
 			if l {
-				return Literalify(l)
+				return Literalify(l), nil
 			}
 			return rw.Rewrite(op.Right, env)
 		case ast.BoolOpAnd:
 			if !l {
-				return Literalify(l)
+				return Literalify(l), nil
 			}
 			return rw.Rewrite(op.Right, env)
 		}
 	}
 
-	right := rw.Rewrite(op.Right, env)
-	if call, ok := rw.Make0ArityFnCall(right, env); ok {
-		right = rw.Rewrite(call, env)
+	right, err := rw.RewriteRule(op.Right, env)
+	if err != nil {
+		return nil, err
 	}
 	if literal, ok := right.(*ast.Literal); ok && literal.Kind == ast.LiteralBool {
 		r := ReifyLiteral(literal).(Boolean).Value
 		switch op.Op {
 		case ast.BoolOpOr:
 			if !r {
-				return left
+				return left, nil
 			}
-			return Literalify(r)
+			return Literalify(r), nil
 		case ast.BoolOpAnd:
 			if r {
-				return left
+				return left, nil
 			}
-			return Literalify(r)
+			return Literalify(r), nil
 		}
 	}
 
@@ -268,93 +362,114 @@ func (rw Inliner) EvalBoolOp(op *ast.BoolOp, env Environment) ast.Expression {
 		Left:  left,
 		Op:    op.Op,
 		Right: right,
-	}
+	}, nil
 }
 
-func (rw Inliner) EvalUnary(unary *ast.UnaryOp, env Environment) ast.Expression {
-	target := rw.Rewrite(unary.Target, env)
+func (rw Inliner) EvalUnary(unary *ast.UnaryOp, env Environment) (ast.Expression, error) {
+	target, err := rw.Rewrite(unary.Target, env)
+	if err != nil {
+		return nil, err
+	}
 	switch unary.Op {
 	case ast.UnaryNot:
 		if target.Type() == ast.ExprLiteral {
 			b := target.(*ast.Literal)
 			if b.Kind != ast.LiteralBool {
-				panic(parseError("can only negate literal bools"))
+				return nil, parseError("can only negate literal bools")
 			}
-			return Literalify(!b.Value.(bool))
+			return Literalify(!b.Value.(bool)), nil
 		}
 		t, _ := rw.fromEnv(target, env)
 		if t != nil {
 			switch t := t.(type) {
 			case Boolean:
-				return Literalify(!t.Value)
+				return Literalify(!t.Value), nil
 			case Callable:
 				break
 			default:
-				panic(parseError("can only negate literal bools"))
+				return nil, parseError("can only negate literal bools")
 			}
 		}
 	default:
-		panic(parseError("unknown unary op: %q", unary.Op))
+		return nil, parseError("unknown unary op: %q", unary.Op)
 	}
 
 	return &ast.UnaryOp{
 		Op:     unary.Op,
 		Target: target,
-	}
+	}, nil
 }
 
-func (rw Inliner) EvalCall(call *ast.Call, env Environment) ast.Expression {
+func (rw Inliner) EvalCall(call *ast.Call, env Environment) (ast.Expression, error) {
 	if ident, ok := call.Callee.(*ast.Identifier); ok && (ident.Value == "here" || ident.Value == "at") {
 		var name string
 		var body ast.Expression
 
 		switch ident.Value {
 		case "here":
+			if len(call.Args) != 1 {
+				return nil, parseError("here(rule) expects 1 argument but got %d", len(call.Args))
+			}
 			name = rw.RegionName
 			body = call.Args[0]
 		case "at":
-			name = call.Args[0].(*ast.Literal).Value.(string)
+			if len(call.Args) != 2 {
+				return nil, parseError("at(region, rule) expects 2 arguments but got %d", len(call.Args))
+			}
+			where, ok := call.Args[0].(*ast.Literal)
+			if !ok || where.Kind != ast.LiteralStr {
+				return nil, parseError("at(region, rule) expects the region's name first")
+			}
+			name = where.Value.(string)
 			body = call.Args[1]
 		}
 
 		return rw.expandMacro(name, body, env)
 	}
 
+	var err error
 	newCall := new(ast.Call)
-	newCall.Callee = rw.Rewrite(call.Callee, env)
+	if newCall.Callee, err = rw.Rewrite(call.Callee, env); err != nil {
+		return nil, err
+	}
 	newCall.Args = make([]ast.Expression, len(call.Args))
 	for i := range newCall.Args {
-		newCall.Args[i] = rw.Rewrite(call.Args[i], env)
+		if newCall.Args[i], err = rw.Rewrite(call.Args[i], env); err != nil {
+			return nil, err
+		}
 	}
 
 	v, ok := rw.fromEnv(newCall.Callee, env)
 	if !ok || v.Type() != CALL_TYPE {
-		return newCall
+		return newCall, nil
 	}
 
 	fn, ok := v.(Fn) // specifically
 	if !ok {
-		return newCall
+		return newCall, nil
 	}
 
 	if fn.Arity() != len(newCall.Args) {
-		panic(parseError("mismatch arg count: wanted %d but got %d", fn.Arity(), len(newCall.Args)))
+		return nil, parseError("mismatch arg count: wanted %d but got %d", fn.Arity(), len(newCall.Args))
 	}
 
 	enclosed := env.Enclosed()
 	for i, arg := range newCall.Args {
 		a := rw.resolveToValue(arg, env)
 		if a == nil {
-			return newCall
+			return newCall, nil
 		}
 
 		enclosed.Set(fn.Params[i], a)
 	}
 
-	body := rw.Rewrite(fn.Body, enclosed)
+	body, err := rw.Rewrite(fn.Body, enclosed)
+	if err != nil {
+		return nil, err
+	}
 	switch body.(type) {
 	case *ast.Literal:
-		return body
+		return body, nil
 	default:
 		addr := contentAddress(body)
 		newName := fmt.Sprintf("%s@%s", fn.Name.Value, addr)
@@ -366,7 +481,7 @@ func (rw Inliner) EvalCall(call *ast.Call, env Environment) ast.Expression {
 		env.Set(newName, partialFn)
 		return &ast.Call{
 			Callee: &ast.Identifier{Value: newName},
-		}
+		}, nil
 	}
 }
 
@@ -380,7 +495,7 @@ func (rw Inliner) identInEnv(expr ast.Expression, env Environment) bool {
 	return ok
 }
 
-func (rw Inliner) expandMacro(where string, rule ast.Expression, env Environment) *ast.Identifier {
+func (rw Inliner) expandMacro(where string, rule ast.Expression, env Environment) (ast.Expression, error) {
 	addr := contentAddress(rule)
 	eventName := fmt.Sprintf("%s@%s", where, addr)
 
@@ -388,36 +503,43 @@ func (rw Inliner) expandMacro(where string, rule ast.Expression, env Environment
 	// because we might be referencing a place that doesn't exist yet
 	origin, err := rw.Builder.Entity(components.Name(where))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 	rw.Builder.Node(origin)
 
 	event, err := rw.Builder.Entity(components.Name(eventName))
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
 	rw.Builder.Node(event)
 
-	arch := components.EventArchetype{}
+	arch := components.EventArchetype{T: components.TokenArchetype{Registry: rw.Builder.Registry}}
 	if err := arch.Apply(event); err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	event.Add(rw.Builder.TypedStrs.InstanceOf(eventName))
+	typ, err := rw.Builder.Registry.TypedString(eventName)
+	if err != nil {
+		return nil, err
+	}
+	event.Add(reflect.New(typ).Elem().Interface())
 	rw.Globals.Set(eventName, Token{
 		Literal:   eventName,
-		Component: rw.Builder.TypedStrs.Typed(eventName),
+		Component: typ,
 	})
 
 	edge, err := rw.Builder.Edge(origin, event)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	rule = rw.Rewrite(rule, env)
-	edge.Add(rule)
-	return &ast.Identifier{Value: eventName}
+	rule, err = rw.Rewrite(rule, env)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", eventName, err)
+	}
+	edge.Add(logic.ParsedRule{R: rule})
+	return &ast.Identifier{Value: eventName}, nil
 }
 
 func contentAddress(expr ast.Expression) string {
@@ -428,46 +550,50 @@ func contentAddress(expr ast.Expression) string {
 	return fmt.Sprintf("sha256:%x", hash.Sum(nil))
 }
 
-func (rw Inliner) EvalIdentifier(ident *ast.Identifier, env Environment) ast.Expression {
+func (rw Inliner) EvalIdentifier(ident *ast.Identifier, env Environment) (ast.Expression, error) {
 	if v, ok := rw.fromEnv(ident, env); ok {
 		if CanLiteralfy(v) {
-			return Literalify(v)
+			return Literalify(v), nil
 		}
-		return ident
+		return ident, nil
 	}
 
 	name := ident.Value
 	if setting, ok := rw.Settings[name]; ok {
 		rw.Globals.Set(name, Box(setting))
-		return Literalify(setting)
+		return Literalify(setting), nil
 	}
 
 	if strings.HasPrefix(name, "logic_") {
 		v := rw.Tricks[strings.TrimPrefix(name, "logic_")]
 		rw.Globals.Set(name, Box(v))
-		return Literalify(v)
+		return Literalify(v), nil
 	}
 
 	if _entityName.MatchString(name) {
 		entity, err := rw.Builder.Entity(components.Name(name))
 		if err != nil {
-			panic(err)
+			return nil, err
 		}
 
-		entity.Add(rw.Builder.TypedStrs.InstanceOf(logic.EscapeName(name)))
+		typ, err := rw.Builder.Registry.TypedString(logic.EscapeName(name))
+		if err != nil {
+			return nil, err
+		}
+		entity.Add(reflect.New(typ).Elem().Interface())
 		rw.Globals.Set(name, Token{
 			Literal:   name,
-			Component: rw.Builder.TypedStrs.Typed(logic.EscapeName(name)),
+			Component: typ,
 		})
 	}
 
-	return ident
+	return ident, nil
 }
 
 // lowers to a boolean from a passed settings dict
-func (rw Inliner) EvalSubscript(subscript *ast.Subscript, env Environment) ast.Expression {
+func (rw Inliner) EvalSubscript(subscript *ast.Subscript, env Environment) (ast.Expression, error) {
 	if subscript.Target.Type() != ast.ExprIdentifier || subscript.Index.Type() != ast.ExprIdentifier {
-		panic("subscript only with identifiers")
+		return nil, parseError("subscript only with identifiers")
 	}
 
 	settings := subscript.Target.(*ast.Identifier)
@@ -475,58 +601,54 @@ func (rw Inliner) EvalSubscript(subscript *ast.Subscript, env Environment) ast.E
 
 	switch settings.Value {
 	case "tricks":
-		return Literalify(rw.Tricks[value])
+		return Literalify(rw.Tricks[value]), nil
 	case "skipped_trials":
-		return Literalify(rw.SkippedTrials[value])
+		return Literalify(rw.SkippedTrials[value]), nil
 	case "settings":
-		return Literalify(rw.Settings[value])
+		return Literalify(rw.Settings[value]), nil
 	case "dungeon_shortcuts":
-		return Literalify(rw.DungeonShortcuts[value])
+		return Literalify(rw.DungeonShortcuts[value]), nil
 	default:
-		panic(parseError("unknown subscript target %s[%s]", settings.Value, value))
+		return nil, parseError("unknown subscript target %s[%s]", settings.Value, value)
 	}
 }
 
-func (rw Inliner) EvalTuple(tup *ast.Tuple, env Environment) ast.Expression {
+func (rw Inliner) EvalTuple(tup *ast.Tuple, env Environment) (ast.Expression, error) {
 	if len(tup.Elems) != 2 {
-		panic(BadTupleErr)
+		return nil, BadTupleErr
 	}
 
-	item := tup.Elems[0]
-	want := tup.Elems[1]
-	var ident *ast.Identifier
-	var qty float64
-
-	switch item := item.(type) {
-	case *ast.Identifier:
-		ident = item
-	case *ast.Literal:
-		panic(BadTupleErr)
-	default:
-		panic(BadTupleErr)
+	ident, ok := tup.Elems[0].(*ast.Identifier)
+	if !ok {
+		return nil, BadTupleErr
 	}
-	switch want := want.(type) {
+
+	var qty float64
+	switch want := tup.Elems[1].(type) {
 	case *ast.Identifier:
-		v := rw.resolveToValue(want, env)
-		qty = v.(Number).Value
-		break
+		v, ok := rw.resolveToValue(want, env).(Number)
+		if !ok {
+			return nil, BadTupleErr
+		}
+		qty = v.Value
 	case *ast.Literal:
-		if want.Kind == ast.LiteralNum {
-			qty = want.Value.(float64)
-			break
+		if want.Kind != ast.LiteralNum {
+			return nil, BadTupleErr
 		}
-		panic(BadTupleErr)
+		qty = want.Value.(float64)
 	default:
-		panic(BadTupleErr)
+		return nil, BadTupleErr
 	}
 
 	// ensure that we create the entity in the global env
-	rw.EvalIdentifier(ident, env)
+	if _, err := rw.EvalIdentifier(ident, env); err != nil {
+		return nil, err
+	}
 
 	return &ast.Call{
 		Callee: &ast.Identifier{Value: "has"},
 		Args:   []ast.Expression{ident, Literalify(qty)},
-	}
+	}, nil
 }
 
 var BadTupleErr = errors.New("tuple must be (Ident, Number)")
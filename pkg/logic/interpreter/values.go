@@ -16,6 +16,7 @@ const (
 	STR_TYPE
 	CALL_TYPE
 	TOK_TYPE
+	DICT_TYPE
 )
 
 type Value interface {
@@ -117,26 +118,57 @@ func (s String) String() string {
 	return fmt.Sprintf("%q", s.Value)
 }
 
-func (t Interpreter) IsTruthy(v Value) bool {
+// settings collections like skipped_trials and dungeon_shortcuts
+type Dict struct {
+	Name   string
+	Values map[string]Value
+}
+
+func (d Dict) Type() Type { return DICT_TYPE }
+
+func (d Dict) Eq(Value) bool { return false }
+
+func (d Dict) String() string {
+	return fmt.Sprintf("<Dict: %s>", d.Name)
+}
+
+// names that aren't in the dict are false
+func (d Dict) Get(key string) Value {
+	if v, ok := d.Values[key]; ok {
+		return v
+	}
+	return Boolean{Value: false}
+}
+
+// helpers and builtins without params are called
+func (t Interpreter) IsTruthy(v Value) (bool, error) {
 	switch v := v.(type) {
 	case Boolean:
-		return bool(v.Value)
+		return bool(v.Value), nil
 	case Number:
-		return v.Value != 0
+		return v.Value != 0, nil
 	case String:
-		return v.Value != ""
+		return v.Value != "", nil
 	case Token:
-		return v.Literal != "" && v.Component != nil
+		return v.Literal != "" && v.Component != nil, nil
+	case Dict:
+		return len(v.Values) != 0, nil
 	case Callable:
-		if v.Arity() == 0 {
-			return t.IsTruthy(v.Call(t, nil))
+		if v.Arity() != 0 {
+			return false, fmt.Errorf("%w: %s must be called with %d arguments", ArityErr, v, v.Arity())
+		}
+		result, err := v.Call(t, nil)
+		if err != nil {
+			return false, err
 		}
-		panic(fmt.Errorf("%d arity func must be evaluated!", v.Arity()))
+		return t.IsTruthy(result)
 	default:
-		panic(errors.New("unknown truthiness kind"))
+		return false, fmt.Errorf("%w: %T has no truthiness", TypeErr, v)
 	}
 }
 
+// values are boxed from literals and settings which are only ever bools,
+// numbers and strings, boxing anything else is a bug and panics
 func Box(v any) Value {
 	switch v := v.(type) {
 	case bool:
@@ -152,6 +184,7 @@ func Box(v any) Value {
 	}
 }
 
+// the inverse of Box, unboxing anything but a literal value is a bug
 func Unbox(v Value) any {
 	switch v := v.(type) {
 	case Number:
@@ -169,6 +202,7 @@ func CanLiteralfy(v Value) bool {
 	return v.Type() == NUM_TYPE || v.Type() == BOOL_TYPE || v.Type() == STR_TYPE
 }
 
+// callers check CanLiteralfy first, anything else is a bug and panics
 func Literalify(v any) ast.Expression {
 	if v == nil {
 		panic(errors.New("nil value"))
@@ -191,6 +225,7 @@ func Literalify(v any) ast.Expression {
 	}
 }
 
+// the parser only makes num, bool and str literals, any other kind is a bug
 func ReifyLiteral(expr *ast.Literal) Value {
 	switch expr.Kind {
 	case ast.LiteralNum:
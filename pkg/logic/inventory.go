@@ -0,0 +1,73 @@
+package logic
+
+import (
+	"errors"
+	"reflect"
+	"sync"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/world/components"
+	"sudonters/zootler/pkg/world/filter"
+)
+
+// counts the tokens World has collected, each token is prepared the first
+// time it's counted and afterwards counting is just reading the query. Rules
+// are fulfilled concurrently so preparing is guarded
+type Inventory struct {
+	Entities entity.Queryable
+	World    components.WorldId
+	mu       sync.Mutex
+	prepared map[reflect.Type]entity.PreparedQuery
+}
+
+func NewInventory(entities entity.Queryable, world components.WorldId) *Inventory {
+	return &Inventory{
+		Entities: entities,
+		World:    world,
+		prepared: make(map[reflect.Type]entity.PreparedQuery),
+	}
+}
+
+// how many tokens with the component have been collected, a component the
+// entities don't know yet isn't prepared until they do
+func (i *Inventory) Count(component reflect.Type) (int, error) {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	q, ok := i.prepared[component]
+	if !ok {
+		var err error
+		q, err = i.Entities.Prepare(entity.BuildFilter(filter.InWorld(i.World)).
+			With(collectedType).
+			With(component).
+			Build())
+		if unregistered(err) {
+			return 0, nil
+		}
+		if err != nil {
+			return 0, err
+		}
+		if i.prepared == nil {
+			i.prepared = make(map[reflect.Type]entity.PreparedQuery)
+		}
+		i.prepared[component] = q
+	}
+
+	return q.Count(), nil
+}
+
+// stops maintaining every prepared query, counting again prepares them anew
+func (i *Inventory) Close() {
+	i.mu.Lock()
+	defer i.mu.Unlock()
+	for _, q := range i.prepared {
+		q.Close()
+	}
+	i.prepared = nil
+}
+
+// pools that register components as they're first added don't know the
+// components nothing has had yet, so nothing has them
+func unregistered(err error) bool {
+	var unknown entity.ErrUnknownComponent
+	return errors.As(err, &unknown)
+}
@@ -0,0 +1,266 @@
+package lint
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+
+	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/logic/interpreter"
+	"sudonters/zootler/pkg/logic/loader"
+	"sudonters/zootler/pkg/rules/ast"
+	"sudonters/zootler/pkg/rules/parser"
+	"sudonters/zootler/pkg/world/items"
+	"sudonters/zootler/pkg/world/settings"
+)
+
+// every finding is tagged with the check that found it
+const (
+	ParseError        = "parse-error"
+	UnknownIdentifier = "unknown-identifier"
+	BadArgument       = "bad-argument"
+	UnknownKey        = "unknown-key"
+	UndefinedExit     = "undefined-exit"
+	UnreachableRegion = "unreachable-region"
+	UnusedHelper      = "unused-helper"
+)
+
+type Finding struct {
+	Check  string `json:"check"`
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column int    `json:"column"`
+	// the rule, helper or region the finding is about
+	Subject string `json:"subject"`
+	Msg     string `json:"message"`
+}
+
+// file:line:col: check: message
+func (f Finding) String() string {
+	return fmt.Sprintf("%s:%d:%d: %s: %s", f.File, f.Line, f.Column, f.Check, f.Msg)
+}
+
+// everything rules may refer to that isn't defined by the logic files
+// themselves, item names are escaped the same way rules write them
+type Names struct {
+	// item name to the kind of token it is
+	Items    map[string]string
+	Settings map[string]bool
+	// without their logic_ prefix
+	Tricks   map[string]bool
+	Builtins map[string]bool
+	// provided to every rule when it's evaluated
+	Env map[string]bool
+	// regions every other region must be reachable from
+	Spawns []string
+}
+
+func DefaultNames() Names {
+	names := Names{
+		Items:    make(map[string]string),
+		Settings: make(map[string]bool),
+		Tricks:   make(map[string]bool),
+		Builtins: make(map[string]bool),
+		Env:      make(map[string]bool),
+		Spawns:   []string{loader.Root},
+	}
+
+	for name, kind := range items.Kinds() {
+		names.Items[logic.EscapeName(name)] = kind
+	}
+	for name := range settings.Defaults() {
+		names.Settings[name] = true
+	}
+	for _, name := range settings.Derived {
+		names.Settings[name] = true
+	}
+	for _, name := range settings.TrickNames {
+		names.Tricks[name] = true
+	}
+	for _, name := range interpreter.BuiltInNames {
+		names.Builtins[name] = true
+	}
+	for _, name := range []string{"age", "spot", "tod", "child", "adult", "both", "either"} {
+		names.Env[name] = true
+	}
+
+	return names
+}
+
+// every finding in the logic sorted by where it was found
+func Lint(l loader.Logic, names Names) []Finding {
+	lint := linter{
+		names:   names,
+		events:  make(map[string]bool),
+		helpers: make(map[string]int),
+		used:    make(map[string]bool),
+	}
+
+	for _, region := range l.Regions {
+		for _, event := range region.Events {
+			lint.events[logic.EscapeName(event.Name)] = true
+		}
+	}
+
+	decls := make([]ast.Expression, len(l.Helpers))
+	for i, helper := range l.Helpers {
+		decl, err := helper.ParseDecl()
+		if err != nil {
+			var syntax *parser.SyntaxError
+			if errors.As(err, &syntax) {
+				lint.parseErrors(helper, []*parser.SyntaxError{syntax})
+			}
+			continue
+		}
+		decls[i] = decl
+		switch decl := decl.(type) {
+		case *ast.Identifier:
+			lint.helpers[decl.Value] = 0
+		case *ast.Call:
+			if callee, ok := decl.Callee.(*ast.Identifier); ok {
+				lint.helpers[callee.Value] = len(decl.Args)
+			}
+		}
+	}
+
+	for _, region := range l.Regions {
+		for _, rules := range [][]loader.Rule{region.Events, region.Locations, region.Exits} {
+			for _, rule := range rules {
+				lint.rule(rule, "", nil)
+			}
+		}
+	}
+
+	for i, helper := range l.Helpers {
+		if decls[i] == nil {
+			continue
+		}
+		name, params := declared(decls[i])
+		lint.rule(helper, name, params)
+	}
+
+	lint.exits(l)
+	lint.reachable(l)
+	lint.unused(l, decls)
+
+	sort.SliceStable(lint.findings, func(i, j int) bool {
+		a, b := lint.findings[i], lint.findings[j]
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		if a.Line != b.Line {
+			return a.Line < b.Line
+		}
+		return a.Column < b.Column
+	})
+	return lint.findings
+}
+
+type linter struct {
+	names    Names
+	events   map[string]bool
+	helpers  map[string]int // arity
+	used     map[string]bool
+	findings []Finding
+}
+
+func (l *linter) report(check, subject string, src *parser.Source, pos ast.Position, format string, v ...any) {
+	l.findings = append(l.findings, Finding{
+		Check:   check,
+		File:    src.Name,
+		Line:    pos.Line,
+		Column:  pos.Column,
+		Subject: subject,
+		Msg:     fmt.Sprintf(format, v...),
+	})
+}
+
+func (l *linter) parseErrors(rule loader.Rule, errs []*parser.SyntaxError) {
+	for _, err := range errs {
+		l.report(ParseError, rule.Name, rule.Source, err.Pos.Start, "%s", err.Msg)
+	}
+}
+
+// helper is the name of the helper being linted, helpers using themselves
+// doesn't count as being used
+func (l *linter) rule(rule loader.Rule, helper string, params map[string]bool) {
+	expr, errs := rule.ParseRecovering()
+	l.parseErrors(rule, errs)
+
+	ast.Visit(&ruleVisitor{linter: l, rule: rule, helper: helper, params: params}, expr)
+}
+
+func (l *linter) exits(logic loader.Logic) {
+	for _, region := range logic.Regions {
+		for _, exit := range region.Exits {
+			if _, defined := logic.Region(exit.Name); !defined {
+				l.report(UndefinedExit, exit.Name, exit.Source, exit.Source.Position(exit.NameStart),
+					"%s exits to undefined region %q", region.Name, exit.Name)
+			}
+		}
+	}
+}
+
+// exits are followed without considering their rules, both quests of a
+// dungeon are walked
+func (l *linter) reachable(logic loader.Logic) {
+	exits := make(map[string][]string, len(logic.Regions))
+	for _, region := range logic.Regions {
+		for _, exit := range region.Exits {
+			exits[region.Name] = append(exits[region.Name], exit.Name)
+		}
+	}
+
+	reached := make(map[string]bool, len(logic.Regions))
+	queue := append([]string(nil), l.names.Spawns...)
+	for len(queue) > 0 {
+		name := queue[0]
+		queue = queue[1:]
+		if reached[name] {
+			continue
+		}
+		reached[name] = true
+		queue = append(queue, exits[name]...)
+	}
+
+	for _, region := range logic.Regions {
+		if !reached[region.Name] {
+			l.report(UnreachableRegion, region.Name, region.Source, region.Source.Position(region.Start),
+				"region %q is unreachable from %s", region.Name, strings.Join(l.names.Spawns, ", "))
+		}
+	}
+}
+
+func (l *linter) unused(logic loader.Logic, decls []ast.Expression) {
+	for i, helper := range logic.Helpers {
+		if decls[i] == nil {
+			continue
+		}
+		if name, _ := declared(decls[i]); !l.used[name] {
+			l.report(UnusedHelper, helper.Name, helper.Source, helper.Source.Position(helper.NameStart),
+				"helper %q is never used", name)
+		}
+	}
+}
+
+func declared(decl ast.Expression) (string, map[string]bool) {
+	switch decl := decl.(type) {
+	case *ast.Identifier:
+		return decl.Value, nil
+	case *ast.Call:
+		callee, _ := decl.Callee.(*ast.Identifier)
+		if callee == nil {
+			return "", nil
+		}
+		params := make(map[string]bool, len(decl.Args))
+		for _, arg := range decl.Args {
+			if param, ok := arg.(*ast.Identifier); ok {
+				params[param.Value] = true
+			}
+		}
+		return callee.Value, params
+	default:
+		return "", nil
+	}
+}
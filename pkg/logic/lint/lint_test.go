@@ -0,0 +1,115 @@
+package lint
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sudonters/zootler/pkg/logic/loader"
+)
+
+func testNames() Names {
+	return Names{
+		Items:    map[string]string{"Bow": "Item", "Zeldas_Lullaby": "Song", "Scarecrow_Song": "Event"},
+		Settings: map[string]bool{"open_forest": true, "logic_rules": true},
+		Tricks:   map[string]bool{"dc_jump": true},
+		Builtins: map[string]bool{"has": true},
+		Env:      map[string]bool{"age": true},
+		Spawns:   []string{"Root"},
+	}
+}
+
+func lintFiles(t *testing.T, files map[string]string) []Finding {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	logic, err := loader.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return Lint(logic, testNames())
+}
+
+func expectFindings(t *testing.T, findings []Finding, expected ...string) {
+	t.Helper()
+	if len(findings) != len(expected) {
+		t.Errorf("expected %d findings but found %d", len(expected), len(findings))
+	}
+
+	for i, finding := range findings {
+		if i >= len(expected) || !strings.HasSuffix(finding.String(), expected[i]) {
+			t.Errorf("unexpected finding %s", finding)
+		}
+	}
+}
+
+func TestCleanLogicHasNoFindings(t *testing.T) {
+	findings := lintFiles(t, map[string]string{
+		"Overworld.json": `[
+  {"region_name": "Root", "events": {"Forest Open": "open_forest == 'open'"}, "exits": {"Forest": "is_child"}},
+  {"region_name": "Forest", "locations": {"Chest": "Forest_Open and logic_dc_jump and can_play(Zeldas_Lullaby)"}}
+]`,
+		loader.HelpersFile: `{"is_child": "age == 'child' or logic_rules == 'glitched'", "can_play(song)": "song and has(Bow, 1)"}`,
+	})
+
+	expectFindings(t, findings)
+}
+
+func TestRulesAreChecked(t *testing.T) {
+	findings := lintFiles(t, map[string]string{
+		"Overworld.json": `[
+  {"region_name": "Root", "locations": {
+    "A": "Bows or logic_dc_jumps",
+    "B": "can_use(Zeldas_Lullaby) or can_use(Hookshot) or can_play(Bow) or can_play(Scarecrow_Song)",
+    "C": "tricks[dc_jump] and settings['open_forests'] and sword[Kokiri]",
+    "D": "is_adult(Bow) and summon()"
+  }}
+]`,
+		loader.HelpersFile: `{"is_adult": "age == 'adult'", "can_use(item)": "item", "can_play(song)": "song"}`,
+	})
+
+	expectFindings(t, findings,
+		`3:11: unknown-identifier: "Bows" is not an item, event, helper, setting or trick`,
+		`3:19: unknown-identifier: unknown trick "logic_dc_jumps"`,
+		`4:46: bad-argument: can_use expects an item but was given "Hookshot"`,
+		`4:68: bad-argument: can_play expects a song but was given "Bow"`,
+		`5:40: unknown-key: settings has no key "open_forests"`,
+		`5:60: unknown-identifier: "sword" can't be subscripted`,
+		`6:11: bad-argument: is_adult takes 0 arguments but was given 1`,
+		`6:29: unknown-identifier: unknown function "summon"`,
+	)
+}
+
+func TestRegionsAndHelpersAreChecked(t *testing.T) {
+	findings := lintFiles(t, map[string]string{
+		"Overworld.json": `[
+  {"region_name": "Root", "exits": {"Forest": "True", "Nowhere": "True"}},
+  {"region_name": "Forest"},
+  {"region_name": "Island", "exits": {"Root": "True"}}
+]`,
+		loader.HelpersFile: `{"is_child": "age == 'child' and is_child"}`,
+	})
+
+	expectFindings(t, findings,
+		`LogicHelpers.json:1:3: unused-helper: helper "is_child" is never used`,
+		`Overworld.json:2:56: undefined-exit: Root exits to undefined region "Nowhere"`,
+		`Overworld.json:4:3: unreachable-region: region "Island" is unreachable from Root`,
+	)
+}
+
+func TestParseErrorsAreFindings(t *testing.T) {
+	findings := lintFiles(t, map[string]string{
+		"Overworld.json": `[{"region_name": "Root", "locations": {"A": "has(Bow,"}}]`,
+	})
+
+	expectFindings(t, findings, `1:54: parse-error: unclosed '(' or '['`)
+	if len(findings) > 0 && findings[0].Subject != "A" {
+		t.Fatalf("expected the finding to be about A but found %q", findings[0].Subject)
+	}
+}
@@ -0,0 +1,199 @@
+package lint
+
+import (
+	"strings"
+
+	"sudonters/zootler/pkg/logic/loader"
+	"sudonters/zootler/pkg/rules/ast"
+)
+
+// checks a single rule, findings are reported to the linter
+type ruleVisitor struct {
+	*linter
+	rule loader.Rule
+	// the helper being checked, if any, and its params
+	helper string
+	params map[string]bool
+}
+
+func (v *ruleVisitor) report(check string, node ast.Expression, format string, args ...any) {
+	v.linter.report(check, v.rule.Name, v.rule.Source, node.Span().Start, format, args...)
+}
+
+func (v *ruleVisitor) use(helper string) {
+	if helper != v.helper {
+		v.used[helper] = true
+	}
+}
+
+func (v *ruleVisitor) isHelper(name string) bool {
+	if _, ok := v.helpers[name]; ok {
+		v.use(name)
+		return true
+	}
+	return false
+}
+
+// settings are checked before tricks because some settings also have the
+// logic_ prefix
+func (v *ruleVisitor) resolves(name string) bool {
+	switch {
+	case v.params[name], v.names.Env[name], v.events[name], v.names.Settings[name], v.names.Builtins[name]:
+		return true
+	case v.isHelper(name):
+		return true
+	}
+
+	if _, ok := v.names.Items[name]; ok {
+		return true
+	}
+
+	if trick, ok := strings.CutPrefix(name, "logic_"); ok {
+		return v.names.Tricks[trick]
+	}
+
+	return false
+}
+
+func (v *ruleVisitor) VisitAttribute(node *ast.Attribute) error {
+	return ast.Visit(v, node.Target)
+}
+
+func (v *ruleVisitor) VisitBinOp(node *ast.BinOp) error {
+	ast.Visit(v, node.Left)
+	return ast.Visit(v, node.Right)
+}
+
+func (v *ruleVisitor) VisitBoolOp(node *ast.BoolOp) error {
+	ast.Visit(v, node.Left)
+	return ast.Visit(v, node.Right)
+}
+
+func (v *ruleVisitor) VisitCall(node *ast.Call) error {
+	callee, ok := node.Callee.(*ast.Identifier)
+	if !ok {
+		ast.Visit(v, node.Callee)
+		return v.visitAll(node.Args)
+	}
+
+	name := callee.Value
+	switch {
+	case name == "can_use" || name == "can_play":
+		v.use(name)
+		v.argument(name, node)
+		return nil
+	case v.names.Builtins[name], v.params[name]:
+	case v.isHelper(name):
+		if arity := v.helpers[name]; arity != len(node.Args) {
+			v.report(BadArgument, node, "%s takes %d arguments but was given %d", name, arity, len(node.Args))
+		}
+	default:
+		v.report(UnknownIdentifier, callee, "unknown function %q", name)
+	}
+
+	return v.visitAll(node.Args)
+}
+
+// can_use must be given an item or a helper that stands in for one and
+// can_play must be given a song. The scarecrow's song is an event rather than
+// one of the ocarina songs
+func (v *ruleVisitor) argument(fn string, call *ast.Call) {
+	if len(call.Args) != 1 {
+		v.report(BadArgument, call, "%s takes 1 argument but was given %d", fn, len(call.Args))
+		return
+	}
+
+	arg, ok := call.Args[0].(*ast.Identifier)
+	if !ok {
+		v.report(BadArgument, call.Args[0], "%s must be given a name", fn)
+		return
+	}
+
+	if v.params[arg.Value] {
+		return
+	}
+
+	kind, isItem := v.names.Items[arg.Value]
+	switch fn {
+	case "can_use":
+		if !isItem && !(v.helpers[arg.Value] == 0 && v.isHelper(arg.Value)) {
+			v.report(BadArgument, arg, "can_use expects an item but was given %q", arg.Value)
+		}
+	case "can_play":
+		if kind != "Song" && !(kind == "Event" && strings.HasSuffix(arg.Value, "_Song")) {
+			v.report(BadArgument, arg, "can_play expects a song but was given %q", arg.Value)
+		}
+	}
+}
+
+func (v *ruleVisitor) VisitIdentifier(node *ast.Identifier) error {
+	if v.resolves(node.Value) {
+		return nil
+	}
+
+	if _, ok := strings.CutPrefix(node.Value, "logic_"); ok {
+		v.report(UnknownIdentifier, node, "unknown trick %q", node.Value)
+		return nil
+	}
+
+	v.report(UnknownIdentifier, node, "%q is not an item, event, helper, setting or trick", node.Value)
+	return nil
+}
+
+func (v *ruleVisitor) VisitInvalid(*ast.Invalid) error {
+	return nil
+}
+
+// only the keys of tricks and settings are known ahead of time
+func (v *ruleVisitor) VisitSubscript(node *ast.Subscript) error {
+	target, ok := node.Target.(*ast.Identifier)
+	if !ok {
+		ast.Visit(v, node.Target)
+		return ast.Visit(v, node.Index)
+	}
+
+	var known map[string]bool
+	switch target.Value {
+	case "tricks":
+		known = v.names.Tricks
+	case "settings":
+		known = v.names.Settings
+	case "skipped_trials", "dungeon_shortcuts":
+		return nil
+	default:
+		v.report(UnknownIdentifier, target, "%q can't be subscripted", target.Value)
+		return nil
+	}
+
+	var key string
+	switch index := node.Index.(type) {
+	case *ast.Identifier:
+		key = index.Value
+	case *ast.Literal:
+		key, _ = index.Value.(string)
+	}
+
+	if !known[key] {
+		v.report(UnknownKey, node.Index, "%s has no key %q", target.Value, key)
+	}
+	return nil
+}
+
+func (v *ruleVisitor) VisitTuple(node *ast.Tuple) error {
+	return v.visitAll(node.Elems)
+}
+
+func (v *ruleVisitor) VisitUnary(node *ast.UnaryOp) error {
+	return ast.Visit(v, node.Target)
+}
+
+func (v *ruleVisitor) VisitLiteral(*ast.Literal) error {
+	return nil
+}
+
+func (v *ruleVisitor) visitAll(nodes []ast.Expression) error {
+	for _, node := range nodes {
+		ast.Visit(v, node)
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package loader
+
+import (
+	"fmt"
+	"strings"
+)
+
+// logic files keep their rules unescaped and may break them across lines,
+// which decoding the file would fold away, so values only record where they
+// are. Strings cover their text and everything else covers the whole value
+type docValue struct {
+	start, end int
+	str        bool
+	members    []docMember
+	elems      []docValue
+}
+
+func (v docValue) text(doc string) string {
+	return doc[v.start:v.end]
+}
+
+type docMember struct {
+	key, value docValue
+}
+
+// scans JSON with # and // line comments and /* */ block comments, strings
+// may hold raw newlines
+type docScanner struct {
+	doc string
+	pos int
+}
+
+func (s *docScanner) document() (docValue, error) {
+	v, err := s.value()
+	if err != nil {
+		return v, err
+	}
+	if s.skip(); s.pos < len(s.doc) {
+		return v, s.errorAt(s.pos, "unexpected %q after document", s.doc[s.pos])
+	}
+	if v.members == nil && v.elems == nil {
+		return v, s.errorAt(v.start, "expected an object or array")
+	}
+	return v, nil
+}
+
+func (s *docScanner) value() (docValue, error) {
+	s.skip()
+	if s.pos >= len(s.doc) {
+		return docValue{}, s.errorAt(s.pos, "unexpected end of document")
+	}
+
+	switch s.doc[s.pos] {
+	case '{':
+		return s.object()
+	case '[':
+		return s.array()
+	case '"':
+		return s.string()
+	default:
+		start := s.pos
+		for s.pos < len(s.doc) && !strings.ContainsRune(",]} \t\r\n", rune(s.doc[s.pos])) {
+			s.pos++
+		}
+		if start == s.pos {
+			return docValue{}, s.errorAt(s.pos, "unexpected %q", s.doc[s.pos])
+		}
+		return docValue{start: start, end: s.pos}, nil
+	}
+}
+
+func (s *docScanner) object() (docValue, error) {
+	v := docValue{start: s.pos, members: []docMember{}}
+	s.pos++
+
+	for s.skip(); !s.accept('}'); s.skip() {
+		if len(v.members) > 0 && !s.accept(',') {
+			return v, s.errorAt(s.pos, "expected ',' or '}'")
+		}
+		// trailing commas are tolerated
+		if s.skip(); s.accept('}') {
+			break
+		}
+
+		s.skip()
+		if s.pos >= len(s.doc) || s.doc[s.pos] != '"' {
+			return v, s.errorAt(s.pos, "expected a key")
+		}
+		key, err := s.string()
+		if err != nil {
+			return v, err
+		}
+
+		if s.skip(); !s.accept(':') {
+			return v, s.errorAt(s.pos, "expected ':' after %q", key.text(s.doc))
+		}
+
+		value, err := s.value()
+		if err != nil {
+			return v, err
+		}
+		v.members = append(v.members, docMember{key, value})
+	}
+
+	v.end = s.pos
+	return v, nil
+}
+
+func (s *docScanner) array() (docValue, error) {
+	v := docValue{start: s.pos, elems: []docValue{}}
+	s.pos++
+
+	for s.skip(); !s.accept(']'); s.skip() {
+		if len(v.elems) > 0 && !s.accept(',') {
+			return v, s.errorAt(s.pos, "expected ',' or ']'")
+		}
+		if s.skip(); s.accept(']') {
+			break
+		}
+
+		elem, err := s.value()
+		if err != nil {
+			return v, err
+		}
+		v.elems = append(v.elems, elem)
+	}
+
+	v.end = s.pos
+	return v, nil
+}
+
+// the opening quote is current
+func (s *docScanner) string() (docValue, error) {
+	v := docValue{start: s.pos + 1, str: true}
+	for s.pos++; s.pos < len(s.doc); s.pos++ {
+		switch s.doc[s.pos] {
+		case '\\':
+			s.pos++
+		case '"':
+			v.end = s.pos
+			s.pos++
+			return v, nil
+		}
+	}
+	return v, s.errorAt(v.start-1, "unterminated string")
+}
+
+func (s *docScanner) accept(b byte) bool {
+	if s.pos < len(s.doc) && s.doc[s.pos] == b {
+		s.pos++
+		return true
+	}
+	return false
+}
+
+// whitespace and comments
+func (s *docScanner) skip() {
+	for s.pos < len(s.doc) {
+		switch rest := s.doc[s.pos:]; {
+		case strings.ContainsRune(" \t\r\n", rune(rest[0])):
+			s.pos++
+		case rest[0] == '#' || strings.HasPrefix(rest, "//"):
+			if end := strings.IndexByte(rest, '\n'); end >= 0 {
+				s.pos += end + 1
+			} else {
+				s.pos = len(s.doc)
+			}
+		case strings.HasPrefix(rest, "/*"):
+			if end := strings.Index(rest[2:], "*/"); end >= 0 {
+				s.pos += end + 4
+			} else {
+				s.pos = len(s.doc)
+			}
+		default:
+			return
+		}
+	}
+}
+
+func (s *docScanner) errorAt(offset int, format string, v ...any) error {
+	line := strings.Count(s.doc[:offset], "\n") + 1
+	col := offset - strings.LastIndexByte(s.doc[:offset], '\n')
+	return fmt.Errorf("%d:%d: %s", line, col, fmt.Sprintf(format, v...))
+}
@@ -0,0 +1,362 @@
+package loader
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"sudonters/zootler/pkg/rules/ast"
+	"sudonters/zootler/pkg/rules/parser"
+)
+
+const HelpersFile = "LogicHelpers.json"
+
+// every world starts in this region
+const Root = "Root"
+
+// master quest logic for a dungeon is in a file named after the dungeon with
+// this suffix, e.g. "Deku Tree MQ.json"
+const MasterQuestSuffix = " MQ"
+
+// which of a dungeon's logic files a region was read from
+type Quest uint8
+
+const (
+	// overworld regions and dungeon regions outside the dungeon's own file,
+	// like boss rooms, are used with either quest
+	AnyQuest Quest = iota
+	VanillaQuest
+	MasterQuest
+)
+
+// every region and helper in a logic directory
+type Logic struct {
+	// in file name order and then the order each file lists them. Dungeons
+	// with master quest logic have their regions twice until Select picks one
+	Regions []Region
+	Helpers []Rule
+}
+
+func (l Logic) Region(name string) (Region, bool) {
+	for _, region := range l.Regions {
+		if region.Name == name {
+			return region, true
+		}
+	}
+	return Region{}, false
+}
+
+// the dungeons that have master quest logic, sorted
+func (l Logic) MasterQuestDungeons() []string {
+	var dungeons []string
+	seen := make(map[string]bool)
+	for _, region := range l.Regions {
+		if region.Quest == MasterQuest && !seen[region.Dungeon] {
+			seen[region.Dungeon] = true
+			dungeons = append(dungeons, region.Dungeon)
+		}
+	}
+	sort.Strings(dungeons)
+	return dungeons
+}
+
+// keeps master quest regions for the dungeons in mq and vanilla regions for
+// every other dungeon
+func (l Logic) Select(mq map[string]bool) Logic {
+	selected := Logic{Helpers: l.Helpers}
+	for _, region := range l.Regions {
+		switch region.Quest {
+		case VanillaQuest:
+			if mq[region.Dungeon] {
+				continue
+			}
+		case MasterQuest:
+			if !mq[region.Dungeon] {
+				continue
+			}
+		}
+		selected.Regions = append(selected.Regions, region)
+	}
+	return selected
+}
+
+type Region struct {
+	Name       string
+	Dungeon    string
+	Scene      string
+	Hint       string
+	AltHint    string
+	FontColor  string
+	TimePasses bool
+	// the region a savewarp in this region returns to
+	Savewarp   string
+	IsBossRoom bool
+	Quest      Quest
+	Events     []Rule
+	Locations  []Rule
+	Exits      []Rule
+	Source     *parser.Source
+	// offset into the source's text the region starts at
+	Start int
+}
+
+// a rule as written in its logic file, rules are often broken across lines
+type Rule struct {
+	// the event, location or exit's name, helpers are named by their
+	// declaration
+	Name string
+	// as written less surrounding whitespace
+	Text string
+	// offsets into the source's text
+	NameStart  int
+	Start, End int
+	Source     *parser.Source
+}
+
+func (r Rule) Parse() (ast.Expression, error) {
+	return parser.ParseSource(r.Source, r.Start, r.End)
+}
+
+func (r Rule) ParseRecovering() (ast.Expression, []*parser.SyntaxError) {
+	return parser.ParseRecovering(r.Source, r.Start, r.End)
+}
+
+// helpers declare either an identifier or a call with identifier params
+func (r Rule) ParseDecl() (ast.Expression, error) {
+	return parser.ParseSource(r.Source, r.NameStart, r.NameStart+len(r.Name))
+}
+
+// helpers are read from LogicHelpers.json and regions from every other json
+// file. Every problem in the directory is reported rather than the first
+func Load(dir string) (Logic, error) {
+	var logic Logic
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return logic, err
+	}
+
+	var errs []error
+	seen := make(map[string][]Region)
+
+	for _, entry := range entries {
+		if entry.IsDir() || filepath.Ext(entry.Name()) != ".json" {
+			continue
+		}
+
+		path := filepath.Join(dir, entry.Name())
+		if entry.Name() == HelpersFile {
+			helpers, err := ReadHelpers(path)
+			if err != nil {
+				errs = append(errs, err)
+			}
+			logic.Helpers = helpers
+			continue
+		}
+
+		regions, err := ReadRegions(path)
+		if err != nil {
+			errs = append(errs, err)
+		}
+
+	regions:
+		for _, region := range regions {
+			// a dungeon's quests name their regions the same
+			for _, first := range seen[region.Name] {
+				if first.Quest == region.Quest || first.Quest == AnyQuest || region.Quest == AnyQuest {
+					errs = append(errs, fmt.Errorf("%s: region %q is already defined in %s",
+						region.Source.Name, region.Name, first.Source.Name))
+					continue regions
+				}
+			}
+			seen[region.Name] = append(seen[region.Name], region)
+			logic.Regions = append(logic.Regions, region)
+		}
+	}
+
+	return logic, errors.Join(errs...)
+}
+
+// regions that fail validation are left out. Regions in a file named after
+// their dungeon are that dungeon's vanilla logic, and every region in a file
+// with the master quest suffix is its dungeon's master quest logic
+func ReadRegions(path string) ([]Region, error) {
+	src, root, err := readDocument(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.elems == nil {
+		return nil, fmt.Errorf("%s: expected a list of regions", path)
+	}
+
+	d := decoder{src: src}
+	stem := strings.TrimSuffix(filepath.Base(path), filepath.Ext(path))
+	dungeon, mq := strings.CutSuffix(stem, MasterQuestSuffix)
+
+	var regions []Region
+	for _, elem := range root.elems {
+		region, ok := d.region(elem)
+		if !ok {
+			continue
+		}
+
+		switch {
+		case mq && region.Dungeon != dungeon:
+			d.errorAt(elem, "master quest region %q must be in %s", region.Name, dungeon)
+			continue
+		case mq:
+			region.Quest = MasterQuest
+		case region.Dungeon == dungeon:
+			region.Quest = VanillaQuest
+		}
+		regions = append(regions, region)
+	}
+
+	return regions, errors.Join(d.errs...)
+}
+
+func ReadHelpers(path string) ([]Rule, error) {
+	src, root, err := readDocument(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if root.members == nil {
+		return nil, fmt.Errorf("%s: expected an object of helpers", path)
+	}
+
+	d := decoder{src: src}
+	return d.rules(root, "helpers"), errors.Join(d.errs...)
+}
+
+func readDocument(path string) (*parser.Source, docValue, error) {
+	contents, err := os.ReadFile(path)
+	if err != nil {
+		return nil, docValue{}, err
+	}
+
+	s := &docScanner{doc: string(contents)}
+	root, err := s.document()
+	if err != nil {
+		return nil, root, fmt.Errorf("%s:%w", path, err)
+	}
+
+	return parser.NewSource(path, s.doc), root, nil
+}
+
+type decoder struct {
+	src  *parser.Source
+	errs []error
+}
+
+func (d *decoder) errorAt(v docValue, format string, args ...any) {
+	d.errs = append(d.errs, fmt.Errorf("%s:%s: %s", d.src.Name, d.src.Position(v.start), fmt.Sprintf(format, args...)))
+}
+
+func (d *decoder) region(v docValue) (Region, bool) {
+	region := Region{Source: d.src, Start: v.start}
+	if v.members == nil {
+		d.errorAt(v, "expected a region")
+		return region, false
+	}
+
+	valid := true
+	for _, member := range v.members {
+		key, value := member.key.text(d.src.Text), member.value
+		switch key {
+		case "region_name":
+			valid = d.str(key, value, &region.Name) && valid
+		case "dungeon":
+			valid = d.str(key, value, &region.Dungeon) && valid
+		case "scene":
+			valid = d.str(key, value, &region.Scene) && valid
+		case "hint":
+			valid = d.str(key, value, &region.Hint) && valid
+		case "alt_hint":
+			valid = d.str(key, value, &region.AltHint) && valid
+		case "font_color":
+			valid = d.str(key, value, &region.FontColor) && valid
+		case "savewarp":
+			valid = d.str(key, value, &region.Savewarp) && valid
+		case "time_passes":
+			valid = d.boolean(key, value, &region.TimePasses) && valid
+		case "is_boss_room":
+			valid = d.boolean(key, value, &region.IsBossRoom) && valid
+		case "events":
+			region.Events = d.rules(value, key)
+		case "locations":
+			region.Locations = d.rules(value, key)
+		case "exits":
+			region.Exits = d.rules(value, key)
+		default:
+			d.errorAt(member.key, "unknown key %q", key)
+			valid = false
+		}
+	}
+
+	if region.Name == "" {
+		d.errorAt(v, "region has no region_name")
+		return region, false
+	}
+
+	return region, valid
+}
+
+func (d *decoder) str(key string, v docValue, dest *string) bool {
+	if !v.str {
+		d.errorAt(v, "%s must be a string", key)
+		return false
+	}
+	*dest = v.text(d.src.Text)
+	return true
+}
+
+// the files write booleans as JSON literals and as Python's True and False
+func (d *decoder) boolean(key string, v docValue, dest *bool) bool {
+	switch v.text(d.src.Text) {
+	case "true", "True":
+		*dest = true
+	case "false", "False":
+		*dest = false
+	default:
+		d.errorAt(v, "%s must be a boolean", key)
+		return false
+	}
+	return true
+}
+
+func (d *decoder) rules(v docValue, kind string) []Rule {
+	if v.members == nil {
+		d.errorAt(v, "%s must be an object", kind)
+		return nil
+	}
+
+	rules := make([]Rule, 0, len(v.members))
+	names := make(map[string]bool, len(v.members))
+	for _, member := range v.members {
+		name := member.key.text(d.src.Text)
+		if names[name] {
+			d.errorAt(member.key, "%s has %q more than once", kind, name)
+			continue
+		}
+		names[name] = true
+
+		if !member.value.str {
+			d.errorAt(member.value, "rule for %q must be a string", name)
+			continue
+		}
+
+		rules = append(rules, Rule{
+			Name:      name,
+			Text:      strings.TrimSpace(member.value.text(d.src.Text)),
+			NameStart: member.key.start,
+			Start:     member.value.start,
+			End:       member.value.end,
+			Source:    d.src,
+		})
+	}
+	return rules
+}
@@ -0,0 +1,209 @@
+package loader
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sudonters/zootler/pkg/rules/ast"
+)
+
+const overworld = `[
+    # comments aren't strings
+    {
+        "region_name": "Root",
+        "hint": "ROOT",
+        "time_passes": true,
+        "locations": {
+            "Links Pocket": "True",
+        },
+        "exits": {
+            "Root Exits": "
+                is_adult or
+                can_play(Prelude_of_Light)" // multiline
+        }
+    },
+    {
+        "region_name": "Ganons Castle Tower",
+        "dungeon": "Ganons Castle",
+        "alt_hint": "GANONDORFS_CHAMBER",
+        "is_boss_room": "True",
+        "savewarp": "Ganons Castle Grounds"
+    }
+]`
+
+func writeLogic(t *testing.T, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, contents := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadRegions(t *testing.T) {
+	dir := writeLogic(t, map[string]string{
+		"Overworld.json": overworld,
+		HelpersFile:      `{"is_child": "age == 'child'", "has_bottle": "Bottle"}`,
+	})
+
+	logic, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logic.Regions) != 2 || len(logic.Helpers) != 2 {
+		t.Fatalf("expected 2 regions and 2 helpers but found %d and %d", len(logic.Regions), len(logic.Helpers))
+	}
+
+	root, _ := logic.Region("Root")
+	if root.Hint != "ROOT" || !root.TimePasses || len(root.Locations) != 1 {
+		t.Fatalf("expected root's fields to be decoded but found %+v", root)
+	}
+
+	tower, _ := logic.Region("Ganons Castle Tower")
+	if !tower.IsBossRoom || tower.AltHint != "GANONDORFS_CHAMBER" || tower.Savewarp != "Ganons Castle Grounds" {
+		t.Fatalf("expected tower's fields to be decoded but found %+v", tower)
+	}
+
+	exit := root.Exits[0]
+	if exit.Name != "Root Exits" || !strings.HasPrefix(exit.Text, "is_adult or") {
+		t.Fatalf("expected the exit as written but found %+v", exit)
+	}
+
+	rule, err := exit.Parse()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if start := rule.Span().Start; start.Line != 12 || start.Column != 17 {
+		t.Fatalf("expected the rule's position in its file but found %s", start)
+	}
+}
+
+func TestHelpersDeclareFunctions(t *testing.T) {
+	dir := writeLogic(t, map[string]string{
+		HelpersFile: `{"has_projectile(for_age)": "Slingshot or Bow"}`,
+	})
+
+	logic, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	decl, err := logic.Helpers[0].ParseDecl()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if call, ok := decl.(*ast.Call); !ok || len(call.Args) != 1 {
+		t.Fatalf("expected a call declaration but found %#v", decl)
+	}
+}
+
+func TestLoadReportsEveryProblem(t *testing.T) {
+	dir := writeLogic(t, map[string]string{
+		"A.json": `[{"region_name": "Root", "tiem_passes": true}, {"region_name": "Dupe"}]`,
+		"B.json": `[
+  {"region_name": "Dupe", "exits": {"Root": True}},
+  {"hint": "NOWHERE"}
+]`,
+	})
+
+	logic, err := Load(dir)
+	if err == nil {
+		t.Fatal("expected problems to be reported")
+	}
+
+	for _, problem := range []string{
+		`A.json:1:27: unknown key "tiem_passes"`,
+		`B.json: region "Dupe" is already defined in`,
+		`B.json:2:45: rule for "Root" must be a string`,
+		`B.json:3:3: region has no region_name`,
+	} {
+		if !strings.Contains(err.Error(), problem) {
+			t.Errorf("expected %q to be reported in\n%s", problem, err)
+		}
+	}
+
+	if len(logic.Regions) != 1 || logic.Regions[0].Name != "Dupe" {
+		t.Fatalf("expected only valid regions to be loaded but found %+v", logic.Regions)
+	}
+}
+
+func TestMasterQuestFiles(t *testing.T) {
+	dir := writeLogic(t, map[string]string{
+		"Deku Tree.json":    `[{"region_name": "Deku Tree Lobby", "dungeon": "Deku Tree"}]`,
+		"Deku Tree MQ.json": `[{"region_name": "Deku Tree Lobby", "dungeon": "Deku Tree"}]`,
+		"Bosses.json":       `[{"region_name": "Queen Gohma Boss Room", "dungeon": "Deku Tree"}]`,
+		"Ice Cavern.json":   `[{"region_name": "Ice Cavern", "dungeon": "Ice Cavern"}]`,
+	})
+
+	logic, err := Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if dungeons := logic.MasterQuestDungeons(); len(dungeons) != 1 || dungeons[0] != "Deku Tree" {
+		t.Fatalf("expected only the deku tree to have master quest logic but found %v", dungeons)
+	}
+
+	quests := func(l Logic) map[string]Quest {
+		found := make(map[string]Quest)
+		for _, region := range l.Regions {
+			found[region.Name] = region.Quest
+		}
+		return found
+	}
+
+	mqLogic, vanillaLogic := logic.Select(map[string]bool{"Deku Tree": true}), logic.Select(nil)
+	if len(mqLogic.Regions) != 3 || len(vanillaLogic.Regions) != 3 {
+		t.Fatalf("expected a single lobby to be selected but found %d and %d regions", len(mqLogic.Regions), len(vanillaLogic.Regions))
+	}
+
+	mq, vanilla := quests(mqLogic), quests(vanillaLogic)
+	for name, expected := range map[string][2]Quest{
+		"Deku Tree Lobby":       {MasterQuest, VanillaQuest},
+		"Queen Gohma Boss Room": {AnyQuest, AnyQuest},
+		"Ice Cavern":            {VanillaQuest, VanillaQuest},
+	} {
+		if mq[name] != expected[0] || vanilla[name] != expected[1] {
+			t.Errorf("%s: expected %v but found %v and %v", name, expected, mq[name], vanilla[name])
+		}
+	}
+}
+
+func TestMasterQuestRegionsBelongToTheirDungeon(t *testing.T) {
+	dir := writeLogic(t, map[string]string{
+		"Deku Tree MQ.json": `[{"region_name": "Lost Woods"}]`,
+		"Overworld.json":    `[{"region_name": "Lost Woods"}]`,
+	})
+
+	_, err := Load(dir)
+	if err == nil || !strings.Contains(err.Error(), `Deku Tree MQ.json:1:2: master quest region "Lost Woods" must be in Deku Tree`) {
+		t.Fatalf("expected the stray region to be reported but got %v", err)
+	}
+}
+
+func TestLoadRealLogic(t *testing.T) {
+	logic, err := Load(filepath.Join("..", "..", "..", "inputs", "logic"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(logic.Regions) == 0 || len(logic.Helpers) == 0 {
+		t.Fatal("expected the logic directory to have regions and helpers")
+	}
+
+	for _, region := range logic.Regions {
+		for _, rules := range [][]Rule{region.Events, region.Locations, region.Exits} {
+			for _, rule := range rules {
+				if _, errs := rule.ParseRecovering(); len(errs) > 0 {
+					t.Errorf("%s: %s", rule.Name, errs[0])
+				}
+			}
+		}
+	}
+}
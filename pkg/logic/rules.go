@@ -1,9 +1,15 @@
 package logic
 
 import (
+	"errors"
 	"io"
+	"reflect"
 
 	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/world/components"
+	"sudonters/zootler/pkg/world/filter"
+
+	"github.com/etc-sudonters/substrate/mirrors"
 )
 
 /*
@@ -88,3 +94,74 @@ func (r OrRule) Fulfill(q entity.Queryable) (bool, error) {
 
 	return r.RHS.Fulfill(q)
 }
+
+// the inverse of the embedded rule
+type NotRule struct {
+	R Rule
+}
+
+func (r NotRule) Fulfill(q entity.Queryable) (bool, error) {
+	ok, err := r.R.Fulfill(q)
+	if err != nil {
+		return false, err
+	}
+	return !ok, nil
+}
+
+var collectedType = mirrors.TypeOf[components.Collected]()
+
+// at least Qty tokens with Component have been collected by World
+type HasRule struct {
+	Component reflect.Type
+	Qty       int
+	World     components.WorldId
+	// counts with prepared queries when the rule is fulfilled against the
+	// inventory's entities, otherwise the entities are queried every time
+	Inventory *Inventory
+}
+
+func (r HasRule) Fulfill(q entity.Queryable) (bool, error) {
+	if r.Inventory != nil && r.Inventory.Entities == q {
+		owned, err := r.Inventory.Count(r.Component)
+		if err != nil {
+			return false, err
+		}
+		return r.Qty <= owned, nil
+	}
+
+	owned, err := q.Query(entity.BuildFilter(filter.InWorld(r.World)).
+		With(collectedType).
+		With(r.Component).
+		Build())
+	if err != nil && !errors.Is(err, entity.ErrNoEntities) && !unregistered(err) {
+		return false, err
+	}
+	return r.Qty <= len(owned), nil
+}
+
+// calls into a built in that can't be expressed as a rule
+type CallRule struct {
+	Name string
+	Fn   func(entity.Queryable) (bool, error)
+}
+
+func (r CallRule) Fulfill(q entity.Queryable) (bool, error) {
+	return r.Fn(q)
+}
+
+// a setting or trick that was known when the rule was compiled, kept apart
+// from TrueRule and FalseRule so it's clear why a rule is constant
+type SettingRule struct {
+	Name  string
+	Value bool
+}
+
+func (r SettingRule) Fulfill(entity.Queryable) (bool, error) {
+	return r.Value, nil
+}
+
+// rules are stored on edges and locations wrapped in CompiledRule since
+// components are stored by their concrete type
+type CompiledRule struct {
+	R Rule
+}
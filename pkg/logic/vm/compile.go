@@ -0,0 +1,493 @@
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+
+	"sudonters/zootler/pkg/logic/interpreter"
+	"sudonters/zootler/pkg/rules/ast"
+)
+
+// every rule compiled by the same compiler shares its program
+type Program struct {
+	consts   []value
+	refs     []interpreter.Value
+	fns      []*function
+	builtins []interpreter.BuiltIn
+	errs     []error
+	// handed to builtins when they're called
+	interp interpreter.Interpreter
+}
+
+type function struct {
+	name  string
+	arity int
+	code  []byte
+	// the helper this was compiled from
+	value interpreter.Value
+}
+
+// a compiled rule, safe to share between VMs
+type Rule struct {
+	prog *Program
+	fn   *function
+}
+
+func (r Rule) Name() string {
+	return r.fn.name
+}
+
+// the rule's bytecode, helpers it calls aren't included
+func (r Rule) String() string {
+	return disassemble(r.fn.code)
+}
+
+// compiles rewritten rules, see interpreter.Inliner. Identifiers are resolved
+// while compiling so every rule should be rewritten before any are compiled.
+// Helpers called by rules are compiled once and shared by every rule.
+//
+// Compiling a rule only fails if it's too large to encode, anything the
+// interpreter would return an error for instead fails the rule when it's evaluated. This
+// keeps short circuiting the same, a rule that never reaches a bad call isn't
+// an error
+type Compiler struct {
+	prog    *Program
+	fns     map[string]uint16
+	consts  map[value]uint16
+	refs    map[interpreter.Value]uint32
+	globals interpreter.Environment
+}
+
+func NewCompiler(globals interpreter.Environment) *Compiler {
+	return &Compiler{
+		prog:    &Program{interp: interpreter.New(globals)},
+		fns:     make(map[string]uint16),
+		consts:  make(map[value]uint16),
+		refs:    make(map[interpreter.Value]uint32),
+		globals: globals,
+	}
+}
+
+var ErrTooLarge = errors.New("rule is too large to compile")
+
+// identifiers in rule are resolved from env
+func (c *Compiler) Compile(name string, rule ast.Expression, env interpreter.Environment) (Rule, error) {
+	fn := &function{name: name}
+	if err := c.function(fn, rule, env, nil); err != nil {
+		return Rule{}, fmt.Errorf("compiling %q: %w", name, err)
+	}
+	return Rule{prog: c.prog, fn: fn}, nil
+}
+
+func (c *Compiler) function(fn *function, body ast.Expression, env interpreter.Environment, params []string) error {
+	e := emitter{Compiler: c, env: env}
+	if len(params) > 0 {
+		e.params = make(map[string]uint8, len(params))
+		for i, param := range params {
+			e.params[param] = uint8(i)
+		}
+	}
+
+	e.expr(body)
+	fn.code = e.code
+	return e.err
+}
+
+// helpers are compiled the first time they're referenced. Partially evaluated
+// helpers are named for their body and are never equal to each other
+func (c *Compiler) helper(v interpreter.Value) (uint16, error) {
+	var key string
+	fn := &function{value: v}
+	switch v := v.(type) {
+	case interpreter.Fn:
+		key, fn.name, fn.arity = "fn "+v.Name.Value, v.Name.Value, v.Arity()
+	case interpreter.PartiallyEvaluatedFn:
+		key, fn.name = "partial "+v.Name, v.Name
+	default:
+		return 0, fmt.Errorf("%T is not a helper", v)
+	}
+
+	if idx, ok := c.fns[key]; ok {
+		return idx, nil
+	}
+
+	if len(c.prog.fns) > math.MaxUint16 {
+		return 0, fmt.Errorf("%w: too many helpers", ErrTooLarge)
+	}
+
+	// added before compiling the body so recursive helpers find themselves
+	idx := uint16(len(c.prog.fns))
+	c.fns[key] = idx
+	c.prog.fns = append(c.prog.fns, fn)
+
+	var err error
+	switch v := v.(type) {
+	case interpreter.Fn:
+		if len(v.Params) > math.MaxUint8 {
+			return 0, fmt.Errorf("%w: %s has too many params", ErrTooLarge, fn.name)
+		}
+		// the interpreter calls helpers in the global environment
+		err = c.function(fn, v.Body, c.globals, v.Params)
+	case interpreter.PartiallyEvaluatedFn:
+		err = c.function(fn, v.Body, v.Env, nil)
+	}
+
+	if err != nil {
+		delete(c.fns, key)
+		return 0, fmt.Errorf("compiling %q: %w", fn.name, err)
+	}
+	return idx, nil
+}
+
+func (c *Compiler) constant(v value) (uint16, error) {
+	if idx, ok := c.consts[v]; ok {
+		return idx, nil
+	}
+
+	if len(c.prog.consts) > math.MaxUint16 {
+		return 0, fmt.Errorf("%w: too many constants", ErrTooLarge)
+	}
+
+	idx := uint16(len(c.prog.consts))
+	c.consts[v] = idx
+	c.prog.consts = append(c.prog.consts, v)
+	return idx, nil
+}
+
+// strings and tokens
+func (c *Compiler) ref(v interpreter.Value) (uint16, error) {
+	idx, ok := c.refs[v]
+	if !ok {
+		idx = uint32(len(c.prog.refs))
+		c.refs[v] = idx
+		c.prog.refs = append(c.prog.refs, v)
+	}
+
+	return c.constant(value{kind: refKind, index: idx})
+}
+
+type emitter struct {
+	*Compiler
+	env    interpreter.Environment
+	params map[string]uint8
+	code   []byte
+	err    error
+}
+
+func (e *emitter) emit(op Op, operands ...byte) int {
+	at := len(e.code)
+	e.code = append(e.code, byte(op))
+	e.code = append(e.code, operands...)
+	return at
+}
+
+func (e *emitter) wide(op Op, operand uint16, rest ...byte) {
+	e.emit(op, append(binary.LittleEndian.AppendUint16(nil, operand), rest...)...)
+}
+
+func (e *emitter) check(err error) bool {
+	if err != nil && e.err == nil {
+		e.err = err
+	}
+	return e.err == nil
+}
+
+// the rule fails with err if evaluation reaches this point
+func (e *emitter) fail(err error) {
+	if len(e.prog.errs) > math.MaxUint16 {
+		e.check(fmt.Errorf("%w: too many errors", ErrTooLarge))
+		return
+	}
+
+	e.wide(OpFail, uint16(len(e.prog.errs)))
+	e.prog.errs = append(e.prog.errs, err)
+}
+
+func (e *emitter) expr(node ast.Expression) {
+	if e.err != nil {
+		return
+	}
+
+	switch node := node.(type) {
+	case *ast.Literal:
+		e.push(interpreter.Box(node.Value))
+	case *ast.Identifier:
+		e.identifier(node)
+	case *ast.BoolOp:
+		e.boolOp(node)
+	case *ast.BinOp:
+		e.binOp(node)
+	case *ast.UnaryOp:
+		if node.Op != ast.UnaryNot {
+			e.fail(fmt.Errorf("unknown unary op %q", node.Op))
+			return
+		}
+		e.expr(node.Target)
+		e.emit(OpNot)
+	case *ast.Call:
+		e.call(node)
+	case *ast.Invalid:
+		e.fail(fmt.Errorf("cannot evaluate rule that failed to parse at %s", node.Pos.Start))
+	default:
+		e.fail(fmt.Errorf("cannot compile %T", node))
+	}
+}
+
+func (e *emitter) identifier(ident *ast.Identifier) {
+	if slot, ok := e.params[ident.Value]; ok {
+		e.emit(OpLocal, slot)
+		return
+	}
+
+	v, ok := e.env.Get(ident.Value)
+	if !ok {
+		e.fail(fmt.Errorf("%w: %q", interpreter.UnknownIdentifierErr, ident.Value))
+		return
+	}
+	e.push(v)
+}
+
+func (e *emitter) push(v interpreter.Value) {
+	switch v := v.(type) {
+	case interpreter.Boolean:
+		if v.Value {
+			e.emit(OpTrue)
+		} else {
+			e.emit(OpFalse)
+		}
+	case interpreter.Number:
+		idx, err := e.constant(numValue(v.Value))
+		if e.check(err) {
+			e.wide(OpConst, idx)
+		}
+	case interpreter.String, interpreter.Token:
+		idx, err := e.ref(v)
+		if e.check(err) {
+			e.wide(OpConst, idx)
+		}
+	case interpreter.Fn, interpreter.PartiallyEvaluatedFn:
+		fn, err := e.helper(v)
+		if !e.check(err) {
+			return
+		}
+		idx, err := e.constant(value{kind: fnKind, index: uint32(fn)})
+		if e.check(err) {
+			e.wide(OpConst, idx)
+		}
+	case interpreter.BuiltIn:
+		idx, err := e.constant(value{kind: builtInKind, index: e.builtIn(v)})
+		if e.check(err) {
+			e.wide(OpConst, idx)
+		}
+	default:
+		e.fail(fmt.Errorf("cannot compile value %T", v))
+	}
+}
+
+func (e *emitter) builtIn(b interpreter.BuiltIn) uint32 {
+	for i := range e.prog.builtins {
+		if e.prog.builtins[i].Eq(b) {
+			return uint32(i)
+		}
+	}
+	e.prog.builtins = append(e.prog.builtins, b)
+	return uint32(len(e.prog.builtins) - 1)
+}
+
+// the left value is left on the stack when it decides the op just like the
+// interpreter returns it
+func (e *emitter) boolOp(op *ast.BoolOp) {
+	jump := OpJumpIfFalse
+	if op.Op == ast.BoolOpOr {
+		jump = OpJumpIfTrue
+	}
+
+	e.expr(op.Left)
+	at := e.emit(jump, 0, 0)
+	e.expr(op.Right)
+
+	if len(e.code) > math.MaxUint16 {
+		e.check(fmt.Errorf("%w: jump is too far", ErrTooLarge))
+		return
+	}
+	binary.LittleEndian.PutUint16(e.code[at+1:], uint16(len(e.code)))
+}
+
+var binOps = map[ast.BinOpKind]Op{
+	ast.BinOpEq:    OpEq,
+	ast.BinOpNotEq: OpNotEq,
+	ast.BinOpLt:    OpLt,
+	ast.BinOpLtEq:  OpLtEq,
+	ast.BinOpGt:    OpGt,
+	ast.BinOpGtEq:  OpGtEq,
+	ast.BinOpAdd:   OpAdd,
+	ast.BinOpSub:   OpSub,
+	ast.BinOpMul:   OpMul,
+}
+
+func (e *emitter) binOp(op *ast.BinOp) {
+	if op.Op == ast.BinOpContains || op.Op == ast.BinOpNotContains {
+		e.contains(op)
+		return
+	}
+
+	code, ok := binOps[op.Op]
+	if !ok {
+		e.fail(fmt.Errorf("cannot compile %q", op.Op))
+		return
+	}
+
+	e.expr(op.Left)
+	e.expr(op.Right)
+	e.emit(code)
+}
+
+// rewriting lowers containment in rules but not in the helpers they call.
+// Settings collections don't change while rules are evaluated so membership
+// is decided while compiling
+func (e *emitter) contains(op *ast.BinOp) {
+	key, isKey := e.key(op.Left)
+	collection, isIdent := op.Right.(*ast.Identifier)
+	if !isKey || !isIdent || e.isParam(collection.Value) {
+		e.fail(fmt.Errorf("%q only checks names in settings", op.Op))
+		return
+	}
+
+	v, ok := e.env.Get(collection.Value)
+	if !ok {
+		e.fail(fmt.Errorf("%w: %q", interpreter.UnknownIdentifierErr, collection.Value))
+		return
+	}
+	dict, ok := v.(interpreter.Dict)
+	if !ok {
+		e.fail(fmt.Errorf("%w: %s is not subscriptable", interpreter.TypeErr, v))
+		return
+	}
+
+	truthy, err := e.prog.interp.IsTruthy(dict.Get(key))
+	if err != nil {
+		e.fail(err)
+		return
+	}
+	e.push(interpreter.Boolean{Value: truthy == (op.Op == ast.BinOpContains)})
+}
+
+// mirrors the interpreter, bare names are keys unless they're bound to a
+// string
+func (e *emitter) key(index ast.Expression) (string, bool) {
+	if ident, ok := index.(*ast.Identifier); ok && !e.isParam(ident.Value) {
+		if str, bound := e.known(ident).(interpreter.String); bound {
+			return str.Value, true
+		}
+		return ident.Value, true
+	}
+	str, ok := e.known(index).(interpreter.String)
+	return str.Value, ok
+}
+
+// calls to helpers and builtins known while compiling are made directly,
+// anything else is looked up when the rule is evaluated
+func (e *emitter) call(call *ast.Call) {
+	if len(call.Args) > math.MaxUint8 {
+		e.check(fmt.Errorf("%w: too many arguments", ErrTooLarge))
+		return
+	}
+	argc := uint8(len(call.Args))
+
+	ident, ok := call.Callee.(*ast.Identifier)
+	if !ok || e.isParam(ident.Value) {
+		e.expr(call.Callee)
+		e.args(call.Args)
+		e.emit(OpCall, argc)
+		return
+	}
+
+	callee, ok := e.env.Get(ident.Value)
+	if !ok {
+		e.fail(fmt.Errorf("%w: %q", interpreter.UnknownIdentifierErr, ident.Value))
+		return
+	}
+
+	fn, ok := callee.(interpreter.Callable)
+	if !ok {
+		e.fail(fmt.Errorf("%v is not callable", callee))
+		return
+	}
+
+	if fn.Arity() != len(call.Args) {
+		e.fail(fmt.Errorf("%q: Expected %d arguments but got %d: %s", fn, fn.Arity(), len(call.Args), call.Args))
+		return
+	}
+
+	switch fn := fn.(type) {
+	case interpreter.BuiltIn:
+		if e.has(fn, call.Args) {
+			return
+		}
+		e.args(call.Args)
+		idx := e.builtIn(fn)
+		if idx > math.MaxUint16 {
+			e.check(fmt.Errorf("%w: too many builtins", ErrTooLarge))
+			return
+		}
+		e.wide(OpCallBuiltIn, uint16(idx), argc)
+	default:
+		idx, err := e.helper(fn)
+		if !e.check(err) {
+			return
+		}
+		e.args(call.Args)
+		e.wide(OpCallFn, idx, argc)
+	}
+}
+
+func (e *emitter) isParam(name string) bool {
+	_, ok := e.params[name]
+	return ok
+}
+
+func (e *emitter) args(args []ast.Expression) {
+	for _, arg := range args {
+		e.expr(arg)
+	}
+}
+
+// has(Token, qty) with both known while compiling is asked of the VM's state
+// instead of calling the builtin
+func (e *emitter) has(fn interpreter.BuiltIn, args []ast.Expression) bool {
+	if fn.Name != "has" || len(args) != 2 {
+		return false
+	}
+
+	token, ok := e.known(args[0]).(interpreter.Token)
+	if !ok {
+		return false
+	}
+	qty, ok := e.known(args[1]).(interpreter.Number)
+	if !ok || int(qty.Value) < 0 || int(qty.Value) > math.MaxUint16 {
+		return false
+	}
+
+	idx, err := e.ref(token)
+	if e.check(err) {
+		e.wide(OpHas, idx, binary.LittleEndian.AppendUint16(nil, uint16(int(qty.Value)))...)
+	}
+	return true
+}
+
+func (e *emitter) known(node ast.Expression) interpreter.Value {
+	switch node := node.(type) {
+	case *ast.Literal:
+		return interpreter.Box(node.Value)
+	case *ast.Identifier:
+		if e.isParam(node.Value) {
+			return nil
+		}
+		v, _ := e.env.Get(node.Value)
+		return v
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,118 @@
+package vm
+
+import (
+	"fmt"
+	"strings"
+)
+
+// operands follow their opcode, wide operands are 2 bytes little endian
+type Op uint8
+
+const (
+	_ Op = iota
+	// push true or false
+	OpTrue
+	OpFalse
+	// push a constant, wide index into the program's constants
+	OpConst
+	// push a param of the current function, 1 byte slot
+	OpLocal
+	// push if the state has at least qty of the token, wide constant index
+	// of the token then wide qty
+	OpHas
+	// replace the top of the stack with its negated truthiness
+	OpNot
+	// leave the top of the stack and jump if it decides the bool op,
+	// otherwise pop it, wide absolute offset
+	OpJumpIfTrue
+	OpJumpIfFalse
+	// pop 2 and push the result
+	OpEq
+	OpNotEq
+	OpLt
+	OpLtEq
+	OpGt
+	OpGtEq
+	OpAdd
+	OpSub
+	OpMul
+	// wide index into the program's builtins then 1 byte argc, the args are
+	// on the stack
+	OpCallBuiltIn
+	// wide index into the program's functions then 1 byte argc
+	OpCallFn
+	// 1 byte argc, the callee is on the stack below its args
+	OpCall
+	// stop evaluation, wide index into the program's errors
+	OpFail
+)
+
+var opNames = map[Op]string{
+	OpTrue:        "TRUE",
+	OpFalse:       "FALSE",
+	OpConst:       "CONST",
+	OpLocal:       "LOCAL",
+	OpHas:         "HAS",
+	OpNot:         "NOT",
+	OpJumpIfTrue:  "JUMP_IF_TRUE",
+	OpJumpIfFalse: "JUMP_IF_FALSE",
+	OpEq:          "EQ",
+	OpNotEq:       "NOT_EQ",
+	OpLt:          "LT",
+	OpLtEq:        "LT_EQ",
+	OpGt:          "GT",
+	OpGtEq:        "GT_EQ",
+	OpAdd:         "ADD",
+	OpSub:         "SUB",
+	OpMul:         "MUL",
+	OpCallBuiltIn: "CALL_BUILTIN",
+	OpCallFn:      "CALL_FN",
+	OpCall:        "CALL",
+	OpFail:        "FAIL",
+}
+
+func (o Op) String() string {
+	if name, ok := opNames[o]; ok {
+		return name
+	}
+	return fmt.Sprintf("Op(%d)", uint8(o))
+}
+
+// bytes of operands following the op
+func (o Op) width() int {
+	switch o {
+	case OpLocal, OpCall:
+		return 1
+	case OpConst, OpJumpIfTrue, OpJumpIfFalse, OpFail:
+		return 2
+	case OpCallBuiltIn, OpCallFn:
+		return 3
+	case OpHas:
+		return 4
+	default:
+		return 0
+	}
+}
+
+// one instruction per line, its offset then the op and its operands
+func disassemble(code []byte) string {
+	var b strings.Builder
+	for pc := 0; pc < len(code); {
+		op := Op(code[pc])
+		operands := code[pc+1 : min(pc+1+op.width(), len(code))]
+		fmt.Fprintf(&b, "%04d %s", pc, op)
+		switch op.width() {
+		case 1:
+			fmt.Fprintf(&b, " %d", operands[0])
+		case 2:
+			fmt.Fprintf(&b, " %d", wide(operands))
+		case 3:
+			fmt.Fprintf(&b, " %d %d", wide(operands), operands[2])
+		case 4:
+			fmt.Fprintf(&b, " %d %d", wide(operands), wide(operands[2:]))
+		}
+		b.WriteByte('\n')
+		pc += 1 + op.width()
+	}
+	return b.String()
+}
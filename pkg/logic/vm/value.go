@@ -0,0 +1,89 @@
+package vm
+
+import (
+	"fmt"
+
+	"sudonters/zootler/pkg/logic/interpreter"
+)
+
+type kind uint8
+
+const (
+	_ kind = iota
+	boolKind
+	numKind
+	// strings and tokens, index into the program's refs
+	refKind
+	// index into the program's functions
+	fnKind
+	// index into the program's builtins
+	builtInKind
+)
+
+// bools and numbers are stored inline, everything else is an index into one
+// of the program's tables
+type value struct {
+	kind  kind
+	num   float64
+	index uint32
+}
+
+func boolValue(b bool) value {
+	if b {
+		return value{kind: boolKind, num: 1}
+	}
+	return value{kind: boolKind}
+}
+
+func numValue(n float64) value {
+	return value{kind: numKind, num: n}
+}
+
+// mirrors interpreter.Value.Eq
+func (p *Program) eq(a, b value) bool {
+	if a.kind != b.kind {
+		return false
+	}
+
+	switch a.kind {
+	case boolKind, numKind:
+		return a.num == b.num
+	case refKind:
+		return p.refs[a.index].Eq(p.refs[b.index])
+	case fnKind:
+		return p.fns[a.index].value.Eq(p.fns[b.index].value)
+	case builtInKind:
+		return p.builtins[a.index].Eq(p.builtins[b.index])
+	default:
+		return false
+	}
+}
+
+func (p *Program) box(v value) interpreter.Value {
+	switch v.kind {
+	case boolKind:
+		return interpreter.Boolean{Value: v.num != 0}
+	case numKind:
+		return interpreter.Number{Value: v.num}
+	case refKind:
+		return p.refs[v.index]
+	case fnKind:
+		return p.fns[v.index].value
+	case builtInKind:
+		return p.builtins[v.index]
+	default:
+		panic(fmt.Errorf("unknown value kind %d", v.kind))
+	}
+}
+
+// builtins only produce bools and numbers
+func unbox(v interpreter.Value) (value, error) {
+	switch v := v.(type) {
+	case interpreter.Boolean:
+		return boolValue(v.Value), nil
+	case interpreter.Number:
+		return numValue(v.Value), nil
+	default:
+		return value{}, fmt.Errorf("builtins must return a bool or number not %T", v)
+	}
+}
@@ -0,0 +1,258 @@
+package vm
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"sudonters/zootler/pkg/logic/interpreter"
+)
+
+// the world a rule is evaluated against
+type State interface {
+	// if at least qty of the token have been collected
+	Has(token interpreter.Token, qty int) (bool, error)
+}
+
+var _ State = (*interpreter.Zoot_HasQuantityOf)(nil)
+
+// helpers calling helpers shouldn't go this deep
+const maxDepth = 256
+
+var ErrStackExhausted = errors.New("rule call depth exhausted")
+
+// evaluates compiled rules, a VM's stack is reused between rules so it
+// shouldn't be shared between goroutines
+type VM struct {
+	State State
+	stack []value
+	depth int
+}
+
+func New(state State) *VM {
+	return &VM{State: state, stack: make([]value, 0, 64)}
+}
+
+// if the rule is fulfilled, builtins that return an error fail the rule
+func (vm *VM) Run(rule Rule) (bool, error) {
+	vm.stack = vm.stack[:0]
+	vm.depth = 0
+
+	v, err := vm.exec(rule.prog, rule.fn, 0)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", rule.Name(), err)
+	}
+
+	ok, err := vm.truthy(rule.prog, v)
+	if err != nil {
+		return false, fmt.Errorf("%s: %w", rule.Name(), err)
+	}
+	return ok, nil
+}
+
+func (vm *VM) push(v value) {
+	vm.stack = append(vm.stack, v)
+}
+
+func (vm *VM) pop() value {
+	v := vm.stack[len(vm.stack)-1]
+	vm.stack = vm.stack[:len(vm.stack)-1]
+	return v
+}
+
+func (vm *VM) peek() value {
+	return vm.stack[len(vm.stack)-1]
+}
+
+// fn's params start at base, the stack is left as it was found
+func (vm *VM) exec(p *Program, fn *function, base int) (value, error) {
+	vm.depth++
+	defer func() { vm.depth-- }()
+	if vm.depth > maxDepth {
+		return value{}, ErrStackExhausted
+	}
+
+	height := len(vm.stack)
+	code := fn.code
+	for pc := 0; pc < len(code); {
+		op := Op(code[pc])
+		operands := code[pc+1 : pc+1+op.width()]
+		pc += 1 + op.width()
+
+		switch op {
+		case OpTrue:
+			vm.push(boolValue(true))
+		case OpFalse:
+			vm.push(boolValue(false))
+		case OpConst:
+			vm.push(p.consts[wide(operands)])
+		case OpLocal:
+			vm.push(vm.stack[base+int(operands[0])])
+		case OpHas:
+			token := p.refs[p.consts[wide(operands)].index].(interpreter.Token)
+			has, err := vm.State.Has(token, int(wide(operands[2:])))
+			if err != nil {
+				return value{}, fmt.Errorf("has %s: %w", token, err)
+			}
+			vm.push(boolValue(has))
+		case OpNot:
+			truthy, err := vm.truthy(p, vm.pop())
+			if err != nil {
+				return value{}, err
+			}
+			vm.push(boolValue(!truthy))
+		case OpJumpIfTrue, OpJumpIfFalse:
+			truthy, err := vm.truthy(p, vm.peek())
+			if err != nil {
+				return value{}, err
+			}
+			if truthy == (op == OpJumpIfTrue) {
+				pc = int(wide(operands))
+			} else {
+				vm.pop()
+			}
+		case OpEq:
+			r, l := vm.pop(), vm.pop()
+			vm.push(boolValue(p.eq(l, r)))
+		case OpNotEq:
+			r, l := vm.pop(), vm.pop()
+			vm.push(boolValue(!p.eq(l, r)))
+		case OpLt, OpLtEq, OpGt, OpGtEq, OpAdd, OpSub, OpMul:
+			r, l := vm.pop(), vm.pop()
+			if l.kind != numKind || r.kind != numKind {
+				return value{}, fmt.Errorf("%s only between numbers not %T and %T", op, p.box(l), p.box(r))
+			}
+			vm.push(arith(op, l.num, r.num))
+		case OpCallBuiltIn:
+			argc := int(operands[2])
+			v, err := vm.callBuiltIn(p, p.builtins[wide(operands)], argc)
+			if err != nil {
+				return value{}, err
+			}
+			vm.push(v)
+		case OpCallFn:
+			argc := int(operands[2])
+			v, err := vm.callFn(p, p.fns[wide(operands)], argc)
+			if err != nil {
+				return value{}, err
+			}
+			vm.push(v)
+		case OpCall:
+			argc := int(operands[0])
+			v, err := vm.call(p, argc)
+			if err != nil {
+				return value{}, err
+			}
+			vm.push(v)
+		case OpFail:
+			return value{}, p.errs[wide(operands)]
+		default:
+			return value{}, fmt.Errorf("unknown op %s at %d in %s", op, pc, fn.name)
+		}
+	}
+
+	if len(vm.stack) != height+1 {
+		return value{}, fmt.Errorf("%s left %d values on the stack", fn.name, len(vm.stack)-height)
+	}
+	return vm.pop(), nil
+}
+
+func wide(operands []byte) uint16 {
+	return binary.LittleEndian.Uint16(operands)
+}
+
+func arith(op Op, l, r float64) value {
+	switch op {
+	case OpLt:
+		return boolValue(l < r)
+	case OpLtEq:
+		return boolValue(l <= r)
+	case OpGt:
+		return boolValue(l > r)
+	case OpGtEq:
+		return boolValue(l >= r)
+	case OpAdd:
+		return numValue(l + r)
+	case OpSub:
+		return numValue(l - r)
+	default:
+		return numValue(l * r)
+	}
+}
+
+// the args are popped
+func (vm *VM) callFn(p *Program, fn *function, argc int) (value, error) {
+	base := len(vm.stack) - argc
+	v, err := vm.exec(p, fn, base)
+	vm.stack = vm.stack[:base]
+	return v, err
+}
+
+// the args are popped
+func (vm *VM) callBuiltIn(p *Program, b interpreter.BuiltIn, argc int) (value, error) {
+	base := len(vm.stack) - argc
+	args := make([]interpreter.Value, argc)
+	for i, arg := range vm.stack[base:] {
+		args[i] = p.box(arg)
+	}
+	vm.stack = vm.stack[:base]
+	v, err := b.Call(p.interp, args)
+	if err != nil {
+		return value{}, err
+	}
+	return unbox(v)
+}
+
+// the callee and its args are popped
+func (vm *VM) call(p *Program, argc int) (value, error) {
+	callee := vm.stack[len(vm.stack)-argc-1]
+
+	var arity int
+	switch callee.kind {
+	case fnKind:
+		arity = p.fns[callee.index].arity
+	case builtInKind:
+		arity = p.builtins[callee.index].Arity()
+	default:
+		return value{}, fmt.Errorf("%v is not callable", p.box(callee))
+	}
+
+	if arity != argc {
+		return value{}, fmt.Errorf("%q: Expected %d arguments but got %d", p.box(callee), arity, argc)
+	}
+
+	var v value
+	var err error
+	if callee.kind == fnKind {
+		v, err = vm.callFn(p, p.fns[callee.index], argc)
+	} else {
+		v, err = vm.callBuiltIn(p, p.builtins[callee.index], argc)
+	}
+	vm.pop()
+	return v, err
+}
+
+// mirrors Interpreter.IsTruthy, helpers and builtins without params are
+// called
+func (vm *VM) truthy(p *Program, v value) (bool, error) {
+	switch v.kind {
+	case boolKind, numKind:
+		return v.num != 0, nil
+	case refKind:
+		switch ref := p.refs[v.index].(type) {
+		case interpreter.String:
+			return ref.Value != "", nil
+		case interpreter.Token:
+			return ref.Literal != "" && ref.Component != nil, nil
+		}
+	case fnKind, builtInKind:
+		vm.push(v)
+		result, err := vm.call(p, 0)
+		if err != nil {
+			return false, err
+		}
+		return vm.truthy(p, result)
+	}
+
+	return false, errors.New("unknown truthiness kind")
+}
@@ -0,0 +1,314 @@
+package vm
+
+import (
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/internal/entity/bitpool"
+	"sudonters/zootler/pkg/logic/compiler"
+	"sudonters/zootler/pkg/logic/interpreter"
+	"sudonters/zootler/pkg/logic/loader"
+	"sudonters/zootler/pkg/rules/ast"
+	"sudonters/zootler/pkg/rules/parser"
+	"sudonters/zootler/pkg/world"
+	"sudonters/zootler/pkg/world/components"
+)
+
+type collected map[string]int
+
+func (c collected) Has(token interpreter.Token, qty int) (bool, error) {
+	return c[token.Literal] >= qty, nil
+}
+
+var errUncountable = errors.New("cannot count")
+
+type uncountable struct{}
+
+func (uncountable) Has(interpreter.Token, int) (bool, error) {
+	return false, errUncountable
+}
+
+type bow struct{}
+
+// has is only called when the qty isn't known while compiling
+func testEnv(state collected) interpreter.Environment {
+	env := interpreter.NewEnv()
+	env.Set("Bow", interpreter.Token{Component: reflect.TypeOf(bow{}), Literal: "Bow"})
+	env.SetBuiltIn("has", 2, interpreter.BuiltInFn(func(_ interpreter.Interpreter, args []interpreter.Value) (interpreter.Value, error) {
+		has, err := state.Has(args[0].(interpreter.Token), int(args[1].(interpreter.Number).Value))
+		return interpreter.Box(has), err
+	}))
+	env.SetBuiltIn("double", 1, interpreter.BuiltInFn(func(_ interpreter.Interpreter, args []interpreter.Value) (interpreter.Value, error) {
+		return interpreter.Box(args[0].(interpreter.Number).Value * 2), nil
+	}))
+	env.SetString("age", "child")
+
+	for decl, body := range map[string]string{
+		"is_child":          "age == 'child'",
+		"can_use(item)":     "is_child and item",
+		"has_bows(qty)":     "has(Bow, qty)",
+		"arrows(bundles)":   "double(bundles) * 10",
+		"enough_arrows(qt)": "arrows(qt) >= 30",
+	} {
+		d, err := parser.Parse(decl)
+		if err != nil {
+			panic(err)
+		}
+		b, err := parser.Parse(body)
+		if err != nil {
+			panic(err)
+		}
+		interpreter.FunctionDecl(d, b, env)
+	}
+	return env
+}
+
+func compile(t *testing.T, env interpreter.Environment, rule string) Rule {
+	t.Helper()
+	expr, err := parser.Parse(rule)
+	if err != nil {
+		t.Fatal(err)
+	}
+	compiled, err := NewCompiler(env).Compile(rule, expr, env)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return compiled
+}
+
+func TestRulesAreEvaluatedAgainstTheState(t *testing.T) {
+	state := collected{"Bow": 2}
+	env := testEnv(state)
+	vm := New(state)
+
+	for rule, expected := range map[string]bool{
+		"True":                            true,
+		"not Bow":                         false,
+		"has(Bow, 2) and not has(Bow, 3)": true,
+		"has_bows(3) or has_bows(2)":      true,
+		"can_use(Bow)":                    true,
+		"is_child and age != 'adult'":     true,
+		"enough_arrows(2)":                true,
+		"enough_arrows(1)":                false,
+		"1 + 2 < 4 and 3 - 1 <= 2":        true,
+	} {
+		ok, err := vm.Run(compile(t, env, rule))
+		if err != nil {
+			t.Fatalf("%s: %s", rule, err)
+		}
+		if ok != expected {
+			t.Errorf("expected %q to be %t", rule, expected)
+		}
+	}
+}
+
+func TestHasIsCompiledToAnOp(t *testing.T) {
+	code := compile(t, testEnv(collected{}), "has(Bow, 2) or False").String()
+	expected := "0000 HAS 0 2\n0005 JUMP_IF_TRUE 9\n0008 FALSE\n"
+	if code != expected {
+		t.Fatalf("expected\n%s\nbut compiled\n%s", expected, code)
+	}
+}
+
+func TestStateErrorsFailTheRule(t *testing.T) {
+	_, err := New(uncountable{}).Run(compile(t, testEnv(collected{}), "has(Bow, 2)"))
+	if !errors.Is(err, errUncountable) {
+		t.Fatalf("expected %s but got %v", errUncountable, err)
+	}
+}
+
+func TestFailuresAreOnlyReachedIfEvaluated(t *testing.T) {
+	env := testEnv(collected{})
+	vm := New(collected{})
+
+	for _, rule := range []string{"True or Hookshot", "False and is_child(Bow)", "is_child or age()"} {
+		if _, err := vm.Run(compile(t, env, rule)); err != nil {
+			t.Errorf("expected %q to short circuit: %s", rule, err)
+		}
+	}
+
+	_, err := vm.Run(compile(t, env, "False or Hookshot"))
+	if !errors.Is(err, interpreter.UnknownIdentifierErr) {
+		t.Errorf("expected %s but got %v", interpreter.UnknownIdentifierErr, err)
+	}
+
+	for _, rule := range []string{"is_child(Bow)", "age()", "Bow < 1", "can_use(has_bows)"} {
+		if _, err := vm.Run(compile(t, env, rule)); err == nil {
+			t.Errorf("expected %q to fail", rule)
+		}
+	}
+}
+
+// every rule is rewritten the same way the repl does, then evaluated by both
+// the interpreter and the VM as more and more is collected: has quantities,
+// medallions and bottles each change what's fulfilled between states
+func TestMatchesInterpreter(t *testing.T) {
+	l, err := loader.Load("../../../inputs/logic")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b, err := world.LimitedBuilder(bitpool.Settings{MaxComponentId: 400, MaxEntityId: 30000})
+	if err != nil {
+		t.Fatal(err)
+	}
+	env, rw, err := compiler.NewEnvironment(b, l.Helpers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer compiler.Release(env)
+	v, _ := env.Get("has")
+	has := v.(interpreter.BuiltIn).F.(*interpreter.Zoot_HasQuantityOf)
+	// notes aren't shuffled by default so every song can be played
+	env.SetBuiltIn("has_all_notes_for_song", 1, interpreter.BuiltInFn(func(interpreter.Interpreter, []interpreter.Value) (interpreter.Value, error) {
+		return interpreter.Box(true), nil
+	}))
+	// helpers under not aren't inlined, their settings collections are read
+	// while evaluating
+	env.Set("dungeon_shortcuts", interpreter.Dict{Name: "dungeon_shortcuts"})
+	env.Set("skipped_trials", interpreter.Dict{Name: "skipped_trials"})
+
+	var names []string
+	var rewritten []ast.Expression
+	for _, region := range l.Regions {
+		rw.RegionName = region.Name
+		for _, rules := range [][]loader.Rule{region.Events, region.Locations, region.Exits} {
+			for _, rule := range rules {
+				expr, err := rule.Parse()
+				if err != nil {
+					t.Fatal(err)
+				}
+				if expr, err = rw.RewriteRule(expr, env); err != nil {
+					t.Fatalf("%s: %s", rule.Name, err)
+				}
+				names = append(names, fmt.Sprintf("%s: %s", region.Name, rule.Name))
+				rewritten = append(rewritten, expr)
+			}
+		}
+	}
+
+	// checked directly in each state below
+	probes := []string{"has_medallions(3)", "has_bottle", "has(Gold_Skulltula_Token, 2)", "has(Gold_Skulltula_Token, 50)"}
+	for _, probe := range probes {
+		expr, err := parser.Parse(probe)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if expr, err = rw.Rewrite(expr, env); err != nil {
+			t.Fatal(err)
+		}
+		names = append(names, probe)
+		rewritten = append(rewritten, expr)
+	}
+
+	c := NewCompiler(env)
+	compiled := make([]Rule, len(rewritten))
+	for i, expr := range rewritten {
+		if compiled[i], err = c.Compile(names[i], expr, env); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	I := interpreter.New(env)
+	vm := New(has)
+	evaluate := func(name string, expr ast.Expression) (bool, error) {
+		v, err := I.ForRule(name).Evaluate(expr, env)
+		if err != nil {
+			return false, err
+		}
+		return I.IsTruthy(v)
+	}
+
+	var tokens []reflect.Type
+	for _, ref := range c.prog.refs {
+		if token, ok := ref.(interpreter.Token); ok {
+			tokens = append(tokens, token.Component)
+		}
+	}
+
+	// each state adds to the last, more is collected so more is fulfilled
+	collect := func(t *testing.T, copies, medallions, bottles int) {
+		t.Helper()
+		add := func(comp entity.Component, n int) {
+			t.Helper()
+			for i := 0; i < n; i++ {
+				ent, err := b.Pool.Create(components.Name(fmt.Sprintf("%s %d", reflect.TypeOf(comp).Name(), i)))
+				if err != nil {
+					t.Fatal(err)
+				}
+				if err := ent.Add([]entity.Component{comp, components.Collected{}, b.World}); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+		for _, token := range tokens {
+			add(reflect.New(token).Elem().Interface(), copies)
+		}
+		add(components.Medallion{}, medallions)
+		add(components.Bottle{}, bottles)
+	}
+
+	var last int
+	compare := func(probed ...bool) func(*testing.T) {
+		return func(t *testing.T) {
+			var fulfilled int
+			results := make(map[string]bool, len(rewritten))
+			for i, expr := range rewritten {
+				expected, err := evaluate(names[i], expr)
+				if err != nil {
+					t.Errorf("%s: interpreter failed: %s", names[i], err)
+					continue
+				}
+				ok, err := vm.Run(compiled[i])
+				if err != nil {
+					t.Errorf("%s: vm failed: %s", names[i], err)
+					continue
+				}
+				if ok != expected {
+					t.Errorf("%s: interpreter found %t but vm found %t", names[i], expected, ok)
+				}
+				if ok {
+					fulfilled++
+				}
+				results[names[i]] = ok
+			}
+
+			for i, probe := range probes {
+				if results[probe] != probed[i] {
+					t.Errorf("expected %s to be %t", probe, probed[i])
+				}
+			}
+			t.Logf("%d of %d rules fulfilled", fulfilled, len(rewritten))
+			if fulfilled <= last && last != 0 {
+				t.Errorf("expected collecting more to fulfill more than %d rules", last)
+			}
+			last = fulfilled
+		}
+	}
+
+	t.Run("NothingCollected", compare(false, false, false, false))
+	for _, state := range []struct {
+		name                        string
+		copies, medallions, bottles int
+		probed                      []bool
+	}{
+		{"OneOfEach", 1, 2, 0, []bool{false, false, false, false}},
+		{"TwoOfEachAndABottle", 1, 1, 1, []bool{true, true, true, false}},
+		{"AllMedallionsAndPlenty", 98, 3, 3, []bool{true, true, true, true}},
+	} {
+		collect(t, state.copies, state.medallions, state.bottles)
+		t.Run(state.name, compare(state.probed...))
+	}
+}
+
+func TestOpsHaveNames(t *testing.T) {
+	for op := OpTrue; op <= OpFail; op++ {
+		if strings.HasPrefix(op.String(), "Op(") {
+			t.Errorf("%d has no name", op)
+		}
+	}
+}
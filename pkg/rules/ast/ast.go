@@ -5,22 +5,41 @@ type BoolOpKind string
 type UnaryOpKind string
 
 var (
-	BinOpEq       BinOpKind   = "=="
-	BinOpNotEq    BinOpKind   = "!="
-	BinOpLt       BinOpKind   = "<"
-	BinOpContains BinOpKind   = "in"
-	BoolOpAnd     BoolOpKind  = "and"
-	BoolOpOr      BoolOpKind  = "or"
-	UnaryNot      UnaryOpKind = "not"
+	BinOpEq          BinOpKind   = "=="
+	BinOpNotEq       BinOpKind   = "!="
+	BinOpLt          BinOpKind   = "<"
+	BinOpLtEq        BinOpKind   = "<="
+	BinOpGt          BinOpKind   = ">"
+	BinOpGtEq        BinOpKind   = ">="
+	BinOpContains    BinOpKind   = "in"
+	BinOpNotContains BinOpKind   = "not in"
+	BinOpAdd         BinOpKind   = "+"
+	BinOpSub         BinOpKind   = "-"
+	BinOpMul         BinOpKind   = "*"
+	BoolOpAnd        BoolOpKind  = "and"
+	BoolOpOr         BoolOpKind  = "or"
+	UnaryNot         UnaryOpKind = "not"
 )
 
+// comparisons produce booleans, the remaining binops are arithmetic
+func (k BinOpKind) IsComparison() bool {
+	switch k {
+	case BinOpEq, BinOpNotEq, BinOpLt, BinOpLtEq, BinOpGt, BinOpGtEq, BinOpContains, BinOpNotContains:
+		return true
+	default:
+		return false
+	}
+}
+
 type ExprType string
 
 const (
+	ExprAttribute  = "Attribute"
 	ExprBinOp      = "BinOp"
 	ExprBoolOp     = "BoolOp"
 	ExprCall       = "Call"
 	ExprIdentifier = "Identifier"
+	ExprInvalid    = "Invalid"
 	ExprSubscript  = "Subscript"
 	ExprTuple      = "Tuple"
 	ExprUnaryOp    = "UnaryOp"
@@ -38,66 +57,160 @@ const (
 type (
 	Expression interface {
 		Type() ExprType
+		// where the node was parsed from, zero for synthesized nodes
+		Span() Span
 		exprNode()
 	}
 )
 
 type (
+	// target.Attr
+	Attribute struct {
+		Target Expression
+		Attr   string
+		Pos    Span
+	}
+
 	BoolOp struct {
 		Left  Expression
 		Op    BoolOpKind
 		Right Expression
+		Pos   Span
 	}
 
 	Literal struct {
 		Kind  LiteralKind
 		Value any
+		Pos   Span
 	}
 
 	Identifier struct {
 		Value string
+		Pos   Span
+	}
+
+	// stands in for source that couldn't be parsed
+	Invalid struct {
+		Pos Span
 	}
 
 	BinOp struct {
 		Left  Expression
 		Op    BinOpKind
 		Right Expression
+		Pos   Span
 	}
 
 	Call struct {
 		Callee Expression
 		Args   []Expression
+		Pos    Span
 	}
 
 	Subscript struct {
 		Target Expression
 		Index  Expression
+		Pos    Span
 	}
 
 	Tuple struct {
 		Elems []Expression
+		Pos   Span
 	}
 
 	UnaryOp struct {
 		Op     UnaryOpKind
 		Target Expression
+		Pos    Span
 	}
 )
 
+func (a *Attribute) exprNode()  {}
 func (b *BinOp) exprNode()      {}
 func (b *BoolOp) exprNode()     {}
 func (c *Call) exprNode()       {}
 func (i *Identifier) exprNode() {}
+func (i *Invalid) exprNode()    {}
 func (s *Subscript) exprNode()  {}
 func (t *Tuple) exprNode()      {}
 func (u *UnaryOp) exprNode()    {}
 func (l *Literal) exprNode()    {}
 
+func (expr *Attribute) Type() ExprType  { return ExprAttribute }
 func (expr *BinOp) Type() ExprType      { return ExprBinOp }
 func (expr *BoolOp) Type() ExprType     { return ExprBoolOp }
 func (expr *Call) Type() ExprType       { return ExprCall }
 func (expr *Identifier) Type() ExprType { return ExprIdentifier }
+func (expr *Invalid) Type() ExprType    { return ExprInvalid }
 func (expr *Subscript) Type() ExprType  { return ExprSubscript }
 func (expr *Tuple) Type() ExprType      { return ExprTuple }
 func (expr *UnaryOp) Type() ExprType    { return ExprUnaryOp }
 func (expr *Literal) Type() ExprType    { return ExprLiteral }
+
+func (expr *Attribute) Span() Span  { return expr.Pos }
+func (expr *BinOp) Span() Span      { return expr.Pos }
+func (expr *BoolOp) Span() Span     { return expr.Pos }
+func (expr *Call) Span() Span       { return expr.Pos }
+func (expr *Identifier) Span() Span { return expr.Pos }
+func (expr *Invalid) Span() Span    { return expr.Pos }
+func (expr *Subscript) Span() Span  { return expr.Pos }
+func (expr *Tuple) Span() Span      { return expr.Pos }
+func (expr *UnaryOp) Span() Span    { return expr.Pos }
+func (expr *Literal) Span() Span    { return expr.Pos }
+
+// a deep copy of the tree, rewriting one copy leaves the other untouched
+func Clone(expr Expression) Expression {
+	switch expr := expr.(type) {
+	case *Attribute:
+		c := *expr
+		c.Target = Clone(expr.Target)
+		return &c
+	case *BinOp:
+		c := *expr
+		c.Left, c.Right = Clone(expr.Left), Clone(expr.Right)
+		return &c
+	case *BoolOp:
+		c := *expr
+		c.Left, c.Right = Clone(expr.Left), Clone(expr.Right)
+		return &c
+	case *Call:
+		c := *expr
+		c.Callee = Clone(expr.Callee)
+		c.Args = cloneAll(expr.Args)
+		return &c
+	case *Identifier:
+		c := *expr
+		return &c
+	case *Invalid:
+		c := *expr
+		return &c
+	case *Literal:
+		c := *expr
+		return &c
+	case *Subscript:
+		c := *expr
+		c.Target, c.Index = Clone(expr.Target), Clone(expr.Index)
+		return &c
+	case *Tuple:
+		c := *expr
+		c.Elems = cloneAll(expr.Elems)
+		return &c
+	case *UnaryOp:
+		c := *expr
+		c.Target = Clone(expr.Target)
+		return &c
+	default:
+		return expr
+	}
+}
+
+func cloneAll(exprs []Expression) []Expression {
+	if exprs == nil {
+		return nil
+	}
+	clones := make([]Expression, len(exprs))
+	for i := range exprs {
+		clones[i] = Clone(exprs[i])
+	}
+	return clones
+}
@@ -0,0 +1,200 @@
+package ast
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// the wire shape of every node, only the fields relevant to Type are present
+type jsonNode struct {
+	Type   ExprType        `json:"type"`
+	Attr   string          `json:"attr,omitempty"`
+	Op     string          `json:"op,omitempty"`
+	Kind   LiteralKind     `json:"kind,omitempty"`
+	Value  json.RawMessage `json:"value,omitempty"`
+	Left   *jsonNode       `json:"left,omitempty"`
+	Right  *jsonNode       `json:"right,omitempty"`
+	Callee *jsonNode       `json:"callee,omitempty"`
+	Args   []*jsonNode     `json:"args,omitempty"`
+	Target *jsonNode       `json:"target,omitempty"`
+	Index  *jsonNode       `json:"index,omitempty"`
+	Elems  []*jsonNode     `json:"elems,omitempty"`
+}
+
+func MarshalExpression(expr Expression) ([]byte, error) {
+	node, err := toJson(expr)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(node)
+}
+
+func UnmarshalExpression(data []byte) (Expression, error) {
+	var node *jsonNode
+	if err := json.Unmarshal(data, &node); err != nil {
+		return nil, err
+	}
+	return fromJson(node)
+}
+
+func toJson(expr Expression) (*jsonNode, error) {
+	if expr == nil {
+		return nil, nil
+	}
+
+	var err error
+	node := &jsonNode{Type: expr.Type()}
+
+	switch expr := expr.(type) {
+	case *Attribute:
+		node.Attr = expr.Attr
+		node.Target, err = toJson(expr.Target)
+	case *BinOp:
+		node.Op = string(expr.Op)
+		if node.Left, err = toJson(expr.Left); err != nil {
+			return nil, err
+		}
+		node.Right, err = toJson(expr.Right)
+	case *BoolOp:
+		node.Op = string(expr.Op)
+		if node.Left, err = toJson(expr.Left); err != nil {
+			return nil, err
+		}
+		node.Right, err = toJson(expr.Right)
+	case *Call:
+		if node.Callee, err = toJson(expr.Callee); err != nil {
+			return nil, err
+		}
+		node.Args, err = toJsonList(expr.Args)
+	case *Identifier:
+		node.Value, err = json.Marshal(expr.Value)
+	case *Invalid:
+	case *Literal:
+		node.Kind = expr.Kind
+		node.Value, err = json.Marshal(expr.Value)
+	case *Subscript:
+		if node.Target, err = toJson(expr.Target); err != nil {
+			return nil, err
+		}
+		node.Index, err = toJson(expr.Index)
+	case *Tuple:
+		node.Elems, err = toJsonList(expr.Elems)
+	case *UnaryOp:
+		node.Op = string(expr.Op)
+		node.Target, err = toJson(expr.Target)
+	default:
+		return nil, fmt.Errorf("cannot encode unknown node type %T", expr)
+	}
+
+	if err != nil {
+		return nil, err
+	}
+	return node, nil
+}
+
+func toJsonList(exprs []Expression) ([]*jsonNode, error) {
+	nodes := make([]*jsonNode, len(exprs))
+	for i := range exprs {
+		node, err := toJson(exprs[i])
+		if err != nil {
+			return nil, err
+		}
+		nodes[i] = node
+	}
+	return nodes, nil
+}
+
+func fromJson(node *jsonNode) (Expression, error) {
+	if node == nil {
+		return nil, nil
+	}
+
+	switch node.Type {
+	case ExprAttribute:
+		target, err := fromJson(node.Target)
+		if err != nil {
+			return nil, err
+		}
+		return &Attribute{Target: target, Attr: node.Attr}, nil
+	case ExprBinOp:
+		left, right, err := fromJsonPair(node.Left, node.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &BinOp{Left: left, Op: BinOpKind(node.Op), Right: right}, nil
+	case ExprBoolOp:
+		left, right, err := fromJsonPair(node.Left, node.Right)
+		if err != nil {
+			return nil, err
+		}
+		return &BoolOp{Left: left, Op: BoolOpKind(node.Op), Right: right}, nil
+	case ExprCall:
+		callee, err := fromJson(node.Callee)
+		if err != nil {
+			return nil, err
+		}
+		args, err := fromJsonList(node.Args)
+		if err != nil {
+			return nil, err
+		}
+		return &Call{Callee: callee, Args: args}, nil
+	case ExprIdentifier:
+		var name string
+		if err := json.Unmarshal(node.Value, &name); err != nil {
+			return nil, fmt.Errorf("identifier: %w", err)
+		}
+		return &Identifier{Value: name}, nil
+	case ExprInvalid:
+		return &Invalid{}, nil
+	case ExprLiteral:
+		var value any
+		if err := json.Unmarshal(node.Value, &value); err != nil {
+			return nil, fmt.Errorf("literal: %w", err)
+		}
+		return &Literal{Kind: node.Kind, Value: value}, nil
+	case ExprSubscript:
+		target, index, err := fromJsonPair(node.Target, node.Index)
+		if err != nil {
+			return nil, err
+		}
+		return &Subscript{Target: target, Index: index}, nil
+	case ExprTuple:
+		elems, err := fromJsonList(node.Elems)
+		if err != nil {
+			return nil, err
+		}
+		return &Tuple{Elems: elems}, nil
+	case ExprUnaryOp:
+		target, err := fromJson(node.Target)
+		if err != nil {
+			return nil, err
+		}
+		return &UnaryOp{Op: UnaryOpKind(node.Op), Target: target}, nil
+	default:
+		return nil, fmt.Errorf("cannot decode unknown node type %q", node.Type)
+	}
+}
+
+func fromJsonPair(a, b *jsonNode) (Expression, Expression, error) {
+	left, err := fromJson(a)
+	if err != nil {
+		return nil, nil, err
+	}
+	right, err := fromJson(b)
+	if err != nil {
+		return nil, nil, err
+	}
+	return left, right, nil
+}
+
+func fromJsonList(nodes []*jsonNode) ([]Expression, error) {
+	exprs := make([]Expression, len(nodes))
+	for i := range nodes {
+		expr, err := fromJson(nodes[i])
+		if err != nil {
+			return nil, err
+		}
+		exprs[i] = expr
+	}
+	return exprs, nil
+}
@@ -6,10 +6,12 @@ import (
 )
 
 type Visitor interface {
+	VisitAttribute(*Attribute) error
 	VisitBinOp(*BinOp) error
 	VisitBoolOp(*BoolOp) error
 	VisitCall(*Call) error
 	VisitIdentifier(*Identifier) error
+	VisitInvalid(*Invalid) error
 	VisitSubscript(*Subscript) error
 	VisitTuple(*Tuple) error
 	VisitUnary(*UnaryOp) error
@@ -18,6 +20,8 @@ type Visitor interface {
 
 func Visit(v Visitor, node Expression) error {
 	switch node := node.(type) {
+	case *Attribute:
+		return v.VisitAttribute(node)
 	case *BinOp:
 		return v.VisitBinOp(node)
 	case *BoolOp:
@@ -26,6 +30,8 @@ func Visit(v Visitor, node Expression) error {
 		return v.VisitCall(node)
 	case *Identifier:
 		return v.VisitIdentifier(node)
+	case *Invalid:
+		return v.VisitInvalid(node)
 	case *Literal:
 		return v.VisitLiteral(node)
 	case *Subscript:
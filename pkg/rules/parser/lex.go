@@ -35,6 +35,13 @@ const (
 	TokenLt
 	TokenUnaryNot
 	TokenContains
+	TokenGt
+	TokenLtEq
+	TokenGtEq
+	TokenPlus
+	TokenMinus
+	TokenStar
+	TokenDot
 )
 
 func TokenTypeString(i peruse.TokenType) string {
@@ -77,6 +84,20 @@ func TokenTypeString(i peruse.TokenType) string {
 		return "<UNARY>"
 	case TokenContains:
 		return "<IN>"
+	case TokenGt:
+		return "<GT>"
+	case TokenLtEq:
+		return "<LTE>"
+	case TokenGtEq:
+		return "<GTE>"
+	case TokenPlus:
+		return "<PLUS>"
+	case TokenMinus:
+		return "<MINUS>"
+	case TokenStar:
+		return "<STAR>"
+	case TokenDot:
+		return "<DOT>"
 	default:
 		return "<UNKNOWN>"
 	}
@@ -118,6 +139,14 @@ func lexRule(l *peruse.StringLexer, state any) peruse.LexFn {
 		return lexCloseBrack
 	case r == ',':
 		return l.Emit(TokenComma)
+	case r == '.':
+		return l.Emit(TokenDot)
+	case r == '+':
+		return l.Emit(TokenPlus)
+	case r == '-':
+		return l.Emit(TokenMinus)
+	case r == '*':
+		return l.Emit(TokenStar)
 	case r == '=':
 		l.Prev()
 		return lexEq
@@ -168,6 +197,9 @@ func lexWhitespace(l *peruse.StringLexer, _ any) peruse.LexFn {
 
 func lexNumber(l *peruse.StringLexer, _ any) peruse.LexFn {
 	l.AcceptWhile(isDigit)
+	if l.AcceptOneOf(".") {
+		l.AcceptWhile(isDigit)
+	}
 	if !atSeparator(l) {
 		return unexpected(l.Peek(), l)
 	}
@@ -224,8 +256,18 @@ func lexNotEq(l *peruse.StringLexer, _ any) peruse.LexFn {
 }
 
 func lexInEq(l *peruse.StringLexer, _ any) peruse.LexFn {
-	l.AcceptOneOf("<") // know its one of these
-	return l.Emit(TokenLt)
+	if l.AcceptOneOf("<") { // know its one of these
+		if l.AcceptOneOf("=") {
+			return l.Emit(TokenLtEq)
+		}
+		return l.Emit(TokenLt)
+	}
+
+	l.AcceptOneOf(">")
+	if l.AcceptOneOf("=") {
+		return l.Emit(TokenGtEq)
+	}
+	return l.Emit(TokenGt)
 }
 
 // opening ' is already scanned
@@ -261,7 +303,7 @@ func atSeparator(l *peruse.StringLexer) bool {
 	}
 
 	switch r {
-	case eof, '.', '(', ')', ',', '[', ']':
+	case eof, '.', '(', ')', ',', '[', ']', '+', '-', '*', '<', '>', '=', '!':
 		return true
 	default:
 		return false
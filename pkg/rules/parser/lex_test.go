@@ -3,8 +3,7 @@ package parser
 import (
 	"testing"
 
-	"sudonters/zootler/internal/peruse"
-
+	"github.com/etc-sudonters/substrate/peruse"
 	"github.com/etc-sudonters/substrate/reiterate"
 )
 
@@ -106,6 +105,61 @@ func TestErrsOnUnexpectedClosedParent(t *testing.T) {
 	toksAreEqual(expected, collected, t)
 }
 
+func TestCanLexComparisons(t *testing.T) {
+	rule := "a < b <= c > d >= e not in f"
+	expected := []peruse.Token{
+		{Type: TokenIdentifier, Pos: 0, Literal: "a"},
+		{Type: TokenLt, Pos: 2, Literal: "<"},
+		{Type: TokenIdentifier, Pos: 4, Literal: "b"},
+		{Type: TokenLtEq, Pos: 6, Literal: "<="},
+		{Type: TokenIdentifier, Pos: 9, Literal: "c"},
+		{Type: TokenGt, Pos: 11, Literal: ">"},
+		{Type: TokenIdentifier, Pos: 13, Literal: "d"},
+		{Type: TokenGtEq, Pos: 15, Literal: ">="},
+		{Type: TokenIdentifier, Pos: 18, Literal: "e"},
+		{Type: TokenUnaryNot, Pos: 20, Literal: "not"},
+		{Type: TokenContains, Pos: 24, Literal: "in"},
+		{Type: TokenIdentifier, Pos: 27, Literal: "f"},
+	}
+
+	l := NewRulesLexer(rule)
+	collected := lexUntilEofOrErr(l, t)
+
+	toksAreEqual(expected, collected, t)
+}
+
+func TestCanLexArithmetic(t *testing.T) {
+	rule := "hearts+2*containers - 0.5"
+	expected := []peruse.Token{
+		{Type: TokenIdentifier, Pos: 0, Literal: "hearts"},
+		{Type: TokenPlus, Pos: 6, Literal: "+"},
+		{Type: TokenNumber, Pos: 7, Literal: "2"},
+		{Type: TokenStar, Pos: 8, Literal: "*"},
+		{Type: TokenIdentifier, Pos: 9, Literal: "containers"},
+		{Type: TokenMinus, Pos: 20, Literal: "-"},
+		{Type: TokenNumber, Pos: 22, Literal: "0.5"},
+	}
+
+	l := NewRulesLexer(rule)
+	collected := lexUntilEofOrErr(l, t)
+
+	toksAreEqual(expected, collected, t)
+}
+
+func TestCanLexAttributeAccess(t *testing.T) {
+	rule := "world.settings"
+	expected := []peruse.Token{
+		{Type: TokenIdentifier, Pos: 0, Literal: "world"},
+		{Type: TokenDot, Pos: 5, Literal: "."},
+		{Type: TokenIdentifier, Pos: 6, Literal: "settings"},
+	}
+
+	l := NewRulesLexer(rule)
+	collected := lexUntilEofOrErr(l, t)
+
+	toksAreEqual(expected, collected, t)
+}
+
 func TestCanLexActualRules(t *testing.T) {
 	rules := []string{
 		"can_play(Song_of_Time) or (logic_shadow_mq_invisible_blades and damage_multiplier != 'ohko')",
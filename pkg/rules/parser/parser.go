@@ -16,14 +16,16 @@ const (
 	AND
 	NOT
 	EQ
+	SUM
+	PRODUCT
 	INDEX
 	PARENS
 )
 
+// positions are relative to raw, rules read out of a larger document should
+// use ParseSource instead
 func Parse(raw string) (ast.Expression, error) {
-	l := NewRulesLexer(raw)
-	p := NewRulesParser(l)
-	return p.Parse()
+	return ParseSource(NewSource("", raw), 0, len(raw))
 }
 
 func NewRulesParser(l *peruse.StringLexer) *peruse.Parser[ast.Expression] {
@@ -31,56 +33,67 @@ func NewRulesParser(l *peruse.StringLexer) *peruse.Parser[ast.Expression] {
 }
 
 func NewRulesGrammar() peruse.Grammar[ast.Expression] {
+	return newGrammar(nil)
+}
+
+// parselets that parse operands or expect closers belong to the recovery, it
+// may be nil
+func newGrammar(r *recovery) peruse.Grammar[ast.Expression] {
 	g := peruse.NewGrammar[ast.Expression]()
 
 	g.Parse(TokenTrue, parseBool)
 	g.Parse(TokenFalse, parseBool)
 	g.Parse(TokenIdentifier, parseIdentifierExpr)
 	g.Parse(TokenNumber, parseNumber)
-	g.Parse(TokenOpenParen, parseParenExpr)
+	g.Parse(TokenOpenParen, r.parseParenExpr)
 	g.Parse(TokenString, parseString)
-	g.Parse(TokenUnaryNot, parsePrefixNot)
-
-	g.Infix(OR, parseBoolOpExpr, TokenOr)
-	g.Infix(AND, parseBoolOpExpr, TokenAnd)
-	g.Infix(EQ, parseBinOp, TokenEq, TokenNotEq, TokenLt, TokenContains)
-	g.Infix(INDEX, parseSubscript, TokenOpenBracket)
-	g.Infix(PARENS, parseCall, TokenOpenParen)
+	g.Parse(TokenUnaryNot, r.parsePrefixNot)
+	g.Parse(TokenMinus, r.parseNegative)
+
+	g.Infix(OR, r.parseBoolOpExpr, TokenOr)
+	g.Infix(AND, r.parseBoolOpExpr, TokenAnd)
+	// not is only infix as part of not in
+	g.Infix(EQ, r.parseComparison, TokenEq, TokenNotEq, TokenLt, TokenLtEq, TokenGt, TokenGtEq, TokenContains, TokenUnaryNot)
+	g.Infix(SUM, r.parseBinOp, TokenPlus, TokenMinus)
+	g.Infix(PRODUCT, r.parseBinOp, TokenStar)
+	g.Infix(INDEX, r.parseSubscript, TokenOpenBracket)
+	g.Infix(PARENS, r.parseCall, TokenOpenParen)
+	g.Infix(PARENS, parseAttribute, TokenDot)
 
 	return g
 }
 
-func parseParenExpr(p *peruse.Parser[ast.Expression]) (ast.Expression, error) {
-	p.Consume()
-	e, err := p.ParseAt(LOWEST)
+func (r *recovery) parseParenExpr(p *peruse.Parser[ast.Expression]) (ast.Expression, error) {
+	open := tokenSpan(p.Cur)
+	e, err := r.operand(p, LOWEST)
 	if err != nil {
 		return nil, err
 	}
 
 	if p.Next.Is(TokenComma) {
-		e, err = parseTuple(p, e)
+		e, err = r.parseTuple(p, e)
 		if err != nil {
 			return nil, err
 		}
 	}
 
-	if !p.Expect(TokenCloseParen) {
-		return nil, fmt.Errorf(
-			"PARENEXPR: expected %q but got %q",
-			TokenTypeString(TokenCloseParen),
-			p.Next,
-		)
+	if err := r.closing(p, TokenCloseParen, "parenthesized expression"); err != nil {
+		return nil, err
+	}
+
+	// tuples are only delimited by their parens
+	if tup, ok := e.(*ast.Tuple); ok {
+		tup.Pos = spanFrom(open, p)
 	}
 
 	return e, nil
 }
 
-func parseTuple(p *peruse.Parser[ast.Expression], left ast.Expression) (ast.Expression, error) {
+func (r *recovery) parseTuple(p *peruse.Parser[ast.Expression], left ast.Expression) (ast.Expression, error) {
 	elems := []ast.Expression{left}
 
 	for p.Expect(TokenComma) {
-		p.Consume()
-		elem, err := p.ParseAt(LOWEST)
+		elem, err := r.operand(p, LOWEST)
 		if err != nil {
 			return nil, err
 		}
@@ -88,17 +101,16 @@ func parseTuple(p *peruse.Parser[ast.Expression], left ast.Expression) (ast.Expr
 		elems = append(elems, elem)
 	}
 
-	return &ast.Tuple{Elems: elems}, nil
+	return &ast.Tuple{Elems: elems, Pos: spanFrom(left.Span(), p)}, nil
 }
 
 func parseIdentifierExpr(p *peruse.Parser[ast.Expression]) (ast.Expression, error) {
-	return &ast.Identifier{p.Cur.Literal}, nil
+	return &ast.Identifier{Value: p.Cur.Literal, Pos: tokenSpan(p.Cur)}, nil
 }
 
-func parseBoolOpExpr(p *peruse.Parser[ast.Expression], left ast.Expression, parentPrecedence peruse.Precedence) (ast.Expression, error) {
+func (r *recovery) parseBoolOpExpr(p *peruse.Parser[ast.Expression], left ast.Expression, parentPrecedence peruse.Precedence) (ast.Expression, error) {
 	thisTok := p.Cur
-	p.Consume()
-	right, err := p.ParseAt(parentPrecedence)
+	right, err := r.operand(p, parentPrecedence)
 	if err != nil {
 		return nil, err
 	}
@@ -106,15 +118,15 @@ func parseBoolOpExpr(p *peruse.Parser[ast.Expression], left ast.Expression, pare
 		Left:  left,
 		Op:    BoolOpFromTok(thisTok),
 		Right: right,
+		Pos:   spanFrom(left.Span(), p),
 	}
 
 	return &b, nil
 }
 
-func parseBinOp(p *peruse.Parser[ast.Expression], left ast.Expression, bp peruse.Precedence) (ast.Expression, error) {
+func (r *recovery) parseBinOp(p *peruse.Parser[ast.Expression], left ast.Expression, bp peruse.Precedence) (ast.Expression, error) {
 	thisTok := p.Cur
-	p.Consume()
-	right, err := p.ParseAt(bp)
+	right, err := r.operand(p, bp)
 	if err != nil {
 		return nil, err
 	}
@@ -123,20 +135,80 @@ func parseBinOp(p *peruse.Parser[ast.Expression], left ast.Expression, bp peruse
 		Left:  left,
 		Op:    BinOpFromTok(thisTok),
 		Right: right,
+		Pos:   spanFrom(left.Span(), p),
 	}
 
 	return &b, nil
 }
 
-func parseCall(p *peruse.Parser[ast.Expression], left ast.Expression, bp peruse.Precedence) (ast.Expression, error) {
+// a < b < c is a < b and b < c, each comparison gets its own copy of b so
+// rewriting one side can't change the other
+func (r *recovery) parseComparison(p *peruse.Parser[ast.Expression], left ast.Expression, bp peruse.Precedence) (ast.Expression, error) {
+	var chain ast.Expression
+
+	for {
+		op, err := comparisonOp(p)
+		if err != nil {
+			return nil, err
+		}
+
+		right, err := r.operand(p, bp)
+		if err != nil {
+			return nil, err
+		}
+
+		var cmp ast.Expression = &ast.BinOp{Left: left, Op: op, Right: right, Pos: spanFrom(left.Span(), p)}
+		if chain != nil {
+			cmp = &ast.BoolOp{Left: chain, Op: ast.BoolOpAnd, Right: cmp, Pos: spanFrom(chain.Span(), p)}
+		}
+		chain = cmp
+
+		if !isComparison(p.Next) {
+			return chain, nil
+		}
+		p.Consume()
+		left = ast.Clone(right)
+	}
+}
+
+// the current token is the comparison, not must be followed by in
+func comparisonOp(p *peruse.Parser[ast.Expression]) (ast.BinOpKind, error) {
+	if !p.Cur.Is(TokenUnaryNot) {
+		return BinOpFromTok(p.Cur), nil
+	}
+
+	if err := expectOrError(p, TokenContains, "not in"); err != nil {
+		return "", err
+	}
+	return ast.BinOpNotContains, nil
+}
+
+func isComparison(t peruse.Token) bool {
+	switch t.Type {
+	case TokenEq, TokenNotEq, TokenLt, TokenLtEq, TokenGt, TokenGtEq, TokenContains, TokenUnaryNot:
+		return true
+	default:
+		return false
+	}
+}
+
+func parseAttribute(p *peruse.Parser[ast.Expression], left ast.Expression, bp peruse.Precedence) (ast.Expression, error) {
+	if err := expectOrError(p, TokenIdentifier, "attribute"); err != nil {
+		return nil, err
+	}
+
+	a := ast.Attribute{Target: left, Attr: p.Cur.Literal, Pos: spanFrom(left.Span(), p)}
+	return &a, nil
+}
+
+func (r *recovery) parseCall(p *peruse.Parser[ast.Expression], left ast.Expression, bp peruse.Precedence) (ast.Expression, error) {
 	if p.Expect(TokenCloseParen) { // fn()
-		return &ast.Call{Callee: left}, nil
+		return &ast.Call{Callee: left, Pos: spanFrom(left.Span(), p)}, nil
 	}
 
 	var args []ast.Expression
 	for {
-		p.Consume()
-		arg, err := p.ParseAt(LOWEST)
+		arg, err := r.operand(p, LOWEST)
 		if err != nil {
 			return nil, err
 		}
@@ -146,50 +218,48 @@ func parseCall(p *peruse.Parser[ast.Expression], left ast.Expression, bp peruse.
 		}
 	}
 
-	if err := p.ExpectOrError(TokenCloseParen); err != nil {
-		return nil, peruse.UnexpectedAt("FNCALL", err)
+	if err := r.closing(p, TokenCloseParen, "call"); err != nil {
+		return nil, err
 	}
 
-	c := ast.Call{Callee: left, Args: args}
+	c := ast.Call{Callee: left, Args: args, Pos: spanFrom(left.Span(), p)}
 	return &c, nil
 }
 
-func parseSubscript(p *peruse.Parser[ast.Expression], left ast.Expression, bp peruse.Precedence) (ast.Expression, error) {
-	p.Consume()
-	index, err := p.ParseAt(LOWEST)
+func (r *recovery) parseSubscript(p *peruse.Parser[ast.Expression], left ast.Expression, bp peruse.Precedence) (ast.Expression, error) {
+	index, err := r.operand(p, LOWEST)
 	if err != nil {
 		return nil, err
 	}
 
-	if err = p.ExpectOrError(TokenCloseBracket); err != nil {
-		return nil, peruse.UnexpectedAt("SUBSCRIPT", err)
+	if err = r.closing(p, TokenCloseBracket, "subscript"); err != nil {
+		return nil, err
 	}
 
-	s := ast.Subscript{Target: left, Index: index}
+	s := ast.Subscript{Target: left, Index: index, Pos: spanFrom(left.Span(), p)}
 	return &s, nil
 }
 
 func parseString(p *peruse.Parser[ast.Expression]) (ast.Expression, error) {
-	s := &ast.Literal{Value: p.Cur.Literal, Kind: ast.LiteralStr}
+	s := &ast.Literal{Value: p.Cur.Literal, Kind: ast.LiteralStr, Pos: tokenSpan(p.Cur)}
 	return s, nil
 }
 
 func parseNumber(p *peruse.Parser[ast.Expression]) (ast.Expression, error) {
 	n, err := strconv.ParseFloat(p.Cur.Literal, 64)
 	if err != nil {
-		return nil, fmt.Errorf("cannot parse %q as number", p.Cur)
+		return nil, errorAt(tokenSpan(p.Cur), "cannot parse %q as number", p.Cur.Literal)
 	}
-	return &ast.Literal{Value: n, Kind: ast.LiteralNum}, nil
+	return &ast.Literal{Value: n, Kind: ast.LiteralNum, Pos: tokenSpan(p.Cur)}, nil
 }
 
 func parseBool(p *peruse.Parser[ast.Expression]) (ast.Expression, error) {
-	return &ast.Literal{Value: p.Cur.Literal == trueWord, Kind: ast.LiteralBool}, nil
+	return &ast.Literal{Value: p.Cur.Literal == trueWord, Kind: ast.LiteralBool, Pos: tokenSpan(p.Cur)}, nil
 }
 
-func parsePrefixNot(p *peruse.Parser[ast.Expression]) (ast.Expression, error) {
+func (r *recovery) parsePrefixNot(p *peruse.Parser[ast.Expression]) (ast.Expression, error) {
 	thisTok := p.Cur
-	p.Consume()
-	target, err := p.ParseAt(NOT)
+	target, err := r.operand(p, NOT)
 	if err != nil {
 		return nil, err
 	}
@@ -198,11 +268,30 @@ func parsePrefixNot(p *peruse.Parser[ast.Expression]) (ast.Expression, error) {
 		u := ast.UnaryOp{
 			Op:     ast.UnaryNot,
 			Target: target,
+			Pos:    spanFrom(tokenSpan(thisTok), p),
 		}
 		return &u, nil
 	default:
-		return nil, fmt.Errorf("unexpected unary op %q", thisTok)
+		return nil, errorAt(tokenSpan(thisTok), "unexpected unary op %q", thisTok.Literal)
+	}
+}
+
+// only numbers can be negated, the sign is folded into the literal
+func (r *recovery) parseNegative(p *peruse.Parser[ast.Expression]) (ast.Expression, error) {
+	minus := tokenSpan(p.Cur)
+	target, err := r.operand(p, PRODUCT)
+	if err != nil {
+		return nil, err
 	}
+
+	lit, ok := target.(*ast.Literal)
+	if !ok || lit.Kind != ast.LiteralNum {
+		return nil, errorAt(spanFrom(minus, p), "unary minus can only negate numbers")
+	}
+
+	lit.Value = -lit.Value.(float64)
+	lit.Pos = spanFrom(minus, p)
+	return lit, nil
 }
 
 func UnaryOpFromTok(t peruse.Token) ast.UnaryOpKind {
@@ -229,12 +318,24 @@ func BinOpFromTok(t peruse.Token) ast.BinOpKind {
 	switch t.Literal {
 	case string(ast.BinOpLt):
 		return ast.BinOpLt
+	case string(ast.BinOpLtEq):
+		return ast.BinOpLtEq
+	case string(ast.BinOpGt):
+		return ast.BinOpGt
+	case string(ast.BinOpGtEq):
+		return ast.BinOpGtEq
 	case string(ast.BinOpEq):
 		return ast.BinOpEq
 	case string(ast.BinOpNotEq):
 		return ast.BinOpNotEq
 	case string(ast.BinOpContains):
 		return ast.BinOpContains
+	case string(ast.BinOpAdd):
+		return ast.BinOpAdd
+	case string(ast.BinOpSub):
+		return ast.BinOpSub
+	case string(ast.BinOpMul):
+		return ast.BinOpMul
 	default:
 		panic(fmt.Errorf("invalid binop %q", t))
 	}
@@ -1,6 +1,13 @@
 package parser
 
-import "testing"
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"sudonters/zootler/internal/astrender"
+	"sudonters/zootler/pkg/rules/ast"
+)
 
 func TestParseRealRule(t *testing.T) {
 	r := "can_play(Song_of_Time) or (logic_shadow_mq_invisible_blades and damage_multiplier != 'ohko')"
@@ -19,8 +26,6 @@ func TestParseRealRule(t *testing.T) {
 	if p.HasMore() {
 		t.Fatal("trailing unparsed content")
 	}
-
-	t.Fatal("task failed successfully")
 }
 
 func TestParseConstRule(t *testing.T) {
@@ -44,17 +49,222 @@ func TestParseConstRule(t *testing.T) {
 			}
 
 			switch r := rule.(type) {
-			case *Boolean:
-				if r.Value != i.expected {
-					t.Logf("expected to parse %s to ConstRule{ %t }", i.raw, i.expected)
+			case *ast.Literal:
+				if r.Kind != ast.LiteralBool || r.Value != i.expected {
+					t.Logf("expected to parse %s to Literal{ %t }", i.raw, i.expected)
 					t.Logf("instead parsed to %v", r)
 					t.FailNow()
 				}
 				break
 			default:
-				t.Fatalf("expected to parse 'True' to ConstRule not %v", rule)
+				t.Fatalf("expected to parse %q to Literal not %v", i.raw, rule)
 				break
 			}
 		})
 	}
 }
+
+func TestParseExpressions(t *testing.T) {
+	inputs := []struct {
+		name, raw, expected string
+	}{
+		{"Gt", "count > 3", "(> count 3)"},
+		{"LtEq", "count <= 3", "(<= count 3)"},
+		{"GtEq", "count >= 3", "(>= count 3)"},
+		{"NotIn", "'Bow' not in items", "(not in Bow items)"},
+		{"NotBindsLooserThanIn", "not 'Bow' in items", "(not (in Bow items))"},
+		{"Arithmetic", "hearts + 2 * containers - 1", "(- (+ hearts (* 2 containers)) 1)"},
+		{"ArithmeticBindsTighterThanComparison", "hearts * 4 >= 12", "(>= (* hearts 4) 12)"},
+		{"Attribute", "settings.bridge == 'open'", "(== (. settings bridge) open)"},
+		{"AttributeCall", "world.settings.has('bridge')", "((. (. world settings) has) bridge)"},
+		{"AttributeSubscript", "skipped_trials[world.id]", "([] skipped_trials (. world id))"},
+		{"Chained", "1 < count <= 5", "(and (< 1 count) (<= count 5))"},
+		{"ChainedMixed", "a == b != c in d", "(and (and (== a b) (!= b c)) (in c d))"},
+		{"ChainedInBoolOp", "is_adult and 0 < hearts < 3 or ohko", "(or (and is_adult (and (< 0 hearts) (< hearts 3))) ohko)"},
+		{"Decimal", "damage_multiplier < 0.5", "(< damage_multiplier 0.5)"},
+		{"NoSpaces", "count>=3", "(>= count 3)"},
+	}
+
+	for _, i := range inputs {
+		i := i
+		t.Run(i.name, func(t *testing.T) {
+			p := NewRulesParser(NewRulesLexer(i.raw))
+			rule, err := p.Parse()
+			if err != nil {
+				t.Fatalf("expected to parse %q: %s", i.raw, err)
+			}
+
+			if p.HasMore() {
+				t.Fatalf("trailing unparsed content after %q", i.raw)
+			}
+
+			sexpr := astrender.NewSexpr(astrender.DontTheme())
+			if err := ast.Visit(sexpr, rule); err != nil {
+				t.Fatal(err)
+			}
+
+			if actual := sexpr.String(); actual != i.expected {
+				t.Fatalf("expected %q to parse to\n%s\nbut got\n%s", i.raw, i.expected, actual)
+			}
+		})
+	}
+}
+
+func TestChainedComparisonsCopyOperands(t *testing.T) {
+	rule, err := Parse("1 < count < 5")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	and, ok := rule.(*ast.BoolOp)
+	if !ok {
+		t.Fatalf("expected chain to become a BoolOp but got %T", rule)
+	}
+
+	left, right := and.Left.(*ast.BinOp).Right, and.Right.(*ast.BinOp).Left
+	if left == right {
+		t.Fatal("expected each comparison to have its own middle operand")
+	}
+	if left.(*ast.Identifier).Value != "count" || left.Span() != right.Span() {
+		t.Fatalf("expected both comparisons to refer to count but got %#v and %#v", left, right)
+	}
+}
+
+func TestUnaryMinusOnlyNegatesNumbers(t *testing.T) {
+	rule, err := Parse("count > -1 * 2")
+	if err != nil {
+		t.Fatal(err)
+	}
+	product := rule.(*ast.BinOp).Right.(*ast.BinOp)
+	if lit, ok := product.Left.(*ast.Literal); !ok || lit.Value != -1.0 || lit.Span().Start.Offset != 8 || lit.Span().End.Offset != 10 {
+		t.Fatalf("expected -1 to be a negative literal but got %#v", product.Left)
+	}
+
+	_, err = Parse("-count")
+	var syntax *SyntaxError
+	if !errors.As(err, &syntax) || syntax.Msg != "unary minus can only negate numbers" {
+		t.Fatalf("expected negating an identifier to be a syntax error but got %v", err)
+	}
+}
+
+func TestNotMustBeFollowedByIn(t *testing.T) {
+	if _, err := Parse("'Bow' not items"); err == nil {
+		t.Fatal("expected not without in to fail")
+	}
+}
+
+func TestNodesSpanTheirSource(t *testing.T) {
+	doc := "{\n  \"rule\": \"is_adult and\n      has(Bow, 2)\"\n}"
+	start := strings.Index(doc, "is_adult")
+	rule, err := ParseSource(NewSource("rules.json", doc), start, strings.LastIndex(doc, "\""))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	and := rule.(*ast.BoolOp)
+	call := and.Right.(*ast.Call)
+	bow := call.Args[0]
+
+	spans := []struct {
+		name     string
+		span     ast.Span
+		expected string
+	}{
+		{"BoolOp", and.Span(), "2:12-3:18"},
+		{"Identifier", and.Left.Span(), "2:12-2:20"},
+		{"Call", call.Span(), "3:7-3:18"},
+		{"Arg", bow.Span(), "3:11-3:14"},
+	}
+
+	for _, s := range spans {
+		if actual := s.span.String(); actual != s.expected {
+			t.Errorf("expected %s to span %s but got %s", s.name, s.expected, actual)
+		}
+	}
+
+	if text := doc[bow.Span().Start.Offset:bow.Span().End.Offset]; text != "Bow" {
+		t.Errorf("expected offsets to cover 'Bow' but covered %q", text)
+	}
+}
+
+func TestSyntaxErrorsPointAtTheirToken(t *testing.T) {
+	doc := "{\n\t\"rule\": \"has(Bow\n\t\tHookshot)\"\n}"
+	start := strings.Index(doc, "has")
+	_, err := ParseSource(NewSource("rules.json", doc), start, strings.LastIndex(doc, "\""))
+
+	var syntax *SyntaxError
+	if !errors.As(err, &syntax) {
+		t.Fatalf("expected a SyntaxError but got %v", err)
+	}
+
+	if expected := `rules.json:3:3: call: expected <CLOSEPAREN> but found "Hookshot"`; syntax.Error() != expected {
+		t.Fatalf("expected error\n%s\nbut got\n%s", expected, syntax.Error())
+	}
+
+	if expected := "\t\tHookshot)\"\n\t\t^^^^^^^^"; syntax.Caret() != expected {
+		t.Fatalf("expected caret\n%s\nbut got\n%s", expected, syntax.Caret())
+	}
+}
+
+func TestTrailingTokensAreErrors(t *testing.T) {
+	if _, err := Parse("is_adult Bow"); err == nil {
+		t.Fatal("expected trailing identifier to fail")
+	}
+}
+
+func TestRecoveringReportsEveryError(t *testing.T) {
+	inputs := []struct {
+		name, raw, expected string
+		errs                []string
+	}{
+		{"MissingOperand", "a and and b", "(and (and a <invalid>) b)", []string{`1:7: expected an expression but found "and"`}},
+		{"UnclosedCall", "has(x y) or c", "(or (has x) c)", []string{`1:7: call: expected <CLOSEPAREN> but found "y"`}},
+		{
+			"SeveralErrors",
+			"can_use(Dins_Fire or $) and has(Bow, ) and is_adult",
+			"(and (and (can_use (or Dins_Fire <invalid>)) (has Bow <invalid>)) is_adult)",
+			[]string{"1:23: unrecongized character U+0024 '$'", `1:38: expected an expression but found ")"`},
+		},
+		{"UnclosedAtEnd", "is_adult or can_use(Hookshot", "(or is_adult (can_use Hookshot))", []string{"1:29: unclosed '(' or '['"}},
+		{"Trailing", "is_adult Bow", "is_adult", []string{`1:10: unexpected "Bow"`}},
+		{"Nothing", "", "<invalid>", []string{"1:1: unexpected end of rule"}},
+		{"Valid", "is_adult and Bow", "(and is_adult Bow)", nil},
+	}
+
+	for _, i := range inputs {
+		i := i
+		t.Run(i.name, func(t *testing.T) {
+			rule, errs := ParseRecovering(NewSource("", i.raw), 0, len(i.raw))
+			if rule == nil {
+				t.Fatalf("expected a partial rule for %q", i.raw)
+			}
+
+			sexpr := astrender.NewSexpr(astrender.DontTheme())
+			if err := ast.Visit(sexpr, rule); err != nil {
+				t.Fatal(err)
+			}
+			if actual := sexpr.String(); actual != i.expected {
+				t.Errorf("expected %q to recover to\n%s\nbut got\n%s", i.raw, i.expected, actual)
+			}
+
+			if len(errs) != len(i.errs) {
+				t.Fatalf("expected %d errors but got %v", len(i.errs), errs)
+			}
+			for idx := range errs {
+				if errs[idx].Error() != i.errs[idx] {
+					t.Errorf("expected error %q but got %q", i.errs[idx], errs[idx].Error())
+				}
+			}
+		})
+	}
+}
+
+func TestRecoveredNodesSpanWhatWasSkipped(t *testing.T) {
+	raw := "has(x y z) or c"
+	rule, _ := ParseRecovering(NewSource("", raw), 0, len(raw))
+	call := rule.(*ast.BoolOp).Left.(*ast.Call)
+
+	if span := call.Span(); raw[span.Start.Offset:span.End.Offset] != "has(x y z)" {
+		t.Fatalf("expected the call to cover what was skipped but covered %q", raw[span.Start.Offset:span.End.Offset])
+	}
+}
@@ -0,0 +1,131 @@
+package parser
+
+import (
+	"fmt"
+	"sudonters/zootler/pkg/rules/ast"
+
+	"github.com/etc-sudonters/substrate/peruse"
+)
+
+// collects every error in a rule instead of stopping at the first. Broken
+// operands become Invalid nodes and the parser skips ahead to the next comma,
+// closer, and or or at the same depth before carrying on. A nil recovery
+// returns the first error instead
+type recovery struct {
+	errs []*SyntaxError
+	// the lexer repeats its error at the end of a rule with unclosed parens
+	lexErrs map[peruse.Pos]bool
+}
+
+func newRecovery() *recovery {
+	return &recovery{lexErrs: make(map[peruse.Pos]bool)}
+}
+
+// parses the operand that follows the current token
+func (r *recovery) operand(p *peruse.Parser[ast.Expression], prec peruse.Precedence) (ast.Expression, error) {
+	if isSync(p.Next) || p.Next.Is(peruse.EOF) {
+		missing := tokenSpan(p.Next)
+		missing.End = missing.Start
+		err := errorAt(missing, "expected an expression but found %s", describe(p.Next))
+		if r == nil {
+			return nil, err
+		}
+		// nothing to skip, whatever's next belongs to the caller
+		r.record(err)
+		return &ast.Invalid{Pos: missing}, nil
+	}
+
+	p.Consume()
+	start := tokenSpan(p.Cur)
+	expr, err := p.ParseAt(prec)
+	if err == nil {
+		return expr, nil
+	}
+	if r == nil {
+		return nil, err
+	}
+
+	r.record(asSyntaxError(err))
+	r.skip(p, isSync)
+	return &ast.Invalid{Pos: spanFrom(start, p)}, nil
+}
+
+// expects the closer for a construct that's already open, while recovering
+// anything before the closer is skipped
+func (r *recovery) closing(p *peruse.Parser[ast.Expression], want peruse.TokenType, what string) error {
+	err := expectOrError(p, want, what)
+	if err == nil || r == nil {
+		return err
+	}
+
+	r.record(asSyntaxError(err))
+	r.skip(p, isCloser)
+	// a different closer belongs to an enclosing construct
+	if p.Next.Is(want) {
+		p.Consume()
+	}
+	return nil
+}
+
+// consumes tokens until the next one is a stop at the current depth
+func (r *recovery) skip(p *peruse.Parser[ast.Expression], stop func(peruse.Token) bool) {
+	depth := 0
+	for !r.atEnd(p) {
+		next := p.Next
+		switch {
+		case depth == 0 && stop(next):
+			return
+		case next.Is(TokenOpenParen) || next.Is(TokenOpenBracket):
+			depth++
+		case isCloser(next):
+			depth--
+		case next.Is(peruse.ERR):
+			r.lexErrs[next.Pos] = true
+			r.record(unexpectedToken(next))
+		}
+		p.Consume()
+	}
+}
+
+func (r *recovery) atEnd(p *peruse.Parser[ast.Expression]) bool {
+	if p.Next.Is(peruse.EOF) {
+		return true
+	}
+	return r != nil && p.Next.Is(peruse.ERR) && r.lexErrs[p.Next.Pos]
+}
+
+// the same error is often found by both an operand and its caller
+func (r *recovery) record(err *SyntaxError) {
+	if n := len(r.errs); n > 0 && *r.errs[n-1] == *err {
+		return
+	}
+	r.errs = append(r.errs, err)
+}
+
+func isSync(t peruse.Token) bool {
+	switch t.Type {
+	case TokenComma, TokenAnd, TokenOr:
+		return true
+	default:
+		return isCloser(t)
+	}
+}
+
+func never(peruse.Token) bool {
+	return false
+}
+
+func isCloser(t peruse.Token) bool {
+	return t.Is(TokenCloseParen) || t.Is(TokenCloseBracket)
+}
+
+func describe(t peruse.Token) string {
+	switch t.Type {
+	case peruse.EOF:
+		return "the end of the rule"
+	case peruse.ERR:
+		return t.Literal
+	default:
+		return fmt.Sprintf("%q", t.Literal)
+	}
+}
@@ -0,0 +1,269 @@
+package parser
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sudonters/zootler/pkg/rules/ast"
+
+	"github.com/etc-sudonters/substrate/peruse"
+)
+
+// the document rules are parsed out of, usually a logic file. Every position
+// is reported against the whole document rather than the rule
+type Source struct {
+	Name string
+	Text string
+	// offset each line starts at
+	lines []int
+}
+
+func NewSource(name, text string) *Source {
+	s := &Source{Name: name, Text: text, lines: []int{0}}
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			s.lines = append(s.lines, i+1)
+		}
+	}
+	return s
+}
+
+// columns count bytes, tabs are a single column
+func (s *Source) Position(offset int) ast.Position {
+	line := sort.Search(len(s.lines), func(i int) bool { return s.lines[i] > offset })
+	return ast.Position{
+		Offset: offset,
+		Line:   line,
+		Column: offset - s.lines[line-1] + 1,
+	}
+}
+
+func (s *Source) Span(start, end int) ast.Span {
+	return ast.Span{Start: s.Position(start), End: s.Position(end)}
+}
+
+// the text of the 1-based line without its line ending
+func (s *Source) Line(n int) string {
+	if n < 1 || n > len(s.lines) {
+		return ""
+	}
+	end := len(s.Text)
+	if n < len(s.lines) {
+		end = s.lines[n] - 1
+	}
+	return strings.TrimSuffix(s.Text[s.lines[n-1]:end], "\r")
+}
+
+// the rule is Text[start:end], a rule that's its whole document can use Parse
+func ParseSource(src *Source, start, end int) (ast.Expression, error) {
+	p := NewRulesParser(NewRulesLexer(src.Text[start:end]))
+	expr, err := p.Parse()
+	if err == nil && !p.Next.Is(peruse.EOF) {
+		err = unexpectedToken(p.Next)
+	}
+
+	if err != nil {
+		return nil, moveError(asSyntaxError(err), src, start)
+	}
+
+	place(expr, src, start)
+	return expr, nil
+}
+
+// keeps parsing after errors and returns every one of them in the order
+// they're found. The expression is never nil, it has Invalid nodes where the
+// rule was broken
+func ParseRecovering(src *Source, start, end int) (ast.Expression, []*SyntaxError) {
+	r := newRecovery()
+	p := peruse.NewParser(newGrammar(r), NewRulesLexer(src.Text[start:end]))
+
+	first := tokenSpan(p.Cur)
+	expr, err := p.Parse()
+	if err != nil {
+		r.record(asSyntaxError(err))
+		r.skip(p, never)
+		expr = &ast.Invalid{Pos: spanFrom(first, p)}
+	} else if !r.atEnd(p) {
+		r.record(unexpectedToken(p.Next))
+		r.skip(p, never)
+	}
+
+	for i := range r.errs {
+		r.errs[i] = moveError(r.errs[i], src, start)
+	}
+
+	place(expr, src, start)
+	return expr, r.errs
+}
+
+// a rule that couldn't be parsed, Pos covers the offending token
+type SyntaxError struct {
+	Pos ast.Span
+	Msg string
+	src *Source
+}
+
+func (e *SyntaxError) Error() string {
+	if e.src == nil || e.src.Name == "" {
+		return fmt.Sprintf("%s: %s", e.Pos.Start, e.Msg)
+	}
+	return fmt.Sprintf("%s:%s: %s", e.src.Name, e.Pos.Start, e.Msg)
+}
+
+// the offending line with the error's span underlined, spans that continue
+// past the line are underlined to its end
+func (e *SyntaxError) Caret() string {
+	if e.src == nil || !e.Pos.Start.IsValid() {
+		return ""
+	}
+
+	line := e.src.Line(e.Pos.Start.Line)
+	col := min(e.Pos.Start.Column-1, len(line))
+	width := 1
+	if e.Pos.End.Line == e.Pos.Start.Line && e.Pos.End.Column > e.Pos.Start.Column {
+		width = e.Pos.End.Column - e.Pos.Start.Column
+	} else if e.Pos.End.Line > e.Pos.Start.Line && len(line) > col {
+		width = len(line) - col
+	}
+
+	// tabs stay tabs so the caret lines up however they're rendered
+	pad := strings.Map(func(r rune) rune {
+		if r == '\t' {
+			return r
+		}
+		return ' '
+	}, line[:col])
+	return fmt.Sprintf("%s\n%s%s", line, pad, strings.Repeat("^", width))
+}
+
+// positions are relative to the rule until the error leaves the parser
+func errorAt(span ast.Span, format string, v ...any) *SyntaxError {
+	return &SyntaxError{Pos: span, Msg: fmt.Sprintf(format, v...)}
+}
+
+func unexpectedToken(t peruse.Token) *SyntaxError {
+	switch t.Type {
+	case peruse.ERR:
+		return errorAt(tokenSpan(t), "%s", t.Literal)
+	case peruse.EOF:
+		return errorAt(tokenSpan(t), "unexpected end of rule")
+	default:
+		return errorAt(tokenSpan(t), "unexpected %q", t.Literal)
+	}
+}
+
+// the next token must be want, what names the construct being parsed
+func expectOrError(p *peruse.Parser[ast.Expression], want peruse.TokenType, what string) error {
+	if p.Expect(want) {
+		return nil
+	}
+
+	switch have := p.Next; have.Type {
+	case peruse.ERR:
+		return unexpectedToken(have)
+	case peruse.EOF:
+		return errorAt(tokenSpan(have), "%s: expected %s but the rule ended", what, TokenTypeString(want))
+	default:
+		return errorAt(tokenSpan(have), "%s: expected %s but found %q", what, TokenTypeString(want), have.Literal)
+	}
+}
+
+// errors from peruse are turned into syntax errors at their token
+func asSyntaxError(err error) *SyntaxError {
+	var syntax *SyntaxError
+	var unexpected peruse.UnexpectedToken
+	var invalid peruse.InvalidToken
+
+	switch {
+	case errors.As(err, &syntax):
+		return syntax
+	case errors.As(err, &unexpected):
+		return unexpectedToken(unexpected.Have)
+	case errors.As(err, &invalid):
+		return unexpectedToken(invalid.Have)
+	default:
+		return errorAt(ast.Span{}, "%s", err)
+	}
+}
+
+// moves the error from its rule into the document, the error is copied so
+// it can't be moved twice
+func moveError(err *SyntaxError, src *Source, base int) *SyntaxError {
+	moved := *err
+	moved.src = src
+	moved.Pos = src.Span(base+err.Pos.Start.Offset, base+err.Pos.End.Offset)
+	return &moved
+}
+
+// the span of the token's text, strings include their quotes and errors are
+// a point
+func tokenSpan(t peruse.Token) ast.Span {
+	start, end := int(t.Pos), int(t.Pos)+len(t.Literal)
+	switch t.Type {
+	case TokenString:
+		start, end = start-1, end+1
+	case peruse.ERR, peruse.EOF:
+		end = start
+	}
+	return ast.Span{Start: ast.Position{Offset: start}, End: ast.Position{Offset: end}}
+}
+
+// from start through the current token
+func spanFrom(start ast.Span, p *peruse.Parser[ast.Expression]) ast.Span {
+	return ast.Span{Start: start.Start, End: tokenSpan(p.Cur).End}
+}
+
+// moves every node's span from the rule into the document
+func place(expr ast.Expression, src *Source, base int) {
+	if expr == nil {
+		return
+	}
+
+	var span *ast.Span
+	switch expr := expr.(type) {
+	case *ast.Attribute:
+		span = &expr.Pos
+		place(expr.Target, src, base)
+	case *ast.BinOp:
+		span = &expr.Pos
+		place(expr.Left, src, base)
+		place(expr.Right, src, base)
+	case *ast.BoolOp:
+		span = &expr.Pos
+		place(expr.Left, src, base)
+		place(expr.Right, src, base)
+	case *ast.Call:
+		span = &expr.Pos
+		place(expr.Callee, src, base)
+		for _, arg := range expr.Args {
+			place(arg, src, base)
+		}
+	case *ast.Identifier:
+		span = &expr.Pos
+	case *ast.Invalid:
+		span = &expr.Pos
+	case *ast.Literal:
+		span = &expr.Pos
+	case *ast.Subscript:
+		span = &expr.Pos
+		place(expr.Target, src, base)
+		place(expr.Index, src, base)
+	case *ast.Tuple:
+		span = &expr.Pos
+		for _, elem := range expr.Elems {
+			place(elem, src, base)
+		}
+	case *ast.UnaryOp:
+		span = &expr.Pos
+		place(expr.Target, src, base)
+	default:
+		return
+	}
+
+	// nodes reached twice are only moved once
+	if span.Start.IsValid() {
+		return
+	}
+	*span = src.Span(base+span.Start.Offset, base+span.End.Offset)
+}
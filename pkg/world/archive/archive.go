@@ -0,0 +1,263 @@
+// reads and writes built worlds so they can be shared and reloaded without
+// parsing the logic files again
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/world"
+	"sudonters/zootler/pkg/world/components"
+
+	"github.com/etc-sudonters/substrate/reiterate"
+	"github.com/etc-sudonters/substrate/skelly/graph"
+)
+
+// bumped whenever the document shape changes
+const Version = 2
+
+var ErrUnsupportedVersion = errors.New("unsupported archive version")
+var ErrPoolNotEmpty = errors.New("archives can only be loaded into an empty pool")
+var ErrNoComponentTable = errors.New("world has no component table to archive")
+
+type document struct {
+	Version   int                `json:"version"`
+	Entities  []archivedEntity   `json:"entities"`
+	Relations []archivedRelation `json:"relations"`
+	Graph     archivedGraph      `json:"graph"`
+}
+
+type archivedEntity struct {
+	Model      entity.Model        `json:"model"`
+	Components []archivedComponent `json:"components"`
+}
+
+type archivedComponent struct {
+	Name  string          `json:"name"`
+	Value json.RawMessage `json:"value,omitempty"`
+}
+
+type archivedRelation struct {
+	Name   string          `json:"name"`
+	Source entity.Model    `json:"source"`
+	Target entity.Model    `json:"target"`
+	Value  json.RawMessage `json:"value,omitempty"`
+}
+
+type archivedGraph struct {
+	Nodes []graph.Node    `json:"nodes"`
+	Edges [][2]graph.Node `json:"edges"`
+}
+
+// entities and their components are written in a deterministic order so
+// archives of the same world are byte for byte identical
+func Save(w io.Writer, reg *Registry, wld world.World) error {
+	if wld.Components == nil {
+		return ErrNoComponentTable
+	}
+
+	views, err := wld.Entities.Query(entity.BuildFilter().Build())
+	if err != nil && !errors.Is(err, entity.ErrNoEntities) {
+		return err
+	}
+
+	models := make([]entity.Model, len(views))
+	for i := range views {
+		models[i] = views[i].Model()
+	}
+	sort.Slice(models, func(i, j int) bool { return models[i].Index() < models[j].Index() })
+
+	doc := document{Version: Version, Entities: make([]archivedEntity, 0, len(models))}
+	rows := reiterate.ToSlice(wld.Components.Rows())
+
+	for _, m := range models {
+		archived := archivedEntity{Model: m, Components: []archivedComponent{}}
+		for _, row := range rows {
+			c := row.Get(m)
+			if c == nil {
+				continue
+			}
+
+			name, value, err := reg.encode(c)
+			if err != nil {
+				return fmt.Errorf("archiving %s: %w", m, err)
+			}
+			if reg.derived[name] {
+				continue
+			}
+			archived.Components = append(archived.Components, archivedComponent{name, value})
+		}
+
+		sort.Slice(archived.Components, func(i, j int) bool {
+			return archived.Components[i].Name < archived.Components[j].Name
+		})
+		doc.Entities = append(doc.Entities, archived)
+	}
+
+	if doc.Relations, err = archiveRelations(reg, wld.Entities); err != nil {
+		return err
+	}
+	doc.Graph = archiveGraph(wld.Graph, models)
+
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	enc.SetEscapeHTML(false)
+	return enc.Encode(doc)
+}
+
+// every registered component is asked for its pairs since the pool doesn't
+// track which kinds of relations it holds
+func archiveRelations(reg *Registry, pool entity.Relatable) ([]archivedRelation, error) {
+	archived := []archivedRelation{}
+	for _, info := range reg.components.Components() {
+		for _, pair := range pool.Pairs(info.Type) {
+			name, value, err := reg.encode(pair.Value)
+			if err != nil {
+				return nil, fmt.Errorf("archiving relation %s -> %s: %w", pair.Source, pair.Target, err)
+			}
+			archived = append(archived, archivedRelation{name, pair.Source, pair.Target, value})
+		}
+	}
+
+	sort.SliceStable(archived, func(i, j int) bool { return archived[i].Name < archived[j].Name })
+	return archived, nil
+}
+
+// Directed can't enumerate its nodes so every entity is asked for its
+// successors instead
+func archiveGraph(g graph.Directed, models []entity.Model) archivedGraph {
+	archived := archivedGraph{Nodes: []graph.Node{}, Edges: [][2]graph.Node{}}
+	for _, m := range models {
+		node := graph.Node(m)
+		successors, err := g.Successors(node)
+		if err != nil {
+			continue
+		}
+
+		archived.Nodes = append(archived.Nodes, node)
+		for _, dest := range successors {
+			archived.Edges = append(archived.Edges, [2]graph.Node{node, graph.Node(dest)})
+		}
+	}
+	return archived
+}
+
+// populates the builder's empty pool and graph with the archived world,
+// entities keep the models they were archived with
+func Load(r io.Reader, reg *Registry, b *world.Builder) (world.World, error) {
+	var doc document
+	if err := json.NewDecoder(r).Decode(&doc); err != nil {
+		return world.World{}, fmt.Errorf("reading archive: %w", err)
+	}
+
+	if doc.Version != Version {
+		return world.World{}, fmt.Errorf("%w: %d", ErrUnsupportedVersion, doc.Version)
+	}
+
+	sort.Slice(doc.Entities, func(i, j int) bool {
+		return doc.Entities[i].Model.Index() < doc.Entities[j].Model.Index()
+	})
+	views, err := materialize(b.Pool, doc.Entities)
+	if err != nil {
+		return world.World{}, err
+	}
+
+	for i, archived := range doc.Entities {
+		view := views[i]
+		var name components.Name
+		var worldId components.WorldId
+		for _, ac := range archived.Components {
+			if reg.derived[ac.Name] {
+				continue
+			}
+			c, err := reg.decode(ac.Name, ac.Value)
+			if err != nil {
+				return world.World{}, fmt.Errorf("loading %s: %w", archived.Model, err)
+			}
+			if err := view.Add(c); err != nil {
+				return world.World{}, fmt.Errorf("loading %s: %w", archived.Model, err)
+			}
+			switch c := c.(type) {
+			case components.Name:
+				name = c
+			case components.WorldId:
+				worldId = c
+			}
+		}
+
+		if name != "" {
+			b.Names(worldId)[name] = view
+		}
+	}
+
+	for _, ar := range doc.Relations {
+		r, err := reg.decode(ar.Name, ar.Value)
+		if err != nil {
+			return world.World{}, fmt.Errorf("loading relation %s -> %s: %w", ar.Source, ar.Target, err)
+		}
+		if err := b.Pool.Relate(ar.Source, ar.Target, r); err != nil {
+			return world.World{}, fmt.Errorf("loading relation %s -> %s: %w", ar.Source, ar.Target, err)
+		}
+	}
+
+	for _, node := range doc.Graph.Nodes {
+		b.Graph.AddNode(node)
+	}
+
+	for _, edge := range doc.Graph.Edges {
+		if err := b.Graph.AddEdge(graph.Origination(edge[0]), graph.Destination(edge[1])); err != nil {
+			return world.World{}, fmt.Errorf("loading edge %s -> %s: %w", edge[0], edge[1], err)
+		}
+	}
+
+	return b.Build(), nil
+}
+
+// recreates the archived models in order, slots that were vacant when the
+// world was archived are created and then destroyed so ids line up
+func materialize(pool world.WorldPool, archived []archivedEntity) ([]entity.View, error) {
+	views := make([]entity.View, len(archived))
+	var vacant []entity.Model
+
+	for i := range archived {
+		want := archived[i].Model
+		for {
+			view, err := pool.Pool.Create()
+			if err != nil {
+				return nil, err
+			}
+
+			got := view.Model()
+			if got == want {
+				views[i] = view
+				break
+			}
+
+			if got.Index() > want.Index() || (got.Index() == want.Index() && got.Generation() > want.Generation()) {
+				return nil, fmt.Errorf("%w: %s is not available", ErrPoolNotEmpty, want)
+			}
+
+			if got.Index() < want.Index() {
+				vacant = append(vacant, got)
+				continue
+			}
+
+			// advance the slot's generation until it matches
+			if err := pool.Destroy(got); err != nil {
+				return nil, err
+			}
+		}
+	}
+
+	for _, m := range vacant {
+		if err := pool.Destroy(m); err != nil {
+			return nil, err
+		}
+	}
+
+	return views, nil
+}
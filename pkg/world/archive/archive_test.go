@@ -0,0 +1,311 @@
+package archive
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/internal/entity/bitpool"
+	"sudonters/zootler/internal/entity/componenttable"
+	"sudonters/zootler/pkg/filler"
+	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/logic/compiler"
+	"sudonters/zootler/pkg/logic/loader"
+	"sudonters/zootler/pkg/rules/ast"
+	"sudonters/zootler/pkg/world"
+	"sudonters/zootler/pkg/world/components"
+
+	"github.com/etc-sudonters/substrate/skelly/graph"
+)
+
+var update = flag.Bool("update", false, "rewrite golden files")
+
+func buildSmallWorld(t *testing.T) *world.Builder {
+	t.Helper()
+	b := world.MustDefaultBuilder()
+
+	must := func(v entity.View, err error) entity.View {
+		t.Helper()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return v
+	}
+
+	// the forest recycles this slot
+	scratch := must(b.Pool.Create("scratch"))
+	if err := b.Pool.Destroy(scratch.Model()); err != nil {
+		t.Fatal(err)
+	}
+
+	forest := must(b.Entity("Kokiri Forest"))
+	woods := must(b.Entity("Lost Woods"))
+	forest.Add(components.KokiriForest{})
+	woods.Add(components.LostWoods{})
+	b.Node(forest)
+	b.Node(woods)
+
+	edge := must(b.Edge(forest, woods))
+	edge.Add(logic.RawRule("is_child and has('Kokiri Sword')"))
+	edge.Add(logic.ParsedRule{R: &ast.BoolOp{
+		Left: &ast.Identifier{Value: "is_child"},
+		Op:   ast.BoolOpAnd,
+		Right: &ast.Call{
+			Callee: &ast.Identifier{Value: "has"},
+			Args:   []ast.Expression{&ast.Literal{Kind: ast.LiteralStr, Value: "Kokiri Sword"}},
+		},
+	}})
+
+	sword := must(b.Entity("Kokiri Sword"))
+	if err := (components.TokenArchetype{Registry: b.Registry}).Apply(sword); err != nil {
+		t.Fatal(err)
+	}
+	sword.Add(components.Price(40))
+	if err := entity.Relate[components.Inhabits](b.Pool, sword.Model(), forest.Model()); err != nil {
+		t.Fatal(err)
+	}
+
+	// leaves a vacant slot behind
+	gap := must(b.Pool.Create("gap"))
+	song := must(b.Entity("Sarias Song"))
+	song.Add(components.Song{Notes: []components.OcarinaButton{components.OcarinaD, components.OcarinaR, components.OcarinaL}})
+
+	if err := b.Pool.Destroy(gap.Model()); err != nil {
+		t.Fatal(err)
+	}
+
+	return b
+}
+
+func defaultRegistry(t *testing.T, components *componenttable.ComponentRegistry) *Registry {
+	t.Helper()
+	reg, err := Default(components)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return reg
+}
+
+func save(t *testing.T, w world.World, reg *Registry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	if err := Save(&buf, reg, w); err != nil {
+		t.Fatalf("could not save world: %s", err)
+	}
+	return buf.Bytes()
+}
+
+func TestGolden(t *testing.T) {
+	b := buildSmallWorld(t)
+	saved := save(t, b.Build(), defaultRegistry(t, b.Registry))
+
+	golden := filepath.Join("testdata", "small_world.golden.json")
+	if *update {
+		if err := os.WriteFile(golden, saved, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatalf("could not read golden file, run with -update to create it: %s", err)
+	}
+
+	if !bytes.Equal(expected, saved) {
+		t.Fatalf("archive differs from %s, run with -update if this is intended:\n%s", golden, saved)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	original := buildSmallWorld(t)
+	saved := save(t, original.Build(), defaultRegistry(t, original.Registry))
+
+	b := world.MustDefaultBuilder()
+	reg := defaultRegistry(t, b.Registry)
+	loaded, err := Load(bytes.NewReader(saved), reg, b)
+	if err != nil {
+		t.Fatalf("could not load world: %s", err)
+	}
+
+	if resaved := save(t, loaded, reg); !bytes.Equal(saved, resaved) {
+		t.Logf("original:\n%s", saved)
+		t.Logf("reloaded:\n%s", resaved)
+		t.Fatal("world changed after a round trip")
+	}
+
+	forest, ok := b.NameCache["Kokiri Forest"]
+	if !ok {
+		t.Fatal("expected loaded names to be cached")
+	}
+	woods := b.NameCache["Lost Woods"]
+
+	edge, err := loaded.Edge(world.Edge{Origination: forest.Model(), Destination: woods.Model()})
+	if err != nil {
+		t.Fatalf("expected edge to survive round trip: %s", err)
+	}
+
+	rule, err := entity.GetComponent[logic.ParsedRule](edge)
+	if err != nil {
+		t.Fatalf("expected parsed rule to survive round trip: %s", err)
+	}
+	if call, ok := rule.R.(*ast.BoolOp).Right.(*ast.Call); !ok || call.Args[0].(*ast.Literal).Value != "Kokiri Sword" {
+		t.Fatalf("parsed rule did not survive round trip: %#v", rule.R)
+	}
+
+	sword := b.NameCache["Kokiri Sword"]
+	if placed := entity.Targets[components.Inhabits](b.Pool, sword.Model()); len(placed) != 1 || placed[0] != forest.Model() {
+		t.Fatalf("expected placement to survive round trip: %v", placed)
+	}
+	typ, err := b.Registry.TypedString("Kokiri_Sword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := sword.(entity.DirectView).Component(typ); err != nil {
+		t.Fatalf("expected typed string component to be restored: %s", err)
+	}
+}
+
+func TestUnregisteredComponents(t *testing.T) {
+	type unregistered struct{ V int }
+	// the default table refuses unregistered components outright
+	tbl := componenttable.New(16)
+	b := world.NewBuilder(bitpool.FromTable(tbl, 16), tbl)
+	w := b.Build()
+	v, err := w.Entities.Create("odd one out")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Add(unregistered{})
+
+	err = Save(&bytes.Buffer{}, defaultRegistry(t, world.MustDefaultComponents()), w)
+	if !errors.Is(err, ErrUnregisteredComponent) {
+		t.Fatalf("expected %s but got %v", ErrUnregisteredComponent, err)
+	}
+}
+
+func TestRejectsUnknownVersion(t *testing.T) {
+	b := world.MustDefaultBuilder()
+	_, err := Load(strings.NewReader(`{"version": 99}`), defaultRegistry(t, b.Registry), b)
+	if !errors.Is(err, ErrUnsupportedVersion) {
+		t.Fatalf("expected %s but got %v", ErrUnsupportedVersion, err)
+	}
+}
+
+// pointer components are registered as their own type and must come back as
+// pointers rather than the values they point at
+func TestPointerComponentsRoundTrip(t *testing.T) {
+	builder := func() *world.Builder {
+		reg := world.MustDefaultComponents()
+		componenttable.MustRegister[*components.Song](reg, "*components.Song")
+		tbl := componenttable.MustFromRegistry(world.DefaultLimits.MaxEntityId, reg)
+		return world.NewBuilder(bitpool.FromTable(tbl, world.DefaultLimits.MaxComponentId), tbl)
+	}
+
+	original := builder()
+	song, err := original.Entity("Sarias Song")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := song.Add(&components.Song{Notes: []components.OcarinaButton{components.OcarinaD, components.OcarinaR, components.OcarinaL}}); err != nil {
+		t.Fatal(err)
+	}
+	saved := save(t, original.Build(), defaultRegistry(t, original.Registry))
+
+	b := builder()
+	if _, err := Load(bytes.NewReader(saved), defaultRegistry(t, b.Registry), b); err != nil {
+		t.Fatalf("could not load world: %s", err)
+	}
+
+	loaded := b.NameCache["Sarias Song"]
+	if _, err := entity.GetComponent[components.Song](loaded); err == nil {
+		t.Fatal("expected the song to be loaded as a pointer not a value")
+	}
+	notes, err := entity.GetComponent[*components.Song](loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(notes.Notes) != 3 || notes.Notes[2] != components.OcarinaL {
+		t.Fatalf("expected the song's notes to survive but got %+v", notes)
+	}
+}
+
+func TestCodecsNeedRegisteredNames(t *testing.T) {
+	reg := NewRegistry(world.MustDefaultComponents())
+	if err := reg.UseCodec("logic.NotAComponent", parsedRuleCodec); !errors.Is(err, ErrUnknownComponentName) {
+		t.Fatalf("expected %s but got %v", ErrUnknownComponentName, err)
+	}
+}
+
+// compiled rules aren't archived, a loaded world has to compile its rules
+// again and the edge they guard has to stay closed until then and after
+func TestCompiledRulesStayClosed(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "Overworld.json"), []byte(`[
+		{"region_name": "Root", "exits": {"Kokiri Forest": "True"}},
+		{"region_name": "Kokiri Forest", "exits": {"Deku Tree Lobby": "Kokiri_Sword"}},
+		{"region_name": "Deku Tree Lobby"}
+	]`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	l, err := loader.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	compile := func(b *world.Builder) {
+		t.Helper()
+		env, rw, err := compiler.NewEnvironment(b, l.Helpers)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { compiler.Release(env) })
+		if err := compiler.CompileWorld(b, env, rw); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	original := world.MustDefaultBuilder()
+	if err := original.LoadLogic(l, nil); err != nil {
+		t.Fatal(err)
+	}
+	compile(original)
+	saved := save(t, original.Build(), defaultRegistry(t, original.Registry))
+	if bytes.Contains(saved, []byte("logic.CompiledRule")) {
+		t.Fatal("expected compiled rules to be left out of the archive")
+	}
+
+	b := world.MustDefaultBuilder()
+	loaded, err := Load(bytes.NewReader(saved), defaultRegistry(t, b.Registry), b)
+	if err != nil {
+		t.Fatalf("could not load world: %s", err)
+	}
+
+	edge, err := loaded.Edge(world.Edge{
+		Origination: b.NameCache["Kokiri Forest"].Model(),
+		Destination: b.NameCache["Deku Tree Lobby"].Model(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := entity.GetComponent[logic.CompiledRule](edge); !errors.Is(err, entity.ErrNotAssigned) {
+		t.Fatalf("expected the edge to wait to be compiled again but got %v", err)
+	}
+
+	compile(b)
+	nodes, err := filler.FindReachableWorld(context.Background(), &loaded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !nodes.Exists(graph.Node(b.NameCache["Kokiri Forest"].Model())) {
+		t.Fatal("expected the forest to be reachable from the root")
+	}
+	if nodes.Exists(graph.Node(b.NameCache["Deku Tree Lobby"].Model())) {
+		t.Fatal("expected the deku tree to stay closed without the sword")
+	}
+}
@@ -0,0 +1,38 @@
+package archive
+
+import (
+	"encoding/json"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/internal/entity/componenttable"
+	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/rules/ast"
+)
+
+// archives the components registered by world.DefaultComponents, parsed
+// rules are interfaces so encoding/json can't read them back on its own.
+// Compiled rules close over the environment they were compiled in so they're
+// compiled again from the rules as written after loading
+func Default(components *componenttable.ComponentRegistry) (*Registry, error) {
+	reg := NewRegistry(components)
+	if err := reg.UseCodec("logic.ParsedRule", parsedRuleCodec); err != nil {
+		return nil, err
+	}
+	if err := reg.Derived("logic.CompiledRule"); err != nil {
+		return nil, err
+	}
+	return reg, nil
+}
+
+var parsedRuleCodec = Codec{
+	Encode: func(c entity.Component) (json.RawMessage, error) {
+		return ast.MarshalExpression(c.(logic.ParsedRule).R)
+	},
+	Decode: func(data json.RawMessage) (entity.Component, error) {
+		expr, err := ast.UnmarshalExpression(data)
+		if err != nil {
+			return nil, err
+		}
+		return logic.ParsedRule{R: expr}, nil
+	},
+}
@@ -0,0 +1,125 @@
+package archive
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/internal/entity/componenttable"
+)
+
+var ErrUnregisteredComponent = componenttable.ErrUnregisteredComponent
+var ErrUnknownComponentName = errors.New("component name is not registered")
+
+// translates a component to and from its archived form, a nil encoding is
+// written for components that carry no data
+type Codec struct {
+	Encode func(entity.Component) (json.RawMessage, error)
+	Decode func(json.RawMessage) (entity.Component, error)
+}
+
+// archives components under the names they were registered with, data
+// components use encoding/json unless given a codec
+type Registry struct {
+	components *componenttable.ComponentRegistry
+	codecs     map[string]Codec
+	derived    map[string]bool
+}
+
+func NewRegistry(components *componenttable.ComponentRegistry) *Registry {
+	return &Registry{
+		components: components,
+		codecs:     make(map[string]Codec),
+		derived:    make(map[string]bool),
+	}
+}
+
+// the named component is computed from other components and left out of
+// archives, whoever loads the archive derives it again
+func (r *Registry) Derived(name string) error {
+	if _, ok := r.components.Lookup(name); !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownComponentName, name)
+	}
+	r.derived[name] = true
+	return nil
+}
+
+// overrides how the named component is archived, the name must be
+// registered since the codec could never be used otherwise
+func (r *Registry) UseCodec(name string, codec Codec) error {
+	if _, ok := r.components.Lookup(name); !ok {
+		return fmt.Errorf("%w: %q", ErrUnknownComponentName, name)
+	}
+	r.codecs[name] = codec
+	return nil
+}
+
+func (r *Registry) NameOf(typ reflect.Type) (string, error) {
+	info, err := r.components.Info(typ)
+	if err != nil {
+		return "", err
+	}
+	return info.Name, nil
+}
+
+// the name and archived form of the component
+func (r *Registry) Encode(c entity.Component) (string, json.RawMessage, error) {
+	return r.encode(c)
+}
+
+func (r *Registry) encode(c entity.Component) (string, json.RawMessage, error) {
+	info, err := r.components.Info(entity.PierceComponentType(c))
+	if err != nil {
+		return "", nil, err
+	}
+
+	if codec, ok := r.codecs[info.Name]; ok {
+		encoded, err := codec.Encode(c)
+		if err != nil {
+			return "", nil, fmt.Errorf("encoding %s: %w", info.Name, err)
+		}
+		return info.Name, encoded, nil
+	}
+
+	if info.Kind == componenttable.TagComponent {
+		return info.Name, nil, nil
+	}
+
+	encoded, err := json.Marshal(c)
+	if err != nil {
+		return "", nil, fmt.Errorf("encoding %s: %w", info.Name, err)
+	}
+	return info.Name, encoded, nil
+}
+
+func (r *Registry) decode(name string, data json.RawMessage) (entity.Component, error) {
+	if codec, ok := r.codecs[name]; ok {
+		c, err := codec.Decode(data)
+		if err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", name, err)
+		}
+		return c, nil
+	}
+
+	// typed strings are registered as they're discovered so the archive may
+	// be the first place this one is seen
+	if literal, ok := strings.CutPrefix(name, componenttable.TypedStringPrefix); ok {
+		return r.components.TypedInstance(literal)
+	}
+
+	info, ok := r.components.Lookup(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnknownComponentName, name)
+	}
+
+	value := reflect.New(info.Type)
+	if info.Kind == componenttable.DataComponent && len(data) != 0 {
+		if err := json.Unmarshal(data, value.Interface()); err != nil {
+			return nil, fmt.Errorf("decoding %s: %w", name, err)
+		}
+	}
+	return value.Elem().Interface(), nil
+}
@@ -9,7 +9,6 @@ import (
 	"sudonters/zootler/internal/entity/componenttable"
 	"sudonters/zootler/pkg/world/components"
 
-	"github.com/etc-sudonters/substrate/mirrors"
 	"github.com/etc-sudonters/substrate/reiterate"
 	"github.com/etc-sudonters/substrate/skelly/graph"
 )
@@ -20,35 +19,97 @@ var ErrUntrackedGraphChange = errors.New("world graph has untracked changes")
 type FromName string
 type ToName string
 
+// names are only unique within a player world, NameCache holds the names
+// for World and ForWorld hands out builders for the other worlds. Settings
+// holds the settings decided while building that override the defaults
 type Builder struct {
 	Pool       WorldPool
 	Graph      graph.Builder
 	NameCache  map[components.Name]entity.View
-	TypedStrs  mirrors.TypedStrings
+	Registry   *componenttable.ComponentRegistry
 	Components *componenttable.Table
+	World      components.WorldId
+	Settings   map[string]any
+	worlds     map[components.WorldId]map[components.Name]entity.View
 }
 
-func DefaultBuilder() *Builder {
-	tbl := componenttable.New(10000)
-	pool := bitpool.FromTable(tbl, 600)
-	return NewBuilder(pool, tbl)
+// the maxima DefaultBuilder sizes its table and pool with, zootler's stats
+// report warns when a world outgrows them
+var DefaultLimits = bitpool.Settings{MaxComponentId: 600, MaxEntityId: 10000}
+
+func DefaultBuilder() (*Builder, error) {
+	return LimitedBuilder(DefaultLimits)
+}
+
+// like DefaultBuilder but panics, for tests and tools that can't go on
+// without a world anyways
+func MustDefaultBuilder() *Builder {
+	b, err := DefaultBuilder()
+	if err != nil {
+		panic(err)
+	}
+	return b
 }
 
+// a builder whose table and pool are sized by limits and whose table
+// accepts the default components
+func LimitedBuilder(limits bitpool.Settings) (*Builder, error) {
+	reg, err := DefaultComponents()
+	if err != nil {
+		return nil, err
+	}
+	tbl, err := componenttable.FromRegistry(limits.MaxEntityId, reg)
+	if err != nil {
+		return nil, err
+	}
+	pool := bitpool.FromTable(tbl, limits.MaxComponentId)
+	return NewBuilder(pool, tbl), nil
+}
+
+// tables that weren't built from a registry get their own so typed strings
+// are still handed out consistently
 func NewBuilder(pool entity.Pool, tbl *componenttable.Table) *Builder {
+	registry := tbl.Registry()
+	if registry == nil {
+		registry = componenttable.NewRegistry()
+	}
+
+	names := make(map[components.Name]entity.View, 128)
 	return &Builder{
 		Pool:       WorldPool{pool},
 		Graph:      graph.Builder{G: graph.New()},
-		NameCache:  make(map[components.Name]entity.View, 128),
-		TypedStrs:  mirrors.NewTypedStrings(),
+		NameCache:  names,
+		Registry:   registry,
 		Components: tbl,
+		worlds:     map[components.WorldId]map[components.Name]entity.View{0: names},
+	}
+}
+
+// a builder for another player world, the pool, graph and registry are
+// shared with this builder
+func (w *Builder) ForWorld(id components.WorldId) *Builder {
+	scoped := *w
+	scoped.World = id
+	scoped.NameCache = w.Names(id)
+	return &scoped
+}
+
+// the names cached for a player world
+func (w *Builder) Names(id components.WorldId) map[components.Name]entity.View {
+	names, ok := w.worlds[id]
+	if !ok {
+		names = make(map[components.Name]entity.View, 128)
+		w.worlds[id] = names
 	}
+	return names
 }
 
 // after calling this it is no longer safe to interact with the builder
 func (w *Builder) Build() World {
 	return World{
-		Entities: w.Pool,
-		Graph:    w.Graph.G,
+		Entities:   w.Pool,
+		Graph:      w.Graph.G,
+		Components: w.Components,
 	}
 }
 
@@ -62,6 +123,9 @@ func (w *Builder) Entity(n components.Name) (entity.View, error) {
 	if err != nil {
 		return nil, err
 	}
+	if err := ent.Add(w.World); err != nil {
+		return nil, err
+	}
 
 	w.NameCache[n] = ent
 	return ent, nil
@@ -71,6 +135,9 @@ func (w *Builder) Node(v entity.View) {
 	w.Graph.AddNode(graph.Node(v.Model()))
 }
 
+// the edge's entity is created the first time the pair is connected, the
+// graph and the pool's connections must agree about which pairs are
+// connected or ErrUntrackedGraphChange is returned
 func (w *Builder) Edge(origin, destination entity.View) (entity.View, error) {
 	// we require that origin exist in the graph already rather than just adding it
 	successors, err := w.Graph.G.Successors(graph.Node(origin.Model()))
@@ -81,41 +148,41 @@ func (w *Builder) Edge(origin, destination entity.View) (entity.View, error) {
 		return nil, err
 	}
 
-	var conns Connections
-
-	if err := origin.Get(&conns); err != nil {
-		if errors.Is(err, entity.ErrNotLoaded) || errors.Is(err, entity.ErrNotAssigned) {
-			conns = make(Connections, 4)
-			origin.Add(conns)
-		} else {
-			return nil, err
-		}
+	edgeName, fromName, toName, err := namesForEdge(origin, destination)
+	if err != nil {
+		return nil, err
 	}
 
+	edgeId, relErr := entity.GetRelation[Connection](w.Pool, origin.Model(), destination.Model())
 	if reiterate.Contains(graph.Destination(destination.Model()), successors) {
-		edgeId, ok := conns[destination.Model()]
-		if !ok {
-			panic(ErrUntrackedGraphChange)
+		if relErr != nil {
+			return nil, fmt.Errorf("%w: %q is in the graph but not connected", ErrUntrackedGraphChange, edgeName)
 		}
 
-		edge, err := w.Pool.Fetch(edgeId)
+		edge, err := w.Pool.Fetch(entity.Model(edgeId))
 		if err != nil {
-			panic(err)
+			return nil, fmt.Errorf("fetching %q: %w", edgeName, err)
 		}
 		return edge, nil
 	}
 
-	edgeName, fromName, toName := namesForEdge(origin, destination)
+	if relErr == nil {
+		return nil, fmt.Errorf("%w: %q is connected but not in the graph", ErrUntrackedGraphChange, edgeName)
+	}
+
 	edge, err := w.Pool.Create(edgeName)
 	if err != nil {
-		panic(err)
+		return nil, err
 	}
 
-	conns[destination.Model()] = edge.Model()
+	if err := entity.RelateWith(w.Pool, origin.Model(), destination.Model(), Connection(edge.Model())); err != nil {
+		return nil, fmt.Errorf("connecting %q: %w", edgeName, err)
+	}
 	if err := w.Graph.AddEdge(graph.Origination(origin.Model()), graph.Destination(destination.Model())); err != nil {
-		panic(err)
+		return nil, fmt.Errorf("adding %q to the graph: %w", edgeName, err)
 	}
 	archetype := edgeArchetype{
+		world:           w.World,
 		origination:     origin.Model(),
 		destination:     destination.Model(),
 		originationName: fromName,
@@ -123,13 +190,14 @@ func (w *Builder) Edge(origin, destination entity.View) (entity.View, error) {
 	}
 
 	if err := archetype.Apply(edge); err != nil {
-		panic(err)
+		return nil, fmt.Errorf("building %q: %w", edgeName, err)
 	}
 
 	return edge, nil
 }
 
 type edgeArchetype struct {
+	world           components.WorldId
 	origination     entity.Model
 	originationName FromName
 	destination     entity.Model
@@ -143,6 +211,9 @@ func (e edgeArchetype) Apply(entity entity.View) error {
 	}); err != nil {
 		return err
 	}
+	if err := entity.Add(e.world); err != nil {
+		return err
+	}
 	if err := entity.Add(e.originationName); err != nil {
 		return err
 	}
@@ -152,16 +223,15 @@ func (e edgeArchetype) Apply(entity entity.View) error {
 	return nil
 }
 
-func namesForEdge(origin, destination entity.View) (components.Name, FromName, ToName) {
-	var from components.Name
-	var to components.Name
-
-	if err := origin.Get(&from); err != nil {
-		panic(err)
+func namesForEdge(origin, destination entity.View) (components.Name, FromName, ToName, error) {
+	from, err := entity.GetComponent[components.Name](origin)
+	if err != nil {
+		return "", "", "", fmt.Errorf("naming edge from %s: %w", origin.Model(), err)
 	}
-	if err := destination.Get(&to); err != nil {
-		panic(err)
+	to, err := entity.GetComponent[components.Name](destination)
+	if err != nil {
+		return "", "", "", fmt.Errorf("naming edge from %q to %s: %w", from, destination.Model(), err)
 	}
 
-	return components.Name(fmt.Sprintf("%s -> %s", from, to)), FromName(from), ToName(to)
+	return components.Name(fmt.Sprintf("%s -> %s", from, to)), FromName(from), ToName(to), nil
 }
@@ -0,0 +1,126 @@
+package world
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/world/components"
+	"sudonters/zootler/pkg/world/filter"
+
+	"github.com/etc-sudonters/substrate/skelly/graph"
+)
+
+func TestNamesAreScopedToWorlds(t *testing.T) {
+	b := MustDefaultBuilder()
+	second := b.ForWorld(1)
+
+	first, err := b.Entity("Kokiri Forest")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := second.Entity("Kokiri Forest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if first.Model() == other.Model() {
+		t.Fatal("expected each world to get its own entity for a shared name")
+	}
+
+	if again, _ := second.Entity("Kokiri Forest"); again.Model() != other.Model() {
+		t.Fatalf("expected %s to be reused but got %s", other.Model(), again.Model())
+	}
+
+	if cached := b.Names(1)["Kokiri Forest"]; cached.Model() != other.Model() {
+		t.Fatalf("expected world 1's names to be visible from the original builder")
+	}
+
+	if id, err := entity.GetComponent[components.WorldId](other); err != nil || id != 1 {
+		t.Fatalf("expected entity to belong to world 1: %v %v", id, err)
+	}
+
+	views, err := b.Pool.Query(entity.BuildFilter(filter.InWorld(1)).Build())
+	if err != nil || len(views) != 1 || views[0].Model() != other.Model() {
+		t.Fatalf("expected to find only world 1's forest: %v %v", views, err)
+	}
+}
+
+func TestPlaceAcrossWorlds(t *testing.T) {
+	b := MustDefaultBuilder()
+	sword, err := b.Entity("Kokiri Sword")
+	if err != nil {
+		t.Fatal(err)
+	}
+	chest, err := b.ForWorld(1).Entity("Mido Chest")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if err := entity.Relate[components.Inhabits](b.Pool, sword.Model(), chest.Model()); err != nil {
+		t.Fatalf("expected items to be placeable in another world: %s", err)
+	}
+
+	owners := entity.Sources[components.Inhabits](b.Pool, chest.Model())
+	if len(owners) != 1 || owners[0] != sword.Model() {
+		t.Fatalf("expected world 0's sword in world 1's chest but got %v", owners)
+	}
+}
+
+func connectable(t *testing.T, b *Builder, names ...components.Name) []entity.View {
+	views := make([]entity.View, len(names))
+	for i, name := range names {
+		view, err := b.Entity(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		b.Node(view)
+		views[i] = view
+	}
+	return views
+}
+
+func TestEdgeReportsUntrackedGraphChanges(t *testing.T) {
+	b := MustDefaultBuilder()
+	views := connectable(t, b, "Kokiri Forest", "Lost Woods", "Deku Tree")
+	forest, woods, tree := views[0], views[1], views[2]
+
+	if err := b.Graph.AddEdge(graph.Origination(forest.Model()), graph.Destination(woods.Model())); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := b.Edge(forest, woods)
+	if !errors.Is(err, ErrUntrackedGraphChange) {
+		t.Fatalf("expected %s but got %v", ErrUntrackedGraphChange, err)
+	}
+	if !strings.Contains(err.Error(), "Kokiri Forest -> Lost Woods") {
+		t.Fatalf("expected error to name the edge: %s", err)
+	}
+
+	if err := entity.RelateWith(b.Pool, forest.Model(), tree.Model(), Connection(woods.Model())); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := b.Edge(forest, tree); !errors.Is(err, ErrUntrackedGraphChange) {
+		t.Fatalf("expected %s but got %v", ErrUntrackedGraphChange, err)
+	}
+}
+
+func TestEdgeRequiresNamedEntities(t *testing.T) {
+	b := MustDefaultBuilder()
+	forest := connectable(t, b, "Kokiri Forest")[0]
+	nameless, err := b.Pool.Pool.Create()
+	if err != nil {
+		t.Fatal(err)
+	}
+	b.Node(nameless)
+
+	if _, err := b.Edge(forest, nameless); !errors.Is(err, entity.ErrNotAssigned) {
+		t.Fatalf("expected %s but got %v", entity.ErrNotAssigned, err)
+	}
+
+	if successors, _ := b.Graph.G.Successors(graph.Node(forest.Model())); len(successors) != 0 {
+		t.Fatalf("expected no edge to be added but found %v", successors)
+	}
+}
@@ -4,12 +4,11 @@ import (
 	"regexp"
 	"strings"
 	"sudonters/zootler/internal/entity"
-
-	"github.com/etc-sudonters/substrate/mirrors"
+	"sudonters/zootler/internal/entity/componenttable"
 )
 
 type TokenArchetype struct {
-	Strs mirrors.TypedStrings
+	Registry *componenttable.ComponentRegistry
 }
 type EventArchetype struct {
 	T TokenArchetype
@@ -22,8 +21,10 @@ func (t TokenArchetype) Apply(entity entity.View) error {
 		return err
 	}
 
-	escaped := EscapeName(name)
-	comp := t.Strs.InstanceOf(escaped)
+	comp, err := t.Registry.TypedInstance(EscapeName(name))
+	if err != nil {
+		return err
+	}
 	if err := entity.Add(comp); err != nil {
 		return err
 	}
@@ -36,9 +37,10 @@ func (t TokenArchetype) Apply(entity entity.View) error {
 }
 
 func (e EventArchetype) Apply(entity entity.View) error {
-	e.T.Apply(entity)
-	entity.Add(Event{})
-	return nil
+	if err := e.T.Apply(entity); err != nil {
+		return err
+	}
+	return entity.Add(Event{})
 }
 
 var _nameEscapeRe *regexp.Regexp = regexp.MustCompile(`['()\[\]-]`)
@@ -1,13 +1,14 @@
 package components
 
-import "sudonters/zootler/internal/entity"
-
 type (
 	Name      string
 	Collected struct{}
 	Trick     struct{}
 	Spawn     struct{}
-	Inhabited entity.Model
-	Inhabits  entity.Model
 	Locked    struct{}
+	// relates a placed item to the location it was placed at
+	Inhabits struct{}
+	// the player world an entity belongs to, single world seeds only have
+	// world 0
+	WorldId uint8
 )
@@ -31,10 +31,14 @@ func Location(b entity.FilterBuilder) entity.FilterBuilder {
 	return b.With(mirrors.TypeOf[components.Location]())
 }
 
-func Inhabits(b entity.FilterBuilder) entity.FilterBuilder {
-	return b.With(mirrors.TypeOf[components.Inhabits]())
+func InWorld(id components.WorldId) entity.FilterOption {
+	return entity.Matching(func(w components.WorldId) bool {
+		return w == id
+	})
 }
 
-func Inhabited(b entity.FilterBuilder) entity.FilterBuilder {
-	return b.With(mirrors.TypeOf[components.Inhabited]())
+func PricedUnder(limit float64) entity.FilterOption {
+	return entity.Matching(func(p components.Price) bool {
+		return float64(p) < limit
+	})
 }
@@ -0,0 +1,64 @@
+package filter
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// the region=...;name=...;kind=... syntax the command line tools accept,
+// region and name are regular expressions and kind is compared without
+// regard to case. Options that aren't provided match everything
+type Spec struct {
+	Region *regexp.Regexp
+	Name   *regexp.Regexp
+	Kind   string
+}
+
+func ParseSpec(raw string) (Spec, error) {
+	var spec Spec
+	if raw == "" {
+		return spec, nil
+	}
+
+	for _, option := range strings.Split(raw, ";") {
+		if option == "" {
+			continue
+		}
+
+		key, value, ok := strings.Cut(option, "=")
+		if !ok {
+			return spec, fmt.Errorf("filter option %q has no value", option)
+		}
+
+		var err error
+		switch strings.ToLower(key) {
+		case "region":
+			spec.Region, err = regexp.Compile(value)
+		case "name":
+			spec.Name, err = regexp.Compile(value)
+		case "kind":
+			spec.Kind = value
+		default:
+			return spec, fmt.Errorf("unknown filter option %q", key)
+		}
+
+		if err != nil {
+			return spec, fmt.Errorf("filter option %q: %w", key, err)
+		}
+	}
+
+	return spec, nil
+}
+
+func (s Spec) MatchRegion(region string) bool {
+	return s.Region == nil || s.Region.MatchString(region)
+}
+
+func (s Spec) MatchName(name string) bool {
+	return s.Name == nil || s.Name.MatchString(name)
+}
+
+func (s Spec) MatchKind(kind string) bool {
+	return s.Kind == "" || strings.EqualFold(s.Kind, kind)
+}
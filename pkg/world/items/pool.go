@@ -386,6 +386,17 @@ type rawtoken struct {
 	special  map[string]any
 }
 
+// every item's name and the kind of token it is, e.g. "Song" or "Event"
+func Kinds() map[string]string {
+	kinds := make(map[string]string, len(item_table))
+	for name, raw := range item_table {
+		if tok, ok := raw.(rawtoken); ok {
+			kinds[name] = tok.name
+		}
+	}
+	return kinds
+}
+
 var item_table = map[string]any{
 	"Bombs (5)":                            rawtoken{"Item", PriorityNormal, GI_BOMBS_5, map[string]any{"junk": 8}},
 	"Deku Nuts (5)":                        rawtoken{"Item", PriorityNormal, GI_DEKU_NUTS_5, map[string]any{"junk": 5}},
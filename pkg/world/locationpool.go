@@ -5,7 +5,9 @@ import (
 	"sudonters/zootler/pkg/world/settings"
 )
 
-type Connections map[entity.Model]entity.Model // destination -> edge
+// relates an origin to a destination, the value is the edge entity that
+// carries the rules for moving between them
+type Connection entity.Model
 
 type Edge struct {
 	Origination entity.Model
@@ -0,0 +1,161 @@
+package world
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/logic/loader"
+	"sudonters/zootler/pkg/world/components"
+	"sudonters/zootler/pkg/world/settings"
+)
+
+var ErrNoMasterQuest = errors.New("dungeon has no master quest logic")
+var ErrNoRng = errors.New("master quest mode needs an rng")
+
+// picks the dungeons that use their master quest logic, dungeons are the
+// ones that have master quest logic to pick from. rng is only used by the
+// count and random modes and must be provided for them
+func ChooseMasterQuest(mq settings.MasterQuestDungeons, dungeons []string, rng *rand.Rand) (map[string]bool, error) {
+	chosen := make(map[string]bool, len(dungeons))
+
+	switch mq.Mode {
+	case 0, settings.MasterQuestVanilla:
+		return chosen, nil
+	case settings.MasterQuestAll:
+		for _, dungeon := range dungeons {
+			chosen[dungeon] = true
+		}
+		return chosen, nil
+	case settings.MasterQuestSpecific:
+		available := make(map[string]bool, len(dungeons))
+		for _, dungeon := range dungeons {
+			available[dungeon] = true
+		}
+		for _, dungeon := range mq.Specific {
+			if !available[dungeon] {
+				return nil, fmt.Errorf("%w: %q", ErrNoMasterQuest, dungeon)
+			}
+			chosen[dungeon] = true
+		}
+		return chosen, nil
+	case settings.MasterQuestCount:
+		if rng == nil {
+			return nil, fmt.Errorf("%w: count", ErrNoRng)
+		}
+		if int(mq.Count) > len(dungeons) {
+			return nil, fmt.Errorf("%d master quest dungeons requested but only %d have master quest logic", mq.Count, len(dungeons))
+		}
+		return chooseN(int(mq.Count), dungeons, rng), nil
+	case settings.MasterQuestRandom:
+		if rng == nil {
+			return nil, fmt.Errorf("%w: random", ErrNoRng)
+		}
+		return chooseN(rng.Intn(len(dungeons)+1), dungeons, rng), nil
+	default:
+		return nil, fmt.Errorf("unknown master quest mode %d", mq.Mode)
+	}
+}
+
+func chooseN(n int, dungeons []string, rng *rand.Rand) map[string]bool {
+	chosen := make(map[string]bool, n)
+	for _, i := range rng.Perm(len(dungeons))[:n] {
+		chosen[dungeons[i]] = true
+	}
+	return chosen
+}
+
+// adds every region for the chosen quests as a node, regions are connected to
+// their exits, events and locations by edges that carry the rule as written.
+// Locations in a dungeon's own logic are tagged with the quest they're from
+// and the root region is where the world spawns. The quests chosen are
+// recorded in Settings for rules that read mq_dungeons_mode
+func (w *Builder) LoadLogic(l loader.Logic, mq map[string]bool) error {
+	chosen := 0
+	for _, isMq := range mq {
+		if isMq {
+			chosen++
+		}
+	}
+	if w.Settings == nil {
+		w.Settings = make(map[string]any)
+	}
+	for name, value := range settings.MasterQuestNames(chosen, len(l.MasterQuestDungeons())) {
+		w.Settings[name] = value
+	}
+
+	for _, region := range l.Select(mq).Regions {
+		if err := w.region(region); err != nil {
+			return fmt.Errorf("loading region %q: %w", region.Name, err)
+		}
+	}
+	return nil
+}
+
+func (w *Builder) region(region loader.Region) error {
+	origin, err := w.Entity(components.Name(region.Name))
+	if err != nil {
+		return err
+	}
+	w.Node(origin)
+
+	if region.Name == loader.Root {
+		if err := origin.Add(components.Spawn{}); err != nil {
+			return fmt.Errorf("spawning in %q: %w", region.Name, err)
+		}
+	}
+
+	var quest entity.Component
+	switch region.Quest {
+	case loader.VanillaQuest:
+		quest = components.VanillaDungeon{}
+	case loader.MasterQuest:
+		quest = components.MasterQuest{}
+	}
+
+	for _, rule := range region.Locations {
+		if err := w.ruled(origin, rule, components.Location{}, quest); err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range region.Events {
+		if err := w.ruled(origin, rule, components.Event{}); err != nil {
+			return err
+		}
+	}
+
+	for _, rule := range region.Exits {
+		if err := w.ruled(origin, rule); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// the rule's entity is connected to origin by an edge carrying its rule
+func (w *Builder) ruled(origin entity.View, rule loader.Rule, comps ...entity.Component) error {
+	target, err := w.Entity(components.Name(rule.Name))
+	if err != nil {
+		return err
+	}
+
+	for _, comp := range comps {
+		if comp == nil {
+			continue
+		}
+		if err := target.Add(comp); err != nil {
+			return fmt.Errorf("adding %T to %q: %w", comp, rule.Name, err)
+		}
+	}
+	w.Node(target)
+
+	edge, err := w.Edge(origin, target)
+	if err != nil {
+		return err
+	}
+	return edge.Add(logic.RawRule(rule.Text))
+}
@@ -0,0 +1,124 @@
+package world
+
+import (
+	"errors"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/logic/loader"
+	"sudonters/zootler/pkg/world/components"
+	"sudonters/zootler/pkg/world/settings"
+)
+
+var mqDungeons = []string{"Deku Tree", "Fire Temple", "Ice Cavern"}
+
+func TestChooseMasterQuest(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	for _, tc := range []struct {
+		name     string
+		settings settings.MasterQuestDungeons
+		count    int
+	}{
+		{"default", settings.MasterQuestDungeons{}, 0},
+		{"vanilla", settings.MasterQuestDungeons{Mode: settings.MasterQuestVanilla}, 0},
+		{"all", settings.MasterQuestDungeons{Mode: settings.MasterQuestAll}, 3},
+		{"specific", settings.MasterQuestDungeons{Mode: settings.MasterQuestSpecific, Specific: []string{"Ice Cavern"}}, 1},
+		{"count", settings.MasterQuestDungeons{Mode: settings.MasterQuestCount, Count: 2}, 2},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			chosen, err := ChooseMasterQuest(tc.settings, mqDungeons, rng)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if len(chosen) != tc.count {
+				t.Fatalf("expected %d master quest dungeons but chose %v", tc.count, chosen)
+			}
+		})
+	}
+
+	chosen, _ := ChooseMasterQuest(settings.MasterQuestDungeons{Mode: settings.MasterQuestSpecific, Specific: []string{"Ice Cavern"}}, mqDungeons, rng)
+	if !chosen["Ice Cavern"] {
+		t.Fatalf("expected the specific dungeon to be chosen but chose %v", chosen)
+	}
+}
+
+func TestChooseMasterQuestRejectsImpossibleSettings(t *testing.T) {
+	rng := rand.New(rand.NewSource(1))
+
+	_, err := ChooseMasterQuest(settings.MasterQuestDungeons{Mode: settings.MasterQuestSpecific, Specific: []string{"Water Temple"}}, mqDungeons, rng)
+	if !errors.Is(err, ErrNoMasterQuest) {
+		t.Fatalf("expected %s but got %v", ErrNoMasterQuest, err)
+	}
+
+	if _, err := ChooseMasterQuest(settings.MasterQuestDungeons{Mode: settings.MasterQuestCount, Count: 4}, mqDungeons, rng); err == nil {
+		t.Fatal("expected more dungeons than have master quest logic to be rejected")
+	}
+
+	for _, mode := range []settings.MasterQuestMode{settings.MasterQuestCount, settings.MasterQuestRandom} {
+		if _, err := ChooseMasterQuest(settings.MasterQuestDungeons{Mode: mode, Count: 1}, mqDungeons, nil); !errors.Is(err, ErrNoRng) {
+			t.Errorf("expected mode %d without an rng to fail with %s but got %v", mode, ErrNoRng, err)
+		}
+	}
+}
+
+func TestLoadLogicTagsQuests(t *testing.T) {
+	dir := t.TempDir()
+	for name, contents := range map[string]string{
+		"Overworld.json": `[{"region_name": "Kokiri Forest", "exits": {"Deku Tree Lobby": "is_child"}}]`,
+		"Deku Tree.json": `[{"region_name": "Deku Tree Lobby", "dungeon": "Deku Tree",
+			"locations": {"Deku Tree Map Chest": "True"}}]`,
+		"Deku Tree MQ.json": `[{"region_name": "Deku Tree Lobby", "dungeon": "Deku Tree",
+			"locations": {"Deku Tree MQ Map Chest": "True"}}]`,
+		"Bosses.json": `[{"region_name": "Queen Gohma Boss Room", "dungeon": "Deku Tree",
+			"locations": {"Queen Gohma": "True"}}]`,
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(contents), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	l, err := loader.Load(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	b := MustDefaultBuilder()
+	if err := b.LoadLogic(l, map[string]bool{"Deku Tree": true}); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, loaded := b.NameCache["Deku Tree Map Chest"]; loaded {
+		t.Fatal("expected vanilla locations to be left out")
+	}
+
+	chest := b.NameCache["Deku Tree MQ Map Chest"]
+	if _, err := entity.GetComponent[components.MasterQuest](chest); err != nil {
+		t.Fatalf("expected the master quest chest to be tagged: %s", err)
+	}
+
+	gohma := b.NameCache["Queen Gohma"]
+	if _, err := entity.GetComponent[components.VanillaDungeon](gohma); err == nil {
+		t.Fatal("expected locations shared by both quests to be untagged")
+	}
+
+	if mode := b.Settings["mq_dungeons_mode"]; mode != "mq" {
+		t.Fatalf("expected the chosen quests to set mq_dungeons_mode but found %v", mode)
+	}
+
+	w := b.Build()
+	edge, err := w.Edge(Edge{
+		Origination: b.NameCache["Kokiri Forest"].Model(),
+		Destination: b.NameCache["Deku Tree Lobby"].Model(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if rule, err := entity.GetComponent[logic.RawRule](edge); err != nil || rule != "is_child" {
+		t.Fatalf("expected the exit to carry its rule but found %q %v", rule, err)
+	}
+}
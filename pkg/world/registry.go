@@ -0,0 +1,154 @@
+package world
+
+import (
+	"errors"
+	"reflect"
+
+	"sudonters/zootler/internal/entity/componenttable"
+	"sudonters/zootler/pkg/logic"
+	"sudonters/zootler/pkg/world/components"
+
+	"github.com/etc-sudonters/substrate/mirrors"
+)
+
+// every component the builder attaches, names are written to archives and
+// must not change once released. Typed strings are registered as they're
+// discovered
+func DefaultComponents() (*componenttable.ComponentRegistry, error) {
+	r := componenttable.NewRegistry()
+	var errs []error
+	register := func(name string, typ reflect.Type) {
+		if _, err := r.RegisterType(name, typ); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	register("ActorOverride", mirrors.TypeOf[components.ActorOverride]())
+	register("Beehive", mirrors.TypeOf[components.Beehive]())
+	register("Boss", mirrors.TypeOf[components.Boss]())
+	register("BossHeart", mirrors.TypeOf[components.BossHeart]())
+	register("BossKey", mirrors.TypeOf[components.BossKey]())
+	register("Bottle", mirrors.TypeOf[components.Bottle]())
+	register("BottomOfTheWell", mirrors.TypeOf[components.BottomOfTheWell]())
+	register("Chest", mirrors.TypeOf[components.Chest]())
+	register("Collectable", mirrors.TypeOf[components.Collectable]())
+	register("Collected", mirrors.TypeOf[components.Collected]())
+	register("Compass", mirrors.TypeOf[components.Compass]())
+	register("Count", mirrors.TypeOf[components.Count]())
+	register("Cow", mirrors.TypeOf[components.Cow]())
+	register("Crate", mirrors.TypeOf[components.Crate]())
+	register("Cutscene", mirrors.TypeOf[components.Cutscene]())
+	register("DeathMountainCrater", mirrors.TypeOf[components.DeathMountainCrater]())
+	register("DeathMountainTrail", mirrors.TypeOf[components.DeathMountainTrail]())
+	register("DefaultItem", mirrors.TypeOf[components.DefaultItem]())
+	register("DekuScrub", mirrors.TypeOf[components.DekuScrub]())
+	register("DekuScrubUpgrade", mirrors.TypeOf[components.DekuScrubUpgrade]())
+	register("DekuTree", mirrors.TypeOf[components.DekuTree]())
+	register("DesertColossus", mirrors.TypeOf[components.DesertColossus]())
+	register("DodongosCavern", mirrors.TypeOf[components.DodongosCavern]())
+	register("Drop", mirrors.TypeOf[components.Drop]())
+	register("DungeonReward", mirrors.TypeOf[components.DungeonReward]())
+	register("Event", mirrors.TypeOf[components.Event]())
+	register("FireTemple", mirrors.TypeOf[components.FireTemple]())
+	register("Flying", mirrors.TypeOf[components.Flying]())
+	register("ForestArea", mirrors.TypeOf[components.ForestArea]())
+	register("ForestTemple", mirrors.TypeOf[components.ForestTemple]())
+	register("Freestanding", mirrors.TypeOf[components.Freestanding]())
+	register("GanonBossKey", mirrors.TypeOf[components.GanonBossKey]())
+	register("GanonsCastle", mirrors.TypeOf[components.GanonsCastle]())
+	register("GanonsTower", mirrors.TypeOf[components.GanonsTower]())
+	register("GerudoTrainingGround", mirrors.TypeOf[components.GerudoTrainingGround]())
+	register("GerudoValley", mirrors.TypeOf[components.GerudoValley]())
+	register("GerudosFortress", mirrors.TypeOf[components.GerudosFortress]())
+	register("GoldSkulltula", mirrors.TypeOf[components.GoldSkulltula]())
+	register("GoldSkulltulaToken", mirrors.TypeOf[components.GoldSkulltulaToken]())
+	register("GoronCity", mirrors.TypeOf[components.GoronCity]())
+	register("Graveyard", mirrors.TypeOf[components.Graveyard]())
+	register("GreatFairie", mirrors.TypeOf[components.GreatFairie]())
+	register("Grotto", mirrors.TypeOf[components.Grotto]())
+	register("GrottoScrub", mirrors.TypeOf[components.GrottoScrub]())
+	register("HauntedWasteland", mirrors.TypeOf[components.HauntedWasteland]())
+	register("HideoutSmallKey", mirrors.TypeOf[components.HideoutSmallKey]())
+	register("Hint", mirrors.TypeOf[components.Hint]())
+	register("HintStone", mirrors.TypeOf[components.HintStone]())
+	register("HyruleCastle", mirrors.TypeOf[components.HyruleCastle]())
+	register("HyruleField", mirrors.TypeOf[components.HyruleField]())
+	register("IceCavern", mirrors.TypeOf[components.IceCavern]())
+	register("Inhabits", mirrors.TypeOf[components.Inhabits]())
+	register("Item", mirrors.TypeOf[components.Item]())
+	register("JabuJabusBelly", mirrors.TypeOf[components.JabuJabusBelly]())
+	register("Junk", mirrors.TypeOf[components.Junk]())
+	register("KakarikoVillage", mirrors.TypeOf[components.KakarikoVillage]())
+	register("KokiriForest", mirrors.TypeOf[components.KokiriForest]())
+	register("LakeHylia", mirrors.TypeOf[components.LakeHylia]())
+	register("Location", mirrors.TypeOf[components.Location]())
+	register("Locked", mirrors.TypeOf[components.Locked]())
+	register("LonLonRanch", mirrors.TypeOf[components.LonLonRanch]())
+	register("LostWoods", mirrors.TypeOf[components.LostWoods]())
+	register("Map", mirrors.TypeOf[components.Map]())
+	register("Market", mirrors.TypeOf[components.Market]())
+	register("MasterQuest", mirrors.TypeOf[components.MasterQuest]())
+	register("Medallion", mirrors.TypeOf[components.Medallion]())
+	register("Minigame", mirrors.TypeOf[components.Minigame]())
+	register("NPC", mirrors.TypeOf[components.NPC]())
+	register("Name", mirrors.TypeOf[components.Name]())
+	register("NeedSpiritualStones", mirrors.TypeOf[components.NeedSpiritualStones]())
+	register("OutsideGanonsCastle", mirrors.TypeOf[components.OutsideGanonsCastle]())
+	register("Placeable", mirrors.TypeOf[components.Placeable]())
+	register("Pot", mirrors.TypeOf[components.Pot]())
+	register("Price", mirrors.TypeOf[components.Price]())
+	register("RecoveryHeart", mirrors.TypeOf[components.RecoveryHeart]())
+	register("Refill", mirrors.TypeOf[components.Refill]())
+	register("RupeeTower", mirrors.TypeOf[components.RupeeTower]())
+	register("SacredForestMeadow", mirrors.TypeOf[components.SacredForestMeadow]())
+	register("Scrub", mirrors.TypeOf[components.Scrub]())
+	register("ShadowTemple", mirrors.TypeOf[components.ShadowTemple]())
+	register("Shop", mirrors.TypeOf[components.Shop]())
+	register("ShopObject", mirrors.TypeOf[components.ShopObject]())
+	register("SkulltulaHouse", mirrors.TypeOf[components.SkulltulaHouse]())
+	register("SmallCrate", mirrors.TypeOf[components.SmallCrate]())
+	register("SmallKey", mirrors.TypeOf[components.SmallKey]())
+	register("Song", mirrors.TypeOf[components.Song]())
+	register("Spawn", mirrors.TypeOf[components.Spawn]())
+	register("SpiritTemple", mirrors.TypeOf[components.SpiritTemple]())
+	register("SpiritualStone", mirrors.TypeOf[components.SpiritualStone]())
+	register("TempleofTime", mirrors.TypeOf[components.TempleofTime]())
+	register("ThievesHideout", mirrors.TypeOf[components.ThievesHideout]())
+	register("Token", mirrors.TypeOf[components.Token]())
+	register("Trade", mirrors.TypeOf[components.Trade]())
+	register("Trick", mirrors.TypeOf[components.Trick]())
+	register("VanillaDungeon", mirrors.TypeOf[components.VanillaDungeon]())
+	register("WaterTemple", mirrors.TypeOf[components.WaterTemple]())
+	register("WorldId", mirrors.TypeOf[components.WorldId]())
+	register("ZorasDomain", mirrors.TypeOf[components.ZorasDomain]())
+	register("ZorasFountain", mirrors.TypeOf[components.ZorasFountain]())
+	register("ZorasRiver", mirrors.TypeOf[components.ZorasRiver]())
+
+	register("world.Connection", mirrors.TypeOf[Connection]())
+	register("world.Edge", mirrors.TypeOf[Edge]())
+	register("world.FromName", mirrors.TypeOf[FromName]())
+	register("world.ToName", mirrors.TypeOf[ToName]())
+
+	register("logic.RawRule", mirrors.TypeOf[logic.RawRule]())
+	register("logic.ParsedRule", mirrors.TypeOf[logic.ParsedRule]())
+	register("logic.CompiledRule", mirrors.TypeOf[logic.CompiledRule]())
+
+	// every entity has these so there's nothing to gain from a sparse row,
+	// everything else starts sparse and densifies if it fills up
+	for _, name := range []string{"Name", "WorldId"} {
+		if err := r.UseStorage(name, componenttable.DenseStorage); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return r, errors.Join(errs...)
+}
+
+// like DefaultComponents but panics, the list is static so an error is a
+// mistake in it rather than something to recover from
+func MustDefaultComponents() *componenttable.ComponentRegistry {
+	r, err := DefaultComponents()
+	if err != nil {
+		panic(err)
+	}
+	return r
+}
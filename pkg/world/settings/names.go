@@ -0,0 +1,327 @@
+package settings
+
+import "fmt"
+
+// the value every setting rules read starts with, keyed by the name OOTR
+// gives the setting
+func Defaults() map[string]any {
+	return map[string]any{
+		"show_seed_info":                          true,
+		"user_message":                            "",
+		"world_count":                             1,
+		"create_spoiler":                          true,
+		"randomize_settings":                      false,
+		"logic_rules":                             "glitchless",
+		"reachable_locations":                     "all",
+		"triforce_hunt":                           false,
+		"lacs_condition":                          "vanilla",
+		"bridge":                                  "medallions",
+		"bridge_medallions":                       6,
+		"trials_random":                           false,
+		"trials":                                  0,
+		"shuffle_ganon_bosskey":                   "remove",
+		"shuffle_bosskeys":                        "dungeon",
+		"shuffle_smallkeys":                       "dungeon",
+		"shuffle_hideoutkeys":                     "vanilla",
+		"shuffle_tcgkeys":                         "vanilla",
+		"key_rings_choice":                        "off",
+		"shuffle_silver_rupees":                   "vanilla",
+		"shuffle_mapcompass":                      "startwith",
+		"enhance_map_compass":                     false,
+		"open_forest":                             "closed_deku",
+		"open_kakariko":                           "open",
+		"open_door_of_time":                       true,
+		"zora_fountain":                           "open",
+		"gerudo_fortress":                         "fast",
+		"dungeon_shortcuts_choice":                "off",
+		"starting_age":                            "adult",
+		"mq_dungeons_mode":                        "vanilla",
+		"mq_dungeons_count":                       0,
+		"empty_dungeons_mode":                     "none",
+		"shuffle_interior_entrances":              "off",
+		"shuffle_grotto_entrances":                false,
+		"shuffle_dungeon_entrances":               "off",
+		"shuffle_bosses":                          "off",
+		"shuffle_overworld_entrances":             false,
+		"shuffle_gerudo_valley_river_exit":        false,
+		"owl_drops":                               true,
+		"warp_songs":                              false,
+		"free_bombchu_drops":                      false,
+		"one_item_per_dungeon":                    false,
+		"shuffle_song_items":                      "song",
+		"shopsanity":                              "off",
+		"tokensanity":                             "off",
+		"shuffle_scrubs":                          "off",
+		"shuffle_freestanding_items":              "off",
+		"shuffle_pots":                            "off",
+		"shuffle_crates":                          "off",
+		"shuffle_cows":                            false,
+		"shuffle_beehives":                        false,
+		"shuffle_kokiri_sword":                    true,
+		"shuffle_ocarinas":                        false,
+		"shuffle_gerudo_card":                     false,
+		"shuffle_beans":                           false,
+		"shuffle_expensive_merchants":             false,
+		"shuffle_frog_song_rupees":                false,
+		"shuffle_individual_ocarina_notes":        true,
+		"shuffle_loach_reward":                    "off",
+		"logic_no_night_tokens_without_suns_song": false,
+		"start_with_consumables":                  true,
+		"start_with_rupees":                       false,
+		"starting_hearts":                         3,
+		"no_escape_sequence":                      true,
+		"no_guard_stealth":                        true,
+		"no_epona_race":                           true,
+		"skip_some_minigame_phases":               true,
+		"complete_mask_quest":                     false,
+		"useful_cutscenes":                        false,
+		"fast_chests":                             true,
+		"free_scarecrow":                          false,
+		"fast_bunny_hood":                         true,
+		"auto_equip_masks":                        false,
+		"plant_beans":                             false,
+		"chicken_count_random":                    false,
+		"chicken_count":                           7,
+		"big_poe_count_random":                    false,
+		"big_poe_count":                           1,
+		"easier_fire_arrow_entry":                 false,
+		"ruto_already_f1_jabu":                    false,
+		"ocarina_songs":                           "off",
+		"correct_chest_appearances":               "both",
+		"minor_items_as_major_chest":              false,
+		"invisible_chests":                        false,
+		"correct_potcrate_appearances":            "textures_content",
+		"key_appearance_match_dungeon":            false,
+		"clearer_hints":                           true,
+		"hints":                                   "always",
+		"hint_dist":                               "tournament",
+		"text_shuffle":                            "none",
+		"damage_multiplier":                       "normal",
+		"deadly_bonks":                            "none",
+		"no_collectible_hearts":                   false,
+		"starting_tod":                            "default",
+		"blue_fire_arrows":                        false,
+		"fix_broken_drops":                        false,
+		"item_pool_value":                         "balanced",
+		"junk_ice_traps":                          "off",
+		"ice_trap_appearance":                     "junk_only",
+		"adult_trade_shuffle":                     false,
+		"bridge_tokens":                           100,
+		"ganon_bosskey_tokens":                    999,
+		"lacs_tokens":                             999,
+		"bridge_stones":                           3,
+		"bridge_rewards":                          9,
+		"bridge_hearts":                           20,
+		"ganon_bosskey_stones":                    3,
+		"ganon_bosskey_medallions":                6,
+		"ganon_bosskey_rewards":                   9,
+		"ganon_bosskey_hearts":                    20,
+		"lacs_stones":                             3,
+		"lacs_medallions":                         6,
+		"lacs_rewards":                            9,
+		"lacs_hearts":                             20,
+	}
+}
+
+// values OOTR works out from other settings before rules read them
+var Derived = []string{
+	"disable_trade_revert",
+	"dungeon_shortcuts",
+	"entrance_shuffle",
+	"keysanity",
+	"skip_child_zelda",
+	"triforce_goal_per_world",
+}
+
+// every trick OOTR's logic checks, rules refer to them with a logic_ prefix
+var TrickNames = []string{
+	"adult_kokiri_gs_hovers",
+	"adult_kokiri_gs_nothing",
+	"beehives_bombchus",
+	"biggoron_bolero",
+	"boomerang_boulders",
+	"botw_basement",
+	"castle_storms_gs",
+	"child_dampe_race_poh",
+	"child_deadhand",
+	"child_rolling_with_strength",
+	"colossus_gs",
+	"crater_bean_poh_with_hovers",
+	"crater_bolero_jump",
+	"crater_boulder_jumpslash",
+	"crater_boulder_skip",
+	"dc_chu_eyes",
+	"dc_hammer_floor",
+	"dc_jump",
+	"dc_scarecrow_gs",
+	"dc_scrub_room",
+	"dc_slingshot_skip",
+	"dc_staircase",
+	"dc_vines_gs",
+	"deku_b1_skip",
+	"deku_b1_webs_with_bow",
+	"deku_basement_gs",
+	"dmt_bombable",
+	"dmt_climb_hovers",
+	"dmt_soil_gs",
+	"domain_gs",
+	"fewer_tunic_requirements",
+	"fire_boss_door_jump",
+	"fire_flame_maze",
+	"fire_scarecrow",
+	"fire_song_of_time",
+	"fire_strength",
+	"fire_trial_slug_rupee",
+	"forest_courtyard_hearts",
+	"forest_door_frame",
+	"forest_first_gs",
+	"forest_outdoor_east_gs",
+	"forest_outdoors_ledge",
+	"forest_outside_backdoor",
+	"forest_vines",
+	"gerudo_kitchen",
+	"gf_break_room_jump",
+	"gf_jump",
+	"goron_city_leftmost",
+	"goron_city_pot",
+	"goron_city_pot_with_strength",
+	"goron_grotto",
+	"graveyard_poh",
+	"grottos_without_agony",
+	"gtg_fake_wall",
+	"gtg_flame_wall",
+	"gtg_underwater_highest",
+	"gtg_without_hookshot",
+	"ice_block_gs",
+	"ice_frozen_pot",
+	"ice_frozen_rupee",
+	"jabu_alcove_jump_dive",
+	"jabu_boss_hover",
+	"jabu_near_boss_explosives",
+	"jabu_near_boss_ranged",
+	"kakariko_rooftop_gs",
+	"kakariko_tower_gs",
+	"king_zora_skip",
+	"lab_diving",
+	"lab_wall_gs",
+	"lens_bongo",
+	"lens_botw",
+	"lens_castle",
+	"lens_gtg",
+	"lens_shadow",
+	"lens_shadow_platform",
+	"lens_spirit",
+	"lens_wasteland",
+	"link_goron_dins",
+	"lost_woods_bridge",
+	"lost_woods_gs_bean",
+	"man_on_roof",
+	"mido_backflip",
+	"reverse_wasteland",
+	"rusted_switches",
+	"shadow_bongo",
+	"shadow_fire_arrow_entry",
+	"shadow_freestanding_key",
+	"shadow_statue",
+	"shadow_triple_pots",
+	"shadow_umbrella",
+	"shadow_umbrella_gs",
+	"spirit_adult_side_hovers",
+	"spirit_child_bombchu",
+	"spirit_fence_gs",
+	"spirit_lobby_gs",
+	"spirit_lobby_jump",
+	"spirit_lower_adult_switch",
+	"spirit_map_chest",
+	"spirit_platform_hookshot",
+	"spirit_sun_chest_bow",
+	"spirit_sun_chest_no_rupees",
+	"spirit_trial_hookshot",
+	"spirit_wall",
+	"trail_gs_lower",
+	"trail_gs_upper",
+	"valley_crate_hovers",
+	"visible_collisions",
+	"wasteland_crossing",
+	"water_bk_jump_dive",
+	"water_central_bow",
+	"water_central_gs_fw",
+	"water_central_gs_irons",
+	"water_cracked_wall_hovers",
+	"water_cracked_wall_nothing",
+	"water_dragon_adult",
+	"water_dragon_child",
+	"water_dragon_jump_dive",
+	"water_falling_platform_gs_boomerang",
+	"water_falling_platform_gs_hookshot",
+	"water_hookshot_entry",
+	"water_morpha",
+	"water_north_basement",
+	"water_north_basement_ledge_jump",
+	"water_river_gs",
+	"water_temple_torch_longshot",
+	"windmill_poh",
+	"zora_river_lower",
+	"zora_river_rupees",
+	"zora_river_upper",
+	"zora_with_cucco",
+	"zora_with_hovers",
+}
+
+// the tricks enabled when nothing else is chosen
+func DefaultTricks() Tricks {
+	return Tricks{
+		"visible_collisions":          true,
+		"grottos_without_agony":       true,
+		"fewer_tunic_requirements":    true,
+		"rusted_switches":             true,
+		"man_on_roof":                 true,
+		"windmill_poh":                true,
+		"crater_bean_poh_with_hovers": true,
+		"dc_jump":                     true,
+		"lens_botw":                   true,
+		"child_deadhand":              true,
+		"forest_vines":                true,
+		"lens_shadow":                 true,
+		"lens_shadow_platform":        true,
+		"lens_bongo":                  true,
+		"lens_spirit":                 true,
+		"lens_gtg":                    true,
+		"lens_castle":                 true,
+	}
+}
+
+// the mq_dungeons settings once chosen of the available dungeons use their
+// master quest logic. Count and random modes have already picked their
+// dungeons by then so they're reported as the dungeons they picked
+func MasterQuestNames(chosen, available int) map[string]any {
+	mode := "specific"
+	switch chosen {
+	case 0:
+		mode = "vanilla"
+	case available:
+		mode = "mq"
+	}
+	return map[string]any{
+		"mq_dungeons_mode":  mode,
+		"mq_dungeons_count": chosen,
+	}
+}
+
+// the master quest mode OOTR names mq_dungeons_mode's value
+func MasterQuestModeNamed(name string) (MasterQuestMode, error) {
+	switch name {
+	case "vanilla":
+		return MasterQuestVanilla, nil
+	case "mq":
+		return MasterQuestAll, nil
+	case "specific":
+		return MasterQuestSpecific, nil
+	case "count":
+		return MasterQuestCount, nil
+	case "random":
+		return MasterQuestRandom, nil
+	default:
+		return 0, fmt.Errorf("unknown mq_dungeons_mode %q", name)
+	}
+}
@@ -17,6 +17,7 @@ type LogicSettings struct {
 	StartingAge     StartingAge
 	ChildTradeQuest ChildTradeQuest
 	AdultTradeItems AdultTradeItems
+	MasterQuest     MasterQuestDungeons
 }
 
 type ShuffleSettings struct {
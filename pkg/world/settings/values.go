@@ -84,6 +84,25 @@ const (
 	StartingChild
 )
 
+type MasterQuestMode uint8
+
+const (
+	_ MasterQuestMode = iota
+	MasterQuestVanilla
+	MasterQuestAll
+	MasterQuestSpecific
+	MasterQuestCount
+	MasterQuestRandom
+)
+
+type MasterQuestDungeons struct {
+	Mode MasterQuestMode
+	// used by MasterQuestSpecific
+	Specific []string
+	// used by MasterQuestCount
+	Count uint8
+}
+
 type SongShuffle uint8
 
 const (
@@ -4,6 +4,7 @@ import (
 	"errors"
 	"fmt"
 	"sudonters/zootler/internal/entity"
+	"sudonters/zootler/internal/entity/componenttable"
 	"sudonters/zootler/pkg/world/components"
 
 	"github.com/etc-sudonters/substrate/skelly/graph"
@@ -13,8 +14,9 @@ var ErrEntityNotConnected = errors.New("entity is not connected to other entitie
 var ErrEntitiesNotConnected = errors.New("the entities are not connected")
 
 type World struct {
-	Entities WorldPool
-	Graph    graph.Directed
+	Entities   WorldPool
+	Graph      graph.Directed
+	Components *componenttable.Table
 }
 
 type WorldPool struct {
@@ -32,18 +34,16 @@ func (p WorldPool) Create(name components.Name) (entity.View, error) {
 }
 
 func (w World) Edge(e Edge) (entity.View, error) {
-	var conns Connections
-	w.Entities.Get(e.Origination, []interface{}{&conns})
-	if conns == nil {
+	if len(entity.Targets[Connection](w.Entities, e.Origination)) == 0 {
 		return nil, ErrEntityNotConnected
 	}
 
-	edgeId, ok := conns[e.Destination]
-	if !ok {
+	edgeId, err := entity.GetRelation[Connection](w.Entities, e.Origination, e.Destination)
+	if err != nil {
 		return nil, ErrEntitiesNotConnected
 	}
 
-	edge, err := w.Entities.Fetch(edgeId)
+	edge, err := w.Entities.Fetch(entity.Model(edgeId))
 	if err != nil {
 		return nil, err
 	}